@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -48,12 +50,74 @@ type DeleteClusterOptions struct {
 	wait        time.Duration
 	count       int
 	interval    time.Duration
+	// deleteInterval is the minimum time between issuing two deletes.
+	deleteInterval time.Duration
+	// maxConsecutiveFailures halts the deletion after that many failed deletes in a row.
+	maxConsecutiveFailures int
+
+	// AzureDeleteSnapshots deletes the Azure disk snapshots of the cluster too.
+	AzureDeleteSnapshots bool
+	// AzureResourceGroupMoveTarget is the Azure resource group that the resources
+	// not owned by the cluster are moved to, so that the cluster resource group
+	// can be deleted with a single call.
+	AzureResourceGroupMoveTarget string
+	// AzureResourceGroupDeleteMode is how the Azure resource group of the cluster
+	// is deleted: "auto", "children" or "wholeGroup".
+	AzureResourceGroupDeleteMode string
+	// AzureDeleteStorage deletes the Azure storage accounts and key vaults of the cluster too.
+	AzureDeleteStorage bool
+	// AzurePurgeKeyVault purges the deleted Azure key vaults of the cluster.
+	AzurePurgeKeyVault bool
+	// AzureDeleteBackups deletes the Azure Recovery Services vaults of the cluster too.
+	AzureDeleteBackups bool
+	// AzurePreserveResourceGroup keeps the Azure resource group of the cluster.
+	AzurePreserveResourceGroup bool
+	// AzureForceDelete clears the protection of the Azure VM Scale Set instances of the cluster.
+	AzureForceDelete bool
+	// AzureNodeResourceGroupName is an AKS-style node resource group holding
+	// resources of the Azure cluster too.
+	AzureNodeResourceGroupName string
+	// AzureNetworkResourceGroupNames are other resource groups holding the network of the cluster.
+	AzureNetworkResourceGroupNames []string
+	// AzureNetworkSubscriptionID is the subscription of the AzureNetworkResourceGroupNames.
+	AzureNetworkSubscriptionID string
+	// AzureTagBeforeDelete tags the Azure resources of the cluster before deleting them.
+	AzureTagBeforeDelete bool
+	// AzureStrictVerify checks that the Azure disks of the cluster are gone after deleting them.
+	AzureStrictVerify bool
+	// AzureLegacyTagKeys are the tag keys that older kops versions used for the Azure resources of the cluster.
+	AzureLegacyTagKeys []string
+	// AzureIncludeSystemResources deletes the resources that Azure creates on its own in the resource group of the cluster.
+	AzureIncludeSystemResources bool
+	// AzureListConcurrency is the maximum number of kinds of Azure resources listed at the same time.
+	AzureListConcurrency int
+	// AzureOmitRawObjects drops the Azure objects from the listed resources to save memory.
+	AzureOmitRawObjects bool
+	// AzureLogEquivalentCLI logs the az CLI command equivalent to each deletion.
+	AzureLogEquivalentCLI bool
+	// AzureCallTimeout is the time after which each call to Azure is given up.
+	AzureCallTimeout time.Duration
+	// AzureTeardownProfile is a preset of the tuning parameters of an Azure teardown:
+	// "fast", "safe" or "throttle-averse".
+	AzureTeardownProfile string
+	// AzureResourceTypes, if not empty, are the only types of Azure resources deleted.
+	AzureResourceTypes []string
+	// AzureExcludeResourceTypes are types of Azure resources not deleted.
+	AzureExcludeResourceTypes []string
+	// AzureContinueOnError gives up on the Azure resources that keep failing to be
+	// deleted, instead of on the whole deletion.
+	AzureContinueOnError bool
+	// AzureShuffleWithinPhase deletes the Azure resources ready to be deleted in a
+	// random order seeded with AzureShuffleSeed.
+	AzureShuffleWithinPhase bool
+	AzureShuffleSeed        int64
 }
 
 func (o *DeleteClusterOptions) InitDefaults() {
 	o.count = 0
 	o.interval = 10 * time.Second
 	o.wait = 10 * time.Minute
+	o.AzureResourceGroupDeleteMode = string(resources.AzureResourceGroupDeleteAuto)
 }
 
 var (
@@ -98,6 +162,33 @@ func NewCmdDeleteCluster(f *util.Factory, out io.Writer) *cobra.Command {
 	cmd.Flags().DurationVar(&options.wait, "wait", options.wait, "Amount of time to wait for the cluster resources to de deleted")
 	cmd.Flags().IntVar(&options.count, "count", options.count, "Number of consecutive failures to make progress deleting the cluster resources")
 	cmd.Flags().DurationVar(&options.interval, "interval", options.interval, "Time in duration to wait between deletion attempts")
+	cmd.Flags().IntVar(&options.maxConsecutiveFailures, "max-consecutive-failures", options.maxConsecutiveFailures, "Halt the deletion after this many resource deletions failed in a row, to investigate a degraded cloud provider (0 never halts)")
+	cmd.Flags().DurationVar(&options.deleteInterval, "delete-interval", options.deleteInterval, "Minimum time to wait between issuing two resource deletions, to avoid being throttled by the cloud provider")
+	cmd.Flags().BoolVar(&options.AzureDeleteSnapshots, "azure-delete-snapshots", options.AzureDeleteSnapshots, "Delete the disk snapshots of an Azure cluster, including the ones taken for VolumeSnapshots")
+	cmd.Flags().StringVar(&options.AzureResourceGroupMoveTarget, "azure-resource-group-move-target", options.AzureResourceGroupMoveTarget, "Delete the resource group of an Azure cluster at once, after moving the few resources in it not owned by the cluster to this resource group")
+	cmd.Flags().BoolVar(&options.AzureDeleteBackups, "azure-delete-backups", options.AzureDeleteBackups, "Delete the Recovery Services vaults of an Azure cluster, with the backups in them")
+	cmd.Flags().StringVar(&options.AzureResourceGroupDeleteMode, "azure-resource-group-delete-mode", options.AzureResourceGroupDeleteMode, "How to delete the resource group of an Azure cluster: auto, children (its resources one by one, then the group) or wholeGroup (the group at once)")
+	cmd.Flags().BoolVar(&options.AzurePreserveResourceGroup, "azure-preserve-resource-group", options.AzurePreserveResourceGroup, "Keep the resource group of an Azure cluster, deleting only the cluster resources in it")
+	cmd.Flags().BoolVar(&options.AzureDeleteStorage, "azure-delete-storage", options.AzureDeleteStorage, "Delete the storage accounts and key vaults of an Azure cluster, except the storage account of the state store")
+	cmd.Flags().BoolVar(&options.AzurePurgeKeyVault, "azure-purge-key-vaults", options.AzurePurgeKeyVault, "Purge the key vaults of an Azure cluster after deleting them, so that their names can be used again")
+	cmd.Flags().BoolVar(&options.AzureForceDelete, "azure-force-delete", options.AzureForceDelete, "Clear the scale-in and scale set actions protection of the VM Scale Set instances of an Azure cluster, so that they can be deleted")
+	cmd.Flags().BoolVar(&options.AzureTagBeforeDelete, "azure-tag-before-delete", options.AzureTagBeforeDelete, "Tag each resource of an Azure cluster with kops.k8s.io/deleting set to the current time right before deleting it")
+	cmd.Flags().BoolVar(&options.AzureStrictVerify, "azure-strict-verify", options.AzureStrictVerify, "Check that the disks of an Azure cluster are gone after deleting them, and retry until they are")
+	cmd.Flags().BoolVar(&options.AzureIncludeSystemResources, "azure-include-system-resources", options.AzureIncludeSystemResources, "Also delete the resources that Azure creates on its own in the resource group of an Azure cluster, like network watchers")
+	cmd.Flags().IntVar(&options.AzureListConcurrency, "azure-list-concurrency", options.AzureListConcurrency, "Maximum number of kinds of resources of an Azure cluster listed at the same time (0 means 8)")
+	cmd.Flags().BoolVar(&options.AzureOmitRawObjects, "azure-omit-raw-objects", options.AzureOmitRawObjects, "Drop the Azure objects of the listed resources of an Azure cluster once they are no longer needed, to save memory")
+	cmd.Flags().BoolVar(&options.AzureLogEquivalentCLI, "azure-log-equivalent-cli", options.AzureLogEquivalentCLI, "Log the az CLI command equivalent to each deletion of a resource of an Azure cluster")
+	cmd.Flags().DurationVar(&options.AzureCallTimeout, "azure-call-timeout", options.AzureCallTimeout, "Time after which each call to Azure is given up when deleting an Azure cluster (0 means 5m)")
+	cmd.Flags().StringVar(&options.AzureTeardownProfile, "azure-teardown-profile", options.AzureTeardownProfile, "Preset of the parameters of an Azure cluster deletion left unset: fast, safe or throttle-averse")
+	cmd.Flags().StringSliceVar(&options.AzureResourceTypes, "azure-resource-types", options.AzureResourceTypes, "Only delete the Azure resources of these types, like Disk or VirtualNetwork, keeping the resource group unless it is included")
+	cmd.Flags().StringSliceVar(&options.AzureExcludeResourceTypes, "azure-exclude-resource-types", options.AzureExcludeResourceTypes, "Do not delete the Azure resources of these types, like Disk, nor the resource group holding them")
+	cmd.Flags().StringSliceVar(&options.AzureLegacyTagKeys, "azure-legacy-tag-keys", options.AzureLegacyTagKeys, "Tag keys that older kops versions used in place of the cluster tag, to also delete the Azure resources tagged with them")
+	cmd.Flags().StringVar(&options.AzureNodeResourceGroupName, "azure-node-resource-group", options.AzureNodeResourceGroupName, "Also delete the resources of an Azure cluster in this AKS-style node resource group, but not the group itself")
+	cmd.Flags().StringSliceVar(&options.AzureNetworkResourceGroupNames, "azure-network-resource-groups", options.AzureNetworkResourceGroupNames, "Also delete the network resources of an Azure cluster in these resource groups, unless the network is shared")
+	cmd.Flags().BoolVar(&options.AzureContinueOnError, "azure-continue-on-error", options.AzureContinueOnError, "Give up on a resource of an Azure cluster that fails to be deleted --count times in a row (3 if --count is 0), and on the resources depending on it, but keep deleting the others")
+	cmd.Flags().BoolVar(&options.AzureShuffleWithinPhase, "azure-shuffle-within-phase", options.AzureShuffleWithinPhase, "Delete the resources of an Azure cluster that are ready to be deleted one at a time in a random order, to surface missing dependencies between them")
+	cmd.Flags().Int64Var(&options.AzureShuffleSeed, "azure-shuffle-seed", options.AzureShuffleSeed, "Seed of the random order of --azure-shuffle-within-phase, to reproduce a deletion")
+	cmd.Flags().StringVar(&options.AzureNetworkSubscriptionID, "azure-network-subscription", options.AzureNetworkSubscriptionID, "ID of the subscription holding the Azure network resource groups, if not the subscription of the cluster")
 
 	return cmd
 }
@@ -141,7 +232,35 @@ func RunDeleteCluster(ctx context.Context, f *util.Factory, out io.Writer, optio
 		}
 
 		klog.Info("Looking for cloud resources to delete")
-		allResources, err := resourceops.ListResources(cloud, cluster)
+		allResources, err := resourceops.ListResourcesWithContext(ctx, cloud, cluster, resourceops.ListOptions{
+			AzureDeleteSnapshots:           options.AzureDeleteSnapshots,
+			AzureResourceGroupMoveTarget:   options.AzureResourceGroupMoveTarget,
+			AzureResourceGroupDeleteMode:   resources.AzureResourceGroupDeleteMode(options.AzureResourceGroupDeleteMode),
+			AzureDeleteStorage:             options.AzureDeleteStorage,
+			AzurePurgeKeyVault:             options.AzurePurgeKeyVault,
+			AzureDeleteBackups:             options.AzureDeleteBackups,
+			AzurePreserveResourceGroup:     options.AzurePreserveResourceGroup,
+			AzureForceDelete:               options.AzureForceDelete,
+			AzureNodeResourceGroupName:     options.AzureNodeResourceGroupName,
+			AzureNetworkResourceGroupNames: options.AzureNetworkResourceGroupNames,
+			AzureNetworkSubscriptionID:     options.AzureNetworkSubscriptionID,
+			AzureTagBeforeDelete:           options.AzureTagBeforeDelete,
+			AzureStrictVerify:              options.AzureStrictVerify,
+			AzureLegacyTagKeys:             options.AzureLegacyTagKeys,
+			AzureIncludeSystemResources:    options.AzureIncludeSystemResources,
+			AzureListConcurrency:           options.AzureListConcurrency,
+			AzureOmitRawObjects:            options.AzureOmitRawObjects,
+			AzureLogEquivalentCLI:          options.AzureLogEquivalentCLI,
+			AzureCallTimeout:               options.AzureCallTimeout,
+			AzureTeardownProfile:           resources.AzureTeardownProfile(options.AzureTeardownProfile),
+			AzureContinueOnError:           options.AzureContinueOnError,
+			AzureShuffleWithinPhase:        options.AzureShuffleWithinPhase,
+			AzureShuffleSeed:               options.AzureShuffleSeed,
+			AzureResourceFilter: resources.AzureResourceFilter{
+				Include: options.AzureResourceTypes,
+				Exclude: options.AzureExcludeResourceTypes,
+			},
+		})
 		if err != nil {
 			return err
 		}
@@ -186,7 +305,40 @@ func RunDeleteCluster(ctx context.Context, f *util.Factory, out io.Writer, optio
 
 			fmt.Fprintf(out, "\n")
 
-			err = resourceops.DeleteResources(cloud, clusterResources, options.count, options.interval, options.wait)
+			statsPath := deletionStatsPath()
+			stats, err := resources.LoadDeletionStats(statsPath)
+			if err != nil {
+				klog.Warningf("ignoring deletion stats: %v", err)
+				stats = &resources.DeletionStats{}
+			}
+			deleteOptions := resourceops.DeleteOptions{
+				Count:                  options.count,
+				Interval:               options.interval,
+				Wait:                   options.wait,
+				DeleteInterval:         options.deleteInterval,
+				MaxConsecutiveFailures: options.maxConsecutiveFailures,
+				Stats:                  stats,
+				EstimateRemaining: func(remaining time.Duration) {
+					if remaining > 0 {
+						fmt.Fprintf(out, "Estimated time remaining: %s\n", remaining.Round(time.Second))
+					}
+				},
+			}
+			if cloud.ProviderID() == kopsapi.CloudProviderAzure {
+				deleteOptions, err = deleteOptions.WithAzureTeardownProfile(resources.AzureTeardownProfile(options.AzureTeardownProfile))
+				if err != nil {
+					return err
+				}
+				deleteOptions.ContinueOnError = options.AzureContinueOnError
+				deleteOptions.ShuffleWithinPhase = options.AzureShuffleWithinPhase
+				deleteOptions.ShuffleSeed = options.AzureShuffleSeed
+			}
+			err = resourceops.DeleteResourcesWithOptions(cloud, clusterResources, deleteOptions)
+			if statsPath != "" {
+				if err := stats.Save(statsPath); err != nil {
+					klog.Warningf("error saving deletion stats: %v", err)
+				}
+			}
 			if err != nil {
 				return err
 			}
@@ -227,3 +379,14 @@ func completeRegion(cmd *cobra.Command, args []string, toComplete string) ([]str
 	// TODO call into cloud provider(s) to get list of valid regions
 	return nil, cobra.ShellCompDirectiveNoFileComp
 }
+
+// deletionStatsPath returns the path where the durations of resource deletions
+// are kept between runs, to estimate the time remaining in later deletions.
+func deletionStatsPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		klog.V(2).Infof("not keeping deletion stats: %v", err)
+		return ""
+	}
+	return filepath.Join(dir, "kops", "deletion-stats.json")
+}
@@ -132,7 +132,7 @@ func RunToolboxDump(ctx context.Context, f commandutils.Factory, out io.Writer,
 		return err
 	}
 
-	resourceMap, err := resourceops.ListResources(cloud, cluster)
+	resourceMap, err := resourceops.ListResources(cloud, cluster, resourceops.ListOptions{})
 	if err != nil {
 		return err
 	}
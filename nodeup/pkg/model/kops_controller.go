@@ -17,8 +17,14 @@ limitations under the License.
 package model
 
 import (
+	"fmt"
+	"net"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/kops/pkg/wellknownusers"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/nodeup/nodetasks"
@@ -37,6 +43,9 @@ func (b *KopsControllerBuilder) Build(c *fi.NodeupModelBuilderContext) error {
 	if !b.IsMaster {
 		return nil
 	}
+	if enabled := b.NodeupConfig.KopsControllerEnabled; enabled != nil && !*enabled {
+		return nil
+	}
 
 	// Create the directory, even if we aren't going to populate it
 	pkiDir := "/etc/kubernetes/kops-controller"
@@ -46,7 +55,8 @@ func (b *KopsControllerBuilder) Build(c *fi.NodeupModelBuilderContext) error {
 		Mode: s("0755"),
 	})
 
-	// We run kops-controller under an unprivileged user (wellknownusers.KopsControllerID), and then grant specific permissions
+	// We run kops-controller under an unprivileged user (wellknownusers.KopsControllerID), and then grant specific permissions.
+	// useradd creates a group of the same name too, which owns the kops-controller files so that sidecars in it can read them.
 	c.AddTask(&nodetasks.UserTask{
 		Name:  wellknownusers.KopsControllerName,
 		UID:   wellknownusers.KopsControllerID,
@@ -60,10 +70,40 @@ func (b *KopsControllerBuilder) Build(c *fi.NodeupModelBuilderContext) error {
 		Type:           "server",
 		Subject:        nodetasks.PKIXName{CommonName: "kops-controller"},
 		AlternateNames: []string{"kops-controller.internal." + b.NodeupConfig.ClusterName},
+		ReusePath:      filepath.Join(pkiDir, "kops-controller"),
+	}
+	if config := b.NodeupConfig.KopsController; config != nil && config.CommonName != nil {
+		if strings.TrimSpace(*config.CommonName) == "" {
+			return fmt.Errorf("the common name of the kops-controller certificate must not be empty")
+		}
+		issueCert.Subject.CommonName = *config.CommonName
 	}
 	if len(b.BootConfig.APIServerIPs) > 0 {
 		issueCert.AlternateNames = append(issueCert.AlternateNames, b.BootConfig.APIServerIPs...)
 	}
+	if config := b.NodeupConfig.KopsController; config != nil && config.TrustDomain != "" {
+		issueCert.Subject.Organization = []string{config.TrustDomain}
+		issueCert.AlternateNames = append(issueCert.AlternateNames, "spiffe://"+config.TrustDomain+"/kops-controller")
+	}
+	if config := b.NodeupConfig.KopsController; config != nil && config.Generation > 0 {
+		issueCert.Subject.OrganizationalUnit = []string{fmt.Sprintf("generation-%d", config.Generation)}
+	}
+	if config := b.NodeupConfig.KopsController; config != nil {
+		issueCert.Algorithm = config.KeyAlgorithm
+	}
+	if config := b.NodeupConfig.KopsController; config != nil && config.CertificateValidity != nil {
+		issueCert.Validity = config.CertificateValidity.Duration
+	}
+	for _, san := range b.NodeupConfig.KopsControllerExtraSANs {
+		if net.ParseIP(san) == nil {
+			if errs := validation.IsDNS1123Subdomain(san); len(errs) > 0 {
+				return fmt.Errorf("invalid extra SAN %q of kops-controller: %s", san, strings.Join(errs, ", "))
+			}
+		}
+		if !slices.Contains(issueCert.AlternateNames, san) {
+			issueCert.AlternateNames = append(issueCert.AlternateNames, san)
+		}
+	}
 	c.AddTask(issueCert)
 
 	certResource, keyResource, _ := issueCert.GetResources()
@@ -73,6 +113,7 @@ func (b *KopsControllerBuilder) Build(c *fi.NodeupModelBuilderContext) error {
 		Type:     nodetasks.FileType_File,
 		Mode:     s("0644"),
 		Owner:    s(wellknownusers.KopsControllerName),
+		Group:    s(wellknownusers.KopsControllerName),
 	})
 	c.AddTask(&nodetasks.File{
 		Path:     filepath.Join(pkiDir, "kops-controller.key"),
@@ -80,12 +121,51 @@ func (b *KopsControllerBuilder) Build(c *fi.NodeupModelBuilderContext) error {
 		Type:     nodetasks.FileType_File,
 		Mode:     s("0600"),
 		Owner:    s(wellknownusers.KopsControllerName),
+		Group:    s(wellknownusers.KopsControllerName),
 	})
+	if config := b.NodeupConfig.KopsController; config != nil && config.WritePKCS12 {
+		c.AddTask(&nodetasks.File{
+			Path:     filepath.Join(pkiDir, "kops-controller.p12"),
+			Contents: issueCert.GetPKCS12Resource(config.PKCS12Passphrase),
+			Type:     nodetasks.FileType_File,
+			Mode:     s("0600"),
+			Owner:    s(wellknownusers.KopsControllerName),
+			Group:    s(wellknownusers.KopsControllerName),
+		})
+	}
+	if config := b.NodeupConfig.KopsController; config != nil && config.WriteSecretManifest {
+		namespace := config.SecretNamespace
+		if namespace == "" {
+			namespace = "kube-system"
+		}
+		name := config.SecretName
+		if name == "" {
+			name = "kops-controller-tls"
+		}
+		c.AddTask(&nodetasks.File{
+			Path:     filepath.Join(pkiDir, "kops-controller-secret.yaml"),
+			Contents: issueCert.GetSecretManifestResource(namespace, name),
+			Type:     nodetasks.FileType_File,
+			Mode:     s("0600"),
+			Owner:    s(wellknownusers.KopsControllerName),
+			Group:    s(wellknownusers.KopsControllerName),
+		})
+	}
 
 	caList := []string{fi.CertificateIDCA}
 	if b.NodeupConfig.UseCiliumEtcd {
 		caList = append(caList, "etcd-clients-ca-cilium")
 	}
+	if config := b.NodeupConfig.KopsController; config != nil {
+		for _, ca := range config.AdditionalTrustedCAs {
+			if _, ok := b.NodeupConfig.KeypairIDs[ca]; !ok {
+				return fmt.Errorf("unknown keypair %q in the additional trusted CAs of kops-controller", ca)
+			}
+			if !slices.Contains(caList, ca) {
+				caList = append(caList, ca)
+			}
+		}
+	}
 	for _, cert := range caList {
 		owner := wellknownusers.KopsControllerName
 		err := b.BuildCertificatePairTask(c, cert, pkiDir, cert, &owner, nil)
@@ -93,6 +173,19 @@ func (b *KopsControllerBuilder) Build(c *fi.NodeupModelBuilderContext) error {
 			return err
 		}
 	}
+	// The bundle is written even with a single CA, so that it can always be used.
+	bundle, err := b.buildCABundle(c, caList)
+	if err != nil {
+		return err
+	}
+	c.AddTask(&nodetasks.File{
+		Path:     filepath.Join(pkiDir, "ca-bundle.crt"),
+		Contents: fi.NewStringResource(bundle),
+		Type:     nodetasks.FileType_File,
+		Mode:     s("0644"),
+		Owner:    s(wellknownusers.KopsControllerName),
+		Group:    s(wellknownusers.KopsControllerName),
+	})
 
 	keypairIDs, err := yaml.Marshal(b.NodeupConfig.KeypairIDs)
 	if err != nil {
@@ -104,7 +197,50 @@ func (b *KopsControllerBuilder) Build(c *fi.NodeupModelBuilderContext) error {
 		Type:     nodetasks.FileType_File,
 		Mode:     s("0600"),
 		Owner:    s(wellknownusers.KopsControllerName),
+		Group:    s(wellknownusers.KopsControllerName),
+	})
+
+	// The pki-ready marker is written once all the other files are, so that kops-controller can wait for it.
+	var pkiFiles []string
+	for _, task := range c.Tasks {
+		if file, ok := task.(*nodetasks.File); ok && file.Type == nodetasks.FileType_File && filepath.Dir(file.Path) == pkiDir {
+			pkiFiles = append(pkiFiles, file.Path)
+		}
+	}
+	sort.Strings(pkiFiles)
+	c.AddTask(&nodetasks.File{
+		Path:       filepath.Join(pkiDir, "pki-ready"),
+		Contents:   fi.NewStringResource(""),
+		Type:       nodetasks.FileType_File,
+		Mode:       s("0644"),
+		Owner:      s(wellknownusers.KopsControllerName),
+		Group:      s(wellknownusers.KopsControllerName),
+		AfterFiles: pkiFiles,
 	})
 
 	return nil
 }
+
+// buildCABundle concatenates the certificates of the CAs, in order, into a single trust bundle.
+func (b *KopsControllerBuilder) buildCABundle(c *fi.NodeupModelBuilderContext, caList []string) (string, error) {
+	var bundle strings.Builder
+	for _, name := range caList {
+		keyset, err := b.KeyStore.FindKeyset(c.Context(), name)
+		if err != nil {
+			return "", err
+		}
+		if keyset == nil {
+			return "", fmt.Errorf("keyset %q not found", name)
+		}
+		item := keyset.Items[b.NodeupConfig.KeypairIDs[name]]
+		if item == nil || item.Certificate == nil {
+			return "", fmt.Errorf("certificate %q not found", name)
+		}
+		cert, err := item.Certificate.AsString()
+		if err != nil {
+			return "", err
+		}
+		bundle.WriteString(cert)
+	}
+	return bundle.String(), nil
+}
@@ -17,9 +17,28 @@ limitations under the License.
 package model
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kops/pkg/apis/kops"
+	"k8s.io/kops/pkg/apis/nodeup"
+	"k8s.io/kops/pkg/pki"
+	"k8s.io/kops/pkg/testutils"
+	"k8s.io/kops/pkg/wellknownusers"
 	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/nodeup/nodetasks"
+	"k8s.io/kops/util/pkg/distributions"
+	"sigs.k8s.io/yaml"
+	gopkcs12 "software.sslmate.com/src/go-pkcs12"
 )
 
 func TestKopsControllerBuilder(t *testing.T) {
@@ -28,3 +47,783 @@ func TestKopsControllerBuilder(t *testing.T) {
 		return builder.Build(target)
 	})
 }
+
+func TestKopsControllerBuilderPKCS12(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	nodeupModelContext.NodeupConfig.KopsController = &nodeup.KopsControllerConfig{
+		WritePKCS12:      true,
+		PKCS12Passphrase: "s3cr3t",
+	}
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	target := &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+
+	task, ok := target.Tasks["File//etc/kubernetes/kops-controller/kops-controller.p12"].(*nodetasks.File)
+	if !ok {
+		t.Fatalf("PKCS#12 file task not found")
+	}
+	if fi.ValueOf(task.Owner) != wellknownusers.KopsControllerName {
+		t.Errorf("unexpected owner %q", fi.ValueOf(task.Owner))
+	}
+	if fi.ValueOf(task.Mode) != "0600" {
+		t.Errorf("unexpected mode %q", fi.ValueOf(task.Mode))
+	}
+
+	issueCert := target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert)
+	nodeupContext, err := fi.NewNodeupContext(context.Background(), nil, keystore, nodeupModelContext.BootConfig, nodeupModelContext.NodeupConfig, target.Tasks)
+	if err != nil {
+		t.Fatalf("error building context: %v", err)
+	}
+	if err := issueCert.Run(nodeupContext); err != nil {
+		t.Fatalf("error issuing certificate: %v", err)
+	}
+
+	data, err := fi.ResourceAsBytes(task.Contents)
+	if err != nil {
+		t.Fatalf("error reading PKCS#12 bundle: %v", err)
+	}
+	_, cert, err := gopkcs12.Decode(data, "s3cr3t")
+	if err != nil {
+		t.Fatalf("error decoding PKCS#12 bundle: %v", err)
+	}
+	if cert.Subject.CommonName != "kops-controller" {
+		t.Errorf("unexpected certificate subject %q", cert.Subject.CommonName)
+	}
+}
+
+func TestKopsControllerBuilderTrustDomain(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	nodeupModelContext.NodeupConfig.KopsController = &nodeup.KopsControllerConfig{
+		TrustDomain: "tenant.example.org",
+	}
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	target := &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+
+	issueCert := target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert)
+	nodeupContext, err := fi.NewNodeupContext(context.Background(), nil, keystore, nodeupModelContext.BootConfig, nodeupModelContext.NodeupConfig, target.Tasks)
+	if err != nil {
+		t.Fatalf("error building context: %v", err)
+	}
+	if err := issueCert.Run(nodeupContext); err != nil {
+		t.Fatalf("error issuing certificate: %v", err)
+	}
+
+	task := target.Tasks["File//etc/kubernetes/kops-controller/kops-controller.crt"].(*nodetasks.File)
+	data, err := fi.ResourceAsBytes(task.Contents)
+	if err != nil {
+		t.Fatalf("error reading certificate: %v", err)
+	}
+	cert, err := pki.ParsePEMCertificate(data)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	var uris []string
+	for _, uri := range cert.Certificate.URIs {
+		uris = append(uris, uri.String())
+	}
+	if !reflect.DeepEqual(uris, []string{"spiffe://tenant.example.org/kops-controller"}) {
+		t.Errorf("unexpected URI alternate names %v", uris)
+	}
+	if !reflect.DeepEqual(cert.Subject.Organization, []string{"tenant.example.org"}) {
+		t.Errorf("unexpected certificate organization %v", cert.Subject.Organization)
+	}
+}
+
+func TestKopsControllerBuilderSecretManifest(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	nodeupModelContext.NodeupConfig.KopsController = &nodeup.KopsControllerConfig{
+		WriteSecretManifest: true,
+		SecretNamespace:     "kops-system",
+		SecretName:          "kops-controller-serving",
+	}
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	target := &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+
+	task, ok := target.Tasks["File//etc/kubernetes/kops-controller/kops-controller-secret.yaml"].(*nodetasks.File)
+	if !ok {
+		t.Fatalf("secret manifest file task not found")
+	}
+	if fi.ValueOf(task.Mode) != "0600" {
+		t.Errorf("unexpected mode %q", fi.ValueOf(task.Mode))
+	}
+
+	issueCert := target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert)
+	nodeupContext, err := fi.NewNodeupContext(context.Background(), nil, keystore, nodeupModelContext.BootConfig, nodeupModelContext.NodeupConfig, target.Tasks)
+	if err != nil {
+		t.Fatalf("error building context: %v", err)
+	}
+	if err := issueCert.Run(nodeupContext); err != nil {
+		t.Fatalf("error issuing certificate: %v", err)
+	}
+
+	data, err := fi.ResourceAsBytes(task.Contents)
+	if err != nil {
+		t.Fatalf("error reading secret manifest: %v", err)
+	}
+	secret := &corev1.Secret{}
+	if err := yaml.UnmarshalStrict(data, secret); err != nil {
+		t.Fatalf("error parsing secret manifest: %v", err)
+	}
+	if secret.Kind != "Secret" || secret.Namespace != "kops-system" || secret.Name != "kops-controller-serving" {
+		t.Errorf("unexpected secret %s %s/%s", secret.Kind, secret.Namespace, secret.Name)
+	}
+	if secret.Type != corev1.SecretTypeTLS {
+		t.Errorf("unexpected secret type %q", secret.Type)
+	}
+
+	for key, path := range map[string]string{
+		corev1.TLSCertKey:       "/etc/kubernetes/kops-controller/kops-controller.crt",
+		corev1.TLSPrivateKeyKey: "/etc/kubernetes/kops-controller/kops-controller.key",
+	} {
+		expected, err := fi.ResourceAsBytes(target.Tasks["File/"+path].(*nodetasks.File).Contents)
+		if err != nil {
+			t.Fatalf("error reading %s: %v", path, err)
+		}
+		if !bytes.Contains(data, []byte(base64.StdEncoding.EncodeToString(expected))) {
+			t.Errorf("expected the manifest to hold %s base64-encoded", path)
+		}
+		if !bytes.Equal(secret.Data[key], expected) {
+			t.Errorf("unexpected %s in secret", key)
+		}
+	}
+}
+
+func TestKopsControllerBuilderAdditionalTrustedCAs(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+		"webhook-ca":    simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	nodeupModelContext.NodeupConfig.KeypairIDs["webhook-ca"] = "3"
+	nodeupModelContext.NodeupConfig.KopsController = &nodeup.KopsControllerConfig{
+		AdditionalTrustedCAs: []string{"webhook-ca", "kubernetes-ca"},
+	}
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	target := &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+
+	for _, path := range []string{
+		"/etc/kubernetes/kops-controller/webhook-ca.crt",
+		"/etc/kubernetes/kops-controller/webhook-ca.key",
+	} {
+		if _, ok := target.Tasks["File/"+path].(*nodetasks.File); !ok {
+			t.Errorf("file task for %s not found", path)
+		}
+	}
+
+	nodeupModelContext.NodeupConfig.KopsController.AdditionalTrustedCAs = []string{"unknown-ca"}
+	target = &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	if err := builder.Build(target); err == nil {
+		t.Errorf("expected error for unknown CA")
+	}
+}
+
+func TestKopsControllerBuilderGeneration(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	nodeupModelContext.NodeupConfig.KopsController = &nodeup.KopsControllerConfig{}
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	build := func(generation int) *nodetasks.IssueCert {
+		nodeupModelContext.NodeupConfig.KopsController.Generation = generation
+		target := &fi.NodeupModelBuilderContext{
+			Tasks: make(map[string]fi.NodeupTask),
+		}
+		builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+		if err := builder.Build(target); err != nil {
+			t.Fatalf("error from Build: %v", err)
+		}
+		return target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert)
+	}
+
+	if ou := build(0).Subject.OrganizationalUnit; len(ou) != 0 {
+		t.Errorf("unexpected organizational unit %v without a generation", ou)
+	}
+	first, second := build(1), build(2)
+	if !reflect.DeepEqual(first.Subject.OrganizationalUnit, []string{"generation-1"}) {
+		t.Errorf("unexpected organizational unit %v", first.Subject.OrganizationalUnit)
+	}
+	if reflect.DeepEqual(first.Subject, second.Subject) {
+		t.Errorf("expected a new generation to change the certificate subject, so that it is issued again")
+	}
+}
+
+func TestKopsControllerBuilderECDSA(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	nodeupModelContext.NodeupConfig.KopsController = &nodeup.KopsControllerConfig{
+		KeyAlgorithm: nodetasks.KeyAlgorithmECDSA,
+	}
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	target := &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+
+	issueCert := target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert)
+	if issueCert.Algorithm != nodetasks.KeyAlgorithmECDSA {
+		t.Fatalf("unexpected key algorithm %q", issueCert.Algorithm)
+	}
+	nodeupContext, err := fi.NewNodeupContext(context.Background(), nil, keystore, nodeupModelContext.BootConfig, nodeupModelContext.NodeupConfig, target.Tasks)
+	if err != nil {
+		t.Fatalf("error building context: %v", err)
+	}
+	if err := issueCert.Run(nodeupContext); err != nil {
+		t.Fatalf("error issuing certificate: %v", err)
+	}
+
+	keyTask := target.Tasks["File//etc/kubernetes/kops-controller/kops-controller.key"].(*nodetasks.File)
+	data, err := fi.ResourceAsBytes(keyTask.Contents)
+	if err != nil {
+		t.Fatalf("error reading key: %v", err)
+	}
+	key, err := pki.ParsePEMPrivateKey(data)
+	if err != nil {
+		t.Fatalf("error parsing key: %v", err)
+	}
+	ecdsaKey, ok := key.Key.(*ecdsa.PrivateKey)
+	if !ok || ecdsaKey.Curve != elliptic.P256() {
+		t.Fatalf("expected a P-256 ECDSA key, but got %T", key.Key)
+	}
+
+	certTask := target.Tasks["File//etc/kubernetes/kops-controller/kops-controller.crt"].(*nodetasks.File)
+	if data, err = fi.ResourceAsBytes(certTask.Contents); err != nil {
+		t.Fatalf("error reading certificate: %v", err)
+	}
+	cert, err := pki.ParsePEMCertificate(data)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	if !ecdsaKey.PublicKey.Equal(cert.Certificate.PublicKey) {
+		t.Errorf("expected the certificate to be for the ECDSA key")
+	}
+}
+
+func TestKopsControllerBuilderExtraSANs(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	build := func(sans ...string) (*nodetasks.IssueCert, error) {
+		nodeupModelContext.NodeupConfig.KopsControllerExtraSANs = sans
+		target := &fi.NodeupModelBuilderContext{
+			Tasks: make(map[string]fi.NodeupTask),
+		}
+		builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+		if err := builder.Build(target); err != nil {
+			return nil, err
+		}
+		return target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert), nil
+	}
+
+	issueCert, err := build("kops-controller.example.com", "10.0.0.10", "kops-controller.example.com", "kops-controller.internal.minimal.example.com")
+	if err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+	expected := []string{"kops-controller.internal.minimal.example.com", "kops-controller.example.com", "10.0.0.10"}
+	if !reflect.DeepEqual(issueCert.AlternateNames, expected) {
+		t.Errorf("unexpected alternate names %v, expected %v", issueCert.AlternateNames, expected)
+	}
+
+	if _, err := build("not a name"); err == nil {
+		t.Errorf("expected an error for an invalid extra SAN")
+	}
+}
+
+func TestKopsControllerBuilderFileGroup(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	target := &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+
+	for name, mode := range map[string]string{
+		"kops-controller.crt": "0644",
+		"kops-controller.key": "0600",
+	} {
+		file, ok := target.Tasks["File//etc/kubernetes/kops-controller/"+name].(*nodetasks.File)
+		if !ok {
+			t.Fatalf("file task for %s not found", name)
+		}
+		if fi.ValueOf(file.Group) != wellknownusers.KopsControllerName {
+			t.Errorf("unexpected group %q of %s", fi.ValueOf(file.Group), name)
+		}
+		if fi.ValueOf(file.Mode) != mode {
+			t.Errorf("unexpected mode %q of %s, expected %q", fi.ValueOf(file.Mode), name, mode)
+		}
+	}
+}
+
+func TestKopsControllerBuilderCertificateValidity(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	nodeupModelContext.NodeupConfig.KopsController = &nodeup.KopsControllerConfig{}
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	target := &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+	if validity := target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert).Validity; validity != 0 {
+		t.Errorf("unexpected validity %v without a configured validity", validity)
+	}
+
+	nodeupModelContext.NodeupConfig.KopsController.CertificateValidity = &metav1.Duration{Duration: 24 * time.Hour}
+	target = &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+	if validity := target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert).Validity; validity != 24*time.Hour {
+		t.Errorf("unexpected validity %v, expected 24h", validity)
+	}
+}
+
+func TestKopsControllerBuilderCABundle(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca":          simplePrivateKeyset(dummyCertificate, dummyKey),
+		"etcd-clients-ca-cilium": simplePrivateKeyset(previousCertificate, previousKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	build := func() *fi.NodeupModelBuilderContext {
+		target := &fi.NodeupModelBuilderContext{
+			Tasks: make(map[string]fi.NodeupTask),
+		}
+		builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+		if err := builder.Build(target); err != nil {
+			t.Fatalf("error from Build: %v", err)
+		}
+		return target
+	}
+
+	caBundle := func() string {
+		task, ok := build().Tasks["File//etc/kubernetes/kops-controller/ca-bundle.crt"].(*nodetasks.File)
+		if !ok {
+			t.Fatalf("file task for the CA bundle not found")
+		}
+		if fi.ValueOf(task.Mode) != "0644" || fi.ValueOf(task.Owner) != wellknownusers.KopsControllerName {
+			t.Errorf("unexpected mode %q or owner %q of the CA bundle", fi.ValueOf(task.Mode), fi.ValueOf(task.Owner))
+		}
+		bundle, err := fi.ResourceAsString(task.Contents)
+		if err != nil {
+			t.Fatalf("error reading the CA bundle: %v", err)
+		}
+		return bundle
+	}
+
+	if bundle := caBundle(); bundle != dummyCertificate {
+		t.Errorf("unexpected CA bundle with a single CA %q, expected %q", bundle, dummyCertificate)
+	}
+
+	nodeupModelContext.NodeupConfig.UseCiliumEtcd = true
+	nodeupModelContext.NodeupConfig.KeypairIDs["etcd-clients-ca-cilium"] = "3"
+	if bundle, expected := caBundle(), dummyCertificate+previousCertificate; bundle != expected {
+		t.Errorf("unexpected CA bundle %q, expected %q", bundle, expected)
+	}
+}
+
+func TestKopsControllerBuilderEnabled(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+	if !nodeupModelContext.IsMaster {
+		t.Fatalf("expected a control-plane node")
+	}
+
+	for _, test := range []struct {
+		name     string
+		enabled  *bool
+		expected bool
+	}{
+		{name: "unset", enabled: nil, expected: true},
+		{name: "enabled", enabled: fi.PtrTo(true), expected: true},
+		{name: "disabled", enabled: fi.PtrTo(false), expected: false},
+	} {
+		nodeupModelContext.NodeupConfig.KopsControllerEnabled = test.enabled
+		target := &fi.NodeupModelBuilderContext{
+			Tasks: make(map[string]fi.NodeupTask),
+		}
+		builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+		if err := builder.Build(target); err != nil {
+			t.Fatalf("error from Build: %v", err)
+		}
+		_, hasUser := target.Tasks["UserTask/"+wellknownusers.KopsControllerName]
+		_, hasCert := target.Tasks["IssueCert/kops-controller"]
+		if hasUser != test.expected || hasCert != test.expected {
+			t.Errorf("%s: expected tasks %v, got user %v and certificate %v", test.name, test.expected, hasUser, hasCert)
+		}
+		if !test.expected && len(target.Tasks) != 0 {
+			t.Errorf("unexpected tasks %v when kops-controller is disabled", target.Tasks)
+		}
+	}
+}
+
+func TestKopsControllerBuilderPKIReady(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	target := &fi.NodeupModelBuilderContext{
+		Tasks: make(map[string]fi.NodeupTask),
+	}
+	builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+	if err := builder.Build(target); err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+
+	marker, ok := target.Tasks["File//etc/kubernetes/kops-controller/pki-ready"].(*nodetasks.File)
+	if !ok {
+		t.Fatalf("file task for the pki-ready marker not found")
+	}
+	deps := marker.GetDependencies(target.Tasks)
+	for _, path := range []string{
+		"/etc/kubernetes/kops-controller/kops-controller.crt",
+		"/etc/kubernetes/kops-controller/kops-controller.key",
+		"/etc/kubernetes/kops-controller/kubernetes-ca.crt",
+		"/etc/kubernetes/kops-controller/keypair-ids.yaml",
+	} {
+		if !slices.Contains(deps, target.Tasks["File/"+path]) {
+			t.Errorf("pki-ready marker is not ordered after %s", path)
+		}
+	}
+}
+
+func TestKopsControllerBuilderCommonName(t *testing.T) {
+	h := testutils.NewIntegrationTestHarness(t)
+	defer h.Close()
+
+	h.MockKopsVersion("1.18.0")
+	h.SetupMockAWS()
+
+	model, err := testutils.LoadModel("tests/golden/minimal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeupModelContext, err := BuildNodeupModelContext(model)
+	if err != nil {
+		t.Fatalf("error loading model: %v", err)
+	}
+	keystore := &fakeKeystore{T: t}
+	keystore.privateKeysets = map[string]*kops.Keyset{
+		"kubernetes-ca": simplePrivateKeyset(dummyCertificate, dummyKey),
+	}
+	nodeupModelContext.KeyStore = keystore
+	nodeupModelContext.Distribution = distributions.DistributionUbuntu2004
+	nodeupModelContext.NodeupConfig.KopsController = &nodeup.KopsControllerConfig{}
+	if err := nodeupModelContext.Init(); err != nil {
+		t.Fatalf("error from nodeupModelContext.Init(): %v", err)
+	}
+
+	build := func(commonName *string) (*nodetasks.IssueCert, error) {
+		nodeupModelContext.NodeupConfig.KopsController.CommonName = commonName
+		target := &fi.NodeupModelBuilderContext{
+			Tasks: make(map[string]fi.NodeupTask),
+		}
+		builder := KopsControllerBuilder{NodeupModelContext: nodeupModelContext}
+		if err := builder.Build(target); err != nil {
+			return nil, err
+		}
+		return target.Tasks["IssueCert/kops-controller"].(*nodetasks.IssueCert), nil
+	}
+
+	issueCert, err := build(nil)
+	if err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+	if issueCert.Subject.CommonName != "kops-controller" {
+		t.Errorf("unexpected default common name %q", issueCert.Subject.CommonName)
+	}
+	issueCert, err = build(fi.PtrTo("kops-controller.example.com"))
+	if err != nil {
+		t.Fatalf("error from Build: %v", err)
+	}
+	if issueCert.Subject.CommonName != "kops-controller.example.com" {
+		t.Errorf("unexpected common name %q", issueCert.Subject.CommonName)
+	}
+	if _, err := build(fi.PtrTo(" ")); err == nil {
+		t.Errorf("expected an error for an empty common name")
+	}
+}
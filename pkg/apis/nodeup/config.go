@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/kops/pkg/apis/kops"
 	"k8s.io/kops/pkg/apis/kops/model"
 	"k8s.io/kops/util/pkg/architectures"
@@ -95,6 +96,14 @@ type Config struct {
 	APIServerConfig *APIServerConfig `json:",omitempty"`
 	// ControlPlaneConfig is additional configuration for control-plane nodes.
 	ControlPlaneConfig *ControlPlaneConfig `json:",omitempty"`
+	// KopsController is additional configuration for the kops-controller keys on control-plane nodes.
+	KopsController *KopsControllerConfig `json:",omitempty"`
+	// KopsControllerEnabled, if set to false, skips the kops-controller user and keys on
+	// control-plane nodes, for clusters that do not run kops-controller.
+	KopsControllerEnabled *bool `json:",omitempty"`
+	// KopsControllerExtraSANs are additional DNS names or IP addresses of the kops-controller
+	// server certificate, e.g. for a load balancer in front of kops-controller.
+	KopsControllerExtraSANs []string `json:",omitempty"`
 	// GossipConfig is configuration for gossip DNS.
 	GossipConfig *kops.GossipConfig `json:",omitempty"`
 	// DNSZone is the DNS zone we should use when configuring DNS.
@@ -212,6 +221,40 @@ type ControlPlaneConfig struct {
 	KubeScheduler kops.KubeSchedulerConfig
 }
 
+// KopsControllerConfig is additional configuration for the kops-controller keys.
+type KopsControllerConfig struct {
+	// WritePKCS12 writes the kops-controller server certificate and key as a PKCS#12 bundle too.
+	WritePKCS12 bool `json:",omitempty"`
+	// PKCS12Passphrase is the passphrase protecting the PKCS#12 bundle. It may be empty.
+	PKCS12Passphrase string `json:",omitempty"`
+	// TrustDomain, if set, is the trust domain of the kops-controller server certificate. It is
+	// added to the certificate subject as the organization, and as the SPIFFE ID
+	// spiffe://<TrustDomain>/kops-controller to its alternate names.
+	TrustDomain string `json:",omitempty"`
+	// WriteSecretManifest writes the kops-controller server certificate and key as the
+	// manifest of a Kubernetes TLS Secret too, to be applied or picked up later.
+	WriteSecretManifest bool `json:",omitempty"`
+	// SecretNamespace is the namespace of the Secret. Defaults to kube-system.
+	SecretNamespace string `json:",omitempty"`
+	// SecretName is the name of the Secret. Defaults to kops-controller-tls.
+	SecretName string `json:",omitempty"`
+	// AdditionalTrustedCAs are the names of other keypairs, e.g. of custom webhooks,
+	// whose certificate and key are written for kops-controller alongside its CA.
+	AdditionalTrustedCAs []string `json:",omitempty"`
+	// Generation is part of the subject of the kops-controller certificate. Increasing
+	// it issues a new certificate and key on the next nodeup run, instead of reusing them.
+	Generation int `json:",omitempty"`
+	// KeyAlgorithm is the algorithm of the kops-controller server key: RSA, the
+	// default, or ECDSA for a P-256 key.
+	KeyAlgorithm string `json:",omitempty"`
+	// CertificateValidity is the lifetime of the kops-controller server certificate.
+	// If unset, the nodeup default is used.
+	CertificateValidity *metav1.Duration `json:",omitempty"`
+	// CommonName, if set, overrides the common name of the kops-controller server certificate,
+	// which is kops-controller by default.
+	CommonName *string `json:",omitempty"`
+}
+
 func NewConfig(cluster *kops.Cluster, instanceGroup *kops.InstanceGroup) (*Config, *BootConfig) {
 	role := instanceGroup.Spec.Role
 
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"math/big"
 	"net"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -42,7 +43,8 @@ type IssueCertRequest struct {
 	Type string
 	// Subject is the certificate subject.
 	Subject pkix.Name
-	// AlternateNames is a list of alternative names for this certificate.
+	// AlternateNames is a list of alternative names for this certificate. Each is an IP address,
+	// a URI with a scheme, such as a SPIFFE ID, or else a DNS name.
 	AlternateNames []string
 
 	// PublicKey is the public key for this certificate. If nil, it will be calculated from PrivateKey.
@@ -109,6 +111,12 @@ func IssueCert(ctx context.Context, request *IssueCertRequest, keystore Keystore
 		}
 		if ip := net.ParseIP(san); ip != nil {
 			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if IsURIAlternateName(san) {
+			uri, err := url.Parse(san)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("parsing alternate name %q: %w", san, err)
+			}
+			template.URIs = append(template.URIs, uri)
 		} else {
 			template.DNSNames = append(template.DNSNames, san)
 		}
@@ -157,3 +165,8 @@ func IssueCert(ctx context.Context, request *IssueCertRequest, keystore Keystore
 
 	return certificate, privateKey, caCertificate, err
 }
+
+// IsURIAlternateName returns true if the alternate name is a URI, rather than a DNS name.
+func IsURIAlternateName(san string) bool {
+	return strings.Contains(san, "://")
+}
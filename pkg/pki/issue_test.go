@@ -69,6 +69,7 @@ func TestIssueCert(t *testing.T) {
 		expectedSubject     pkix.Name
 		expectedDNSNames    []string
 		expectedIPAddresses []net.IP
+		expectedURIs        []string
 	}{
 		{
 			name: "ca",
@@ -139,6 +140,38 @@ func TestIssueCert(t *testing.T) {
 			expectedDNSNames:    []string{"*.internal.test.cluster.local", "localhost"},
 			expectedIPAddresses: []net.IP{net.ParseIP("127.0.0.1").To4()},
 		},
+		{
+			name: "serverWithURI",
+			req: IssueCertRequest{
+				Type: "server",
+				Subject: pkix.Name{
+					CommonName: "Test server",
+				},
+				AlternateNames: []string{"localhost", "spiffe://example.org/server"},
+				PrivateKey:     privateKey,
+			},
+			expectedKeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			expectedSubject:     pkix.Name{CommonName: "Test server"},
+			expectedDNSNames:    []string{"localhost"},
+			expectedURIs:        []string{"spiffe://example.org/server"},
+		},
+		{
+			name: "serverWithIPv6",
+			req: IssueCertRequest{
+				Type: "server",
+				Subject: pkix.Name{
+					CommonName: "Test server",
+				},
+				AlternateNames: []string{"kops-controller.internal.test.cluster.local", "10.0.0.1", "fd00:10:96::1", "::1"},
+				PrivateKey:     privateKey,
+			},
+			expectedKeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			expectedSubject:     pkix.Name{CommonName: "Test server"},
+			expectedDNSNames:    []string{"kops-controller.internal.test.cluster.local"},
+			expectedIPAddresses: []net.IP{net.ParseIP("10.0.0.1").To4(), net.ParseIP("fd00:10:96::1"), net.ParseIP("::1")},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := context.TODO()
@@ -191,6 +224,11 @@ func TestIssueCert(t *testing.T) {
 			// alternateNames
 			assert.Equal(t, tc.expectedDNSNames, cert.DNSNames, "DNSNames")
 			assert.Equal(t, tc.expectedIPAddresses, cert.IPAddresses, "IPAddresses")
+			var uris []string
+			for _, uri := range cert.URIs {
+				uris = append(uris, uri.String())
+			}
+			assert.Equal(t, tc.expectedURIs, uris, "URIs")
 			assert.Empty(t, cert.EmailAddresses, "EmailAddresses")
 
 			// privateKey
@@ -0,0 +1,40 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pki
+
+import (
+	"fmt"
+
+	gopkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// EncodePKCS12 returns a PKCS#12 bundle holding the certificate and its private key.
+// The bundle is encrypted with the password, which may be empty.
+func EncodePKCS12(certificate *Certificate, privateKey *PrivateKey, password string) ([]byte, error) {
+	if certificate == nil || certificate.Certificate == nil {
+		return nil, fmt.Errorf("certificate is required for a PKCS#12 bundle")
+	}
+	if privateKey == nil || privateKey.Key == nil {
+		return nil, fmt.Errorf("private key is required for a PKCS#12 bundle")
+	}
+
+	data, err := gopkcs12.Modern.Encode(privateKey.Key, certificate.Certificate, nil, password)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding PKCS#12 bundle: %v", err)
+	}
+	return data, nil
+}
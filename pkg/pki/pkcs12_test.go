@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pki
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gopkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+func TestEncodePKCS12(t *testing.T) {
+	certificate, privateKey, _, err := IssueCert(context.Background(), &IssueCertRequest{
+		Type:    "ca",
+		Subject: pkix.Name{CommonName: "Test CA"},
+	}, nil)
+	require.NoError(t, err)
+
+	for _, password := range []string{"", "s3cr3t"} {
+		t.Run(password, func(t *testing.T) {
+			data, err := EncodePKCS12(certificate, privateKey, password)
+			require.NoError(t, err)
+
+			key, cert, err := gopkcs12.Decode(data, password)
+			require.NoError(t, err)
+			assert.Equal(t, certificate.Certificate.Raw, cert.Raw)
+			assert.Equal(t, privateKey.Key, key)
+
+			_, _, err = gopkcs12.Decode(data, password+"wrong")
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestEncodePKCS12OpenSSL checks that the bundles can be read by OpenSSL,
+// which is what most consumers of PKCS#12 files are built on.
+func TestEncodePKCS12OpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found in PATH")
+	}
+
+	certificate, privateKey, _, err := IssueCert(context.Background(), &IssueCertRequest{
+		Type:    "ca",
+		Subject: pkix.Name{CommonName: "Test CA"},
+	}, nil)
+	require.NoError(t, err)
+
+	for _, password := range []string{"", "s3cr3t"} {
+		t.Run(password, func(t *testing.T) {
+			data, err := EncodePKCS12(certificate, privateKey, password)
+			require.NoError(t, err)
+			p12 := filepath.Join(t.TempDir(), "bundle.p12")
+			require.NoError(t, os.WriteFile(p12, data, 0o600))
+
+			out, err := exec.Command(openssl, "pkcs12", "-in", p12, "-passin", "pass:"+password, "-nodes").CombinedOutput()
+			require.NoError(t, err, string(out))
+
+			cert, err := ParsePEMCertificate(out)
+			require.NoError(t, err)
+			assert.Equal(t, certificate.Certificate.Raw, cert.Certificate.Raw)
+			key, err := ParsePEMPrivateKey(out)
+			require.NoError(t, err)
+			assert.Equal(t, privateKey.Key, key.Key)
+
+			out, err = exec.Command(openssl, "pkcs12", "-in", p12, "-passin", "pass:"+password+"wrong", "-nodes").CombinedOutput()
+			assert.Error(t, err, string(out))
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	crypto_rand "crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -72,6 +73,15 @@ func GeneratePrivateKey() (*PrivateKey, error) {
 	return privateKey, nil
 }
 
+// GenerateECDSAPrivateKey generates a P-256 ECDSA private key.
+func GenerateECDSAPrivateKey() (*PrivateKey, error) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), crypto_rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating ECDSA private key: %v", err)
+	}
+	return &PrivateKey{Key: ecdsaKey}, nil
+}
+
 type PrivateKey struct {
 	Key crypto.Signer
 }
@@ -18,12 +18,23 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	authz "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
 	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 	azureresources "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog/v2"
 	"k8s.io/kops/pkg/resources"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
@@ -34,84 +45,741 @@ const (
 	typeResourceGroup            = "ResourceGroup"
 	typeVirtualNetwork           = "VirtualNetwork"
 	typeNetworkSecurityGroup     = "NetworkSecurityGroup"
+	typeNetworkSecurityRule      = "NetworkSecurityRule"
 	typeApplicationSecurityGroup = "ApplicationSecurityGroup"
 	typeSubnet                   = "Subnet"
 	typeRouteTable               = "RouteTable"
+	typeRoute                    = "Route"
 	typeVMScaleSet               = "VMScaleSet"
+	typeVirtualMachine           = "VirtualMachine"
+	typeNetworkInterface         = "NetworkInterface"
 	typeDisk                     = "Disk"
+	typeDiskEncryptionSet        = "DiskEncryptionSet"
 	typeRoleAssignment           = "RoleAssignment"
 	typeLoadBalancer             = "LoadBalancer"
 	typePublicIPAddress          = "PublicIPAddress"
 	typeNatGateway               = "NatGateway"
+	typeSnapshot                 = "Snapshot"
+	typeStorageAccount           = "StorageAccount"
+	typePrivateEndpoint          = "PrivateEndpoint"
+	typeKeyVault                 = "KeyVault"
+	typeRecoveryServicesVault    = "RecoveryServicesVault"
+	typeNetworkWatcher           = "NetworkWatcher"
+	typeManagedIdentity          = "ManagedIdentity"
+	typePrivateDNSZone           = "PrivateDNSZone"
+	typePrivateDNSRecordSet      = "PrivateDNSRecordSet"
+	typePrivateDNSZoneLink       = "PrivateDNSZoneLink"
+	typeApplicationGateway       = "ApplicationGateway"
+)
+
+const (
+	// metadataAssociation is the metadata key of the type of the resource that a
+	// public IP address is attached to.
+	metadataAssociation = "association"
+	// metadataSKU is the metadata key of the SKU name of a VM Scale Set, disk,
+	// public IP address or load balancer, e.g. Gateway for a gateway load balancer.
+	metadataSKU = "sku"
+	// metadataTier is the metadata key of the SKU or performance tier of a VM
+	// Scale Set, disk or public IP address.
+	metadataTier = "tier"
+	// metadataCapacity is the metadata key of the number of VMs of a VM Scale Set.
+	metadataCapacity = "capacity"
+	// metadataSizeGB is the metadata key of the size of a disk, in GB.
+	metadataSizeGB = "sizeGB"
+	// metadataScope is the metadata key of the granularity of the scope of a
+	// role assignment.
+	metadataScope = "scope"
+	// metadataUsedBy is the metadata key of the other clusters using a resource
+	// tagged for the cluster, which is kept for them.
+	metadataUsedBy = "usedBy"
+)
+
+// tagDeleting is the tag set to the time of the deletion of a resource, right
+// before deleting it, when AzureTagBeforeDelete is set.
+const tagDeleting = "kops.k8s.io/deleting"
+
+// defaultCallTimeout is the time after which a call to Azure is given up, unless
+// AzureCallTimeout is set.
+const defaultCallTimeout = 5 * time.Minute
+
+// defaultListConcurrency is the number of listers run at the same time, unless
+// AzureListConcurrency is set.
+const defaultListConcurrency = 8
+
+const (
+	// clusterTagValueOwned is the value of the ownership tag of the cluster on the
+	// resources owned by the cluster.
+	clusterTagValueOwned = "owned"
+	// clusterTagValueShared is the value of the ownership tag of the cluster on the
+	// resources shared with the cluster. They are listed, but never deleted.
+	clusterTagValueShared = "shared"
+)
+
+const (
+	// keyVaultResourceType is the ARM type of key vaults.
+	keyVaultResourceType = "Microsoft.KeyVault/vaults"
+	// keyVaultAPIVersion is the API version used to delete key vaults.
+	keyVaultAPIVersion = "2022-07-01"
+	// recoveryServicesVaultResourceType is the ARM type of Recovery Services vaults.
+	recoveryServicesVaultResourceType = "Microsoft.RecoveryServices/vaults"
+	// recoveryServicesVaultAPIVersion is the API version used to delete Recovery Services vaults.
+	recoveryServicesVaultAPIVersion = "2023-04-01"
+	// networkWatcherResourceType is the ARM type of network watchers.
+	networkWatcherResourceType = "Microsoft.Network/networkWatchers"
+	// networkWatcherAPIVersion is the API version used to delete network watchers.
+	networkWatcherAPIVersion = "2023-09-01"
 )
 
 // ListResourcesAzure lists all resources for the cluster by quering Azure.
+// Listing only makes read calls, so a principal with the built-in Reader role
+// on the subscription is enough to preview what would be deleted.
+//
+// If some kinds of resources cannot be listed, the resources of the other kinds
+// are still returned, along with the errors of all the kinds that failed.
 func ListResourcesAzure(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) (map[string]*resources.Resource, error) {
+	return ListResourcesAzureWithContext(context.Background(), cloud, clusterInfo)
+}
+
+// ListResourcesAzureWithContext is ListResourcesAzure with a context, which the
+// deleters of the resources use too. Each call to Azure is also given up after
+// the AzureCallTimeout of the cluster info. The progress of the listing is
+// logged at verbosity 2 with the logger of the context.
+func ListResourcesAzureWithContext(ctx context.Context, cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) (map[string]*resources.Resource, error) {
 	g := resourceGetter{
+		ctx:         ctx,
 		cloud:       cloud,
 		clusterInfo: clusterInfo,
 	}
 	return g.listResourcesAzure()
 }
 
+// ListResourcesAzureWithNetworkCloud is ListResourcesAzureWithContext for a cluster
+// whose network resource groups are in the AzureNetworkSubscriptionID of the
+// cluster info. The resources in them are listed and deleted with networkCloud,
+// which must be scoped to that subscription. The other resources are listed with
+// cloud.
+func ListResourcesAzureWithNetworkCloud(ctx context.Context, cloud, networkCloud azure.AzureCloud, clusterInfo resources.ClusterInfo) (map[string]*resources.Resource, error) {
+	g := resourceGetter{
+		ctx:          ctx,
+		cloud:        cloud,
+		networkCloud: networkCloud,
+		clusterInfo:  clusterInfo,
+	}
+	return g.listResourcesAzure()
+}
+
+// ListResourcesAzureSorted lists all resources for the cluster like ListResourcesAzure,
+// but returns them as a slice sorted by type and then by name so that the output is stable.
+func ListResourcesAzureSorted(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) ([]*resources.Resource, error) {
+	resourceMap, err := ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		return nil, err
+	}
+	return sortResources(resourceMap), nil
+}
+
+// ListResourcesAzureMultiSub lists all resources for a cluster whose resources are
+// spread across several subscriptions, given a cloud for each subscription. Each
+// subscription is listed like ListResourcesAzure. The keys of the returned map, and
+// the dependencies between the resources, are qualified with the subscription ID.
+func ListResourcesAzureMultiSub(clouds []azure.AzureCloud, clusterInfo resources.ClusterInfo) (map[string]*resources.Resource, error) {
+	resourceMap := make(map[string]*resources.Resource)
+	subscriptions := set.New[string]()
+	for _, cloud := range clouds {
+		subscriptionID := cloud.SubscriptionID()
+		if subscriptions.Has(subscriptionID) {
+			return nil, fmt.Errorf("subscription %q is listed more than once", subscriptionID)
+		}
+		subscriptions.Insert(subscriptionID)
+
+		rs, err := ListResourcesAzure(cloud, clusterInfo)
+		if err != nil {
+			return nil, fmt.Errorf("listing resources in subscription %q: %w", subscriptionID, err)
+		}
+		for k, r := range rs {
+			r.Blocks = toSubscriptionKeys(subscriptionID, r.Blocks)
+			r.Blocked = toSubscriptionKeys(subscriptionID, r.Blocked)
+			resourceMap[toSubscriptionKey(subscriptionID, k)] = r
+		}
+	}
+	return resourceMap, nil
+}
+
+// toSubscriptionKey qualifies a resource key with a subscription ID.
+func toSubscriptionKey(subscriptionID, key string) string {
+	return subscriptionID + "/" + key
+}
+
+func toSubscriptionKeys(subscriptionID string, keys []string) []string {
+	if keys == nil {
+		return nil
+	}
+	qualified := make([]string, 0, len(keys))
+	for _, k := range keys {
+		qualified = append(qualified, toSubscriptionKey(subscriptionID, k))
+	}
+	return qualified
+}
+
+// sortResources returns the resources in the map sorted by type, name and ID.
+func sortResources(resourceMap map[string]*resources.Resource) []*resources.Resource {
+	rs := make([]*resources.Resource, 0, len(resourceMap))
+	for _, r := range resourceMap {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if rs[i].Type != rs[j].Type {
+			return rs[i].Type < rs[j].Type
+		}
+		if rs[i].Name != rs[j].Name {
+			return rs[i].Name < rs[j].Name
+		}
+		return rs[i].ID < rs[j].ID
+	})
+	return rs
+}
+
 type resourceGetter struct {
+	// ctx is the context of the listing. The deleters, which take no context,
+	// use it too.
+	ctx         context.Context
 	cloud       azure.AzureCloud
 	clusterInfo resources.ClusterInfo
+	// networkCloud, if set, is the cloud of the subscription holding the network
+	// resource groups of the cluster.
+	networkCloud azure.AzureCloud
+
+	// armIDs holds the ARM IDs of the Azure objects backing the resources.
+	armIDs map[*resources.Resource]string
+
+	// vmScaleSets caches the VM Scale Sets of the resource group, which several
+	// listers correlate their resources with.
+	vmScaleSets       []*compute.VirtualMachineScaleSet
+	vmScaleSetsListed bool
+	// vms caches the standalone virtual machines of the resource group, whose
+	// disks are listed with the other disks.
+	vms       []*compute.VirtualMachine
+	vmsListed bool
+
+	// mutex guards armIDs and the caches while the listers run
+	// concurrently. It is nil while they run one at a time.
+	mutex *sync.Mutex
+}
+
+// context returns the context of the getter, or the background context if it
+// has none.
+func (g *resourceGetter) context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+	return g.ctx
+}
+
+// callTimeout returns the time after which a call to Azure is given up.
+func (g *resourceGetter) callTimeout() time.Duration {
+	if g.clusterInfo.AzureCallTimeout > 0 {
+		return g.clusterInfo.AzureCallTimeout
+	}
+	return defaultCallTimeout
+}
+
+// applyTeardownProfile sets the tuning parameters left at zero in the cluster
+// info to the values of its teardown profile.
+func (g *resourceGetter) applyTeardownProfile() error {
+	settings, err := g.clusterInfo.AzureTeardownProfile.Settings()
+	if err != nil {
+		return err
+	}
+	if g.clusterInfo.AzureListConcurrency == 0 {
+		g.clusterInfo.AzureListConcurrency = settings.ListConcurrency
+	}
+	if g.clusterInfo.AzureCallTimeout == 0 {
+		g.clusterInfo.AzureCallTimeout = settings.CallTimeout
+	}
+	return nil
+}
+
+// lock locks the mutex of the getter, if any, and returns the function unlocking it.
+func (g *resourceGetter) lock() func() {
+	if g.mutex == nil {
+		return func() {}
+	}
+	g.mutex.Lock()
+	return g.mutex.Unlock
+}
+
+// listVMScaleSets lists the VM Scale Sets of the resource group, once.
+func (g *resourceGetter) listVMScaleSets(ctx context.Context) ([]*compute.VirtualMachineScaleSet, error) {
+	defer g.lock()()
+	if g.vmScaleSetsListed {
+		return g.vmScaleSets, nil
+	}
+	vmsses, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*compute.VirtualMachineScaleSet, error) {
+		return g.cloud.VMScaleSet().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.vmScaleSets = vmsses
+	g.vmScaleSetsListed = true
+	return vmsses, nil
+}
+
+// listVMs lists the standalone virtual machines of the resource group, once.
+func (g *resourceGetter) listVMs(ctx context.Context) ([]*compute.VirtualMachine, error) {
+	defer g.lock()()
+	if g.vmsListed {
+		return g.vms, nil
+	}
+	vms, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*compute.VirtualMachine, error) {
+		return g.cloud.VirtualMachine().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.vms = vms
+	g.vmsListed = true
+	return vms, nil
+}
+
+// withARMID records the ARM ID of the Azure object backing the resource.
+func (g *resourceGetter) withARMID(r *resources.Resource, id *string) *resources.Resource {
+	if id == nil || *id == "" {
+		return r
+	}
+	defer g.lock()()
+	if g.armIDs == nil {
+		g.armIDs = make(map[*resources.Resource]string)
+	}
+	g.armIDs[r] = *id
+	return r
+}
+
+// armID returns the ARM ID of the Azure object backing the resource, or an
+// empty string if it is not known.
+func (g *resourceGetter) armID(r *resources.Resource) string {
+	defer g.lock()()
+	return g.armIDs[r]
 }
 
 func (g *resourceGetter) resourceGroupName() string {
 	return g.clusterInfo.AzureResourceGroupName
 }
 
+// isNodeResourceGroup returns true if the resource group is the AKS node
+// resource group of the cluster.
+func (g *resourceGetter) isNodeResourceGroup(name string) bool {
+	return g.clusterInfo.AzureNodeResourceGroupName != "" && strings.EqualFold(name, g.clusterInfo.AzureNodeResourceGroupName)
+}
+
+// resourceGroupOf returns the resource group of the Azure object backing the
+// resource, as parsed from its ARM ID, or the cluster resource group if the ARM
+// ID is not known.
+func (g *resourceGetter) resourceGroupOf(r *resources.Resource) string {
+	// The ID has the form /subscriptions/<sub>/resourceGroups/<rg>/providers/...
+	l := strings.Split(g.armID(r), "/")
+	for i := 0; i+1 < len(l); i++ {
+		if strings.EqualFold(l[i], "resourceGroups") && l[i+1] != "" {
+			return l[i+1]
+		}
+	}
+	return g.resourceGroupName()
+}
+
 func (g *resourceGetter) listResourcesAzure() (map[string]*resources.Resource, error) {
+	if err := g.applyTeardownProfile(); err != nil {
+		return nil, err
+	}
+	if err := g.validateResourceFilter(); err != nil {
+		return nil, err
+	}
+	if err := g.validateNetworkSubscription(); err != nil {
+		return nil, err
+	}
+	// The listers that fail do not prevent the others from listing their
+	// resources. The resources found are returned along with the errors.
+	var listErrs []error
 	rs, err := g.listAll()
 	if err != nil {
+		listErrs = append(listErrs, err)
+	}
+	if nodeRG := g.clusterInfo.AzureNodeResourceGroupName; nodeRG != "" && !strings.EqualFold(nodeRG, g.resourceGroupName()) {
+		nodeRS, err := g.listNodeResourceGroup(nodeRG)
+		if err != nil {
+			listErrs = append(listErrs, fmt.Errorf("listing node resource group %q: %w", nodeRG, err))
+		}
+		rs = append(rs, nodeRS...)
+	}
+	for _, networkRG := range g.clusterInfo.AzureNetworkResourceGroupNames {
+		// A resource group of the same name in another subscription is another
+		// resource group.
+		if g.networkCloud == nil && strings.EqualFold(networkRG, g.resourceGroupName()) {
+			continue
+		}
+		networkRS, err := g.listNetworkResourceGroup(networkRG)
+		if err != nil {
+			listErrs = append(listErrs, fmt.Errorf("listing network resource group %q: %w", networkRG, err))
+		}
+		rs = append(rs, networkRS...)
+	}
+
+	resourceMap, err := g.toResourceMap(g.filterResources(rs))
+	if err != nil {
+		return nil, err
+	}
+	linkPrivateEndpoints(resourceMap)
+	if logger := klog.FromContext(g.context()).V(2); logger.Enabled() {
+		counts := make(map[string]int)
+		for _, r := range resourceMap {
+			counts[r.Type]++
+		}
+		logger.Info("Listed the Azure resources of the cluster", "resourceGroup", g.resourceGroupName(), "total", len(resourceMap), "counts", counts)
+	}
+	if err := g.applyResourceGroupDeletePlan(g.context(), resourceMap); err != nil {
+		return nil, err
+	}
+	// A cycle, from a bug in the listers, would only show when deleting.
+	if err := resources.ValidateGraph(resourceMap); err != nil {
 		return nil, err
 	}
+	if g.clusterInfo.AzureTagBeforeDelete {
+		g.tagBeforeDelete(resourceMap)
+	}
+	if g.clusterInfo.AzureStrictVerify {
+		g.verifyAfterDelete(resourceMap)
+	}
+	if g.clusterInfo.AzureLogEquivalentCLI {
+		g.logEquivalentCLI(resourceMap)
+	}
+	if g.clusterInfo.AzureOmitRawObjects {
+		for _, r := range resourceMap {
+			r.Obj = nil
+		}
+	}
+	return resourceMap, errors.Join(listErrs...)
+}
+
+// tagBeforeDelete makes the deleters of the taggable resources tag them with
+// the time of their deletion first.
+func (g *resourceGetter) tagBeforeDelete(resourceMap map[string]*resources.Resource) {
+	for _, r := range resourceMap {
+		if _, ok := armResourceTypes[r.Type]; !ok && r.Type != typeResourceGroup {
+			continue
+		}
+		id := g.armID(r)
+		if r.Shared || r.Done || r.Deleter == nil || id == "" {
+			continue
+		}
+		deleter := r.Deleter
+		r.Deleter = func(cloud fi.Cloud, r *resources.Resource) error {
+			tags := map[string]*string{
+				tagDeleting: fi.PtrTo(time.Now().UTC().Format(time.RFC3339)),
+			}
+			if err := retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+				return g.cloud.Resource().UpdateTags(ctx, id, tags)
+			}); err != nil {
+				return fmt.Errorf("tagging %s %q before deletion: %w", r.Type, r.Name, err)
+			}
+			return deleter(cloud, r)
+		}
+	}
+}
 
-	// Convert a slice of resources to a map of resources keyed by type and ID.
-	resources := make(map[string]*resources.Resource)
+// toResourceMap converts a slice of resources to a map of resources keyed by
+// type and ID. Resources of the same type and name in different resource
+// groups would collide, so they are keyed by their ARM IDs instead, and the
+// dependencies on the ambiguous key are kept on all of them. It is an error if
+// colliding resources cannot be told apart by their ARM IDs.
+func (g *resourceGetter) toResourceMap(rs []*resources.Resource) (map[string]*resources.Resource, error) {
+	byKey := make(map[string][]*resources.Resource)
+	var keys []string
 	for _, r := range rs {
 		if r.Done {
 			continue
 		}
-		resources[toKey(r.Type, r.ID)] = r
+		k := toKey(r.Type, r.ID)
+		if _, found := byKey[k]; !found {
+			keys = append(keys, k)
+		}
+		byKey[k] = append(byKey[k], r)
+	}
+
+	resourceMap := make(map[string]*resources.Resource)
+	renamed := make(map[string][]string)
+	for _, k := range keys {
+		dups := byKey[k]
+		if len(dups) == 1 || g.sameARMID(dups) {
+			resourceMap[k] = dups[len(dups)-1]
+			continue
+		}
+		if !g.distinctARMIDs(dups) {
+			return nil, fmt.Errorf("found %d resources with key %q that cannot be told apart by their ARM IDs", len(dups), k)
+		}
+		klog.Warningf("Found %d resources with key %q in different resource groups", len(dups), k)
+		for _, r := range dups {
+			r.ID = g.armID(r)
+			newKey := toKey(r.Type, r.ID)
+			resourceMap[newKey] = r
+			renamed[k] = append(renamed[k], newKey)
+		}
+	}
+	if len(renamed) == 0 {
+		return resourceMap, nil
+	}
+
+	rewrite := func(keys []string) []string {
+		var l []string
+		for _, k := range keys {
+			if newKeys, ok := renamed[k]; ok {
+				l = append(l, newKeys...)
+			} else {
+				l = append(l, k)
+			}
+		}
+		return l
+	}
+	for _, r := range resourceMap {
+		r.Blocks = rewrite(r.Blocks)
+		r.Blocked = rewrite(r.Blocked)
 	}
-	return resources, nil
+	return resourceMap, nil
 }
 
-// listAll list all resources owned by kops for the cluster.
-//
-// TODO(kenji): Set the "Shared" field of each resource so that we won't delete
-// shared resources.
+// sameARMID returns true if all the resources are the same Azure object, listed
+// more than once.
+func (g *resourceGetter) sameARMID(rs []*resources.Resource) bool {
+	id := g.armID(rs[0])
+	if id == "" {
+		return false
+	}
+	for _, r := range rs[1:] {
+		if !strings.EqualFold(g.armID(r), id) {
+			return false
+		}
+	}
+	return true
+}
+
+// distinctARMIDs returns true if all the resources have known and different ARM IDs.
+func (g *resourceGetter) distinctARMIDs(rs []*resources.Resource) bool {
+	ids := set.New[string]()
+	for _, r := range rs {
+		id := g.armID(r)
+		if id == "" || ids.Has(id) {
+			return false
+		}
+		ids.Insert(id)
+	}
+	return true
+}
+
+// listAll list all resources owned by kops for the cluster. The resources
+// tagged as shared with the cluster are listed as Shared, and are never deleted.
+// The resources are sorted by key, so that they are listed the same way whatever
+// the order ARM returns them in. The order in which they are deleted is given by
+// their Blocks and Blocked, not by their order in the slice.
 func (g *resourceGetter) listAll() ([]*resources.Resource, error) {
-	fns := []func(ctx context.Context) ([]*resources.Resource, error){
-		g.listResourceGroups,
-		g.listVirtualNetworksAndSubnets,
-		g.listNetworkSecurityGroups,
-		g.listApplicationSecurityGroups,
-		g.listRouteTables,
-		g.listVMScaleSetsAndRoleAssignments,
-		g.listDisks,
-		g.listLoadBalancers,
-		g.listPublicIPAddresses,
-		g.listNatGateways,
+	listers := append([]typedLister{
+		{[]string{typeResourceGroup}, g.listResourceGroups},
+	}, g.resourceGroupListers()...)
+	fns := g.allowedListers(listers)
+	fns = append(fns, g.registeredCleanups()...)
+	rs, err := g.runListers(fns)
+	sort.SliceStable(rs, func(i, j int) bool {
+		return toKey(rs[i].Type, rs[i].ID) < toKey(rs[j].Type, rs[j].ID)
+	})
+	return rs, err
+}
+
+// listNodeResourceGroup lists the resources of the cluster in a node resource
+// group, like the MC_ resource group of an AKS cluster. They are listed like the
+// resources in the cluster resource group, except that the node resource group
+// itself is never deleted, as it is managed by AKS.
+func (g *resourceGetter) listNodeResourceGroup(nodeRG string) ([]*resources.Resource, error) {
+	ng := g.forResourceGroup(nodeRG)
+	return ng.runListers(ng.allowedListers(ng.resourceGroupListers()))
+}
+
+// listNetworkResourceGroup lists the virtual networks, subnets, security groups
+// and route tables of the cluster in a resource group separate from the cluster
+// resource group. They are all listed as shared if the network is shared.
+func (g *resourceGetter) listNetworkResourceGroup(networkRG string) ([]*resources.Resource, error) {
+	ng := g.forResourceGroup(networkRG)
+	if g.networkCloud != nil {
+		ng.cloud = g.networkCloud
+	}
+	rs, err := ng.runListers(ng.allowedListers([]typedLister{
+		{[]string{typeVirtualNetwork, typeSubnet}, ng.listVirtualNetworksAndSubnets},
+		{[]string{typeNetworkSecurityGroup, typeNetworkSecurityRule}, ng.listNetworkSecurityGroups},
+		{[]string{typeApplicationSecurityGroup}, ng.listApplicationSecurityGroups},
+		{[]string{typeRouteTable, typeRoute}, ng.listRouteTables},
+	}))
+	if g.clusterInfo.AzureNetworkShared {
+		for _, r := range rs {
+			if r.Shared || r.Type == typeNetworkSecurityRule {
+				continue
+			}
+			r.Shared = true
+			// The rules kops created on a security group that is kept are
+			// removed on their own.
+			if nsg, ok := r.Obj.(*network.SecurityGroup); ok {
+				rules, err := ng.toNetworkSecurityRuleResources(nsg)
+				if err != nil {
+					return nil, err
+				}
+				rs = append(rs, rules...)
+			}
+		}
+	}
+	return rs, err
+}
+
+// validateNetworkSubscription checks that the network resource groups in
+// another subscription can be listed.
+func (g *resourceGetter) validateNetworkSubscription() error {
+	id := g.clusterInfo.AzureNetworkSubscriptionID
+	if id == "" || id == g.cloud.SubscriptionID() {
+		return nil
 	}
+	if len(g.clusterInfo.AzureNetworkResourceGroupNames) == 0 {
+		return fmt.Errorf("no Azure network resource groups are given for network subscription %q", id)
+	}
+	if g.networkCloud == nil || g.networkCloud.SubscriptionID() != id {
+		return fmt.Errorf("listing the Azure network resource groups of subscription %q needs a cloud scoped to that subscription", id)
+	}
+	return nil
+}
+
+// forResourceGroup returns a getter listing the resources of the cluster in
+// another resource group. The resource group itself is listed, if at all, by g.
+func (g *resourceGetter) forResourceGroup(rgName string) *resourceGetter {
+	if g.armIDs == nil {
+		g.armIDs = make(map[*resources.Resource]string)
+	}
+	clusterInfo := g.clusterInfo
+	clusterInfo.AzureResourceGroupName = rgName
+	clusterInfo.AzureResourceGroupShared = true
+	clusterInfo.AzureResourceGroupMoveTarget = ""
+	clusterInfo.AzureNodeResourceGroupName = ""
+	clusterInfo.AzureNetworkResourceGroupNames = nil
+	clusterInfo.AzureDiscoveryProgress = nil
+	return &resourceGetter{
+		ctx:         g.ctx,
+		cloud:       g.cloud,
+		clusterInfo: clusterInfo,
+		armIDs:      g.armIDs,
+		mutex:       g.mutex,
+	}
+}
+
+// resourceGroupListers returns the listers of the resources in the resource group.
+func (g *resourceGetter) resourceGroupListers() []typedLister {
+	return []typedLister{
+		{[]string{typeVirtualNetwork, typeSubnet}, g.listVirtualNetworksAndSubnets},
+		{[]string{typeNetworkSecurityGroup, typeNetworkSecurityRule}, g.listNetworkSecurityGroups},
+		{[]string{typeApplicationSecurityGroup}, g.listApplicationSecurityGroups},
+		{[]string{typeRouteTable, typeRoute}, g.listRouteTables},
+		{[]string{typeVMScaleSet, typeRoleAssignment}, g.listVMScaleSetsAndRoleAssignments},
+		{[]string{typeVirtualMachine}, g.listVirtualMachines},
+		{[]string{typeNetworkInterface}, g.listNetworkInterfaces},
+		{[]string{typeDisk}, g.listDisks},
+		{[]string{typeDiskEncryptionSet}, g.listDiskEncryptionSets},
+		{[]string{typeLoadBalancer}, g.listLoadBalancers},
+		{[]string{typePublicIPAddress}, g.listPublicIPAddresses},
+		{[]string{typeNatGateway}, g.listNatGateways},
+		{[]string{typeApplicationGateway}, g.listApplicationGateways},
+		{[]string{typeSnapshot}, g.listSnapshots},
+		{[]string{typeStorageAccount}, g.listStorageAccounts},
+		{[]string{typeKeyVault}, g.listKeyVaults},
+		{[]string{typeRecoveryServicesVault}, g.listRecoveryServicesVaults},
+		{[]string{typePrivateEndpoint}, g.listPrivateEndpoints},
+		{[]string{typeManagedIdentity}, g.listManagedIdentities},
+		{[]string{typePrivateDNSZone, typePrivateDNSRecordSet, typePrivateDNSZoneLink}, g.listPrivateDNSZones},
+		{[]string{typeNetworkWatcher}, g.listNetworkWatchers},
+	}
+}
+
+// runListers runs the listers concurrently, at most AzureListConcurrency at a
+// time, reporting the discovery progress. A lister failing does not stop the
+// others: the resources listed are returned in the order of the listers, along
+// with the errors of all the listers that failed.
+func (g *resourceGetter) runListers(fns []func(ctx context.Context) ([]*resources.Resource, error)) ([]*resources.Resource, error) {
+	if g.mutex == nil {
+		g.mutex = &sync.Mutex{}
+	}
+	if g.armIDs == nil {
+		g.armIDs = make(map[*resources.Resource]string)
+	}
+	limit := g.clusterInfo.AzureListConcurrency
+	if limit <= 0 {
+		limit = defaultListConcurrency
+	}
+
+	results := make([][]*resources.Resource, len(fns))
+	errs := make([]error, len(fns))
+	completed := 0
+	var eg errgroup.Group
+	eg.SetLimit(limit)
+	ctx := g.context()
+	for i, fn := range fns {
+		eg.Go(func() error {
+			rs, err := fn(ctx)
+			if err != nil {
+				errs[i] = err
+				return nil
+			}
+			g.logListed(ctx, rs)
+			// The resources are grouped by the resource group they are in.
+			for _, r := range rs {
+				if r.GroupName == "" {
+					r.GroupName = g.resourceGroupOf(r)
+				}
+			}
+			unlock := g.lock()
+			defer unlock()
+			results[i] = rs
+			completed++
+			g.reportDiscoveryProgress(completed, len(fns))
+			return nil
+		})
+	}
+	_ = eg.Wait()
 
 	var resources []*resources.Resource
-	ctx := context.TODO()
-	for _, fn := range fns {
-		rs, err := fn(ctx)
-		if err != nil {
-			return nil, err
-		}
+	for _, rs := range results {
 		resources = append(resources, rs...)
 	}
-	return resources, nil
+	return resources, errors.Join(errs...)
+}
+
+// logListed logs at verbosity 2 the number of resources of each type found by
+// a lister in the resource group.
+func (g *resourceGetter) logListed(ctx context.Context, rs []*resources.Resource) {
+	logger := klog.FromContext(ctx).V(2)
+	if !logger.Enabled() {
+		return
+	}
+	counts := make(map[string]int)
+	for _, r := range rs {
+		counts[r.Type]++
+	}
+	for _, t := range set.KeySet(counts).SortedList() {
+		logger.Info("Listed Azure resources", "resourceGroup", g.resourceGroupName(), "type", t, "count", counts[t])
+	}
+}
+
+// reportDiscoveryProgress notifies the discovery progress callback, if any.
+func (g *resourceGetter) reportDiscoveryProgress(completed, total int) {
+	if g.clusterInfo.AzureDiscoveryProgress == nil {
+		return
+	}
+	g.clusterInfo.AzureDiscoveryProgress(completed, total)
 }
 
 func (g *resourceGetter) listResourceGroups(ctx context.Context) ([]*resources.Resource, error) {
-	rgs, err := g.cloud.ResourceGroup().List(ctx)
+	rgs, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azureresources.ResourceGroup, error) {
+		return g.cloud.ResourceGroup().List(ctx)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -127,22 +795,29 @@ func (g *resourceGetter) listResourceGroups(ctx context.Context) ([]*resources.R
 }
 
 func (g *resourceGetter) toResourceGroupResource(rg *azureresources.ResourceGroup) *resources.Resource {
-	return &resources.Resource{
-		Obj:     rg,
-		Type:    typeResourceGroup,
-		ID:      *rg.Name,
-		Name:    *rg.Name,
-		Deleter: g.deleteResourceGroup,
-		Shared:  g.clusterInfo.AzureResourceGroupShared,
-	}
+	return g.withARMID(&resources.Resource{
+		Obj:       rg,
+		Tags:      flattenTags(rg.Tags),
+		Type:      typeResourceGroup,
+		ID:        *rg.Name,
+		Name:      *rg.Name,
+		GroupName: *rg.Name,
+		Async:     true,
+		Deleter:   g.deleteResourceGroup,
+		Shared:    g.clusterInfo.AzureResourceGroupShared || g.clusterInfo.AzurePreserveResourceGroup || g.resourceFilterKeepsResourceGroup() || g.isSharedWithCluster(rg.Tags) || g.isNodeResourceGroup(*rg.Name),
+	}, rg.ID)
 }
 
 func (g *resourceGetter) deleteResourceGroup(_ fi.Cloud, r *resources.Resource) error {
-	return g.cloud.ResourceGroup().Delete(context.TODO(), r.Name)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.ResourceGroup().Delete(ctx, r.Name)
+	})
 }
 
 func (g *resourceGetter) listVirtualNetworksAndSubnets(ctx context.Context) ([]*resources.Resource, error) {
-	vnets, err := g.cloud.VirtualNetwork().List(ctx, g.resourceGroupName())
+	vnets, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.VirtualNetwork, error) {
+		return g.cloud.VirtualNetwork().List(ctx, g.resourceGroupName())
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -188,35 +863,45 @@ func (g *resourceGetter) toVirtualNetworkResource(vnet *network.VirtualNetwork)
 		blocks = append(blocks, toKey(typeNetworkSecurityGroup, nsg))
 	}
 
-	return &resources.Resource{
+	return g.withARMID(&resources.Resource{
 		Obj:     vnet,
+		Tags:    flattenTags(vnet.Tags),
 		Type:    typeVirtualNetwork,
 		ID:      *vnet.Name,
 		Name:    *vnet.Name,
+		Async:   true,
 		Deleter: g.deleteVirtualNetwork,
 		Blocks:  blocks,
-		Shared:  g.clusterInfo.AzureNetworkShared,
-	}, nil
+		Shared:  g.clusterInfo.AzureNetworkShared || g.isSharedWithCluster(vnet.Tags),
+	}, vnet.ID), nil
 }
 
 func (g *resourceGetter) deleteVirtualNetwork(_ fi.Cloud, r *resources.Resource) error {
-	return g.cloud.VirtualNetwork().Delete(context.TODO(), g.resourceGroupName(), r.Name)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.VirtualNetwork().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
 }
 
 func (g *resourceGetter) listSubnets(ctx context.Context, vnetName string) ([]*resources.Resource, error) {
-	subnets, err := g.cloud.Subnet().List(ctx, g.resourceGroupName(), vnetName)
+	subnets, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.Subnet, error) {
+		return g.cloud.Subnet().List(ctx, g.resourceGroupName(), vnetName)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	var rs []*resources.Resource
 	for _, sn := range subnets {
-		rs = append(rs, g.toSubnetResource(sn, vnetName))
+		r, err := g.toSubnetResource(sn, vnetName)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
 	}
 	return rs, nil
 }
 
-func (g *resourceGetter) toSubnetResource(subnet *network.Subnet, vnetName string) *resources.Resource {
+func (g *resourceGetter) toSubnetResource(subnet *network.Subnet, vnetName string) (*resources.Resource, error) {
 	var blocks []string
 	blocks = append(blocks, toKey(typeVirtualNetwork, vnetName))
 	blocks = append(blocks, toKey(typeResourceGroup, g.resourceGroupName()))
@@ -224,37 +909,68 @@ func (g *resourceGetter) toSubnetResource(subnet *network.Subnet, vnetName strin
 	if subnet.Properties != nil && subnet.Properties.NatGateway != nil && subnet.Properties.NatGateway.ID != nil {
 		blocks = append(blocks, toKey(typeNatGateway, *subnet.Properties.NatGateway.ID))
 	}
+	// Azure refuses to delete a route table or a network security group that a
+	// subnet is still associated with.
+	if subnet.Properties != nil && subnet.Properties.RouteTable != nil && subnet.Properties.RouteTable.ID != nil {
+		rtID, err := azure.ParseRouteTableID(*subnet.Properties.RouteTable.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parsing route table ID: %w", err)
+		}
+		blocks = append(blocks, toKey(typeRouteTable, rtID.RouteTableName))
+	}
+	if subnet.Properties != nil && subnet.Properties.NetworkSecurityGroup != nil && subnet.Properties.NetworkSecurityGroup.ID != nil {
+		nsgID, err := azure.ParseNetworkSecurityGroupID(*subnet.Properties.NetworkSecurityGroup.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parsing network security group ID: %w", err)
+		}
+		blocks = append(blocks, toKey(typeNetworkSecurityGroup, nsgID.NetworkSecurityGroupName))
+	}
 
-	return &resources.Resource{
-		Obj:  subnet,
-		Type: typeSubnet,
-		ID:   *subnet.Name,
-		Name: *subnet.Name,
+	return g.withARMID(&resources.Resource{
+		Obj:   subnet,
+		Type:  typeSubnet,
+		ID:    *subnet.Name,
+		Name:  *subnet.Name,
+		Async: true,
 		Deleter: func(_ fi.Cloud, r *resources.Resource) error {
 			return g.deleteSubnet(vnetName, r)
 		},
 		Blocks: blocks,
 		Shared: g.clusterInfo.AzureNetworkShared,
-	}
+	}, subnet.ID), nil
 }
 
 func (g *resourceGetter) deleteSubnet(vnetName string, r *resources.Resource) error {
-	return g.cloud.Subnet().Delete(context.TODO(), g.resourceGroupName(), vnetName, r.Name)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.Subnet().Delete(ctx, g.resourceGroupOf(r), vnetName, r.Name)
+	})
 }
 
 func (g *resourceGetter) listNetworkSecurityGroups(ctx context.Context) ([]*resources.Resource, error) {
-	NetworkSecurityGroups, err := g.cloud.NetworkSecurityGroup().List(ctx, g.resourceGroupName())
+	NetworkSecurityGroups, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.SecurityGroup, error) {
+		return g.cloud.NetworkSecurityGroup().List(ctx, g.resourceGroupName())
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	var rs []*resources.Resource
 	for i := range NetworkSecurityGroups {
+		if !g.isOwnedByCluster(NetworkSecurityGroups[i].Tags) {
+			continue
+		}
 		r, err := g.toNetworkSecurityGroupResource(NetworkSecurityGroups[i])
 		if err != nil {
 			return nil, err
 		}
 		rs = append(rs, r)
+		if r.Shared {
+			rules, err := g.toNetworkSecurityRuleResources(NetworkSecurityGroups[i])
+			if err != nil {
+				return nil, err
+			}
+			rs = append(rs, rules...)
+		}
 	}
 	return rs, nil
 }
@@ -266,148 +982,476 @@ func (g *resourceGetter) toNetworkSecurityGroupResource(NetworkSecurityGroup *ne
 	asgs := set.New[string]()
 	if NetworkSecurityGroup.Properties.SecurityRules != nil {
 		for _, nsr := range NetworkSecurityGroup.Properties.SecurityRules {
-			if nsr.Properties.SourceApplicationSecurityGroups != nil {
-				for _, sasg := range nsr.Properties.SourceApplicationSecurityGroups {
-					asgID, err := azure.ParseApplicationSecurityGroupID(*sasg.ID)
-					if err != nil {
-						return nil, fmt.Errorf("parsing application security group ID: %w", err)
-					}
-					asgs.Insert(asgID.ApplicationSecurityGroupName)
-				}
-			}
-			if nsr.Properties.DestinationApplicationSecurityGroups != nil {
-				for _, dasg := range nsr.Properties.DestinationApplicationSecurityGroups {
-					asgID, err := azure.ParseApplicationSecurityGroupID(*dasg.ID)
-					if err != nil {
-						return nil, fmt.Errorf("parsing application security group ID: %w", err)
-					}
-					asgs.Insert(asgID.ApplicationSecurityGroupName)
-				}
+			ruleASGs, err := securityRuleApplicationSecurityGroups(nsr)
+			if err != nil {
+				return nil, err
 			}
+			asgs = asgs.Union(ruleASGs)
 		}
 	}
 	for asg := range asgs {
 		blocks = append(blocks, toKey(typeApplicationSecurityGroup, asg))
 	}
 
-	return &resources.Resource{
-		Obj:  NetworkSecurityGroup,
-		Type: typeNetworkSecurityGroup,
-		ID:   *NetworkSecurityGroup.Name,
-		Name: *NetworkSecurityGroup.Name,
+	return g.withARMID(&resources.Resource{
+		Obj:    NetworkSecurityGroup,
+		Tags:   flattenTags(NetworkSecurityGroup.Tags),
+		Type:   typeNetworkSecurityGroup,
+		ID:     *NetworkSecurityGroup.Name,
+		Name:   *NetworkSecurityGroup.Name,
+		Shared: g.isSharedWithCluster(NetworkSecurityGroup.Tags),
+		Async:  true,
 		Deleter: func(_ fi.Cloud, r *resources.Resource) error {
 			return g.deleteNetworkSecurityGroup(r)
 		},
 		Blocks: blocks,
-	}, nil
+	}, NetworkSecurityGroup.ID), nil
 }
 
 func (g *resourceGetter) deleteNetworkSecurityGroup(r *resources.Resource) error {
-	return g.cloud.NetworkSecurityGroup().Delete(context.TODO(), g.resourceGroupName(), r.Name)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.NetworkSecurityGroup().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
 }
 
-func (g *resourceGetter) listApplicationSecurityGroups(ctx context.Context) ([]*resources.Resource, error) {
-	ApplicationSecurityGroups, err := g.cloud.ApplicationSecurityGroup().List(ctx, g.resourceGroupName())
-	if err != nil {
-		return nil, err
+// securityRuleApplicationSecurityGroups returns the names of the application
+// security groups that a security rule refers to.
+func securityRuleApplicationSecurityGroups(nsr *network.SecurityRule) (set.Set[string], error) {
+	asgs := set.New[string]()
+	if nsr.Properties == nil {
+		return asgs, nil
 	}
-
-	var rs []*resources.Resource
-	for _, asg := range ApplicationSecurityGroups {
-		rs = append(rs, g.toApplicationSecurityGroupResource(asg))
+	for _, asg := range append(nsr.Properties.SourceApplicationSecurityGroups, nsr.Properties.DestinationApplicationSecurityGroups...) {
+		asgID, err := azure.ParseApplicationSecurityGroupID(*asg.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parsing application security group ID: %w", err)
+		}
+		asgs.Insert(asgID.ApplicationSecurityGroupName)
 	}
-	return rs, nil
+	return asgs, nil
 }
 
-func (g *resourceGetter) toApplicationSecurityGroupResource(ApplicationSecurityGroup *network.ApplicationSecurityGroup) *resources.Resource {
-	return &resources.Resource{
-		Obj:  ApplicationSecurityGroup,
-		Type: typeApplicationSecurityGroup,
-		ID:   *ApplicationSecurityGroup.Name,
-		Name: *ApplicationSecurityGroup.Name,
-		Deleter: func(_ fi.Cloud, r *resources.Resource) error {
-			return g.deleteApplicationSecurityGroup(r)
-		},
-		Blocks: []string{
-			toKey(typeResourceGroup, g.resourceGroupName()),
-		},
-	}
+// kopsSecurityRuleNamePrefixes are the prefixes of the names of the security
+// rules that kops creates, see pkg/model/azuremodel/network.go.
+var kopsSecurityRuleNamePrefixes = []string{
+	"AllowSSH",
+	"AllowKubernetesAPI",
+	"AllowNodePort",
+	"AllowControlPlaneTo",
+	"AllowNodesTo",
+	"DenyNodesTo",
 }
 
-func (g *resourceGetter) deleteApplicationSecurityGroup(r *resources.Resource) error {
-	return g.cloud.ApplicationSecurityGroup().Delete(context.TODO(), g.resourceGroupName(), r.Name)
+// isKopsSecurityRule returns true if the security rule was created by kops.
+func isKopsSecurityRule(nsr *network.SecurityRule) bool {
+	if nsr.Name == nil {
+		return false
+	}
+	for _, prefix := range kopsSecurityRuleNamePrefixes {
+		if strings.HasPrefix(*nsr.Name, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
-func (g *resourceGetter) listRouteTables(ctx context.Context) ([]*resources.Resource, error) {
-	rts, err := g.cloud.RouteTable().List(ctx, g.resourceGroupName())
-	if err != nil {
-		return nil, err
+// toNetworkSecurityRuleResources returns the rules that kops created on a
+// shared security group. They are deleted one at a time by updating the
+// security group without them, while the security group itself is kept.
+func (g *resourceGetter) toNetworkSecurityRuleResources(nsg *network.SecurityGroup) ([]*resources.Resource, error) {
+	if nsg.Properties == nil {
+		return nil, nil
 	}
-
+	// The rules of a security group are removed one at a time, so that an
+	// update does not bring back a rule removed by another.
+	var mutex sync.Mutex
 	var rs []*resources.Resource
-	for _, rt := range rts {
-		if !g.isOwnedByCluster(rt.Tags) {
+	for _, nsr := range nsg.Properties.SecurityRules {
+		if !isKopsSecurityRule(nsr) {
 			continue
 		}
-		rs = append(rs, g.toRouteTableResource(rt))
-	}
-	return rs, nil
-}
+		blocks := []string{toKey(typeResourceGroup, g.resourceGroupName())}
+		asgs, err := securityRuleApplicationSecurityGroups(nsr)
+		if err != nil {
+			return nil, err
+		}
+		for _, asg := range asgs.SortedList() {
+			blocks = append(blocks, toKey(typeApplicationSecurityGroup, asg))
+		}
+		nsgName := *nsg.Name
+		rs = append(rs, g.withARMID(&resources.Resource{
+			Obj:   nsr,
+			Type:  typeNetworkSecurityRule,
+			ID:    nsgName + "/" + *nsr.Name,
+			Name:  *nsr.Name,
+			Async: true,
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return g.deleteNetworkSecurityRule(nsgName, r)
+			},
+			Blocks: blocks,
+		}, nsr.ID))
+	}
+	return rs, nil
+}
+
+// deleteNetworkSecurityRule removes a rule from a security group, by updating
+// the security group as it currently is without the rule.
+func (g *resourceGetter) deleteNetworkSecurityRule(nsgName string, r *resources.Resource) error {
+	rgName := g.resourceGroupOf(r)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		nsgs, err := g.cloud.NetworkSecurityGroup().List(ctx, rgName)
+		if err != nil {
+			return err
+		}
+		for _, nsg := range nsgs {
+			if nsg.Name == nil || *nsg.Name != nsgName || nsg.Properties == nil {
+				continue
+			}
+			var rules []*network.SecurityRule
+			for _, nsr := range nsg.Properties.SecurityRules {
+				if nsr.Name == nil || *nsr.Name != r.Name {
+					rules = append(rules, nsr)
+				}
+			}
+			if len(rules) == len(nsg.Properties.SecurityRules) {
+				return nil
+			}
+			updated := *nsg
+			properties := *nsg.Properties
+			properties.SecurityRules = rules
+			updated.Properties = &properties
+			_, err := g.cloud.NetworkSecurityGroup().CreateOrUpdate(ctx, rgName, nsgName, updated)
+			return err
+		}
+		return nil
+	})
+}
+
+func (g *resourceGetter) listApplicationSecurityGroups(ctx context.Context) ([]*resources.Resource, error) {
+	ApplicationSecurityGroups, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.ApplicationSecurityGroup, error) {
+		return g.cloud.ApplicationSecurityGroup().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, asg := range ApplicationSecurityGroups {
+		if !g.isOwnedByCluster(asg.Tags) {
+			continue
+		}
+		rs = append(rs, g.toApplicationSecurityGroupResource(asg))
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) toApplicationSecurityGroupResource(ApplicationSecurityGroup *network.ApplicationSecurityGroup) *resources.Resource {
+	return g.withARMID(&resources.Resource{
+		Obj:    ApplicationSecurityGroup,
+		Tags:   flattenTags(ApplicationSecurityGroup.Tags),
+		Type:   typeApplicationSecurityGroup,
+		ID:     *ApplicationSecurityGroup.Name,
+		Name:   *ApplicationSecurityGroup.Name,
+		Shared: g.isSharedWithCluster(ApplicationSecurityGroup.Tags),
+		Async:  true,
+		Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+			return g.deleteApplicationSecurityGroup(r)
+		},
+		Blocks: []string{
+			toKey(typeResourceGroup, g.resourceGroupName()),
+		},
+	}, ApplicationSecurityGroup.ID)
+}
+
+func (g *resourceGetter) deleteApplicationSecurityGroup(r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.ApplicationSecurityGroup().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+func (g *resourceGetter) listRouteTables(ctx context.Context) ([]*resources.Resource, error) {
+	rts, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.RouteTable, error) {
+		return g.cloud.RouteTable().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	var clusterPrefixes []netip.Prefix
+	clusterPrefixesListed := false
+	for _, rt := range rts {
+		if !g.isOwnedByCluster(rt.Tags) {
+			continue
+		}
+		r := g.toRouteTableResource(rt)
+		if !r.Shared && rt.Properties != nil {
+			others, err := g.otherClustersUsingSubnets(ctx, rt.Properties.Subnets)
+			if err != nil {
+				return nil, err
+			}
+			if len(others) > 0 {
+				klog.Warningf("Route table %q is used by the subnets of clusters %v too; it will not be deleted", r.Name, others)
+				r.Shared = true
+				setMetadata(r, metadataUsedBy, strings.Join(others, ","))
+			}
+		}
+		rs = append(rs, r)
+		if r.Shared || !hasVirtualApplianceRoutes(rt) {
+			continue
+		}
+		if !clusterPrefixesListed {
+			clusterPrefixes, err = g.clusterAddressPrefixes(ctx)
+			if err != nil {
+				return nil, err
+			}
+			clusterPrefixesListed = true
+		}
+		rs = append(rs, g.toRouteResources(rt, clusterPrefixes)...)
+	}
+	return rs, nil
+}
+
+// hasVirtualApplianceRoutes returns true if the route table has routes to a
+// virtual appliance.
+func hasVirtualApplianceRoutes(rt *network.RouteTable) bool {
+	if rt.Properties == nil {
+		return false
+	}
+	for _, route := range rt.Properties.Routes {
+		if route.Properties != nil && route.Properties.NextHopType != nil && *route.Properties.NextHopType == network.RouteNextHopTypeVirtualAppliance {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterAddressPrefixes returns the address prefixes of the virtual networks
+// of the cluster, which hold the IPs of its instances.
+func (g *resourceGetter) clusterAddressPrefixes(ctx context.Context) ([]netip.Prefix, error) {
+	vnets, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.VirtualNetwork, error) {
+		return g.cloud.VirtualNetwork().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+	var prefixes []netip.Prefix
+	for _, vnet := range vnets {
+		if !g.isOwnedByCluster(vnet.Tags) || vnet.Properties == nil || vnet.Properties.AddressSpace == nil {
+			continue
+		}
+		for _, s := range vnet.Properties.AddressSpace.AddressPrefixes {
+			if s == nil {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(*s)
+			if err != nil {
+				return nil, fmt.Errorf("parsing address prefix of virtual network %q: %w", *vnet.Name, err)
+			}
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes, nil
+}
+
+// toRouteResources returns the routes of a route table to a virtual appliance
+// with an IP of the cluster, typically of a deleted instance. They block the
+// route table, so that they are removed before it, one at a time by updating
+// the route table without them.
+func (g *resourceGetter) toRouteResources(rt *network.RouteTable, clusterPrefixes []netip.Prefix) []*resources.Resource {
+	// The routes of a route table are removed one at a time, so that an
+	// update does not bring back a route removed by another.
+	var mutex sync.Mutex
+	var rs []*resources.Resource
+	for _, route := range rt.Properties.Routes {
+		if route.Name == nil || route.Properties == nil || route.Properties.NextHopType == nil || *route.Properties.NextHopType != network.RouteNextHopTypeVirtualAppliance || route.Properties.NextHopIPAddress == nil {
+			continue
+		}
+		ip, err := netip.ParseAddr(*route.Properties.NextHopIPAddress)
+		if err != nil || !slices.ContainsFunc(clusterPrefixes, func(prefix netip.Prefix) bool { return prefix.Contains(ip) }) {
+			continue
+		}
+		rtName := *rt.Name
+		rs = append(rs, g.withARMID(&resources.Resource{
+			Obj:   route,
+			Type:  typeRoute,
+			ID:    rtName + "/" + *route.Name,
+			Name:  *route.Name,
+			Async: true,
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return g.deleteRoute(rtName, r)
+			},
+			Blocks: []string{
+				toKey(typeRouteTable, rtName),
+				toKey(typeResourceGroup, g.resourceGroupName()),
+			},
+		}, route.ID))
+	}
+	return rs
+}
+
+// deleteRoute removes a route from a route table, by updating the route table
+// as it currently is without the route.
+func (g *resourceGetter) deleteRoute(rtName string, r *resources.Resource) error {
+	rgName := g.resourceGroupOf(r)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		rts, err := g.cloud.RouteTable().List(ctx, rgName)
+		if err != nil {
+			return err
+		}
+		for _, rt := range rts {
+			if rt.Name == nil || *rt.Name != rtName || rt.Properties == nil {
+				continue
+			}
+			var routes []*network.Route
+			for _, route := range rt.Properties.Routes {
+				if route.Name == nil || *route.Name != r.Name {
+					routes = append(routes, route)
+				}
+			}
+			if len(routes) == len(rt.Properties.Routes) {
+				return nil
+			}
+			updated := *rt
+			properties := *rt.Properties
+			properties.Routes = routes
+			updated.Properties = &properties
+			_, err := g.cloud.RouteTable().CreateOrUpdate(ctx, rgName, rtName, updated)
+			return err
+		}
+		return nil
+	})
+}
+
+// otherClustersUsingSubnets returns the sorted names of the clusters other than
+// this one whose virtual networks hold some of the subnets. Virtual networks
+// without a cluster tag naming a cluster are ignored.
+func (g *resourceGetter) otherClustersUsingSubnets(ctx context.Context, subnets []*network.Subnet) ([]string, error) {
+	others := set.New[string]()
+	vnetsByResourceGroup := make(map[string][]*network.VirtualNetwork)
+	for _, subnet := range subnets {
+		if subnet == nil || subnet.ID == nil {
+			continue
+		}
+		subnetID, err := azure.ParseSubnetID(*subnet.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parsing subnet ID: %w", err)
+		}
+		vnets, ok := vnetsByResourceGroup[subnetID.ResourceGroupName]
+		if !ok {
+			vnets, err = retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.VirtualNetwork, error) {
+				return g.cloud.VirtualNetwork().List(ctx, subnetID.ResourceGroupName)
+			})
+			if err != nil {
+				return nil, err
+			}
+			vnetsByResourceGroup[subnetID.ResourceGroupName] = vnets
+		}
+		for _, vnet := range vnets {
+			if vnet.Name == nil || !strings.EqualFold(*vnet.Name, subnetID.VirtualNetworkName) {
+				continue
+			}
+			switch v, _ := g.clusterTagValue(vnet.Tags); v {
+			case "", g.clusterInfo.Name, clusterTagValueOwned, clusterTagValueShared:
+			default:
+				others.Insert(v)
+			}
+		}
+	}
+	return others.SortedList(), nil
+}
 
 func (g *resourceGetter) toRouteTableResource(rt *network.RouteTable) *resources.Resource {
-	return &resources.Resource{
+	return g.withARMID(&resources.Resource{
 		Obj:     rt,
+		Tags:    flattenTags(rt.Tags),
 		Type:    typeRouteTable,
 		ID:      *rt.Name,
 		Name:    *rt.Name,
+		Async:   true,
 		Deleter: g.deleteRouteTable,
 		Blocks:  []string{toKey(typeResourceGroup, g.resourceGroupName())},
-		Shared:  g.clusterInfo.AzureRouteTableShared,
-	}
+		Shared:  g.clusterInfo.AzureRouteTableShared || g.isSharedWithCluster(rt.Tags),
+	}, rt.ID)
 }
 
 func (g *resourceGetter) deleteRouteTable(_ fi.Cloud, r *resources.Resource) error {
-	return g.cloud.RouteTable().Delete(context.TODO(), g.resourceGroupName(), r.Name)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.RouteTable().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
 }
 
 func (g *resourceGetter) listVMScaleSetsAndRoleAssignments(ctx context.Context) ([]*resources.Resource, error) {
-	vmsses, err := g.cloud.VMScaleSet().List(ctx, g.resourceGroupName())
+	rs, unowned, err := g.findVMScaleSetsAndRoleAssignments(ctx)
 	if err != nil {
 		return nil, err
 	}
+	for _, u := range unowned {
+		klog.Warningf("Role assignment %q belongs to VM Scale Set %q, which is not tagged %s=%s; it will not be deleted", u.roleAssignmentName, u.vmScaleSetName, azure.TagClusterName, g.clusterInfo.Name)
+	}
+	return rs, nil
+}
+
+// findVMScaleSetsAndRoleAssignments lists the VM Scale Sets owned by the cluster
+// and their role assignments. It also returns the role assignments of the VM
+// Scale Sets in the resource group that are not owned by the cluster.
+func (g *resourceGetter) findVMScaleSetsAndRoleAssignments(ctx context.Context) ([]*resources.Resource, []unownedRoleAssignment, error) {
+	vmsses, err := g.listVMScaleSets(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var rs []*resources.Resource
-	principalIDs := map[string]*compute.VirtualMachineScaleSet{}
+	// Identity.PrincipalID is the principal of the system-assigned identity, which
+	// Azure creates for each VM Scale Set, so principal IDs are normally unique.
+	// A principal ID still maps to all the VM Scale Sets reporting it, so that a
+	// role assignment is deleted after every one of them if they ever collide.
+	principalIDs := map[string][]*compute.VirtualMachineScaleSet{}
+	// Principal IDs of VM Scale Sets that are not owned by the cluster, used to
+	// diagnose role assignments that would otherwise be silently leaked.
+	unownedPrincipalIDs := map[string]string{}
 	for _, vmss := range vmsses {
 		if !g.isOwnedByCluster(vmss.Tags) {
+			if vmss.Identity != nil && vmss.Identity.PrincipalID != nil {
+				unownedPrincipalIDs[*vmss.Identity.PrincipalID] = *vmss.Name
+			}
 			continue
 		}
 
-		vms, err := g.cloud.VMScaleSetVM().List(ctx, g.resourceGroupName(), *vmss.Name)
+		vms, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*compute.VirtualMachineScaleSetVM, error) {
+			return g.cloud.VMScaleSetVM().List(ctx, g.resourceGroupName(), *vmss.Name)
+		})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		r, err := g.toVMScaleSetResource(vmss, vms)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		rs = append(rs, r)
 
-		principalIDs[*vmss.Identity.PrincipalID] = vmss
+		if vmss.Identity != nil && vmss.Identity.PrincipalID != nil {
+			principalIDs[*vmss.Identity.PrincipalID] = append(principalIDs[*vmss.Identity.PrincipalID], vmss)
+		}
 	}
 
-	ras, err := g.listRoleAssignments(ctx, principalIDs)
+	ras, unowned, err := g.listRoleAssignments(ctx, principalIDs, unownedPrincipalIDs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	rs = append(rs, ras...)
 
-	return rs, nil
+	return rs, unowned, nil
 }
 
 func (g *resourceGetter) toVMScaleSetResource(vmss *compute.VirtualMachineScaleSet, vms []*compute.VirtualMachineScaleSetVM) (*resources.Resource, error) {
-	// Add resources whose deletion is blocked by this VMSS.
+	// Add resources whose deletion is blocked by this VMSS. A VMSS of a zone-spread
+	// cluster may have IP configurations in several subnets.
 	var blocks []string
 	blocks = append(blocks, toKey(typeResourceGroup, g.resourceGroupName()))
 
@@ -443,127 +1487,263 @@ func (g *resourceGetter) toVMScaleSetResource(vmss *compute.VirtualMachineScaleS
 			}
 		}
 	}
-	for vnet := range vnets {
+	for _, vnet := range vnets.SortedList() {
 		blocks = append(blocks, toKey(typeVirtualNetwork, vnet))
 	}
-	for subnet := range subnets {
+	for _, subnet := range subnets.SortedList() {
 		blocks = append(blocks, toKey(typeSubnet, subnet))
 	}
-	for asg := range asgs {
+	for _, asg := range asgs.SortedList() {
 		blocks = append(blocks, toKey(typeApplicationSecurityGroup, asg))
 	}
-	for lb := range lbs {
+	for _, lb := range lbs.SortedList() {
 		blocks = append(blocks, toKey(typeLoadBalancer, lb))
 	}
+	if vmss.Identity != nil {
+		identities := set.New[string]()
+		for id := range vmss.Identity.UserAssignedIdentities {
+			identityID, err := azure.ParseManagedIdentityID(id)
+			if err != nil {
+				return nil, fmt.Errorf("parsing managed identity ID: %w", err)
+			}
+			identities.Insert(identityID.ManagedIdentityName)
+		}
+		for _, identity := range identities.SortedList() {
+			blocks = append(blocks, toKey(typeManagedIdentity, identity))
+		}
+	}
 
+	// The data disks attached to the VMs are keyed by name, like in toDiskResource,
+	// so that the VM Scale Set is deleted before them. A VM whose storage profile
+	// is not known, e.g. while it is being created, has none.
 	for _, vm := range vms {
-		if disks := vm.Properties.StorageProfile.DataDisks; disks != nil {
-			for _, d := range disks {
-				blocks = append(blocks, toKey(typeDisk, *d.Name))
+		if vm.Properties == nil || vm.Properties.StorageProfile == nil {
+			continue
+		}
+		for _, d := range vm.Properties.StorageProfile.DataDisks {
+			if name := dataDiskName(d); name != "" {
+				blocks = append(blocks, toKey(typeDisk, name))
 			}
 		}
 	}
 
-	return &resources.Resource{
+	r := &resources.Resource{
 		Obj:     vmss,
+		Tags:    flattenTags(vmss.Tags),
 		Type:    typeVMScaleSet,
 		ID:      *vmss.Name,
 		Name:    *vmss.Name,
+		Shared:  g.isSharedWithCluster(vmss.Tags),
+		Async:   true,
 		Deleter: g.deleteVMScaleSet,
 		Blocks:  blocks,
-	}, nil
+	}
+	if sku := vmss.SKU; sku != nil {
+		setMetadata(r, metadataSKU, fi.ValueOf(sku.Name))
+		setMetadata(r, metadataTier, fi.ValueOf(sku.Tier))
+		if sku.Capacity != nil {
+			setMetadata(r, metadataCapacity, strconv.FormatInt(*sku.Capacity, 10))
+		}
+	}
+	return g.withARMID(r, vmss.ID), nil
+}
+
+// dataDiskName returns the name of the managed disk backing a data disk, or ""
+// if it is not known.
+func dataDiskName(d *compute.DataDisk) string {
+	if d.Name != nil {
+		return *d.Name
+	}
+	if d.ManagedDisk != nil && d.ManagedDisk.ID != nil {
+		l := strings.Split(*d.ManagedDisk.ID, "/")
+		return l[len(l)-1]
+	}
+	return ""
 }
 
 func (g *resourceGetter) deleteVMScaleSet(_ fi.Cloud, r *resources.Resource) error {
-	return g.cloud.VMScaleSet().Delete(context.TODO(), g.resourceGroupName(), r.Name)
+	ctx := g.context()
+	rg := g.resourceGroupOf(r)
+	if g.clusterInfo.AzureForceDelete {
+		if err := g.clearInstanceProtection(ctx, rg, r.Name); err != nil {
+			return err
+		}
+	}
+	return retryOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.VMScaleSet().Delete(ctx, rg, r.Name)
+	})
 }
 
-func (g *resourceGetter) listDisks(ctx context.Context) ([]*resources.Resource, error) {
-	disks, err := g.cloud.Disk().List(ctx, g.resourceGroupName())
+// clearInstanceProtection clears the protection of the VMs of a VM Scale Set
+// against scale-in and scale set actions. Deleting a VM Scale Set fails while
+// any of its VMs is protected.
+func (g *resourceGetter) clearInstanceProtection(ctx context.Context, resourceGroupName, vmssName string) error {
+	vms, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*compute.VirtualMachineScaleSetVM, error) {
+		return g.cloud.VMScaleSetVM().List(ctx, resourceGroupName, vmssName)
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	var rs []*resources.Resource
-	for _, disk := range disks {
-		if !g.isOwnedByCluster(disk.Tags) {
+	for _, vm := range vms {
+		if !isProtectedVM(vm) {
 			continue
 		}
-		rs = append(rs, g.toDiskResource(disk))
+		klog.Infof("Clearing the protection of instance %s of VMSS %s", fi.ValueOf(vm.InstanceID), vmssName)
+		props := *vm.Properties
+		props.ProtectionPolicy = &compute.VirtualMachineScaleSetVMProtectionPolicy{
+			ProtectFromScaleIn:         fi.PtrTo(false),
+			ProtectFromScaleSetActions: fi.PtrTo(false),
+		}
+		update := *vm
+		update.Properties = &props
+		if _, err := g.cloud.VMScaleSetVM().Update(ctx, resourceGroupName, vmssName, fi.ValueOf(vm.InstanceID), update); err != nil {
+			return fmt.Errorf("clearing the protection of instance %s of VMSS %s: %w", fi.ValueOf(vm.InstanceID), vmssName, err)
+		}
 	}
-	return rs, nil
+	return nil
 }
 
-func (g *resourceGetter) toDiskResource(disk *compute.Disk) *resources.Resource {
-	return &resources.Resource{
-		Obj:     disk,
-		Type:    typeDisk,
-		ID:      *disk.Name,
-		Name:    *disk.Name,
-		Deleter: g.deleteDisk,
-		Blocks:  []string{toKey(typeResourceGroup, g.resourceGroupName())},
+// isProtectedVM returns true if the VM Scale Set VM is protected against
+// scale-in or scale set actions.
+func isProtectedVM(vm *compute.VirtualMachineScaleSetVM) bool {
+	if vm.Properties == nil || vm.Properties.ProtectionPolicy == nil {
+		return false
 	}
+	p := vm.Properties.ProtectionPolicy
+	return fi.ValueOf(p.ProtectFromScaleIn) || fi.ValueOf(p.ProtectFromScaleSetActions)
 }
 
-func (g *resourceGetter) deleteDisk(_ fi.Cloud, r *resources.Resource) error {
-	return g.cloud.Disk().Delete(context.TODO(), g.resourceGroupName(), r.Name)
-}
+// listVirtualMachines lists the standalone virtual machines owned by the
+// cluster, like the instances of VM Scale Sets in Flexible orchestration mode,
+// which are not VM Scale Set VMs.
+func (g *resourceGetter) listVirtualMachines(ctx context.Context) ([]*resources.Resource, error) {
+	vms, err := g.listVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var clusterVMs []*compute.VirtualMachine
+	for _, vm := range vms {
+		if g.isOwnedByCluster(vm.Tags) {
+			clusterVMs = append(clusterVMs, vm)
+		}
+	}
+	if len(clusterVMs) == 0 {
+		return nil, nil
+	}
 
-func (g *resourceGetter) listRoleAssignments(ctx context.Context, principalIDs map[string]*compute.VirtualMachineScaleSet) ([]*resources.Resource, error) {
-	ras, err := g.cloud.RoleAssignment().List(ctx, g.resourceGroupName())
+	// The public IP addresses of a virtual machine are those of its network interfaces.
+	nics, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.Interface, error) {
+		return g.cloud.NetworkInterface().List(ctx, g.resourceGroupName())
+	})
 	if err != nil {
 		return nil, err
 	}
+	nicsByID := make(map[string]*network.Interface)
+	for _, nic := range nics {
+		if nic.ID != nil {
+			nicsByID[strings.ToLower(*nic.ID)] = nic
+		}
+	}
 
 	var rs []*resources.Resource
-	for _, ra := range ras {
-		// Add a Role Assignment to the slice if its principal ID is that of one of the VM Scale Sets.
-		if ra.Properties == nil || ra.Properties.PrincipalID == nil {
-			continue
-		}
-		vmss, ok := principalIDs[*ra.Properties.PrincipalID]
-		if !ok {
-			continue
+	for _, vm := range clusterVMs {
+		r, err := g.toVirtualMachineResource(vm, nicsByID)
+		if err != nil {
+			return nil, err
 		}
-		rs = append(rs, g.toRoleAssignmentResource(ra, vmss))
+		rs = append(rs, r)
 	}
 	return rs, nil
 }
 
-func (g *resourceGetter) toRoleAssignmentResource(ra *authz.RoleAssignment, vmss *compute.VirtualMachineScaleSet) *resources.Resource {
-	return &resources.Resource{
-		Obj:     ra,
-		Type:    typeRoleAssignment,
-		ID:      *ra.Name,
-		Name:    *ra.Name,
-		Deleter: g.deleteRoleAssignment,
-		Blocks: []string{
-			toKey(typeResourceGroup, g.resourceGroupName()),
-			toKey(typeVMScaleSet, *vmss.Name),
-		},
+func (g *resourceGetter) toVirtualMachineResource(vm *compute.VirtualMachine, nicsByID map[string]*network.Interface) (*resources.Resource, error) {
+	blocks := []string{toKey(typeResourceGroup, g.resourceGroupName())}
+	if props := vm.Properties; props != nil {
+		// A VM Scale Set in Flexible orchestration mode is deleted after its instances.
+		if props.VirtualMachineScaleSet != nil && props.VirtualMachineScaleSet.ID != nil {
+			l := strings.Split(*props.VirtualMachineScaleSet.ID, "/")
+			blocks = append(blocks, toKey(typeVMScaleSet, l[len(l)-1]))
+		}
+		if storage := props.StorageProfile; storage != nil {
+			var diskIDs []*string
+			if storage.OSDisk != nil && storage.OSDisk.ManagedDisk != nil {
+				diskIDs = append(diskIDs, storage.OSDisk.ManagedDisk.ID)
+			}
+			for _, dataDisk := range storage.DataDisks {
+				if dataDisk.ManagedDisk != nil {
+					diskIDs = append(diskIDs, dataDisk.ManagedDisk.ID)
+				}
+			}
+			for _, id := range diskIDs {
+				if id == nil {
+					continue
+				}
+				diskID, err := azure.ParseDiskID(*id)
+				if err != nil {
+					return nil, fmt.Errorf("parsing disk ID: %w", err)
+				}
+				blocks = append(blocks, toKey(typeDisk, diskID.DiskName))
+			}
+		}
+		if props.NetworkProfile != nil {
+			for _, ref := range props.NetworkProfile.NetworkInterfaces {
+				if ref.ID == nil {
+					continue
+				}
+				nic, ok := nicsByID[strings.ToLower(*ref.ID)]
+				if !ok || nic.Properties == nil {
+					continue
+				}
+				for _, ipConfig := range nic.Properties.IPConfigurations {
+					if ipConfig.Properties == nil || ipConfig.Properties.PublicIPAddress == nil || ipConfig.Properties.PublicIPAddress.ID == nil {
+						continue
+					}
+					pipID, err := azure.ParsePublicIPAddressID(*ipConfig.Properties.PublicIPAddress.ID)
+					if err != nil {
+						return nil, fmt.Errorf("parsing public IP address ID: %w", err)
+					}
+					blocks = append(blocks, toKey(typePublicIPAddress, pipID.PublicIPAddressName))
+				}
+			}
+		}
 	}
+
+	return g.withARMID(&resources.Resource{
+		Obj:     vm,
+		Tags:    flattenTags(vm.Tags),
+		Type:    typeVirtualMachine,
+		ID:      *vm.Name,
+		Name:    *vm.Name,
+		Shared:  g.isSharedWithCluster(vm.Tags),
+		Async:   true,
+		Deleter: g.deleteVirtualMachine,
+		Blocks:  blocks,
+	}, vm.ID), nil
 }
 
-func (g *resourceGetter) deleteRoleAssignment(_ fi.Cloud, r *resources.Resource) error {
-	ra, ok := r.Obj.(*authz.RoleAssignment)
-	if !ok {
-		return fmt.Errorf("expected RoleAssignment, but got %T", r)
-	}
-	return g.cloud.RoleAssignment().Delete(context.TODO(), *ra.Properties.Scope, *ra.Name)
+func (g *resourceGetter) deleteVirtualMachine(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.VirtualMachine().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
 }
 
-func (g *resourceGetter) listLoadBalancers(ctx context.Context) ([]*resources.Resource, error) {
-	loadBalancers, err := g.cloud.LoadBalancer().List(ctx, g.resourceGroupName())
+// listNetworkInterfaces lists the standalone network interfaces owned by the
+// cluster, like those of virtual machines in Flexible orchestration mode or of
+// bastion hosts, which would otherwise keep their subnet from being deleted.
+func (g *resourceGetter) listNetworkInterfaces(ctx context.Context) ([]*resources.Resource, error) {
+	nics, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.Interface, error) {
+		return g.cloud.NetworkInterface().List(ctx, g.resourceGroupName())
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	var rs []*resources.Resource
-	for _, lb := range loadBalancers {
-		if !g.isOwnedByCluster(lb.Tags) {
+	for _, nic := range nics {
+		if !g.isOwnedByCluster(nic.Tags) {
 			continue
 		}
-		r, err := g.toLoadBalancerResource(lb)
+		r, err := g.toNetworkInterfaceResource(nic)
 		if err != nil {
 			return nil, err
 		}
@@ -572,74 +1752,571 @@ func (g *resourceGetter) listLoadBalancers(ctx context.Context) ([]*resources.Re
 	return rs, nil
 }
 
-func (g *resourceGetter) toLoadBalancerResource(loadBalancer *network.LoadBalancer) (*resources.Resource, error) {
-	var blocks []string
-	blocks = append(blocks, toKey(typeResourceGroup, g.resourceGroupName()))
-
-	pips := set.New[string]()
-	if loadBalancer.Properties != nil {
-		for _, fip := range loadBalancer.Properties.FrontendIPConfigurations {
-			if fip.Properties == nil || fip.Properties.PublicIPAddress == nil {
+func (g *resourceGetter) toNetworkInterfaceResource(nic *network.Interface) (*resources.Resource, error) {
+	blocks := []string{toKey(typeResourceGroup, g.resourceGroupName())}
+	var blocked []string
+	if props := nic.Properties; props != nil {
+		// The network interface is deleted after the virtual machine using it.
+		if props.VirtualMachine != nil && props.VirtualMachine.ID != nil {
+			l := strings.Split(*props.VirtualMachine.ID, "/")
+			blocked = append(blocked, toKey(typeVirtualMachine, l[len(l)-1]))
+		}
+		if props.NetworkSecurityGroup != nil && props.NetworkSecurityGroup.ID != nil {
+			nsgID, err := azure.ParseNetworkSecurityGroupID(*props.NetworkSecurityGroup.ID)
+			if err != nil {
+				return nil, fmt.Errorf("parsing network security group ID: %w", err)
+			}
+			blocks = append(blocks, toKey(typeNetworkSecurityGroup, nsgID.NetworkSecurityGroupName))
+		}
+		for _, ipConfig := range props.IPConfigurations {
+			if ipConfig.Properties == nil {
 				continue
 			}
-			pipID, err := azure.ParsePublicIPAddressID(*fip.Properties.PublicIPAddress.ID)
-			if err != nil {
-				return nil, fmt.Errorf("parsing public IP address ID: %s", err)
+			if ipConfig.Properties.Subnet != nil && ipConfig.Properties.Subnet.ID != nil {
+				subnetID, err := azure.ParseSubnetID(*ipConfig.Properties.Subnet.ID)
+				if err != nil {
+					return nil, fmt.Errorf("parsing subnet ID: %w", err)
+				}
+				blocks = append(blocks, toKey(typeSubnet, subnetID.SubnetName))
+			}
+			if ipConfig.Properties.PublicIPAddress != nil && ipConfig.Properties.PublicIPAddress.ID != nil {
+				pipID, err := azure.ParsePublicIPAddressID(*ipConfig.Properties.PublicIPAddress.ID)
+				if err != nil {
+					return nil, fmt.Errorf("parsing public IP address ID: %w", err)
+				}
+				blocks = append(blocks, toKey(typePublicIPAddress, pipID.PublicIPAddressName))
 			}
-			pips.Insert(pipID.PublicIPAddressName)
 		}
 	}
-	for pip := range pips {
-		blocks = append(blocks, toKey(typePublicIPAddress, pip))
-	}
 
-	return &resources.Resource{
-		Obj:     loadBalancer,
-		Type:    typeLoadBalancer,
-		ID:      *loadBalancer.Name,
-		Name:    *loadBalancer.Name,
-		Deleter: g.deleteLoadBalancer,
+	return g.withARMID(&resources.Resource{
+		Obj:     nic,
+		Tags:    flattenTags(nic.Tags),
+		Type:    typeNetworkInterface,
+		ID:      *nic.Name,
+		Name:    *nic.Name,
+		Shared:  g.isSharedWithCluster(nic.Tags),
+		Async:   true,
+		Deleter: g.deleteNetworkInterface,
 		Blocks:  blocks,
-	}, nil
+		Blocked: blocked,
+	}, nic.ID), nil
 }
 
-func (g *resourceGetter) deleteLoadBalancer(_ fi.Cloud, r *resources.Resource) error {
-	return g.cloud.LoadBalancer().Delete(context.TODO(), g.resourceGroupName(), r.Name)
+func (g *resourceGetter) deleteNetworkInterface(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.NetworkInterface().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
 }
 
-func (g *resourceGetter) listPublicIPAddresses(ctx context.Context) ([]*resources.Resource, error) {
-	publicIPAddresses, err := g.cloud.PublicIPAddress().List(ctx, g.resourceGroupName())
+// listDisks lists the disks owned by the cluster: the disks attached to the VM
+// Scale Sets or virtual machines of the cluster, which may not be tagged yet,
+// and the detached disks tagged with the cluster. Disks attached to other VM
+// Scale Sets are never listed, whatever their tags.
+func (g *resourceGetter) listDisks(ctx context.Context) ([]*resources.Resource, error) {
+	vmsses, err := g.listVMScaleSets(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	var rs []*resources.Resource
-	for _, pip := range publicIPAddresses {
-		if !g.isOwnedByCluster(pip.Tags) {
+	clusterVMSSes := set.New[string]()
+	for _, vmss := range vmsses {
+		if !g.isOwnedByCluster(vmss.Tags) {
 			continue
 		}
-		rs = append(rs, g.toPublicIPAddressResource(pip))
+		clusterVMSSes.Insert(*vmss.Name)
 	}
-	return rs, nil
-}
-
+	vms, err := g.listVMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	clusterVMs := set.New[string]()
+	for _, vm := range vms {
+		if g.isOwnedByCluster(vm.Tags) {
+			clusterVMs.Insert(*vm.Name)
+		}
+	}
+
+	disks, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*compute.Disk, error) {
+		return g.cloud.Disk().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, disk := range disks {
+		if vmssName := diskManagedByVMScaleSet(disk); vmssName != "" {
+			if !clusterVMSSes.Has(vmssName) {
+				continue
+			}
+		} else if vmName := diskManagedByVirtualMachine(disk); vmName != "" && clusterVMs.Has(vmName) {
+			// The data disks of the virtual machines of the cluster may not
+			// have the tags of the cluster.
+		} else if !g.isOwnedByCluster(disk.Tags) {
+			continue
+		}
+		rs = append(rs, g.toDiskResource(disk, clusterVMSSes))
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) toDiskResource(disk *compute.Disk, clusterVMSSes set.Set[string]) *resources.Resource {
+	// A disk that is still attached can only be deleted after its VM Scale Set,
+	// when that VM Scale Set is deleted with the cluster.
+	var blocked []string
+	if vmssName := diskManagedByVMScaleSet(disk); clusterVMSSes.Has(vmssName) {
+		blocked = append(blocked, toKey(typeVMScaleSet, vmssName))
+	}
+	// A disk encrypted with customer-managed keys is deleted before its disk
+	// encryption set.
+	blocks := []string{toKey(typeResourceGroup, g.resourceGroupName())}
+	if props := disk.Properties; props != nil && props.Encryption != nil && props.Encryption.DiskEncryptionSetID != nil {
+		l := strings.Split(*props.Encryption.DiskEncryptionSetID, "/")
+		blocks = append(blocks, toKey(typeDiskEncryptionSet, l[len(l)-1]))
+	}
+
+	r := &resources.Resource{
+		Obj:     disk,
+		Tags:    flattenTags(disk.Tags),
+		Type:    typeDisk,
+		ID:      *disk.Name,
+		Name:    *disk.Name,
+		Shared:  g.isSharedWithCluster(disk.Tags),
+		Async:   true,
+		Deleter: g.deleteDisk,
+		Blocks:  blocks,
+		Blocked: blocked,
+	}
+	if disk.SKU != nil && disk.SKU.Name != nil {
+		setMetadata(r, metadataSKU, string(*disk.SKU.Name))
+	}
+	if props := disk.Properties; props != nil {
+		setMetadata(r, metadataTier, fi.ValueOf(props.Tier))
+		if props.DiskSizeGB != nil {
+			setMetadata(r, metadataSizeGB, strconv.Itoa(int(*props.DiskSizeGB)))
+		}
+	}
+	return g.withARMID(r, disk.ID)
+}
+
+// diskManagedByVMScaleSet returns the name of the VM Scale Set whose VM the
+// disk is attached to, or an empty string if the disk is not attached to a
+// VM Scale Set VM.
+func diskManagedByVMScaleSet(disk *compute.Disk) string {
+	if disk.ManagedBy == nil {
+		return ""
+	}
+	// The ID has the form
+	// /subscriptions/<sub>/resourceGroups/<rg>/providers/Microsoft.Compute/virtualMachineScaleSets/<vmss>/virtualMachines/<id>.
+	l := strings.Split(*disk.ManagedBy, "/")
+	for i := 0; i+1 < len(l); i++ {
+		if strings.EqualFold(l[i], "virtualMachineScaleSets") {
+			return l[i+1]
+		}
+	}
+	return ""
+}
+
+// diskManagedByVirtualMachine returns the name of the standalone virtual
+// machine that the disk is attached to, or an empty string if the disk is not
+// attached to one.
+func diskManagedByVirtualMachine(disk *compute.Disk) string {
+	if disk.ManagedBy == nil || diskManagedByVMScaleSet(disk) != "" {
+		return ""
+	}
+	// The ID has the form
+	// /subscriptions/<sub>/resourceGroups/<rg>/providers/Microsoft.Compute/virtualMachines/<vm>.
+	l := strings.Split(*disk.ManagedBy, "/")
+	for i := 0; i+1 < len(l); i++ {
+		if strings.EqualFold(l[i], "virtualMachines") {
+			return l[i+1]
+		}
+	}
+	return ""
+}
+
+func (g *resourceGetter) deleteDisk(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.Disk().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// listDiskEncryptionSets lists the disk encryption sets owned by the cluster.
+// They are created for clusters whose disks are encrypted with customer-managed
+// keys.
+func (g *resourceGetter) listDiskEncryptionSets(ctx context.Context) ([]*resources.Resource, error) {
+	diskEncryptionSets, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*compute.DiskEncryptionSet, error) {
+		return g.cloud.DiskEncryptionSet().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, des := range diskEncryptionSets {
+		if !g.isOwnedByCluster(des.Tags) {
+			continue
+		}
+		rs = append(rs, g.toDiskEncryptionSetResource(des))
+	}
+	return rs, nil
+}
+
+// toDiskEncryptionSetResource converts a disk encryption set to a resource. It
+// is deleted before the key vault holding its key.
+func (g *resourceGetter) toDiskEncryptionSetResource(des *compute.DiskEncryptionSet) *resources.Resource {
+	blocks := []string{toKey(typeResourceGroup, g.resourceGroupName())}
+	if props := des.Properties; props != nil && props.ActiveKey != nil && props.ActiveKey.SourceVault != nil && props.ActiveKey.SourceVault.ID != nil {
+		if key := privateEndpointTargetKey(*props.ActiveKey.SourceVault.ID); key != "" {
+			blocks = append(blocks, key)
+		}
+	}
+	return g.withARMID(&resources.Resource{
+		Obj:     des,
+		Tags:    flattenTags(des.Tags),
+		Type:    typeDiskEncryptionSet,
+		ID:      *des.Name,
+		Name:    *des.Name,
+		Shared:  g.isSharedWithCluster(des.Tags),
+		Async:   true,
+		Deleter: g.deleteDiskEncryptionSet,
+		Blocks:  blocks,
+	}, des.ID)
+}
+
+func (g *resourceGetter) deleteDiskEncryptionSet(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.DiskEncryptionSet().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// unownedRoleAssignment is a role assignment whose principal is that of a VM
+// Scale Set that exists in the resource group, but is not owned by the cluster.
+type unownedRoleAssignment struct {
+	roleAssignmentName string
+	vmScaleSetName     string
+}
+
+// listRoleAssignments lists the role assignments of the VM Scale Sets owned by the cluster.
+// It also returns the role assignments of the VM Scale Sets in unownedPrincipalIDs,
+// which are not deleted, so that the user can be told to fix the tagging.
+func (g *resourceGetter) listRoleAssignments(ctx context.Context, principalIDs map[string][]*compute.VirtualMachineScaleSet, unownedPrincipalIDs map[string]string) ([]*resources.Resource, []unownedRoleAssignment, error) {
+	// The role assignments granting access to other resource groups are made at
+	// the scope of the subscription.
+	scopes := []string{
+		fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", g.cloud.SubscriptionID(), g.resourceGroupName()),
+		fmt.Sprintf("/subscriptions/%s", g.cloud.SubscriptionID()),
+	}
+	// Only the role assignments of the known principals are listed, rather than
+	// all the role assignments of the subscription.
+	principals := set.New[string]()
+	for id := range principalIDs {
+		principals.Insert(id)
+	}
+	for id := range unownedPrincipalIDs {
+		principals.Insert(id)
+	}
+	var ras []*authz.RoleAssignment
+	for _, principalID := range principals.SortedList() {
+		for _, scope := range scopes {
+			l, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*authz.RoleAssignment, error) {
+				return g.cloud.RoleAssignment().ListAssignedTo(ctx, scope, principalID)
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			ras = append(ras, l...)
+		}
+	}
+
+	var rs []*resources.Resource
+	var unowned []unownedRoleAssignment
+	// Listing at a scope may return the role assignments of the scopes above it too.
+	seen := set.New[string]()
+	for _, ra := range ras {
+		// Add a Role Assignment to the slice if its principal ID is that of one of the VM Scale Sets.
+		if ra.Name == nil || ra.Properties == nil || ra.Properties.PrincipalID == nil {
+			continue
+		}
+		id := fi.ValueOf(ra.ID)
+		if id == "" {
+			id = *ra.Name
+		}
+		if seen.Has(id) {
+			continue
+		}
+		seen.Insert(id)
+		vmsses, ok := principalIDs[*ra.Properties.PrincipalID]
+		if !ok {
+			if vmssName, found := unownedPrincipalIDs[*ra.Properties.PrincipalID]; found {
+				unowned = append(unowned, unownedRoleAssignment{
+					roleAssignmentName: *ra.Name,
+					vmScaleSetName:     vmssName,
+				})
+			}
+			continue
+		}
+		rs = append(rs, g.toRoleAssignmentResource(ra, vmsses))
+	}
+	return rs, unowned, nil
+}
+
+func (g *resourceGetter) toRoleAssignmentResource(ra *authz.RoleAssignment, vmsses []*compute.VirtualMachineScaleSet) *resources.Resource {
+	var blocks []string
+	blocks = append(blocks, toKey(typeResourceGroup, g.resourceGroupName()))
+	// The role assignment is only deleted with a VM Scale Set owned by the cluster.
+	shared := len(vmsses) > 0
+	for _, vmss := range vmsses {
+		blocks = append(blocks, toKey(typeVMScaleSet, *vmss.Name))
+		if !g.isSharedWithCluster(vmss.Tags) {
+			shared = false
+		}
+	}
+
+	r := &resources.Resource{
+		Obj:     ra,
+		Type:    typeRoleAssignment,
+		ID:      *ra.Name,
+		Name:    *ra.Name,
+		Deleter: g.roleAssignmentDeleter(roleAssignmentScope(ra), *ra.Name),
+		Blocks:  blocks,
+		Shared:  shared,
+	}
+	if ra.Properties != nil && ra.Properties.Scope != nil {
+		setMetadata(r, metadataScope, roleAssignmentScopeLevel(*ra.Properties.Scope))
+	}
+	return g.withARMID(r, ra.ID)
+}
+
+// roleAssignmentScopeLevel returns the granularity of the scope of a role
+// assignment: "Subscription", "ResourceGroup", or the type of the resource it
+// applies to, e.g. "Disk".
+func roleAssignmentScopeLevel(scope string) string {
+	// The scope has the form /subscriptions/<sub>[/resourceGroups/<rg>[/providers/<namespace>/<type>/<name>...]].
+	l := strings.Split(strings.TrimSuffix(scope, "/"), "/")
+	switch {
+	case len(l) == 3 && strings.EqualFold(l[1], "subscriptions"):
+		return "Subscription"
+	case len(l) == 5 && strings.EqualFold(l[3], "resourceGroups"):
+		return "ResourceGroup"
+	case len(l) >= 9 && strings.EqualFold(l[5], "providers"):
+		armType := l[6] + "/" + l[7]
+		for rtype, t := range armResourceTypes {
+			if strings.EqualFold(t, armType) {
+				return rtype
+			}
+		}
+		return armType
+	case len(l) >= 5 && strings.EqualFold(l[1], "providers"):
+		// e.g. a management group.
+		return l[2] + "/" + l[3]
+	}
+	return ""
+}
+
+// roleAssignmentScope returns the full scope of a role assignment, from its ID
+// if the scope is not set, or an empty string if it is not known.
+func roleAssignmentScope(ra *authz.RoleAssignment) string {
+	if ra.Properties != nil && ra.Properties.Scope != nil {
+		return *ra.Properties.Scope
+	}
+	// The ID has the form <scope>/providers/Microsoft.Authorization/roleAssignments/<name>.
+	if ra.ID != nil {
+		if i := strings.LastIndex(strings.ToLower(*ra.ID), "/providers/microsoft.authorization/roleassignments/"); i > 0 {
+			return (*ra.ID)[:i]
+		}
+	}
+	return ""
+}
+
+// roleAssignmentDeleter returns a deleter for the role assignment with the given
+// scope and name, so that the deletion does not depend on the listed object.
+func (g *resourceGetter) roleAssignmentDeleter(scope, name string) func(fi.Cloud, *resources.Resource) error {
+	return func(_ fi.Cloud, _ *resources.Resource) error {
+		if scope == "" {
+			return fmt.Errorf("role assignment %q has no scope", name)
+		}
+		return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+			return g.cloud.RoleAssignment().Delete(ctx, scope, name)
+		})
+	}
+}
+
+func (g *resourceGetter) listLoadBalancers(ctx context.Context) ([]*resources.Resource, error) {
+	loadBalancers, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.LoadBalancer, error) {
+		return g.cloud.LoadBalancer().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, lb := range loadBalancers {
+		if !g.isOwnedByCluster(lb.Tags) {
+			continue
+		}
+		if rules := foreignLoadBalancingRules(lb); len(rules) > 0 {
+			klog.Warningf("Load balancer %q has rules not created by kops, which will be deleted with it: %v", *lb.Name, rules)
+		}
+		r, err := g.toLoadBalancerResource(lb)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) toLoadBalancerResource(loadBalancer *network.LoadBalancer) (*resources.Resource, error) {
+	var blocks []string
+	blocks = append(blocks, toKey(typeResourceGroup, g.resourceGroupName()))
+
+	pips := set.New[string]()
+	gateways := set.New[string]()
+	if loadBalancer.Properties != nil {
+		for _, fip := range loadBalancer.Properties.FrontendIPConfigurations {
+			if fip.Properties == nil {
+				continue
+			}
+			// A load balancer chained to a gateway load balancer must be deleted
+			// first, as the gateway cannot be deleted while it is referenced.
+			if gw := fip.Properties.GatewayLoadBalancer; gw != nil && gw.ID != nil {
+				gwID, err := azure.ParseLoadBalancerID(*gw.ID)
+				if err != nil {
+					return nil, fmt.Errorf("parsing gateway load balancer frontend IP configuration ID: %s", err)
+				}
+				if gwID.LoadBalancerName != *loadBalancer.Name {
+					gateways.Insert(gwID.LoadBalancerName)
+				}
+			}
+			if fip.Properties.PublicIPAddress == nil {
+				continue
+			}
+			pipID, err := azure.ParsePublicIPAddressID(*fip.Properties.PublicIPAddress.ID)
+			if err != nil {
+				return nil, fmt.Errorf("parsing public IP address ID: %s", err)
+			}
+			pips.Insert(pipID.PublicIPAddressName)
+		}
+	}
+	for pip := range pips {
+		blocks = append(blocks, toKey(typePublicIPAddress, pip))
+	}
+	for gateway := range gateways {
+		blocks = append(blocks, toKey(typeLoadBalancer, gateway))
+	}
+
+	r := g.withARMID(&resources.Resource{
+		Obj:     loadBalancer,
+		Tags:    flattenTags(loadBalancer.Tags),
+		Type:    typeLoadBalancer,
+		ID:      *loadBalancer.Name,
+		Name:    *loadBalancer.Name,
+		Shared:  g.isSharedWithCluster(loadBalancer.Tags),
+		Async:   true,
+		Deleter: g.deleteLoadBalancer,
+		Blocks:  blocks,
+	}, loadBalancer.ID)
+	if sku := loadBalancer.SKU; sku != nil && sku.Name != nil {
+		setMetadata(r, metadataSKU, string(*sku.Name))
+	}
+	return r, nil
+}
+
+func (g *resourceGetter) deleteLoadBalancer(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.LoadBalancer().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+func (g *resourceGetter) listPublicIPAddresses(ctx context.Context) ([]*resources.Resource, error) {
+	publicIPAddresses, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.PublicIPAddress, error) {
+		return g.cloud.PublicIPAddress().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, pip := range publicIPAddresses {
+		if !g.isOwnedByCluster(pip.Tags) {
+			continue
+		}
+		rs = append(rs, g.toPublicIPAddressResource(pip))
+	}
+	return rs, nil
+}
+
 func (g *resourceGetter) toPublicIPAddressResource(publicIPAddress *network.PublicIPAddress) *resources.Resource {
-	return &resources.Resource{
+	r := &resources.Resource{
 		Obj:     publicIPAddress,
+		Tags:    flattenTags(publicIPAddress.Tags),
 		Type:    typePublicIPAddress,
 		ID:      *publicIPAddress.Name,
 		Name:    *publicIPAddress.Name,
+		Shared:  g.isSharedWithCluster(publicIPAddress.Tags),
+		Async:   true,
 		Deleter: g.deletePublicIPAddress,
 		Blocks:  []string{toKey(typeResourceGroup, g.resourceGroupName())},
 	}
+	setMetadata(r, metadataAssociation, publicIPAddressAssociation(publicIPAddress))
+	if sku := publicIPAddress.SKU; sku != nil {
+		if sku.Name != nil {
+			setMetadata(r, metadataSKU, string(*sku.Name))
+		}
+		if sku.Tier != nil {
+			setMetadata(r, metadataTier, string(*sku.Tier))
+		}
+	}
+	return g.withARMID(r, publicIPAddress.ID)
+}
+
+// publicIPAddressAssociation returns the type of the resource that the public
+// IP address is attached to, or an empty string if it is not attached.
+func publicIPAddressAssociation(publicIPAddress *network.PublicIPAddress) string {
+	props := publicIPAddress.Properties
+	if props == nil {
+		return ""
+	}
+	if props.NatGateway != nil {
+		return typeNatGateway
+	}
+	if props.IPConfiguration == nil || props.IPConfiguration.ID == nil {
+		return ""
+	}
+	ownerTypes := map[string]string{
+		"microsoft.network/loadbalancers":           typeLoadBalancer,
+		"microsoft.network/networkinterfaces":       "NetworkInterface",
+		"microsoft.compute/virtualmachinescalesets": "NetworkInterface",
+		"microsoft.network/virtualnetworkgateways":  "VirtualNetworkGateway",
+		"microsoft.network/applicationgateways":     "ApplicationGateway",
+		"microsoft.network/azurefirewalls":          "AzureFirewall",
+		"microsoft.network/bastionhosts":            "BastionHost",
+	}
+	// The ID has the form
+	// /subscriptions/<sub>/resourceGroups/<rg>/providers/<namespace>/<type>/<name>/...,
+	// where the IP configuration of a VM Scale Set instance is nested under its
+	// network interface.
+	l := strings.Split(*props.IPConfiguration.ID, "/")
+	if len(l) < 9 || !strings.EqualFold(l[5], "providers") {
+		return ""
+	}
+	if ownerType, ok := ownerTypes[strings.ToLower(l[6]+"/"+l[7])]; ok {
+		return ownerType
+	}
+	return l[7]
 }
 
 func (g *resourceGetter) deletePublicIPAddress(_ fi.Cloud, r *resources.Resource) error {
-	return g.cloud.PublicIPAddress().Delete(context.TODO(), g.resourceGroupName(), r.Name)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.PublicIPAddress().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
 }
 
 func (g *resourceGetter) listNatGateways(ctx context.Context) ([]*resources.Resource, error) {
-	natGateways, err := g.cloud.NatGateway().List(ctx, g.resourceGroupName())
+	natGateways, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.NatGateway, error) {
+		return g.cloud.NatGateway().List(ctx, g.resourceGroupName())
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -676,28 +2353,772 @@ func (g *resourceGetter) toNatGatewayResource(natGateway *network.NatGateway) (*
 		blocks = append(blocks, toKey(typePublicIPAddress, pip))
 	}
 
-	return &resources.Resource{
+	return g.withARMID(&resources.Resource{
 		Obj:     natGateway,
+		Tags:    flattenTags(natGateway.Tags),
 		Type:    typeNatGateway,
 		ID:      *natGateway.ID,
 		Name:    *natGateway.Name,
+		Shared:  g.isSharedWithCluster(natGateway.Tags),
+		Async:   true,
 		Deleter: g.deleteNatGateway,
 		Blocks:  blocks,
-	}, nil
+	}, natGateway.ID), nil
 }
 
 func (g *resourceGetter) deleteNatGateway(_ fi.Cloud, r *resources.Resource) error {
-	return g.cloud.NatGateway().Delete(context.TODO(), g.resourceGroupName(), r.Name)
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.NatGateway().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// listApplicationGateways lists the application gateways of the cluster, like
+// those fronting its ingress. Their backend pools and listeners go with them.
+func (g *resourceGetter) listApplicationGateways(ctx context.Context) ([]*resources.Resource, error) {
+	applicationGateways, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.ApplicationGateway, error) {
+		return g.cloud.ApplicationGateway().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, agw := range applicationGateways {
+		if !g.isOwnedByCluster(agw.Tags) {
+			continue
+		}
+		r, err := g.toApplicationGatewayResource(agw)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) toApplicationGatewayResource(applicationGateway *network.ApplicationGateway) (*resources.Resource, error) {
+	var blocks []string
+	blocks = append(blocks, toKey(typeResourceGroup, g.resourceGroupName()))
+
+	// The gateway lives in a subnet of its own, and its frontends may use a
+	// public IP address or a private IP address of another subnet.
+	subnets := set.New[string]()
+	pips := set.New[string]()
+	addSubnet := func(subnet *network.SubResource) error {
+		if subnet == nil || subnet.ID == nil {
+			return nil
+		}
+		subnetID, err := azure.ParseSubnetID(*subnet.ID)
+		if err != nil {
+			return fmt.Errorf("parsing subnet ID: %w", err)
+		}
+		subnets.Insert(subnetID.SubnetName)
+		return nil
+	}
+	if props := applicationGateway.Properties; props != nil {
+		for _, ipConfig := range props.GatewayIPConfigurations {
+			if ipConfig.Properties == nil {
+				continue
+			}
+			if err := addSubnet(ipConfig.Properties.Subnet); err != nil {
+				return nil, err
+			}
+		}
+		for _, ipConfig := range props.FrontendIPConfigurations {
+			if ipConfig.Properties == nil {
+				continue
+			}
+			if err := addSubnet(ipConfig.Properties.Subnet); err != nil {
+				return nil, err
+			}
+			if pip := ipConfig.Properties.PublicIPAddress; pip != nil && pip.ID != nil {
+				pipID, err := azure.ParsePublicIPAddressID(*pip.ID)
+				if err != nil {
+					return nil, fmt.Errorf("parsing public IP address ID: %w", err)
+				}
+				pips.Insert(pipID.PublicIPAddressName)
+			}
+		}
+	}
+	for _, subnet := range subnets.SortedList() {
+		blocks = append(blocks, toKey(typeSubnet, subnet))
+	}
+	for _, pip := range pips.SortedList() {
+		blocks = append(blocks, toKey(typePublicIPAddress, pip))
+	}
+
+	return g.withARMID(&resources.Resource{
+		Obj:     applicationGateway,
+		Tags:    flattenTags(applicationGateway.Tags),
+		Type:    typeApplicationGateway,
+		ID:      *applicationGateway.Name,
+		Name:    *applicationGateway.Name,
+		Shared:  g.isSharedWithCluster(applicationGateway.Tags),
+		Async:   true,
+		Deleter: g.deleteApplicationGateway,
+		Blocks:  blocks,
+	}, applicationGateway.ID), nil
+}
+
+func (g *resourceGetter) deleteApplicationGateway(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.ApplicationGateway().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// listSnapshots lists the snapshots owned by the cluster, as well as the snapshots
+// whose source disk is owned by the cluster. The latter are typically created by
+// the Azure Disk CSI driver for VolumeSnapshots and only carry PV/PVC tags.
+// Snapshots are only listed when the user opted in to deleting them.
+func (g *resourceGetter) listSnapshots(ctx context.Context) ([]*resources.Resource, error) {
+	if !g.clusterInfo.AzureDeleteSnapshots {
+		return nil, nil
+	}
+
+	disks, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*compute.Disk, error) {
+		return g.cloud.Disk().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Disks are matched by their full ID, as the CSI driver may snapshot a
+	// same-named disk in another resource group or subscription.
+	clusterDisks := set.New[string]()
+	// diskNames holds the names of the disks still present, by ID.
+	diskNames := make(map[string]string)
+	for _, disk := range disks {
+		diskID := &azure.DiskID{
+			SubscriptionID:    g.cloud.SubscriptionID(),
+			ResourceGroupName: g.resourceGroupName(),
+			DiskName:          *disk.Name,
+		}
+		if disk.ID != nil {
+			parsed, err := azure.ParseDiskID(*disk.ID)
+			if err != nil {
+				return nil, fmt.Errorf("parsing disk ID: %w", err)
+			}
+			diskID = parsed
+		}
+		diskNames[diskIDKey(diskID)] = *disk.Name
+		if g.isOwnedByCluster(disk.Tags) {
+			clusterDisks.Insert(diskIDKey(diskID))
+		}
+	}
+
+	snapshots, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*compute.Snapshot, error) {
+		return g.cloud.Snapshot().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, snapshot := range snapshots {
+		sourceDiskID := diskIDKey(snapshotSourceDiskID(snapshot))
+		if !g.isOwnedByCluster(snapshot.Tags) && !clusterDisks.Has(sourceDiskID) {
+			continue
+		}
+		rs = append(rs, g.toSnapshotResource(snapshot, diskNames[sourceDiskID]))
+	}
+	return rs, nil
+}
+
+// toSnapshotResource converts a snapshot to a resource. If sourceDisk is not
+// empty, the snapshot is deleted before the disk it was created from.
+func (g *resourceGetter) toSnapshotResource(snapshot *compute.Snapshot, sourceDisk string) *resources.Resource {
+	blocks := []string{toKey(typeResourceGroup, g.resourceGroupName())}
+	if sourceDisk != "" {
+		blocks = append(blocks, toKey(typeDisk, sourceDisk))
+	}
+	return g.withARMID(&resources.Resource{
+		Obj:     snapshot,
+		Tags:    flattenTags(snapshot.Tags),
+		Type:    typeSnapshot,
+		ID:      *snapshot.Name,
+		Name:    *snapshot.Name,
+		Shared:  g.isSharedWithCluster(snapshot.Tags),
+		Async:   true,
+		Deleter: g.deleteSnapshot,
+		Blocks:  blocks,
+	}, snapshot.ID)
+}
+
+func (g *resourceGetter) deleteSnapshot(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.Snapshot().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// snapshotSourceDiskID returns the ID of the managed disk the snapshot was
+// created from, or nil if the snapshot was not created from a disk.
+func snapshotSourceDiskID(snapshot *compute.Snapshot) *azure.DiskID {
+	if snapshot.Properties == nil || snapshot.Properties.CreationData == nil || snapshot.Properties.CreationData.SourceResourceID == nil {
+		return nil
+	}
+	id := *snapshot.Properties.CreationData.SourceResourceID
+	if !strings.Contains(strings.ToLower(id), "/providers/microsoft.compute/disks/") {
+		return nil
+	}
+	diskID, err := azure.ParseDiskID(id)
+	if err != nil {
+		return nil
+	}
+	return diskID
+}
+
+// diskIDKey returns a key identifying the disk across subscriptions and
+// resource groups. ARM IDs are case-insensitive.
+func diskIDKey(diskID *azure.DiskID) string {
+	if diskID == nil {
+		return ""
+	}
+	return strings.ToLower(diskID.String())
+}
+
+// listStorageAccounts lists the storage accounts owned by the cluster, except
+// the one holding the kops state store. They are only listed when the user
+// opted in to deleting storage.
+func (g *resourceGetter) listStorageAccounts(ctx context.Context) ([]*resources.Resource, error) {
+	if !g.clusterInfo.AzureDeleteStorage {
+		return nil, nil
+	}
+
+	storageAccounts, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*armstorage.Account, error) {
+		return g.cloud.StorageAccount().ListByResourceGroup(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, sa := range storageAccounts {
+		if !g.isOwnedByCluster(sa.Tags) {
+			continue
+		}
+		if *sa.Name == g.clusterInfo.AzureStateStoreAccount {
+			klog.Warningf("Storage account %q holds the kops state store; it will not be deleted", *sa.Name)
+			continue
+		}
+		rs = append(rs, g.toStorageAccountResource(sa))
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) toStorageAccountResource(storageAccount *armstorage.Account) *resources.Resource {
+	return g.withARMID(&resources.Resource{
+		Obj:     storageAccount,
+		Tags:    flattenTags(storageAccount.Tags),
+		Type:    typeStorageAccount,
+		ID:      *storageAccount.Name,
+		Name:    *storageAccount.Name,
+		Shared:  g.isSharedWithCluster(storageAccount.Tags),
+		Deleter: g.deleteStorageAccount,
+		Blocks:  []string{toKey(typeResourceGroup, g.resourceGroupName())},
+	}, storageAccount.ID)
+}
+
+func (g *resourceGetter) deleteStorageAccount(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.StorageAccount().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// listKeyVaults lists the key vaults owned by the cluster. They are only listed
+// when the user opted in to deleting storage. kops does not manage key vaults,
+// so they are found and deleted through the generic resources client.
+func (g *resourceGetter) listKeyVaults(ctx context.Context) ([]*resources.Resource, error) {
+	if !g.clusterInfo.AzureDeleteStorage {
+		return nil, nil
+	}
+
+	rgResources, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azureresources.GenericResourceExpanded, error) {
+		return g.cloud.Resource().ListByResourceGroup(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, r := range rgResources {
+		if r.Type == nil || !strings.EqualFold(*r.Type, keyVaultResourceType) {
+			continue
+		}
+		if !g.isOwnedByCluster(r.Tags) {
+			continue
+		}
+		rs = append(rs, g.toKeyVaultResource(r))
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) toKeyVaultResource(keyVault *azureresources.GenericResourceExpanded) *resources.Resource {
+	// The location is needed to purge the key vault, after its object may have
+	// been dropped.
+	location := fi.ValueOf(keyVault.Location)
+	return g.withARMID(&resources.Resource{
+		Obj:    keyVault,
+		Tags:   flattenTags(keyVault.Tags),
+		Type:   typeKeyVault,
+		ID:     *keyVault.Name,
+		Name:   *keyVault.Name,
+		Shared: g.isSharedWithCluster(keyVault.Tags),
+		Async:  true,
+		Deleter: func(cloud fi.Cloud, r *resources.Resource) error {
+			return g.deleteKeyVault(r, location)
+		},
+		Blocks: []string{toKey(typeResourceGroup, g.resourceGroupName())},
+	}, keyVault.ID)
+}
+
+// deleteKeyVault deletes the key vault. Key vaults are soft-deleted by Azure,
+// and are purged once their retention period has passed, or right away if
+// AzurePurgeKeyVault is set.
+func (g *resourceGetter) deleteKeyVault(r *resources.Resource, location string) error {
+	if err := retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.Resource().DeleteByID(ctx, g.armID(r), keyVaultAPIVersion)
+	}); err != nil {
+		return err
+	}
+	if !g.clusterInfo.AzurePurgeKeyVault {
+		return nil
+	}
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.KeyVault().PurgeDeleted(ctx, location, r.Name)
+	})
+}
+
+// listRecoveryServicesVaults lists the Recovery Services vaults owned by the
+// cluster. They hold the backups taken by Azure Backup, so they are only listed
+// when the user opted in to deleting backups.
+func (g *resourceGetter) listRecoveryServicesVaults(ctx context.Context) ([]*resources.Resource, error) {
+	if !g.clusterInfo.AzureDeleteBackups {
+		return nil, nil
+	}
+
+	rgResources, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azureresources.GenericResourceExpanded, error) {
+		return g.cloud.Resource().ListByResourceGroup(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, r := range rgResources {
+		if r.Type == nil || !strings.EqualFold(*r.Type, recoveryServicesVaultResourceType) {
+			continue
+		}
+		if !g.isOwnedByCluster(r.Tags) {
+			continue
+		}
+		rs = append(rs, g.toRecoveryServicesVaultResource(r))
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) toRecoveryServicesVaultResource(vault *azureresources.GenericResourceExpanded) *resources.Resource {
+	return g.withARMID(&resources.Resource{
+		Obj:     vault,
+		Tags:    flattenTags(vault.Tags),
+		Type:    typeRecoveryServicesVault,
+		ID:      *vault.Name,
+		Name:    *vault.Name,
+		Shared:  g.isSharedWithCluster(vault.Tags),
+		Async:   true,
+		Deleter: g.deleteRecoveryServicesVault,
+		Blocks:  []string{toKey(typeResourceGroup, g.resourceGroupName())},
+	}, vault.ID)
+}
+
+// deleteRecoveryServicesVault deletes the backup items of the vault, then the
+// vault. Azure refuses to delete a vault that still holds backup items.
+func (g *resourceGetter) deleteRecoveryServicesVault(_ fi.Cloud, r *resources.Resource) error {
+	ctx := g.context()
+	vaultID := g.armID(r)
+	items, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azure.BackupItem, error) {
+		return g.cloud.BackupItem().List(ctx, vaultID)
+	})
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.ID == nil {
+			continue
+		}
+		klog.V(2).Infof("Deleting backup item %q of Recovery Services vault %q", *item.ID, r.Name)
+		if err := retryOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) error {
+			return g.cloud.BackupItem().Delete(ctx, *item.ID)
+		}); err != nil {
+			return err
+		}
+	}
+	return retryOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.Resource().DeleteByID(ctx, vaultID, recoveryServicesVaultAPIVersion)
+	})
+}
+
+func (g *resourceGetter) listManagedIdentities(ctx context.Context) ([]*resources.Resource, error) {
+	identities, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azure.ManagedIdentity, error) {
+		return g.cloud.ManagedIdentity().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, identity := range identities {
+		if !g.isOwnedByCluster(identity.Tags) {
+			continue
+		}
+		rs = append(rs, g.toManagedIdentityResource(identity))
+	}
+	return rs, nil
 }
 
-// isOwnedByCluster returns true if the resource is owned by the cluster.
+func (g *resourceGetter) toManagedIdentityResource(identity *azure.ManagedIdentity) *resources.Resource {
+	return g.withARMID(&resources.Resource{
+		Obj:     identity,
+		Tags:    flattenTags(identity.Tags),
+		Type:    typeManagedIdentity,
+		ID:      *identity.Name,
+		Name:    *identity.Name,
+		Shared:  g.isSharedWithCluster(identity.Tags),
+		Deleter: g.deleteManagedIdentity,
+		Blocks:  []string{toKey(typeResourceGroup, g.resourceGroupName())},
+	}, identity.ID)
+}
+
+func (g *resourceGetter) deleteManagedIdentity(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.ManagedIdentity().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// listPrivateDNSZones lists the private DNS zones of the cluster, like the one
+// resolving the API server, along with their record sets and virtual network
+// links. The record sets and links of a shared zone are kept with it.
+func (g *resourceGetter) listPrivateDNSZones(ctx context.Context) ([]*resources.Resource, error) {
+	zones, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azure.PrivateDNSZone, error) {
+		return g.cloud.PrivateDNSZone().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, zone := range zones {
+		if !g.isOwnedByCluster(zone.Tags) {
+			continue
+		}
+		r := g.toPrivateDNSZoneResource(zone)
+		rs = append(rs, r)
+		if r.Shared {
+			continue
+		}
+
+		recordSets, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azure.PrivateDNSRecordSet, error) {
+			return g.cloud.PrivateDNSZone().ListRecordSets(ctx, g.resourceGroupName(), *zone.Name)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing record sets of private DNS zone %q: %w", *zone.Name, err)
+		}
+		for _, recordSet := range recordSets {
+			// The SOA record set cannot be deleted, and goes with the zone.
+			if recordType := privateDNSRecordType(recordSet); recordType != "" && recordType != "SOA" {
+				rs = append(rs, g.toPrivateDNSRecordSetResource(*zone.Name, recordType, recordSet))
+			}
+		}
+
+		links, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azure.PrivateDNSVirtualNetworkLink, error) {
+			return g.cloud.PrivateDNSZone().ListVirtualNetworkLinks(ctx, g.resourceGroupName(), *zone.Name)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing virtual network links of private DNS zone %q: %w", *zone.Name, err)
+		}
+		for _, link := range links {
+			rs = append(rs, g.toPrivateDNSZoneLinkResource(*zone.Name, link))
+		}
+	}
+	return rs, nil
+}
+
+// privateDNSRecordType returns the type of the record set, like A, from its
+// resource type, or "" if it is unknown.
+func privateDNSRecordType(recordSet *azure.PrivateDNSRecordSet) string {
+	if recordSet.Type == nil {
+		return ""
+	}
+	l := strings.Split(*recordSet.Type, "/")
+	return l[len(l)-1]
+}
+
+func (g *resourceGetter) toPrivateDNSZoneResource(zone *azure.PrivateDNSZone) *resources.Resource {
+	return g.withARMID(&resources.Resource{
+		Obj:     zone,
+		Tags:    flattenTags(zone.Tags),
+		Type:    typePrivateDNSZone,
+		ID:      *zone.Name,
+		Name:    *zone.Name,
+		Shared:  g.isSharedWithCluster(zone.Tags),
+		Async:   true,
+		Deleter: g.deletePrivateDNSZone,
+		Blocks:  []string{toKey(typeResourceGroup, g.resourceGroupName())},
+	}, zone.ID)
+}
+
+func (g *resourceGetter) deletePrivateDNSZone(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.PrivateDNSZone().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// toPrivateDNSRecordSetResource returns the resource of a record set, whose ID
+// is made of the zone name, the record type and the record set name, as record
+// sets of different zones or types can have the same name.
+func (g *resourceGetter) toPrivateDNSRecordSetResource(zoneName, recordType string, recordSet *azure.PrivateDNSRecordSet) *resources.Resource {
+	return g.withARMID(&resources.Resource{
+		Obj:     recordSet,
+		Type:    typePrivateDNSRecordSet,
+		ID:      strings.Join([]string{zoneName, recordType, *recordSet.Name}, "/"),
+		Name:    *recordSet.Name,
+		Deleter: g.deletePrivateDNSRecordSet,
+		Blocks:  []string{toKey(typePrivateDNSZone, zoneName)},
+	}, recordSet.ID)
+}
+
+func (g *resourceGetter) deletePrivateDNSRecordSet(_ fi.Cloud, r *resources.Resource) error {
+	l := strings.SplitN(r.ID, "/", 3)
+	if len(l) != 3 {
+		return fmt.Errorf("unexpected private DNS record set ID %q", r.ID)
+	}
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.PrivateDNSZone().DeleteRecordSet(ctx, g.resourceGroupOf(r), l[0], l[1], l[2])
+	})
+}
+
+// toPrivateDNSZoneLinkResource returns the resource of a virtual network link,
+// whose ID is made of the zone name and the link name. A zone cannot be deleted
+// while it has links, and the link goes before the virtual network too.
+func (g *resourceGetter) toPrivateDNSZoneLinkResource(zoneName string, link *azure.PrivateDNSVirtualNetworkLink) *resources.Resource {
+	blocks := []string{toKey(typePrivateDNSZone, zoneName)}
+	if link.Properties != nil && link.Properties.VirtualNetwork != nil && link.Properties.VirtualNetwork.ID != nil {
+		l := strings.Split(*link.Properties.VirtualNetwork.ID, "/")
+		blocks = append(blocks, toKey(typeVirtualNetwork, l[len(l)-1]))
+	}
+	return g.withARMID(&resources.Resource{
+		Obj:     link,
+		Tags:    flattenTags(link.Tags),
+		Type:    typePrivateDNSZoneLink,
+		ID:      zoneName + "/" + *link.Name,
+		Name:    *link.Name,
+		Async:   true,
+		Deleter: g.deletePrivateDNSZoneLink,
+		Blocks:  blocks,
+	}, link.ID)
+}
+
+func (g *resourceGetter) deletePrivateDNSZoneLink(_ fi.Cloud, r *resources.Resource) error {
+	zoneName, _, ok := strings.Cut(r.ID, "/")
+	if !ok {
+		return fmt.Errorf("unexpected private DNS zone link ID %q", r.ID)
+	}
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.PrivateDNSZone().DeleteVirtualNetworkLink(ctx, g.resourceGroupOf(r), zoneName, r.Name)
+	})
+}
+
+// listNetworkWatchers lists the network watchers in the resource group, which
+// Azure creates on its own without the tags of the cluster. They are only listed
+// if the resource group is deleted with the cluster and AzureIncludeSystemResources
+// is set, so that nothing is left in the resource group.
+func (g *resourceGetter) listNetworkWatchers(ctx context.Context) ([]*resources.Resource, error) {
+	if !g.clusterInfo.AzureIncludeSystemResources || g.clusterInfo.AzureResourceGroupShared || g.clusterInfo.AzurePreserveResourceGroup {
+		return nil, nil
+	}
+
+	rgResources, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azureresources.GenericResourceExpanded, error) {
+		return g.cloud.Resource().ListByResourceGroup(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, r := range rgResources {
+		if r.Type == nil || !strings.EqualFold(*r.Type, networkWatcherResourceType) {
+			continue
+		}
+		rs = append(rs, g.withARMID(&resources.Resource{
+			Obj:  r,
+			Tags: flattenTags(r.Tags),
+			Type: typeNetworkWatcher,
+			ID:   *r.Name,
+			Name: *r.Name,
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+					return g.cloud.Resource().DeleteByID(ctx, g.armID(r), networkWatcherAPIVersion)
+				})
+			},
+			Blocks: []string{toKey(typeResourceGroup, g.resourceGroupName())},
+		}, r.ID))
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) listPrivateEndpoints(ctx context.Context) ([]*resources.Resource, error) {
+	privateEndpoints, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*network.PrivateEndpoint, error) {
+		return g.cloud.PrivateEndpoint().List(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []*resources.Resource
+	for _, pe := range privateEndpoints {
+		if !g.isOwnedByCluster(pe.Tags) {
+			continue
+		}
+		r, err := g.toPrivateEndpointResource(pe)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func (g *resourceGetter) toPrivateEndpointResource(privateEndpoint *network.PrivateEndpoint) (*resources.Resource, error) {
+	var blocks []string
+	blocks = append(blocks, toKey(typeResourceGroup, g.resourceGroupName()))
+
+	if privateEndpoint.Properties != nil && privateEndpoint.Properties.Subnet != nil && privateEndpoint.Properties.Subnet.ID != nil {
+		subnetID, err := azure.ParseSubnetID(*privateEndpoint.Properties.Subnet.ID)
+		if err != nil {
+			return nil, fmt.Errorf("parsing subnet ID: %w", err)
+		}
+		blocks = append(blocks, toKey(typeSubnet, subnetID.SubnetName))
+	}
+
+	return g.withARMID(&resources.Resource{
+		Obj:     privateEndpoint,
+		Tags:    flattenTags(privateEndpoint.Tags),
+		Type:    typePrivateEndpoint,
+		ID:      *privateEndpoint.Name,
+		Name:    *privateEndpoint.Name,
+		Shared:  g.isSharedWithCluster(privateEndpoint.Tags),
+		Async:   true,
+		Deleter: g.deletePrivateEndpoint,
+		Blocks:  blocks,
+	}, privateEndpoint.ID), nil
+}
+
+func (g *resourceGetter) deletePrivateEndpoint(_ fi.Cloud, r *resources.Resource) error {
+	return retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+		return g.cloud.PrivateEndpoint().Delete(ctx, g.resourceGroupOf(r), r.Name)
+	})
+}
+
+// linkPrivateEndpoints makes each private endpoint block the deletion of the
+// resources it connects to, when those resources are deleted with the cluster.
+// Azure refuses to delete a Key Vault or Storage Account that still has
+// private endpoint connections.
+func linkPrivateEndpoints(resourceMap map[string]*resources.Resource) {
+	for _, r := range resourceMap {
+		pe, ok := r.Obj.(*network.PrivateEndpoint)
+		if !ok || pe.Properties == nil {
+			continue
+		}
+		var conns []*network.PrivateLinkServiceConnection
+		conns = append(conns, pe.Properties.PrivateLinkServiceConnections...)
+		conns = append(conns, pe.Properties.ManualPrivateLinkServiceConnections...)
+		for _, conn := range conns {
+			if conn == nil || conn.Properties == nil || conn.Properties.PrivateLinkServiceID == nil {
+				continue
+			}
+			key := privateEndpointTargetKey(*conn.Properties.PrivateLinkServiceID)
+			if key == "" {
+				continue
+			}
+			if _, ok := resourceMap[key]; !ok {
+				continue
+			}
+			r.Blocks = append(r.Blocks, key)
+		}
+	}
+}
+
+// privateEndpointTargetKey returns the resource key of the Storage Account or
+// Key Vault with the given ID, or an empty string for any other resource.
+func privateEndpointTargetKey(id string) string {
+	targetTypes := map[string]string{
+		"microsoft.storage/storageaccounts": typeStorageAccount,
+		"microsoft.keyvault/vaults":         typeKeyVault,
+	}
+	// The ID has the form
+	// /subscriptions/<sub>/resourceGroups/<rg>/providers/<namespace>/<type>/<name>.
+	l := strings.Split(id, "/")
+	if len(l) != 9 || !strings.EqualFold(l[5], "providers") {
+		return ""
+	}
+	rtype, ok := targetTypes[strings.ToLower(l[6]+"/"+l[7])]
+	if !ok {
+		return ""
+	}
+	return toKey(rtype, l[8])
+}
+
+// isOwnedByCluster returns true if the resource is tagged as belonging to the
+// cluster: either its cluster tag is set to the cluster name, or its ownership
+// tag of the cluster is set to "owned" or "shared". A cluster tag set to "owned"
+// or "shared" does not name the cluster, so it is not enough. Resources shared
+// with the cluster are listed, but never deleted.
 func (g *resourceGetter) isOwnedByCluster(tags map[string]*string) bool {
-	for k, v := range tags {
-		if k == azure.TagClusterName && *v == g.clusterInfo.Name {
-			return true
+	switch g.clusterOwnershipTagValue(tags) {
+	case clusterTagValueOwned, clusterTagValueShared:
+		return true
+	}
+	v, ok := g.clusterTagValue(tags)
+	return ok && v == g.clusterInfo.Name
+}
+
+// isSharedWithCluster returns true if the resource is tagged as shared with the
+// cluster, rather than owned by it.
+func (g *resourceGetter) isSharedWithCluster(tags map[string]*string) bool {
+	if g.clusterOwnershipTagValue(tags) == clusterTagValueShared {
+		return true
+	}
+	v, ok := g.clusterTagValue(tags)
+	return ok && v == clusterTagValueShared
+}
+
+// clusterOwnershipTagValue returns the value of the ownership tag of the cluster,
+// or "" if the resource does not have it.
+func (g *resourceGetter) clusterOwnershipTagValue(tags map[string]*string) string {
+	if v := tags[azure.TagNameClusterOwnershipPrefix+g.clusterInfo.Name]; v != nil {
+		return *v
+	}
+	return ""
+}
+
+// clusterTagValue returns the value of the cluster tag of the resource. The
+// legacy tag keys are checked too, for resources created by older kops versions.
+func (g *resourceGetter) clusterTagValue(tags map[string]*string) (string, bool) {
+	for _, key := range append([]string{azure.TagClusterName}, g.clusterInfo.AzureLegacyTagKeys...) {
+		if v, ok := tags[key]; ok && v != nil {
+			return *v, true
 		}
 	}
-	return false
+	return "", false
+}
+
+// setMetadata sets the metadata of the resource under the key, unless the value is empty.
+func setMetadata(r *resources.Resource, key, value string) {
+	if value == "" {
+		return
+	}
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]string)
+	}
+	r.Metadata[key] = value
 }
 
 func toKey(rtype, id string) string {
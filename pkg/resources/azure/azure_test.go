@@ -17,15 +17,26 @@ limitations under the License.
 package azure
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"reflect"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	authz "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
 	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/textlogger"
 	"k8s.io/kops/pkg/resources"
 	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
 	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
@@ -268,50 +279,4085 @@ func TestListResourcesAzure(t *testing.T) {
 	}
 }
 
+func TestVMScaleSetBlocksListedDisks(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		vmssName    = "nodes"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	subnetID := azure.SubnetID{
+		SubscriptionID:     "sid",
+		ResourceGroupName:  rgName,
+		VirtualNetworkName: "vnet",
+		SubnetName:         "subnet",
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VMScaleSetsClient.VMSSes[vmssName] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr(vmssName),
+		Tags: clusterTags,
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
+					NetworkInterfaceConfigurations: []*compute.VirtualMachineScaleSetNetworkConfiguration{
+						{
+							Properties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+								IPConfigurations: []*compute.VirtualMachineScaleSetIPConfiguration{
+									{
+										Properties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+											Subnet: &compute.APIEntityReference{ID: to.Ptr(subnetID.String())},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	vms := cloud.VMScaleSetVMsClient.VMs
+	vms[vmssName+"/0"] = &compute.VirtualMachineScaleSetVM{
+		Properties: &compute.VirtualMachineScaleSetVMProperties{
+			StorageProfile: &compute.StorageProfile{
+				DataDisks: []*compute.DataDisk{{Name: to.Ptr("data-0")}},
+			},
+		},
+	}
+	// A data disk known only by the ID of its managed disk.
+	vms[vmssName+"/1"] = &compute.VirtualMachineScaleSetVM{
+		Properties: &compute.VirtualMachineScaleSetVMProperties{
+			StorageProfile: &compute.StorageProfile{
+				DataDisks: []*compute.DataDisk{
+					{
+						ManagedDisk: &compute.ManagedDiskParameters{
+							ID: to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Compute/disks/data-1", rgName)),
+						},
+					},
+				},
+			},
+		},
+	}
+	// VMs being created may not have a storage profile yet.
+	vms[vmssName+"/2"] = &compute.VirtualMachineScaleSetVM{
+		Properties: &compute.VirtualMachineScaleSetVMProperties{},
+	}
+	vms[vmssName+"/3"] = &compute.VirtualMachineScaleSetVM{}
+	for _, name := range []string{"data-0", "data-1"} {
+		cloud.DisksClient.Disks[name] = &compute.Disk{
+			Name: to.Ptr(name),
+			Tags: clusterTags,
+		}
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	vmss, ok := rs[toKey(typeVMScaleSet, vmssName)]
+	if !ok {
+		t.Fatalf("expected VM Scale Set to be listed")
+	}
+	var diskKeys []string
+	for _, k := range vmss.Blocks {
+		if !strings.HasPrefix(k, typeDisk+":") {
+			continue
+		}
+		diskKeys = append(diskKeys, k)
+		if _, ok := rs[k]; !ok {
+			t.Errorf("expected disk %q blocked by the VM Scale Set to be listed", k)
+		}
+	}
+	sort.Strings(diskKeys)
+	if expected := []string{toKey(typeDisk, "data-0"), toKey(typeDisk, "data-1")}; !reflect.DeepEqual(diskKeys, expected) {
+		t.Errorf("expected the VM Scale Set to block %v, but got %v", expected, diskKeys)
+	}
+}
+
 func TestIsOwnedByCluster(t *testing.T) {
 	clusterName := "test-cluster"
 
 	testCases := []struct {
-		tags     map[string]*string
-		expected bool
+		name   string
+		tags   map[string]*string
+		owned  bool
+		shared bool
 	}{
 		{
+			name: "cluster name",
 			tags: map[string]*string{
 				azure.TagClusterName: to.Ptr(clusterName),
 			},
-			expected: true,
+			owned: true,
 		},
 		{
+			name: "cluster name with other tags",
 			tags: map[string]*string{
 				azure.TagClusterName: to.Ptr(clusterName),
 				"other-key":          to.Ptr("other-tag"),
 			},
-			expected: true,
+			owned: true,
+		},
+		{
+			// The value does not name the cluster, so it could be any cluster.
+			name: "owned",
+			tags: map[string]*string{
+				azure.TagClusterName: to.Ptr("owned"),
+			},
+		},
+		{
+			name: "shared",
+			tags: map[string]*string{
+				azure.TagClusterName: to.Ptr("shared"),
+			},
+			shared: true,
 		},
 		{
+			name: "no cluster tag",
 			tags: map[string]*string{
 				"other-key": to.Ptr("other-tag"),
 			},
-			expected: false,
 		},
 		{
+			name: "nil cluster tag",
+			tags: map[string]*string{
+				azure.TagClusterName: nil,
+			},
+		},
+		{
+			name: "cluster name with nil tag values",
+			// Tags inherited from a policy may have no value.
+			tags: map[string]*string{
+				azure.TagClusterName:                   to.Ptr(clusterName),
+				"policy-tag":                           nil,
+				"kops-cluster":                         nil,
+				"kubernetes.io_cluster_" + clusterName: nil,
+			},
+			owned: true,
+		},
+		{
+			name: "different cluster",
+			tags: map[string]*string{
+				azure.TagClusterName: to.Ptr("different-cluster"),
+			},
+		},
+		{
+			name: "legacy cluster tag",
+			tags: map[string]*string{
+				"kops-cluster": to.Ptr(clusterName),
+			},
+			owned: true,
+		},
+		{
+			name: "legacy shared tag",
+			tags: map[string]*string{
+				"kops-cluster": to.Ptr("shared"),
+			},
+			shared: true,
+		},
+		{
+			name: "ownership tag owned",
+			tags: map[string]*string{
+				"kubernetes.io_cluster_" + clusterName: to.Ptr("owned"),
+			},
+			owned: true,
+		},
+		{
+			name: "ownership tag shared",
+			tags: map[string]*string{
+				"kubernetes.io_cluster_" + clusterName: to.Ptr("shared"),
+			},
+			owned:  true,
+			shared: true,
+		},
+		{
+			name: "cluster name with ownership tag shared",
+			tags: map[string]*string{
+				azure.TagClusterName:                   to.Ptr(clusterName),
+				"kubernetes.io_cluster_" + clusterName: to.Ptr("shared"),
+			},
+			owned:  true,
+			shared: true,
+		},
+		{
+			name: "ownership tag of another cluster",
+			tags: map[string]*string{
+				"kubernetes.io_cluster_other-cluster": to.Ptr("owned"),
+			},
+		},
+		{
+			name: "cluster tag takes precedence over legacy tag",
 			tags: map[string]*string{
 				azure.TagClusterName: to.Ptr("different-cluster"),
+				"kops-cluster":       to.Ptr(clusterName),
 			},
-			expected: false,
 		},
 	}
-	for i, tc := range testCases {
-		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
 			g := &resourceGetter{
 				clusterInfo: resources.ClusterInfo{
-					Name: clusterName,
+					Name:               clusterName,
+					AzureLegacyTagKeys: []string{"kops-cluster"},
 				},
 			}
-			a := g.isOwnedByCluster(tc.tags)
-			if a != tc.expected {
-				t.Errorf("expected %t, but got %t", tc.expected, a)
+			if a := g.isOwnedByCluster(tc.tags); a != tc.owned {
+				t.Errorf("expected owned %t, but got %t", tc.owned, a)
+			}
+			if a := g.isSharedWithCluster(tc.tags); a != tc.shared {
+				t.Errorf("expected shared %t, but got %t", tc.shared, a)
 			}
 		})
 	}
 }
+
+func TestListSharedResources(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip-owned"] = &network.PublicIPAddress{
+		Name: to.Ptr("pip-owned"),
+		Tags: map[string]*string{
+			azure.TagNameClusterOwnershipPrefix + clusterName: to.Ptr("owned"),
+		},
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip-shared"] = &network.PublicIPAddress{
+		Name: to.Ptr("pip-shared"),
+		Tags: map[string]*string{
+			azure.TagNameClusterOwnershipPrefix + clusterName: to.Ptr("shared"),
+		},
+	}
+	// A cluster tag set to "shared" does not say which cluster shares it.
+	cloud.PublicIPAddressesClient.PubIPs["pip-any-cluster"] = &network.PublicIPAddress{
+		Name: to.Ptr("pip-any-cluster"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr("shared"),
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for name, shared := range map[string]bool{"pip-owned": false, "pip-shared": true} {
+		r, ok := rs[toKey(typePublicIPAddress, name)]
+		if !ok {
+			t.Errorf("expected %s to be listed", name)
+			continue
+		}
+		if r.Shared != shared {
+			t.Errorf("expected %s to have Shared %t, but got %t", name, shared, r.Shared)
+		}
+	}
+	if _, ok := rs[toKey(typePublicIPAddress, "pip-any-cluster")]; ok {
+		t.Errorf("expected pip-any-cluster not to be listed")
+	}
+}
+
+func TestListRouteTablesUsedByOtherClusters(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	subnet := func(rgName, vnetName string) *network.Subnet {
+		id := azure.SubnetID{
+			SubscriptionID:     "sid",
+			ResourceGroupName:  rgName,
+			VirtualNetworkName: vnetName,
+			SubnetName:         "subnet",
+		}
+		return &network.Subnet{ID: to.Ptr(id.String())}
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+		Name: to.Ptr("vnet"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+	}
+	cloud.VirtualNetworksClient.VNets["other-vnet"] = &network.VirtualNetwork{
+		Name: to.Ptr("other-vnet"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr("other-cluster"),
+		},
+	}
+	cloud.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &network.RouteTablePropertiesFormat{
+			Subnets: []*network.Subnet{
+				subnet(rgName, "vnet"),
+			},
+		},
+	}
+	cloud.RouteTablesClient.RTs["used-rt"] = &network.RouteTable{
+		Name: to.Ptr("used-rt"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &network.RouteTablePropertiesFormat{
+			Subnets: []*network.Subnet{
+				subnet(rgName, "vnet"),
+				subnet("other-rg", "other-vnet"),
+			},
+		},
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listRouteTables(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	byName := make(map[string]*resources.Resource)
+	for _, r := range rs {
+		byName[r.Name] = r
+	}
+	if r := byName["rt"]; r == nil || r.Shared {
+		t.Errorf("expected the route table used only by the cluster to be deleted")
+	}
+	r := byName["used-rt"]
+	if r == nil || !r.Shared {
+		t.Fatalf("expected the route table used by another cluster to be kept")
+	}
+	if usedBy := r.Metadata[metadataUsedBy]; usedBy != "other-cluster" {
+		t.Errorf("expected the route table to be used by other-cluster, but got %q", usedBy)
+	}
+}
+
+func TestListResourcesAzureLogsProgress(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	for _, name := range []string{"disk-a", "disk-b"} {
+		cloud.DisksClient.Disks[name] = &compute.Disk{
+			Name: to.Ptr(name),
+			Tags: clusterTags,
+		}
+	}
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+
+	listWithVerbosity := func(v int) string {
+		var logs bytes.Buffer
+		logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Verbosity(v), textlogger.Output(&logs)))
+		ctx := klog.NewContext(context.Background(), logger)
+		if _, err := ListResourcesAzureWithContext(ctx, cloud, clusterInfo); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		return logs.String()
+	}
+
+	logs := listWithVerbosity(2)
+	for _, expected := range []string{
+		`"Listed Azure resources" resourceGroup="rg" type="Disk" count=2`,
+		`"Listed the Azure resources of the cluster" resourceGroup="rg" total=3 counts={"Disk":2,"ResourceGroup":1}`,
+	} {
+		if !strings.Contains(logs, expected) {
+			t.Errorf("expected the logs to contain %s, but got %q", expected, logs)
+		}
+	}
+
+	if logs := listWithVerbosity(0); logs != "" {
+		t.Errorf("expected nothing to be logged at default verbosity, but got %q", logs)
+	}
+}
+
+func TestListResourcesAzureSorted(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+		Name:       to.Ptr("vnet"),
+		Tags:       clusterTags,
+		Properties: &network.VirtualNetworkPropertiesFormat{},
+	}
+	for _, name := range []string{"disk-c", "disk-a", "disk-b"} {
+		cloud.DisksClient.Disks[name] = &compute.Disk{
+			Name: to.Ptr(name),
+			Tags: clusterTags,
+		}
+	}
+	cloud.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: clusterTags,
+	}
+
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+	actual, err := ListResourcesAzureSorted(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	resourceMap, err := ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	var keys []string
+	for _, r := range actual {
+		keys = append(keys, toKey(r.Type, r.ID))
+	}
+	expected := []string{
+		toKey(typeDisk, "disk-a"),
+		toKey(typeDisk, "disk-b"),
+		toKey(typeDisk, "disk-c"),
+		toKey(typeResourceGroup, rgName),
+		toKey(typeRouteTable, "rt"),
+		toKey(typeVirtualNetwork, "vnet"),
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, but got %v", expected, keys)
+	}
+	if len(actual) != len(resourceMap) {
+		t.Errorf("expected %d resources, but got %d", len(resourceMap), len(actual))
+	}
+	for _, r := range actual {
+		if _, ok := resourceMap[toKey(r.Type, r.ID)]; !ok {
+			t.Errorf("resource %q not found in the resource map", toKey(r.Type, r.ID))
+		}
+	}
+}
+
+func TestListSnapshots(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	diskIDIn := func(subscriptionID, resourceGroupName, name string) *string {
+		id := azure.DiskID{
+			SubscriptionID:    subscriptionID,
+			ResourceGroupName: resourceGroupName,
+			DiskName:          name,
+		}
+		return to.Ptr(id.String())
+	}
+	diskID := func(name string) *string {
+		return diskIDIn("sid", rgName, name)
+	}
+	csiTags := map[string]*string{
+		"kubernetes.io-created-for-pv-name":  to.Ptr("pv"),
+		"kubernetes.io-created-for-pvc-name": to.Ptr("pvc"),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.DisksClient.Disks["disk"] = &compute.Disk{
+		ID:   diskID("disk"),
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+	cloud.DisksClient.Disks["irrelevant"] = &compute.Disk{
+		ID:   diskID("irrelevant"),
+		Name: to.Ptr("irrelevant"),
+	}
+	snapshots := cloud.SnapshotsClient.Snapshots
+	snapshots["owned"] = &compute.Snapshot{
+		Name: to.Ptr("owned"),
+		Tags: clusterTags,
+	}
+	snapshots["csi"] = &compute.Snapshot{
+		Name: to.Ptr("csi"),
+		Tags: csiTags,
+		Properties: &compute.SnapshotProperties{
+			CreationData: &compute.CreationData{
+				SourceResourceID: diskID("disk"),
+			},
+		},
+	}
+	snapshots["csi-irrelevant"] = &compute.Snapshot{
+		Name: to.Ptr("csi-irrelevant"),
+		Tags: csiTags,
+		Properties: &compute.SnapshotProperties{
+			CreationData: &compute.CreationData{
+				SourceResourceID: diskID("irrelevant"),
+			},
+		},
+	}
+
+	// Snapshots of disks with the same name as the cluster disk, but in another
+	// resource group or subscription, are not owned by the cluster.
+	snapshots["csi-other-rg"] = &compute.Snapshot{
+		Name: to.Ptr("csi-other-rg"),
+		Tags: csiTags,
+		Properties: &compute.SnapshotProperties{
+			CreationData: &compute.CreationData{
+				SourceResourceID: diskIDIn("sid", "other-rg", "disk"),
+			},
+		},
+	}
+	snapshots["csi-other-subscription"] = &compute.Snapshot{
+		Name: to.Ptr("csi-other-subscription"),
+		Tags: csiTags,
+		Properties: &compute.SnapshotProperties{
+			CreationData: &compute.CreationData{
+				SourceResourceID: diskIDIn("other-sid", rgName, "disk"),
+			},
+		},
+	}
+
+	testCases := []struct {
+		deleteSnapshots bool
+		expected        []string
+	}{
+		{
+			deleteSnapshots: false,
+		},
+		{
+			deleteSnapshots: true,
+			expected:        []string{"csi", "owned"},
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			g := &resourceGetter{
+				cloud: cloud,
+				clusterInfo: resources.ClusterInfo{
+					Name:                   clusterName,
+					AzureResourceGroupName: rgName,
+					AzureDeleteSnapshots:   tc.deleteSnapshots,
+				},
+			}
+			rs, err := g.listSnapshots(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			var actual []string
+			for _, r := range rs {
+				if r.Type != typeSnapshot {
+					t.Errorf("expected type %q, but got %q", typeSnapshot, r.Type)
+				}
+				actual = append(actual, r.Name)
+			}
+			sort.Strings(actual)
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestListSnapshotsBlocksSourceDisk(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	diskID := func(name string) *string {
+		id := azure.DiskID{
+			SubscriptionID:    "sid",
+			ResourceGroupName: rgName,
+			DiskName:          name,
+		}
+		return to.Ptr(id.String())
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.DisksClient.Disks["disk"] = &compute.Disk{
+		ID:   diskID("disk"),
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+	snapshots := cloud.SnapshotsClient.Snapshots
+	snapshots["present"] = &compute.Snapshot{
+		Name: to.Ptr("present"),
+		Tags: clusterTags,
+		Properties: &compute.SnapshotProperties{
+			CreationData: &compute.CreationData{
+				SourceResourceID: diskID("disk"),
+			},
+		},
+	}
+	// The source disk of this snapshot has already been removed.
+	snapshots["removed"] = &compute.Snapshot{
+		Name: to.Ptr("removed"),
+		Tags: clusterTags,
+		Properties: &compute.SnapshotProperties{
+			CreationData: &compute.CreationData{
+				SourceResourceID: diskID("removed-disk"),
+			},
+		},
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+			AzureDeleteSnapshots:   true,
+		},
+	}
+	rs, err := g.listSnapshots(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	actual := make(map[string][]string)
+	for _, r := range rs {
+		actual[r.Name] = r.Blocks
+	}
+	expected := map[string][]string{
+		"present": {
+			toKey(typeResourceGroup, rgName),
+			toKey(typeDisk, "disk"),
+		},
+		"removed": {
+			toKey(typeResourceGroup, rgName),
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestListDiskEncryptionSets(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	keyVaultID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.KeyVault/vaults/kv", rgName)
+	desID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Compute/diskEncryptionSets/des", rgName)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourcesClient.Resources[keyVaultID] = &armresources.GenericResourceExpanded{
+		ID:   to.Ptr(keyVaultID),
+		Name: to.Ptr("kv"),
+		Type: to.Ptr(keyVaultResourceType),
+		Tags: clusterTags,
+	}
+	cloud.DiskEncryptionSetsClient.DESs["des"] = &compute.DiskEncryptionSet{
+		ID:   to.Ptr(desID),
+		Name: to.Ptr("des"),
+		Tags: clusterTags,
+		Properties: &compute.EncryptionSetProperties{
+			ActiveKey: &compute.KeyForDiskEncryptionSet{
+				KeyURL:      to.Ptr("https://kv.vault.azure.net/keys/key/version"),
+				SourceVault: &compute.SourceVault{ID: to.Ptr(keyVaultID)},
+			},
+		},
+	}
+	cloud.DiskEncryptionSetsClient.DESs["other-des"] = &compute.DiskEncryptionSet{
+		Name: to.Ptr("other-des"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr("other-cluster"),
+		},
+	}
+	cloud.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+		Properties: &compute.DiskProperties{
+			Encryption: &compute.Encryption{
+				DiskEncryptionSetID: to.Ptr(desID),
+			},
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+		AzureDeleteStorage:     true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, ok := rs[toKey(typeDiskEncryptionSet, "other-des")]; ok {
+		t.Errorf("expected the disk encryption set of another cluster not to be listed")
+	}
+
+	// The disk is deleted before its disk encryption set, which is deleted
+	// before the key vault holding its key.
+	desKey := toKey(typeDiskEncryptionSet, "des")
+	kvKey := toKey(typeKeyVault, "kv")
+	for _, edge := range []struct{ from, to string }{
+		{toKey(typeDisk, "disk"), desKey},
+		{desKey, kvKey},
+	} {
+		r, ok := rs[edge.from]
+		if !ok {
+			t.Fatalf("expected %q to be listed", edge.from)
+		}
+		if _, ok := rs[edge.to]; !ok {
+			t.Fatalf("expected %q to be listed", edge.to)
+		}
+		if !slices.Contains(r.Blocks, edge.to) {
+			t.Errorf("expected %q to block %q, but it blocks %v", edge.from, edge.to, r.Blocks)
+		}
+	}
+
+	des := rs[desKey]
+	if err := des.Deleter(cloud, des); err != nil {
+		t.Fatalf("unexpected error deleting disk encryption set: %s", err)
+	}
+	if _, ok := cloud.DiskEncryptionSetsClient.DESs["des"]; ok {
+		t.Errorf("expected disk encryption set to be deleted")
+	}
+}
+
+type failingPublicIPAddressesClient struct {
+	*azuretasks.MockPublicIPAddressesClient
+}
+
+func (c *failingPublicIPAddressesClient) List(ctx context.Context, resourceGroupName string) ([]*network.PublicIPAddress, error) {
+	return nil, errors.New("listing public IP addresses failed")
+}
+
+type failingNatGatewaysClient struct {
+	*azuretasks.MockNatGatewaysClient
+}
+
+func (c *failingNatGatewaysClient) List(ctx context.Context, resourceGroupName string) ([]*network.NatGateway, error) {
+	return nil, errors.New("listing NAT gateways failed")
+}
+
+// failingListersCloud fails to list public IP addresses and NAT gateways.
+type failingListersCloud struct {
+	*azuretasks.MockAzureCloud
+}
+
+func (c *failingListersCloud) PublicIPAddress() azure.PublicIPAddressesClient {
+	return &failingPublicIPAddressesClient{MockPublicIPAddressesClient: c.MockAzureCloud.PublicIPAddressesClient}
+}
+
+func (c *failingListersCloud) NatGateway() azure.NatGatewaysClient {
+	return &failingNatGatewaysClient{MockNatGatewaysClient: c.MockAzureCloud.NatGatewaysClient}
+}
+
+func TestListResourcesAzureJoinsListerErrors(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+	mock.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: clusterTags,
+	}
+
+	rs, err := ListResourcesAzure(&failingListersCloud{MockAzureCloud: mock}, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, msg := range []string{"listing public IP addresses failed", "listing NAT gateways failed"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error %q to contain %q", err, msg)
+		}
+	}
+	for _, key := range []string{toKey(typeDisk, "disk"), toKey(typeRouteTable, "rt")} {
+		if _, ok := rs[key]; !ok {
+			t.Errorf("expected %q to be listed despite the errors", key)
+		}
+	}
+}
+
+func TestListAllDiscoveryProgress(t *testing.T) {
+	type progress struct {
+		completed int
+		total     int
+	}
+	var actual []progress
+	g := &resourceGetter{
+		cloud: azuretasks.NewMockAzureCloud("eastus"),
+		clusterInfo: resources.ClusterInfo{
+			Name:                   "cluster",
+			AzureResourceGroupName: "rg",
+			AzureDiscoveryProgress: func(completed, total int) {
+				actual = append(actual, progress{completed: completed, total: total})
+			},
+		},
+	}
+	if _, err := g.listAll(); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if len(actual) == 0 {
+		t.Fatalf("expected the progress callback to be called")
+	}
+	total := actual[0].total
+	if len(actual) != total {
+		t.Errorf("expected the progress callback to be called %d times, but got %d", total, len(actual))
+	}
+	for i, p := range actual {
+		if p.completed != i+1 || p.total != total {
+			t.Errorf("expected progress %d/%d, but got %d/%d", i+1, total, p.completed, p.total)
+		}
+	}
+
+	// A nil callback must be safe.
+	g.clusterInfo.AzureDiscoveryProgress = nil
+	if _, err := g.listAll(); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+}
+
+func TestListPrivateEndpoints(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	storageAccountID := func(name string) *string {
+		return to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Storage/storageAccounts/%s", rgName, name))
+	}
+	keyVaultID := func(name string) *string {
+		return to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.KeyVault/vaults/%s", rgName, name))
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.StorageAccountsClient.SAs["owned"] = &armstorage.Account{
+		Name: to.Ptr("owned"),
+		Tags: clusterTags,
+	}
+	cloud.StorageAccountsClient.SAs["irrelevant"] = &armstorage.Account{
+		Name: to.Ptr("irrelevant"),
+	}
+	cloud.StorageAccountsClient.SAs["state"] = &armstorage.Account{
+		Name: to.Ptr("state"),
+		Tags: clusterTags,
+	}
+	cloud.ResourcesClient.Resources[*keyVaultID("kv")] = &armresources.GenericResourceExpanded{
+		ID:   keyVaultID("kv"),
+		Name: to.Ptr("kv"),
+		Type: to.Ptr("Microsoft.KeyVault/vaults"),
+		Tags: clusterTags,
+	}
+	cloud.ResourcesClient.Resources[*keyVaultID("kv-irrelevant")] = &armresources.GenericResourceExpanded{
+		ID:   keyVaultID("kv-irrelevant"),
+		Name: to.Ptr("kv-irrelevant"),
+		Type: to.Ptr("Microsoft.KeyVault/vaults"),
+	}
+	pes := cloud.PrivateEndpointsClient.PEs
+	pes["pe-owned"] = &network.PrivateEndpoint{
+		Name: to.Ptr("pe-owned"),
+		Tags: clusterTags,
+		Properties: &network.PrivateEndpointProperties{
+			PrivateLinkServiceConnections: []*network.PrivateLinkServiceConnection{
+				{
+					Properties: &network.PrivateLinkServiceConnectionProperties{
+						PrivateLinkServiceID: storageAccountID("owned"),
+					},
+				},
+			},
+		},
+	}
+	pes["pe-kv"] = &network.PrivateEndpoint{
+		Name: to.Ptr("pe-kv"),
+		Tags: clusterTags,
+		Properties: &network.PrivateEndpointProperties{
+			PrivateLinkServiceConnections: []*network.PrivateLinkServiceConnection{
+				{
+					Properties: &network.PrivateLinkServiceConnectionProperties{
+						PrivateLinkServiceID: keyVaultID("kv"),
+					},
+				},
+			},
+		},
+	}
+	pes["pe-irrelevant"] = &network.PrivateEndpoint{
+		Name: to.Ptr("pe-irrelevant"),
+		Tags: clusterTags,
+		Properties: &network.PrivateEndpointProperties{
+			ManualPrivateLinkServiceConnections: []*network.PrivateLinkServiceConnection{
+				{
+					Properties: &network.PrivateLinkServiceConnectionProperties{
+						PrivateLinkServiceID: storageAccountID("irrelevant"),
+					},
+				},
+			},
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+		AzureDeleteStorage:     true,
+		AzureStateStoreAccount: "state",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if _, ok := rs[toKey(typeStorageAccount, "state")]; ok {
+		t.Errorf("expected state store storage account not to be listed")
+	}
+	if _, ok := rs[toKey(typeKeyVault, "kv-irrelevant")]; ok {
+		t.Errorf("expected irrelevant key vault not to be listed")
+	}
+
+	saKey := toKey(typeStorageAccount, "owned")
+	if _, ok := rs[saKey]; !ok {
+		t.Fatalf("expected storage account %q to be listed", saKey)
+	}
+	if _, ok := rs[toKey(typeStorageAccount, "irrelevant")]; ok {
+		t.Errorf("expected irrelevant storage account not to be listed")
+	}
+
+	// The private endpoint must be deleted before the storage account it connects to.
+	pe, ok := rs[toKey(typePrivateEndpoint, "pe-owned")]
+	if !ok {
+		t.Fatalf("expected private endpoint to be listed")
+	}
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		saKey,
+	}
+	if !reflect.DeepEqual(pe.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, pe.Blocks)
+	}
+	for _, b := range rs[saKey].Blocks {
+		if b == toKey(typePrivateEndpoint, "pe-owned") {
+			t.Errorf("expected storage account not to block the private endpoint")
+		}
+	}
+
+	// The private endpoint must be deleted before the key vault it connects to.
+	kvKey := toKey(typeKeyVault, "kv")
+	kv, ok := rs[kvKey]
+	if !ok {
+		t.Fatalf("expected key vault %q to be listed", kvKey)
+	}
+	pe, ok = rs[toKey(typePrivateEndpoint, "pe-kv")]
+	if !ok {
+		t.Fatalf("expected private endpoint to be listed")
+	}
+	expected = []string{
+		toKey(typeResourceGroup, rgName),
+		kvKey,
+	}
+	if !reflect.DeepEqual(pe.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, pe.Blocks)
+	}
+	if err := kv.Deleter(cloud, kv); err != nil {
+		t.Fatalf("unexpected error deleting key vault: %s", err)
+	}
+	if _, ok := cloud.ResourcesClient.Resources[*keyVaultID("kv")]; ok {
+		t.Errorf("expected key vault to be deleted")
+	}
+
+	// Targets that are not deleted with the cluster do not add edges.
+	pe, ok = rs[toKey(typePrivateEndpoint, "pe-irrelevant")]
+	if !ok {
+		t.Fatalf("expected private endpoint to be listed")
+	}
+	expected = []string{
+		toKey(typeResourceGroup, rgName),
+	}
+	if !reflect.DeepEqual(pe.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, pe.Blocks)
+	}
+}
+
+func TestListStorageRequiresOptIn(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	keyVaultID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.KeyVault/vaults/kv", rgName)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.StorageAccountsClient.SAs["sa"] = &armstorage.Account{
+		Name: to.Ptr("sa"),
+		Tags: clusterTags,
+	}
+	cloud.ResourcesClient.Resources[keyVaultID] = &armresources.GenericResourceExpanded{
+		ID:   to.Ptr(keyVaultID),
+		Name: to.Ptr("kv"),
+		Type: to.Ptr("Microsoft.KeyVault/vaults"),
+		Tags: clusterTags,
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for _, key := range []string{toKey(typeStorageAccount, "sa"), toKey(typeKeyVault, "kv")} {
+		if _, ok := rs[key]; ok {
+			t.Errorf("expected %q not to be listed without opting in", key)
+		}
+	}
+}
+
+func TestDeleteKeyVault(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	keyVaultID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.KeyVault/vaults/kv", rgName)
+
+	grid := []struct {
+		name   string
+		purge  bool
+		purged map[string]string
+	}{
+		{
+			name:   "delete only",
+			purged: map[string]string{},
+		},
+		{
+			name:   "delete and purge",
+			purge:  true,
+			purged: map[string]string{"kv": "westus"},
+		},
+	}
+	for _, g := range grid {
+		t.Run(g.name, func(t *testing.T) {
+			cloud := azuretasks.NewMockAzureCloud("eastus")
+			cloud.ResourcesClient.Resources[keyVaultID] = &armresources.GenericResourceExpanded{
+				ID:       to.Ptr(keyVaultID),
+				Name:     to.Ptr("kv"),
+				Type:     to.Ptr(keyVaultResourceType),
+				Location: to.Ptr("westus"),
+				Tags: map[string]*string{
+					azure.TagClusterName: to.Ptr(clusterName),
+				},
+			}
+
+			rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+				Name:                   clusterName,
+				AzureResourceGroupName: rgName,
+				AzureDeleteStorage:     true,
+				AzurePurgeKeyVault:     g.purge,
+				// The key vault is purged even if its object is dropped.
+				AzureOmitRawObjects: true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			kv, ok := rs[toKey(typeKeyVault, "kv")]
+			if !ok {
+				t.Fatalf("expected the key vault to be listed")
+			}
+			if err := kv.Deleter(cloud, kv); err != nil {
+				t.Fatalf("unexpected error deleting key vault: %s", err)
+			}
+			if _, ok := cloud.ResourcesClient.Resources[keyVaultID]; ok {
+				t.Errorf("expected key vault to be deleted")
+			}
+			if !reflect.DeepEqual(cloud.KeyVaultsClient.Purged, g.purged) {
+				t.Errorf("expected the purged key vaults to be %v, but got %v", g.purged, cloud.KeyVaultsClient.Purged)
+			}
+		})
+	}
+}
+
+func TestListRecoveryServicesVaults(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	vaultID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.RecoveryServices/vaults/vault", rgName)
+	itemID := func(name string) *string {
+		return to.Ptr(vaultID + "/backupFabrics/Azure/protectionContainers/container/protectedItems/" + name)
+	}
+	vaultKey := toKey(typeRecoveryServicesVault, "vault")
+
+	newCloud := func() *azuretasks.MockAzureCloud {
+		cloud := azuretasks.NewMockAzureCloud("eastus")
+		cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+			Name: to.Ptr(rgName),
+			Tags: clusterTags,
+		}
+		cloud.ResourcesClient.Resources[vaultID] = &armresources.GenericResourceExpanded{
+			ID:   to.Ptr(vaultID),
+			Name: to.Ptr("vault"),
+			Type: to.Ptr("Microsoft.RecoveryServices/vaults"),
+			Tags: clusterTags,
+		}
+		cloud.BackupItemsClient.Items[vaultID] = []*azure.BackupItem{
+			{ID: itemID("disk-1"), Name: to.Ptr("disk-1")},
+			{ID: itemID("disk-2"), Name: to.Ptr("disk-2")},
+		}
+		return cloud
+	}
+
+	t.Run("preserved by default", func(t *testing.T) {
+		cloud := newCloud()
+		rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if _, ok := rs[vaultKey]; ok {
+			t.Errorf("expected %q not to be listed without opting in", vaultKey)
+		}
+	})
+
+	t.Run("opt-in with backup items", func(t *testing.T) {
+		cloud := newCloud()
+		rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+			AzureDeleteBackups:     true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		vault, ok := rs[vaultKey]
+		if !ok {
+			t.Fatalf("expected %q to be listed", vaultKey)
+		}
+		if expected := []string{toKey(typeResourceGroup, rgName)}; !reflect.DeepEqual(vault.Blocks, expected) {
+			t.Errorf("expected %v, but got %v", expected, vault.Blocks)
+		}
+
+		if err := vault.Deleter(cloud, vault); err != nil {
+			t.Fatalf("unexpected error deleting vault: %s", err)
+		}
+		if items := cloud.BackupItemsClient.Items[vaultID]; len(items) != 0 {
+			t.Errorf("expected backup items to be deleted, but got %d", len(items))
+		}
+		if _, ok := cloud.ResourcesClient.Resources[vaultID]; ok {
+			t.Errorf("expected vault to be deleted")
+		}
+	})
+}
+
+func TestListNatGateways(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	ngwID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/natGateways/ngw", rgName)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.NatGatewaysClient.NGWs["ngw"] = &network.NatGateway{
+		ID:   to.Ptr(ngwID),
+		Name: to.Ptr("ngw"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+	}
+	cloud.NatGatewaysClient.NGWs["other"] = &network.NatGateway{
+		ID:   to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/natGateways/other", rgName)),
+		Name: to.Ptr("other"),
+	}
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+
+	rs, err := g.listNatGateways(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("expected only the tagged NAT gateway to be listed, but got %d", len(rs))
+	}
+	ngw := rs[0]
+	if ngw.Type != typeNatGateway || ngw.Name != "ngw" {
+		t.Errorf("unexpected resource %s %q", ngw.Type, ngw.Name)
+	}
+	if expected := []string{toKey(typeResourceGroup, rgName)}; !reflect.DeepEqual(ngw.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, ngw.Blocks)
+	}
+
+	// A subnet using the NAT gateway must be deleted before it.
+	subnet, err := g.toSubnetResource(&network.Subnet{
+		Name: to.Ptr("subnet"),
+		Properties: &network.SubnetPropertiesFormat{
+			NatGateway: &network.SubResource{ID: to.Ptr(ngwID)},
+		},
+	}, "vnet")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !slices.Contains(subnet.Blocks, toKey(typeNatGateway, ngw.ID)) {
+		t.Errorf("expected subnet to block the NAT gateway, but got %v", subnet.Blocks)
+	}
+
+	if err := ngw.Deleter(cloud, ngw); err != nil {
+		t.Fatalf("unexpected error deleting NAT gateway: %s", err)
+	}
+	if _, ok := cloud.NatGatewaysClient.NGWs["ngw"]; ok {
+		t.Errorf("expected NAT gateway to be deleted")
+	}
+	if _, ok := cloud.NatGatewaysClient.NGWs["other"]; !ok {
+		t.Errorf("expected untagged NAT gateway to be kept")
+	}
+}
+
+func TestListApplicationGateways(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	subnetID := func(name string) *string {
+		id := azure.SubnetID{
+			SubscriptionID:     "sid",
+			ResourceGroupName:  rgName,
+			VirtualNetworkName: "vnet",
+			SubnetName:         name,
+		}
+		return to.Ptr(id.String())
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ApplicationGatewaysClient.AGWs["ingress"] = &network.ApplicationGateway{
+		ID:   to.Ptr((&azure.ApplicationGatewayID{SubscriptionID: "sid", ResourceGroupName: rgName, ApplicationGatewayName: "ingress"}).String()),
+		Name: to.Ptr("ingress"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &network.ApplicationGatewayPropertiesFormat{
+			GatewayIPConfigurations: []*network.ApplicationGatewayIPConfiguration{
+				{
+					Properties: &network.ApplicationGatewayIPConfigurationPropertiesFormat{
+						Subnet: &network.SubResource{ID: subnetID("agw")},
+					},
+				},
+			},
+			FrontendIPConfigurations: []*network.ApplicationGatewayFrontendIPConfiguration{
+				{
+					Properties: &network.ApplicationGatewayFrontendIPConfigurationPropertiesFormat{
+						PublicIPAddress: &network.SubResource{
+							ID: to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/publicIPAddresses/ingress-pip", rgName)),
+						},
+					},
+				},
+				{
+					Properties: &network.ApplicationGatewayFrontendIPConfigurationPropertiesFormat{
+						Subnet: &network.SubResource{ID: subnetID("nodes")},
+					},
+				},
+			},
+		},
+	}
+	// An application gateway of another cluster, or created by hand.
+	cloud.ApplicationGatewaysClient.AGWs["other"] = &network.ApplicationGateway{
+		Name: to.Ptr("other"),
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listApplicationGateways(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("expected only the application gateway of the cluster to be listed, but got %d", len(rs))
+	}
+	agw := rs[0]
+	if agw.Type != typeApplicationGateway || agw.Name != "ingress" {
+		t.Errorf("unexpected resource %s %q", agw.Type, agw.Name)
+	}
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		toKey(typeSubnet, "agw"),
+		toKey(typeSubnet, "nodes"),
+		toKey(typePublicIPAddress, "ingress-pip"),
+	}
+	if !reflect.DeepEqual(agw.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, agw.Blocks)
+	}
+
+	if err := agw.Deleter(cloud, agw); err != nil {
+		t.Fatalf("unexpected error deleting application gateway: %s", err)
+	}
+	if _, ok := cloud.ApplicationGatewaysClient.AGWs["ingress"]; ok {
+		t.Errorf("expected application gateway to be deleted")
+	}
+}
+
+func TestSubnetBlocksRouteTableAndNetworkSecurityGroup(t *testing.T) {
+	const rgName = "rg"
+	rtID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/routeTables/rt", rgName)
+	nsgID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/networkSecurityGroups/nsg", rgName)
+
+	testCases := []struct {
+		name       string
+		properties *network.SubnetPropertiesFormat
+		expected   []string
+	}{
+		{
+			name: "both",
+			properties: &network.SubnetPropertiesFormat{
+				RouteTable:           &network.RouteTable{ID: to.Ptr(rtID)},
+				NetworkSecurityGroup: &network.SecurityGroup{ID: to.Ptr(nsgID)},
+			},
+			expected: []string{
+				toKey(typeRouteTable, "rt"),
+				toKey(typeNetworkSecurityGroup, "nsg"),
+			},
+		},
+		{
+			name: "route table only",
+			properties: &network.SubnetPropertiesFormat{
+				RouteTable: &network.RouteTable{ID: to.Ptr(rtID)},
+			},
+			expected: []string{
+				toKey(typeRouteTable, "rt"),
+			},
+		},
+		{
+			name: "network security group only",
+			properties: &network.SubnetPropertiesFormat{
+				NetworkSecurityGroup: &network.SecurityGroup{ID: to.Ptr(nsgID)},
+			},
+			expected: []string{
+				toKey(typeNetworkSecurityGroup, "nsg"),
+			},
+		},
+		{
+			name:       "neither",
+			properties: &network.SubnetPropertiesFormat{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &resourceGetter{
+				clusterInfo: resources.ClusterInfo{
+					AzureResourceGroupName: rgName,
+				},
+			}
+			subnet, err := g.toSubnetResource(&network.Subnet{
+				Name:       to.Ptr("subnet"),
+				Properties: tc.properties,
+			}, "vnet")
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			expected := append([]string{
+				toKey(typeVirtualNetwork, "vnet"),
+				toKey(typeResourceGroup, rgName),
+			}, tc.expected...)
+			if !reflect.DeepEqual(subnet.Blocks, expected) {
+				t.Errorf("expected %v, but got %v", expected, subnet.Blocks)
+			}
+		})
+	}
+}
+
+func TestListNetworkSecurityGroups(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.NetworkSecurityGroupsClient.NSGs["nsg"] = &network.SecurityGroup{
+		Name: to.Ptr("nsg"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &network.SecurityGroupPropertiesFormat{},
+	}
+	// An NSG of another cluster, or created by hand, in the same resource group.
+	cloud.NetworkSecurityGroupsClient.NSGs["other"] = &network.SecurityGroup{
+		Name:       to.Ptr("other"),
+		Properties: &network.SecurityGroupPropertiesFormat{},
+	}
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+
+	rs, err := g.listNetworkSecurityGroups(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var names []string
+	for _, r := range rs {
+		names = append(names, r.Name)
+	}
+	if expected := []string{"nsg"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v to be listed, but got %v", expected, names)
+	}
+}
+
+// updatableNSGsClient is a mock network security groups client that supports
+// updates.
+type updatableNSGsClient struct {
+	*azuretasks.MockNetworkSecurityGroupsClient
+}
+
+func (c *updatableNSGsClient) CreateOrUpdate(ctx context.Context, resourceGroupName, nsgName string, parameters network.SecurityGroup) (*network.SecurityGroup, error) {
+	c.NSGs[nsgName] = &parameters
+	return &parameters, nil
+}
+
+type updatableNSGsCloud struct {
+	*azuretasks.MockAzureCloud
+	nsgs *updatableNSGsClient
+}
+
+func (c *updatableNSGsCloud) NetworkSecurityGroup() azure.NetworkSecurityGroupsClient {
+	return c.nsgs
+}
+
+func TestDeleteSharedNetworkSecurityGroupRules(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	rule := func(name string) *network.SecurityRule {
+		return &network.SecurityRule{
+			ID:         to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/networkSecurityGroups/nsg/securityRules/%s", rgName, name)),
+			Name:       to.Ptr(name),
+			Properties: &network.SecurityRulePropertiesFormat{},
+		}
+	}
+
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.NetworkSecurityGroupsClient.NSGs["nsg"] = &network.SecurityGroup{
+		ID:   to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/networkSecurityGroups/nsg", rgName)),
+		Name: to.Ptr("nsg"),
+		Tags: map[string]*string{
+			azure.TagNameClusterOwnershipPrefix + clusterName: to.Ptr("shared"),
+		},
+		Properties: &network.SecurityGroupPropertiesFormat{
+			SecurityRules: []*network.SecurityRule{
+				rule("AllowSSH"),
+				rule("AllowKubernetesAPI_v6"),
+				rule("AllowCorporateVPN"),
+			},
+		},
+	}
+	cloud := &updatableNSGsCloud{
+		MockAzureCloud: mock,
+		nsgs:           &updatableNSGsClient{MockNetworkSecurityGroupsClient: mock.NetworkSecurityGroupsClient},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	var deleted []string
+	for _, r := range rs {
+		if r.Shared {
+			if r.Type != typeNetworkSecurityGroup {
+				t.Errorf("expected only the security group to be shared, but got %s %q", r.Type, r.Name)
+			}
+			continue
+		}
+		if r.Type != typeNetworkSecurityRule {
+			continue
+		}
+		if err := r.Deleter(cloud, r); err != nil {
+			t.Fatalf("unexpected error deleting %q: %s", r.Name, err)
+		}
+		deleted = append(deleted, r.Name)
+	}
+	sort.Strings(deleted)
+	if expected := []string{"AllowKubernetesAPI_v6", "AllowSSH"}; !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("expected rules %v to be deleted, but got %v", expected, deleted)
+	}
+
+	nsg, ok := mock.NetworkSecurityGroupsClient.NSGs["nsg"]
+	if !ok {
+		t.Fatalf("expected the shared security group to be kept")
+	}
+	var remaining []string
+	for _, nsr := range nsg.Properties.SecurityRules {
+		remaining = append(remaining, *nsr.Name)
+	}
+	if expected := []string{"AllowCorporateVPN"}; !reflect.DeepEqual(remaining, expected) {
+		t.Errorf("expected rules %v to remain, but got %v", expected, remaining)
+	}
+}
+
+func TestListApplicationSecurityGroups(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	asgID := func(name string) *string {
+		return to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/applicationSecurityGroups/%s", rgName, name))
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ApplicationSecurityGroupsClient.ASGs["nodes"] = &network.ApplicationSecurityGroup{
+		ID:   asgID("nodes"),
+		Name: to.Ptr("nodes"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+	}
+	cloud.ApplicationSecurityGroupsClient.ASGs["other-nodes"] = &network.ApplicationSecurityGroup{
+		ID:   asgID("other-nodes"),
+		Name: to.Ptr("other-nodes"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr("other-cluster"),
+		},
+	}
+	cloud.NetworkSecurityGroupsClient.NSGs["nsg"] = &network.SecurityGroup{
+		Name: to.Ptr("nsg"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &network.SecurityGroupPropertiesFormat{
+			SecurityRules: []*network.SecurityRule{
+				{
+					Name: to.Ptr("AllowNodesToNodes"),
+					Properties: &network.SecurityRulePropertiesFormat{
+						SourceApplicationSecurityGroups: []*network.ApplicationSecurityGroup{
+							{ID: asgID("nodes")},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	asgKey := toKey(typeApplicationSecurityGroup, "nodes")
+	if _, ok := rs[asgKey]; !ok {
+		t.Errorf("expected the application security group of the cluster to be listed")
+	}
+	if _, ok := rs[toKey(typeApplicationSecurityGroup, "other-nodes")]; ok {
+		t.Errorf("expected the application security group of another cluster not to be listed")
+	}
+	nsg, ok := rs[toKey(typeNetworkSecurityGroup, "nsg")]
+	if !ok {
+		t.Fatalf("expected the network security group to be listed")
+	}
+	if !slices.Contains(nsg.Blocks, asgKey) {
+		t.Errorf("expected the network security group to block the application security group its rules refer to, but got %v", nsg.Blocks)
+	}
+}
+
+// updatableRouteTablesClient is a MockRouteTablesClient that supports updates.
+type updatableRouteTablesClient struct {
+	*azuretasks.MockRouteTablesClient
+}
+
+func (c *updatableRouteTablesClient) CreateOrUpdate(ctx context.Context, resourceGroupName, routeTableName string, parameters network.RouteTable) (*network.RouteTable, error) {
+	c.RTs[routeTableName] = &parameters
+	return &parameters, nil
+}
+
+type updatableRouteTablesCloud struct {
+	*azuretasks.MockAzureCloud
+	rts *updatableRouteTablesClient
+}
+
+func (c *updatableRouteTablesCloud) RouteTable() azure.RouteTablesClient {
+	return c.rts
+}
+
+func TestListRoutesToClusterIPs(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	route := func(name, nextHop string) *network.Route {
+		return &network.Route{
+			ID:   to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/routeTables/rt/routes/%s", rgName, name)),
+			Name: to.Ptr(name),
+			Properties: &network.RoutePropertiesFormat{
+				AddressPrefix:    to.Ptr("100.96.1.0/24"),
+				NextHopType:      to.Ptr(network.RouteNextHopTypeVirtualAppliance),
+				NextHopIPAddress: to.Ptr(nextHop),
+			},
+		}
+	}
+
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+		Name: to.Ptr("vnet"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &network.VirtualNetworkPropertiesFormat{
+			AddressSpace: &network.AddressSpace{
+				AddressPrefixes: []*string{to.Ptr("10.0.0.0/16")},
+			},
+		},
+	}
+	mock.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		ID:   to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/routeTables/rt", rgName)),
+		Name: to.Ptr("rt"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &network.RouteTablePropertiesFormat{
+			Routes: []*network.Route{
+				route("node-1", "10.0.1.4"),
+				route("firewall", "192.168.0.4"),
+			},
+		},
+	}
+	cloud := &updatableRouteTablesCloud{
+		MockAzureCloud: mock,
+		rts:            &updatableRouteTablesClient{MockRouteTablesClient: mock.RouteTablesClient},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	var routes []*resources.Resource
+	for _, r := range rs {
+		if r.Type == typeRoute {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) != 1 || routes[0].ID != "rt/node-1" {
+		t.Fatalf("expected only the route to the cluster IP, but got %v", routes)
+	}
+	r := routes[0]
+	if !slices.Contains(r.Blocks, toKey(typeRouteTable, "rt")) {
+		t.Errorf("expected the route to block its route table, but got %v", r.Blocks)
+	}
+	if err := r.Deleter(cloud, r); err != nil {
+		t.Fatalf("unexpected error deleting %q: %s", r.Name, err)
+	}
+
+	var remaining []string
+	for _, route := range mock.RouteTablesClient.RTs["rt"].Properties.Routes {
+		remaining = append(remaining, *route.Name)
+	}
+	if expected := []string{"firewall"}; !reflect.DeepEqual(remaining, expected) {
+		t.Errorf("expected routes %v to remain, but got %v", expected, remaining)
+	}
+}
+
+func TestListNetworkWatchers(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	watcherID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/networkWatchers/NetworkWatcher_eastus", rgName)
+	watcherKey := toKey(typeNetworkWatcher, "NetworkWatcher_eastus")
+
+	newCloud := func() *azuretasks.MockAzureCloud {
+		cloud := azuretasks.NewMockAzureCloud("eastus")
+		cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+			Name: to.Ptr(rgName),
+			Tags: map[string]*string{
+				azure.TagClusterName: to.Ptr(clusterName),
+			},
+		}
+		// Network watchers are created by Azure, without the tags of the cluster.
+		cloud.ResourcesClient.Resources[watcherID] = &armresources.GenericResourceExpanded{
+			ID:   to.Ptr(watcherID),
+			Name: to.Ptr("NetworkWatcher_eastus"),
+			Type: to.Ptr("Microsoft.Network/networkWatchers"),
+		}
+		return cloud
+	}
+
+	for _, include := range []bool{false, true} {
+		t.Run(fmt.Sprintf("include %t", include), func(t *testing.T) {
+			cloud := newCloud()
+			rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+				Name:                        clusterName,
+				AzureResourceGroupName:      rgName,
+				AzureIncludeSystemResources: include,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			watcher, ok := rs[watcherKey]
+			if ok != include {
+				t.Fatalf("expected %q to be listed: %t, but got %t", watcherKey, include, ok)
+			}
+			if !include {
+				return
+			}
+			if expected := []string{toKey(typeResourceGroup, rgName)}; !reflect.DeepEqual(watcher.Blocks, expected) {
+				t.Errorf("expected %v, but got %v", expected, watcher.Blocks)
+			}
+			if err := watcher.Deleter(cloud, watcher); err != nil {
+				t.Fatalf("unexpected error deleting network watcher: %s", err)
+			}
+			if _, ok := cloud.ResourcesClient.Resources[watcherID]; ok {
+				t.Errorf("expected network watcher to be deleted")
+			}
+		})
+	}
+
+	t.Run("shared resource group", func(t *testing.T) {
+		rs, err := ListResourcesAzure(newCloud(), resources.ClusterInfo{
+			Name:                        clusterName,
+			AzureResourceGroupName:      rgName,
+			AzureResourceGroupShared:    true,
+			AzureIncludeSystemResources: true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if _, ok := rs[watcherKey]; ok {
+			t.Errorf("expected %q not to be listed in a shared resource group", watcherKey)
+		}
+	})
+}
+
+func TestPrivateEndpointTargetKey(t *testing.T) {
+	testCases := []struct {
+		id       string
+		expected string
+	}{
+		{
+			id:       "/subscriptions/sid/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/sa",
+			expected: toKey(typeStorageAccount, "sa"),
+		},
+		{
+			id:       "/subscriptions/sid/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv",
+			expected: toKey(typeKeyVault, "kv"),
+		},
+		{
+			id:       "/subscriptions/sid/resourceGroups/rg/providers/Microsoft.Sql/servers/sql",
+			expected: "",
+		},
+		{
+			id:       "invalid",
+			expected: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			actual := privateEndpointTargetKey(tc.id)
+			if actual != tc.expected {
+				t.Errorf("expected %q, but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestListGatewayLoadBalancers(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.LoadBalancersClient.LBs["gwlb"] = &network.LoadBalancer{
+		Name: to.Ptr("gwlb"),
+		Tags: clusterTags,
+		SKU: &network.LoadBalancerSKU{
+			Name: to.Ptr(network.LoadBalancerSKUNameGateway),
+		},
+		Properties: &network.LoadBalancerPropertiesFormat{
+			FrontendIPConfigurations: []*network.FrontendIPConfiguration{
+				{
+					Name:       to.Ptr("gateway"),
+					Properties: &network.FrontendIPConfigurationPropertiesFormat{},
+				},
+			},
+		},
+	}
+	cloud.LoadBalancersClient.LBs["api"] = &network.LoadBalancer{
+		Name: to.Ptr("api"),
+		Tags: clusterTags,
+		SKU: &network.LoadBalancerSKU{
+			Name: to.Ptr(network.LoadBalancerSKUNameStandard),
+		},
+		Properties: &network.LoadBalancerPropertiesFormat{
+			FrontendIPConfigurations: []*network.FrontendIPConfiguration{
+				{
+					Name: to.Ptr("api"),
+					Properties: &network.FrontendIPConfigurationPropertiesFormat{
+						GatewayLoadBalancer: &network.SubResource{
+							ID: to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/loadBalancers/gwlb/frontendIPConfigurations/gateway", rgName)),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	api := rs[toKey(typeLoadBalancer, "api")]
+	if !slices.Contains(api.Blocks, toKey(typeLoadBalancer, "gwlb")) {
+		t.Errorf("expected the chained load balancer to block the gateway load balancer, but got %v", api.Blocks)
+	}
+	if sku := rs[toKey(typeLoadBalancer, "gwlb")].Metadata[metadataSKU]; sku != string(network.LoadBalancerSKUNameGateway) {
+		t.Errorf("expected the SKU of the gateway load balancer to be recorded, but got %q", sku)
+	}
+
+	order, err := deletionOrder(rs)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if slices.Index(order, toKey(typeLoadBalancer, "api")) > slices.Index(order, toKey(typeLoadBalancer, "gwlb")) {
+		t.Errorf("expected the chained load balancer to be deleted before the gateway load balancer, but got %v", order)
+	}
+}
+
+func TestListPublicIPAddressAssociation(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	ipConfig := func(id string) *network.PublicIPAddressPropertiesFormat {
+		return &network.PublicIPAddressPropertiesFormat{
+			IPConfiguration: &network.IPConfiguration{
+				ID: to.Ptr(id),
+			},
+		}
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip-lb"] = &network.PublicIPAddress{
+		Name:       to.Ptr("pip-lb"),
+		Tags:       clusterTags,
+		Properties: ipConfig("/subscriptions/sid/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/api/frontendIPConfigurations/fe"),
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip-ngw"] = &network.PublicIPAddress{
+		Name: to.Ptr("pip-ngw"),
+		Tags: clusterTags,
+		Properties: &network.PublicIPAddressPropertiesFormat{
+			NatGateway: &network.NatGateway{
+				ID: to.Ptr("/subscriptions/sid/resourceGroups/rg/providers/Microsoft.Network/natGateways/ngw"),
+			},
+		},
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip-vmss"] = &network.PublicIPAddress{
+		Name:       to.Ptr("pip-vmss"),
+		Tags:       clusterTags,
+		Properties: ipConfig("/subscriptions/sid/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/nodes/virtualMachines/0/networkInterfaces/nic/ipConfigurations/ip"),
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip-unattached"] = &network.PublicIPAddress{
+		Name:       to.Ptr("pip-unattached"),
+		Tags:       clusterTags,
+		Properties: &network.PublicIPAddressPropertiesFormat{},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	testCases := map[string]string{
+		"pip-lb":         "LoadBalancer",
+		"pip-ngw":        "NatGateway",
+		"pip-vmss":       "NetworkInterface",
+		"pip-unattached": "",
+	}
+	for name, expected := range testCases {
+		r, ok := rs[toKey(typePublicIPAddress, name)]
+		if !ok {
+			t.Fatalf("expected public IP address %q to be listed", name)
+		}
+		if actual := r.Metadata[metadataAssociation]; actual != expected {
+			t.Errorf("expected %q to be associated with %q, but got %q", name, expected, actual)
+		}
+	}
+}
+
+func TestListCapacityMetadata(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: clusterTags,
+		SKU: &compute.SKU{
+			Name:     to.Ptr("Standard_D4s_v3"),
+			Tier:     to.Ptr("Standard"),
+			Capacity: to.Ptr[int64](3),
+		},
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+	}
+	cloud.DisksClient.Disks["etcd"] = &compute.Disk{
+		Name: to.Ptr("etcd"),
+		Tags: clusterTags,
+		SKU: &compute.DiskSKU{
+			Name: to.Ptr(compute.DiskStorageAccountTypesPremiumLRS),
+		},
+		Properties: &compute.DiskProperties{
+			DiskSizeGB: to.Ptr[int32](20),
+			Tier:       to.Ptr("P4"),
+		},
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip"] = &network.PublicIPAddress{
+		Name: to.Ptr("pip"),
+		Tags: clusterTags,
+		SKU: &network.PublicIPAddressSKU{
+			Name: to.Ptr(network.PublicIPAddressSKUNameStandard),
+			Tier: to.Ptr(network.PublicIPAddressSKUTierRegional),
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	expected := map[string]map[string]string{
+		toKey(typeVMScaleSet, "nodes"): {
+			metadataSKU:      "Standard_D4s_v3",
+			metadataTier:     "Standard",
+			metadataCapacity: "3",
+		},
+		toKey(typeDisk, "etcd"): {
+			metadataSKU:    "Premium_LRS",
+			metadataTier:   "P4",
+			metadataSizeGB: "20",
+		},
+		toKey(typePublicIPAddress, "pip"): {
+			metadataSKU:  "Standard",
+			metadataTier: "Regional",
+		},
+	}
+	for key, metadata := range expected {
+		r, ok := rs[key]
+		if !ok {
+			t.Fatalf("expected %q to be listed", key)
+		}
+		if !reflect.DeepEqual(r.Metadata, metadata) {
+			t.Errorf("expected metadata of %q to be %v, but got %v", key, metadata, r.Metadata)
+		}
+	}
+}
+
+func TestTagBeforeDelete(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	rgID := "/subscriptions/sid/resourceGroups/" + rgName
+	diskID := rgID + "/providers/Microsoft.Compute/disks/disk"
+
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		ID:   to.Ptr(rgID),
+		Tags: clusterTags,
+	}
+	mock.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		ID:   to.Ptr(diskID),
+		Tags: clusterTags,
+	}
+	cloud := &recordingDeleteCloud{MockAzureCloud: mock}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+		AzureTagBeforeDelete:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(cloud.calls) != 0 {
+		t.Fatalf("expected listing not to tag anything, but got %v", cloud.calls)
+	}
+
+	order, err := deletionOrder(rs)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for _, k := range order {
+		r := rs[k]
+		if err := r.Deleter(cloud, r); err != nil {
+			t.Fatalf("unexpected error deleting %q: %s", k, err)
+		}
+	}
+
+	expected := []string{
+		"tags:" + diskID,
+		"Disk:disk",
+		"tags:" + rgID,
+		"ResourceGroup:rg",
+	}
+	if !reflect.DeepEqual(cloud.calls, expected) {
+		t.Errorf("expected calls %v, but got %v", expected, cloud.calls)
+	}
+	for _, id := range []string{diskID, rgID} {
+		v := mock.ResourcesClient.UpdatedTags[id][tagDeleting]
+		if v == nil {
+			t.Fatalf("expected %q to be tagged with %q", id, tagDeleting)
+		}
+		if _, err := time.Parse(time.RFC3339, *v); err != nil {
+			t.Errorf("expected a timestamp, but got %q: %v", *v, err)
+		}
+	}
+}
+
+func TestListVMScaleSetsWithSurge(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	igTags := map[string]*string{
+		azure.TagClusterName:        to.Ptr(clusterName),
+		"kops.k8s.io_instancegroup": to.Ptr("nodes"),
+	}
+	subnetID := azure.SubnetID{
+		SubscriptionID:     "sid",
+		ResourceGroupName:  rgName,
+		VirtualNetworkName: "vnet",
+		SubnetName:         "sub",
+	}
+	newVMSS := func(name, principalID string) *compute.VirtualMachineScaleSet {
+		return &compute.VirtualMachineScaleSet{
+			Name: to.Ptr(name),
+			Tags: igTags,
+			Properties: &compute.VirtualMachineScaleSetProperties{
+				VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+					NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
+						NetworkInterfaceConfigurations: []*compute.VirtualMachineScaleSetNetworkConfiguration{
+							{
+								Properties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+									IPConfigurations: []*compute.VirtualMachineScaleSetIPConfiguration{
+										{
+											Properties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+												Subnet: &compute.APIEntityReference{
+													ID: to.Ptr(subnetID.String()),
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			Identity: &compute.VirtualMachineScaleSetIdentity{
+				Type:        to.Ptr(compute.ResourceIdentityTypeSystemAssigned),
+				PrincipalID: to.Ptr(principalID),
+			},
+		}
+	}
+	newVM := func(diskName string) *compute.VirtualMachineScaleSetVM {
+		return &compute.VirtualMachineScaleSetVM{
+			Properties: &compute.VirtualMachineScaleSetVMProperties{
+				StorageProfile: &compute.StorageProfile{
+					DataDisks: []*compute.DataDisk{
+						{
+							Name: to.Ptr(diskName),
+						},
+					},
+				},
+			},
+		}
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = newVMSS("nodes", "pid")
+	cloud.VMScaleSetsClient.VMSSes["nodes-surge"] = newVMSS("nodes-surge", "pid-surge")
+	cloud.VMScaleSetVMsClient.VMs["nodes/0"] = newVM("disk")
+	cloud.VMScaleSetVMsClient.VMs["nodes-surge/0"] = newVM("disk-surge")
+	for _, name := range []string{"disk", "disk-surge"} {
+		cloud.DisksClient.Disks[name] = &compute.Disk{
+			Name: to.Ptr(name),
+			Tags: igTags,
+		}
+	}
+	for name, principalID := range map[string]string{"ra": "pid", "ra-surge": "pid-surge"} {
+		cloud.RoleAssignmentsClient.RAs[name] = &authz.RoleAssignment{
+			Name: to.Ptr(name),
+			Properties: &authz.RoleAssignmentProperties{
+				Scope:       to.Ptr("scope"),
+				PrincipalID: to.Ptr(principalID),
+			},
+		}
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	testCases := []struct {
+		key      string
+		expected []string
+	}{
+		{
+			key: toKey(typeVMScaleSet, "nodes"),
+			expected: []string{
+				toKey(typeResourceGroup, rgName),
+				toKey(typeVirtualNetwork, "vnet"),
+				toKey(typeSubnet, "sub"),
+				toKey(typeDisk, "disk"),
+			},
+		},
+		{
+			key: toKey(typeVMScaleSet, "nodes-surge"),
+			expected: []string{
+				toKey(typeResourceGroup, rgName),
+				toKey(typeVirtualNetwork, "vnet"),
+				toKey(typeSubnet, "sub"),
+				toKey(typeDisk, "disk-surge"),
+			},
+		},
+		{
+			key: toKey(typeRoleAssignment, "ra"),
+			expected: []string{
+				toKey(typeResourceGroup, rgName),
+				toKey(typeVMScaleSet, "nodes"),
+			},
+		},
+		{
+			key: toKey(typeRoleAssignment, "ra-surge"),
+			expected: []string{
+				toKey(typeResourceGroup, rgName),
+				toKey(typeVMScaleSet, "nodes-surge"),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			r, ok := rs[tc.key]
+			if !ok {
+				t.Fatalf("expected %q to be listed", tc.key)
+			}
+			if !reflect.DeepEqual(r.Blocks, tc.expected) {
+				t.Errorf("expected %v, but got %v", tc.expected, r.Blocks)
+			}
+		})
+	}
+}
+
+func TestListRoleAssignmentsSharedPrincipal(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	for _, name := range []string{"nodes", "nodes-surge"} {
+		cloud.VMScaleSetsClient.VMSSes[name] = &compute.VirtualMachineScaleSet{
+			Name: to.Ptr(name),
+			Tags: map[string]*string{
+				azure.TagClusterName: to.Ptr(clusterName),
+			},
+			Properties: &compute.VirtualMachineScaleSetProperties{
+				VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+					NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+				},
+			},
+			Identity: &compute.VirtualMachineScaleSetIdentity{
+				Type:        to.Ptr(compute.ResourceIdentityTypeSystemAssigned),
+				PrincipalID: to.Ptr("pid"),
+			},
+		}
+	}
+	cloud.RoleAssignmentsClient.RAs["ra"] = &authz.RoleAssignment{
+		Name: to.Ptr("ra"),
+		Properties: &authz.RoleAssignmentProperties{
+			Scope:       to.Ptr("scope"),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	ra, ok := rs[toKey(typeRoleAssignment, "ra")]
+	if !ok {
+		t.Fatalf("expected role assignment to be listed")
+	}
+	actual := append([]string(nil), ra.Blocks...)
+	sort.Strings(actual)
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		toKey(typeVMScaleSet, "nodes"),
+		toKey(typeVMScaleSet, "nodes-surge"),
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestListVMScaleSetsWithoutIdentity(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+
+	// A VM Scale Set using a service principal has no managed identity.
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+	}
+	cloud.RoleAssignmentsClient.RAs["ra"] = &authz.RoleAssignment{
+		Name: to.Ptr("ra"),
+		Properties: &authz.RoleAssignmentProperties{
+			Scope:       to.Ptr("scope"),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listVMScaleSetsAndRoleAssignments(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var actual []string
+	for _, r := range rs {
+		actual = append(actual, toKey(r.Type, r.Name))
+	}
+	if expected := []string{toKey(typeVMScaleSet, "nodes")}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+// scopedRoleAssignmentsClient lists the role assignments of a principal made at
+// exactly the given scope, and records the scopes of the deletions. Listing all
+// the role assignments of a scope fails.
+type scopedRoleAssignmentsClient struct {
+	*azuretasks.MockRoleAssignmentsClient
+	deletedScopes map[string]string
+}
+
+func (c *scopedRoleAssignmentsClient) List(ctx context.Context, scope string) ([]*authz.RoleAssignment, error) {
+	return nil, fmt.Errorf("listing all the role assignments of %s", scope)
+}
+
+func (c *scopedRoleAssignmentsClient) ListAssignedTo(ctx context.Context, scope, principalID string) ([]*authz.RoleAssignment, error) {
+	var l []*authz.RoleAssignment
+	for _, ra := range c.RAs {
+		if roleAssignmentScope(ra) == scope && *ra.Properties.PrincipalID == principalID {
+			l = append(l, ra)
+		}
+	}
+	return l, nil
+}
+
+func (c *scopedRoleAssignmentsClient) Delete(ctx context.Context, scope, raName string) error {
+	c.deletedScopes[raName] = scope
+	return c.MockRoleAssignmentsClient.Delete(ctx, scope, raName)
+}
+
+type scopedRoleAssignmentsCloud struct {
+	*azuretasks.MockAzureCloud
+	ras *scopedRoleAssignmentsClient
+}
+
+func (c *scopedRoleAssignmentsCloud) SubscriptionID() string {
+	return "sid"
+}
+
+func (c *scopedRoleAssignmentsCloud) RoleAssignment() azure.RoleAssignmentsClient {
+	return c.ras
+}
+
+func TestListRoleAssignmentsAtSubscriptionScope(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		rgScope     = "/subscriptions/sid/resourceGroups/rg"
+		subScope    = "/subscriptions/sid"
+	)
+
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+		Identity: &compute.VirtualMachineScaleSetIdentity{
+			Type:        to.Ptr(compute.ResourceIdentityTypeSystemAssigned),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	mock.RoleAssignmentsClient.RAs["ra-rg"] = &authz.RoleAssignment{
+		ID:   to.Ptr(rgScope + "/providers/Microsoft.Authorization/roleAssignments/ra-rg"),
+		Name: to.Ptr("ra-rg"),
+		Properties: &authz.RoleAssignmentProperties{
+			Scope:       to.Ptr(rgScope),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	// The scope is not always returned; it is then taken from the ID.
+	mock.RoleAssignmentsClient.RAs["ra-sub"] = &authz.RoleAssignment{
+		ID:   to.Ptr(subScope + "/providers/Microsoft.Authorization/roleAssignments/ra-sub"),
+		Name: to.Ptr("ra-sub"),
+		Properties: &authz.RoleAssignmentProperties{
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	cloud := &scopedRoleAssignmentsCloud{
+		MockAzureCloud: mock,
+		ras: &scopedRoleAssignmentsClient{
+			MockRoleAssignmentsClient: mock.RoleAssignmentsClient,
+			deletedScopes:             make(map[string]string),
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for _, name := range []string{"ra-rg", "ra-sub"} {
+		ra, ok := rs[toKey(typeRoleAssignment, name)]
+		if !ok {
+			t.Fatalf("expected role assignment %q to be listed", name)
+		}
+		if err := ra.Deleter(cloud, ra); err != nil {
+			t.Fatalf("unexpected error deleting %q: %s", name, err)
+		}
+	}
+	expected := map[string]string{
+		"ra-rg":  rgScope,
+		"ra-sub": subScope,
+	}
+	if !reflect.DeepEqual(cloud.ras.deletedScopes, expected) {
+		t.Errorf("expected deletions at scopes %v, but got %v", expected, cloud.ras.deletedScopes)
+	}
+}
+
+func TestListRoleAssignmentsOfUnownedVMScaleSet(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	// The VM Scale Set lost its cluster tag, so it is not considered owned.
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Identity: &compute.VirtualMachineScaleSetIdentity{
+			Type:        to.Ptr(compute.ResourceIdentityTypeSystemAssigned),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	cloud.RoleAssignmentsClient.RAs["ra"] = &authz.RoleAssignment{
+		Name: to.Ptr("ra"),
+		Properties: &authz.RoleAssignmentProperties{
+			Scope:       to.Ptr("scope"),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	cloud.RoleAssignmentsClient.RAs["irrelevant"] = &authz.RoleAssignment{
+		Name: to.Ptr("irrelevant"),
+		Properties: &authz.RoleAssignmentProperties{
+			Scope:       to.Ptr("scope"),
+			PrincipalID: to.Ptr("other"),
+		},
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, unowned, err := g.findVMScaleSetsAndRoleAssignments(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 0 {
+		t.Errorf("expected no resources, but got %d", len(rs))
+	}
+	expected := []unownedRoleAssignment{
+		{
+			roleAssignmentName: "ra",
+			vmScaleSetName:     "nodes",
+		},
+	}
+	if !reflect.DeepEqual(unowned, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, unowned)
+	}
+}
+
+func TestPreserveResourceGroup(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: clusterTags,
+	}
+	cloud.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip"] = &network.PublicIPAddress{
+		Name: to.Ptr("pip"),
+		Tags: clusterTags,
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                       clusterName,
+		AzureResourceGroupName:     rgName,
+		AzurePreserveResourceGroup: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	rg, ok := rs[toKey(typeResourceGroup, rgName)]
+	if !ok {
+		t.Fatalf("expected the resource group to be listed")
+	}
+	if !rg.Shared {
+		t.Errorf("expected the preserved resource group to be shared")
+	}
+
+	order, err := deletionOrder(rs)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for _, k := range order {
+		if err := rs[k].Deleter(cloud, rs[k]); err != nil {
+			t.Fatalf("unexpected error deleting %s: %s", k, err)
+		}
+	}
+	if _, ok := cloud.ResourceGroupsClient.RGs[rgName]; !ok {
+		t.Errorf("expected the resource group to survive")
+	}
+	if n := len(cloud.RouteTablesClient.RTs) + len(cloud.DisksClient.Disks) + len(cloud.PublicIPAddressesClient.PubIPs); n != 0 {
+		t.Errorf("expected all children to be deleted, but %d remain", n)
+	}
+}
+
+func TestListVirtualMachinesFlexible(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	prefix := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers", rgName)
+	vmID := prefix + "/Microsoft.Compute/virtualMachines/vm"
+	nicID := prefix + "/Microsoft.Network/networkInterfaces/nic"
+	diskID := func(name string) *string {
+		return to.Ptr(prefix + "/Microsoft.Compute/disks/" + name)
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VirtualMachinesClient.VMs["vm"] = &compute.VirtualMachine{
+		ID:   to.Ptr(vmID),
+		Name: to.Ptr("vm"),
+		Tags: clusterTags,
+		Properties: &compute.VirtualMachineProperties{
+			VirtualMachineScaleSet: &compute.SubResource{
+				ID: to.Ptr(prefix + "/Microsoft.Compute/virtualMachineScaleSets/nodes"),
+			},
+			StorageProfile: &compute.StorageProfile{
+				DataDisks: []*compute.DataDisk{
+					{ManagedDisk: &compute.ManagedDiskParameters{ID: diskID("data-0")}},
+					{ManagedDisk: &compute.ManagedDiskParameters{ID: diskID("data-1")}},
+				},
+			},
+			NetworkProfile: &compute.NetworkProfile{
+				NetworkInterfaces: []*compute.NetworkInterfaceReference{
+					{ID: to.Ptr(nicID)},
+				},
+			},
+		},
+	}
+	// A virtual machine of another cluster, or created by hand.
+	cloud.VirtualMachinesClient.VMs["other"] = &compute.VirtualMachine{
+		Name: to.Ptr("other"),
+	}
+	cloud.NetworkInterfacesClient.NIs["nic"] = &network.Interface{
+		ID:   to.Ptr(nicID),
+		Name: to.Ptr("nic"),
+		Properties: &network.InterfacePropertiesFormat{
+			IPConfigurations: []*network.InterfaceIPConfiguration{
+				{
+					Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+						PublicIPAddress: &network.PublicIPAddress{
+							ID: to.Ptr(prefix + "/Microsoft.Network/publicIPAddresses/pip"),
+						},
+					},
+				},
+			},
+		},
+	}
+	// The data disks of the virtual machine do not have the tags of the cluster.
+	for _, name := range []string{"data-0", "data-1"} {
+		cloud.DisksClient.Disks[name] = &compute.Disk{
+			ID:        diskID(name),
+			Name:      to.Ptr(name),
+			ManagedBy: to.Ptr(vmID),
+		}
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listVirtualMachines(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("expected only the virtual machine of the cluster to be listed, but got %d", len(rs))
+	}
+	vm := rs[0]
+	if vm.Type != typeVirtualMachine || vm.Name != "vm" {
+		t.Errorf("unexpected resource %s %q", vm.Type, vm.Name)
+	}
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		toKey(typeVMScaleSet, "nodes"),
+		toKey(typeDisk, "data-0"),
+		toKey(typeDisk, "data-1"),
+		toKey(typePublicIPAddress, "pip"),
+	}
+	if !reflect.DeepEqual(vm.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, vm.Blocks)
+	}
+
+	disks, err := g.listDisks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var diskNames []string
+	for _, r := range disks {
+		diskNames = append(diskNames, r.Name)
+	}
+	sort.Strings(diskNames)
+	if expected := []string{"data-0", "data-1"}; !reflect.DeepEqual(diskNames, expected) {
+		t.Errorf("expected the data disks %v to be listed, but got %v", expected, diskNames)
+	}
+
+	if err := vm.Deleter(cloud, vm); err != nil {
+		t.Fatalf("unexpected error deleting virtual machine: %s", err)
+	}
+	if _, ok := cloud.VirtualMachinesClient.VMs["vm"]; ok {
+		t.Errorf("expected virtual machine to be deleted")
+	}
+}
+
+func TestListNetworkInterfaces(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	prefix := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers", rgName)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.NetworkInterfacesClient.NIs["nic"] = &network.Interface{
+		ID:   to.Ptr(prefix + "/Microsoft.Network/networkInterfaces/nic"),
+		Name: to.Ptr("nic"),
+		Tags: clusterTags,
+		Properties: &network.InterfacePropertiesFormat{
+			VirtualMachine: &network.SubResource{
+				ID: to.Ptr(prefix + "/Microsoft.Compute/virtualMachines/vm"),
+			},
+			IPConfigurations: []*network.InterfaceIPConfiguration{
+				{
+					Properties: &network.InterfaceIPConfigurationPropertiesFormat{
+						Subnet: &network.Subnet{
+							ID: to.Ptr(prefix + "/Microsoft.Network/virtualNetworks/vnet/subnets/subnet"),
+						},
+						PublicIPAddress: &network.PublicIPAddress{
+							ID: to.Ptr(prefix + "/Microsoft.Network/publicIPAddresses/pip"),
+						},
+					},
+				},
+			},
+		},
+	}
+	// A network interface of another cluster, or created by hand.
+	cloud.NetworkInterfacesClient.NIs["other"] = &network.Interface{
+		Name: to.Ptr("other"),
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listNetworkInterfaces(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("expected only the network interface of the cluster to be listed, but got %d", len(rs))
+	}
+	nic := rs[0]
+	if nic.Type != typeNetworkInterface || nic.Name != "nic" {
+		t.Errorf("unexpected resource %s %q", nic.Type, nic.Name)
+	}
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		toKey(typeSubnet, "subnet"),
+		toKey(typePublicIPAddress, "pip"),
+	}
+	if !reflect.DeepEqual(nic.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, nic.Blocks)
+	}
+	if expected := []string{toKey(typeVirtualMachine, "vm")}; !reflect.DeepEqual(nic.Blocked, expected) {
+		t.Errorf("expected %v, but got %v", expected, nic.Blocked)
+	}
+
+	if err := nic.Deleter(cloud, nic); err != nil {
+		t.Fatalf("unexpected error deleting network interface: %s", err)
+	}
+	if _, ok := cloud.NetworkInterfacesClient.NIs["nic"]; ok {
+		t.Errorf("expected network interface to be deleted")
+	}
+}
+
+func TestListDisksManagedBy(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	managedBy := func(vmssName string) *string {
+		return to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Compute/virtualMachineScaleSets/%s/virtualMachines/0", rgName, vmssName))
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: clusterTags,
+	}
+	cloud.VMScaleSetsClient.VMSSes["other"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("other"),
+	}
+	disks := cloud.DisksClient.Disks
+	// Tagged, but no longer attached after a scale-in.
+	disks["orphaned"] = &compute.Disk{
+		Name: to.Ptr("orphaned"),
+		Tags: clusterTags,
+	}
+	// Attached to a cluster VM Scale Set, but not tagged yet.
+	disks["untagged"] = &compute.Disk{
+		Name:      to.Ptr("untagged"),
+		ManagedBy: managedBy("nodes"),
+	}
+	// Attached to a VM Scale Set of another cluster.
+	disks["irrelevant"] = &compute.Disk{
+		Name:      to.Ptr("irrelevant"),
+		ManagedBy: managedBy("other"),
+	}
+	// Tagged with the cluster, but attached to a VM Scale Set of another cluster.
+	disks["foreign"] = &compute.Disk{
+		Name:      to.Ptr("foreign"),
+		Tags:      clusterTags,
+		ManagedBy: managedBy("other"),
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listDisks(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	actual := map[string][]string{}
+	for _, r := range rs {
+		actual[r.Name] = r.Blocked
+	}
+	expected := map[string][]string{
+		"orphaned": nil,
+		"untagged": {toKey(typeVMScaleSet, "nodes")},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestListVMScaleSetsMultipleSubnets(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		vnetName    = "vnet"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	var ipConfigs []*compute.VirtualMachineScaleSetIPConfiguration
+	for _, subnetName := range []string{"sub-3", "sub-1", "sub-2"} {
+		subnetID := azure.SubnetID{
+			SubscriptionID:     "sid",
+			ResourceGroupName:  rgName,
+			VirtualNetworkName: vnetName,
+			SubnetName:         subnetName,
+		}
+		ipConfigs = append(ipConfigs, &compute.VirtualMachineScaleSetIPConfiguration{
+			Properties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+				Subnet: &compute.APIEntityReference{
+					ID: to.Ptr(subnetID.String()),
+				},
+			},
+		})
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: clusterTags,
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
+					NetworkInterfaceConfigurations: []*compute.VirtualMachineScaleSetNetworkConfiguration{
+						{
+							Properties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+								IPConfigurations: ipConfigs,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listVMScaleSetsAndRoleAssignments(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("expected 1 resource, but got %d", len(rs))
+	}
+
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		toKey(typeVirtualNetwork, vnetName),
+		toKey(typeSubnet, "sub-1"),
+		toKey(typeSubnet, "sub-2"),
+		toKey(typeSubnet, "sub-3"),
+	}
+	if !reflect.DeepEqual(rs[0].Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, rs[0].Blocks)
+	}
+}
+
+func TestListManagedIdentities(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	identityID := azure.ManagedIdentityID{
+		SubscriptionID:      "sid",
+		ResourceGroupName:   rgName,
+		ManagedIdentityName: "control-plane",
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ManagedIdentitiesClient.Identities["control-plane"] = &azure.ManagedIdentity{
+		ID:   to.Ptr(identityID.String()),
+		Name: to.Ptr("control-plane"),
+		Tags: clusterTags,
+	}
+	cloud.ManagedIdentitiesClient.Identities["other"] = &azure.ManagedIdentity{
+		ID:   to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.ManagedIdentity/userAssignedIdentities/other", rgName)),
+		Name: to.Ptr("other"),
+	}
+	cloud.VMScaleSetsClient.VMSSes["control-plane"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("control-plane"),
+		Tags: clusterTags,
+		Identity: &compute.VirtualMachineScaleSetIdentity{
+			Type: to.Ptr(compute.ResourceIdentityTypeUserAssigned),
+			UserAssignedIdentities: map[string]*compute.VirtualMachineScaleSetIdentityUserAssignedIdentitiesValue{
+				identityID.String(): {},
+			},
+		},
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listManagedIdentities(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 1 || rs[0].Name != "control-plane" {
+		t.Fatalf("expected only the tagged managed identity to be listed, but got %v", rs)
+	}
+	identityKey := toKey(typeManagedIdentity, "control-plane")
+
+	// The identity is deleted only after the VM Scale Set using it.
+	rs, err = g.listVMScaleSetsAndRoleAssignments(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("expected 1 resource, but got %d", len(rs))
+	}
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		identityKey,
+	}
+	if !reflect.DeepEqual(rs[0].Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, rs[0].Blocks)
+	}
+}
+
+func TestListPrivateDNSZones(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		zoneName    = "cluster.example.com"
+	)
+	prefix := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network", rgName)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.PrivateDNSZonesClient.Zones[zoneName] = &azure.PrivateDNSZone{
+		ID:   to.Ptr(prefix + "/privateDnsZones/" + zoneName),
+		Name: to.Ptr(zoneName),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+	}
+	// A zone of another cluster, or created by hand.
+	cloud.PrivateDNSZonesClient.Zones["other.example.com"] = &azure.PrivateDNSZone{
+		Name: to.Ptr("other.example.com"),
+	}
+	cloud.PrivateDNSZonesClient.RecordSets[zoneName] = []*azure.PrivateDNSRecordSet{
+		{Name: to.Ptr("@"), Type: to.Ptr("Microsoft.Network/privateDnsZones/SOA")},
+		{Name: to.Ptr("api"), Type: to.Ptr("Microsoft.Network/privateDnsZones/A")},
+		{Name: to.Ptr("api.internal"), Type: to.Ptr("Microsoft.Network/privateDnsZones/A")},
+	}
+	cloud.PrivateDNSZonesClient.Links[zoneName] = []*azure.PrivateDNSVirtualNetworkLink{
+		{
+			Name: to.Ptr("vnet-link"),
+			Properties: &azure.PrivateDNSVirtualNetworkLinkProperties{
+				VirtualNetwork: &azure.PrivateDNSSubResource{
+					ID: to.Ptr(prefix + "/virtualNetworks/vnet"),
+				},
+			},
+		},
+	}
+
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			Name:                   clusterName,
+			AzureResourceGroupName: rgName,
+		},
+	}
+	rs, err := g.listPrivateDNSZones(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	resourceMap := make(map[string]*resources.Resource)
+	for _, r := range rs {
+		resourceMap[toKey(r.Type, r.ID)] = r
+	}
+
+	zoneKey := toKey(typePrivateDNSZone, zoneName)
+	expected := map[string][]string{
+		zoneKey: {toKey(typeResourceGroup, rgName)},
+		toKey(typePrivateDNSRecordSet, zoneName+"/A/api"):          {zoneKey},
+		toKey(typePrivateDNSRecordSet, zoneName+"/A/api.internal"): {zoneKey},
+		toKey(typePrivateDNSZoneLink, zoneName+"/vnet-link"):       {zoneKey, toKey(typeVirtualNetwork, "vnet")},
+	}
+	if len(resourceMap) != len(expected) {
+		t.Errorf("expected %d resources, but got %d", len(expected), len(resourceMap))
+	}
+	for k, blocks := range expected {
+		r, ok := resourceMap[k]
+		if !ok {
+			t.Errorf("expected %q to be listed", k)
+			continue
+		}
+		if !reflect.DeepEqual(r.Blocks, blocks) {
+			t.Errorf("expected %q to block %v, but got %v", k, blocks, r.Blocks)
+		}
+	}
+
+	// The zone is deleted last, as its links and record sets block it.
+	keys := []string{
+		toKey(typePrivateDNSZoneLink, zoneName+"/vnet-link"),
+		toKey(typePrivateDNSRecordSet, zoneName+"/A/api"),
+		toKey(typePrivateDNSRecordSet, zoneName+"/A/api.internal"),
+		zoneKey,
+	}
+	for _, k := range keys {
+		r := resourceMap[k]
+		if r == nil {
+			t.Fatalf("expected %q to be listed", k)
+		}
+		if err := r.Deleter(cloud, r); err != nil {
+			t.Fatalf("unexpected error deleting %q: %s", k, err)
+		}
+	}
+	if _, ok := cloud.PrivateDNSZonesClient.Zones[zoneName]; ok {
+		t.Errorf("expected private DNS zone to be deleted")
+	}
+	if _, ok := cloud.PrivateDNSZonesClient.Zones["other.example.com"]; !ok {
+		t.Errorf("expected the private DNS zone of another cluster to be kept")
+	}
+}
+
+func TestToResourceMapDuplicateNames(t *testing.T) {
+	diskID := func(rgName string) string {
+		id := azure.DiskID{
+			SubscriptionID:    "sid",
+			ResourceGroupName: rgName,
+			DiskName:          "disk",
+		}
+		return id.String()
+	}
+	g := &resourceGetter{
+		clusterInfo: resources.ClusterInfo{
+			Name:                   "cluster",
+			AzureResourceGroupName: "rg1",
+		},
+	}
+	disk1 := g.toDiskResource(&compute.Disk{Name: to.Ptr("disk"), ID: to.Ptr(diskID("rg1"))}, nil)
+	disk2 := g.toDiskResource(&compute.Disk{Name: to.Ptr("disk"), ID: to.Ptr(diskID("rg2"))}, nil)
+	vmss := &resources.Resource{
+		Type:   typeVMScaleSet,
+		ID:     "vmss",
+		Name:   "vmss",
+		Blocks: []string{toKey(typeResourceGroup, "rg1"), toKey(typeDisk, "disk")},
+	}
+
+	rs, err := g.toResourceMap([]*resources.Resource{disk1, disk2, vmss})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	key1 := toKey(typeDisk, diskID("rg1"))
+	key2 := toKey(typeDisk, diskID("rg2"))
+	if rs[key1] != disk1 {
+		t.Errorf("expected disk in rg1 to be keyed %q", key1)
+	}
+	if rs[key2] != disk2 {
+		t.Errorf("expected disk in rg2 to be keyed %q", key2)
+	}
+	if _, ok := rs[toKey(typeDisk, "disk")]; ok {
+		t.Errorf("expected the ambiguous key not to be used")
+	}
+	if len(rs) != 3 {
+		t.Errorf("expected 3 resources, but got %d", len(rs))
+	}
+	for _, r := range []*resources.Resource{disk1, disk2} {
+		if r.Name != "disk" {
+			t.Errorf("expected name to be kept, but got %q", r.Name)
+		}
+	}
+
+	expected := []string{toKey(typeResourceGroup, "rg1"), key1, key2}
+	if !reflect.DeepEqual(vmss.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, vmss.Blocks)
+	}
+
+	// Each disk is deleted from its own resource group.
+	disks := &recordingDisksClient{MockDisksClient: &azuretasks.MockDisksClient{Disks: map[string]*compute.Disk{"disk": {}}}}
+	g.cloud = &recordingDisksCloud{MockAzureCloud: azuretasks.NewMockAzureCloud("eastus"), disks: disks}
+	if err := disk2.Deleter(g.cloud, disk2); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(disks.deletedFrom, []string{"rg2"}) {
+		t.Errorf("expected the disk to be deleted from rg2, but got %v", disks.deletedFrom)
+	}
+}
+
+func TestToResourceMapDuplicatesWithoutARMIDs(t *testing.T) {
+	g := &resourceGetter{
+		clusterInfo: resources.ClusterInfo{
+			Name:                   "cluster",
+			AzureResourceGroupName: "rg",
+		},
+	}
+	diskID := to.Ptr("/subscriptions/sid/resourceGroups/rg/providers/Microsoft.Compute/disks/disk")
+
+	// The same disk listed twice is kept once.
+	rs, err := g.toResourceMap([]*resources.Resource{
+		g.toDiskResource(&compute.Disk{Name: to.Ptr("disk"), ID: diskID}, nil),
+		g.toDiskResource(&compute.Disk{Name: to.Ptr("disk"), ID: diskID}, nil),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 1 {
+		t.Errorf("expected 1 resource, but got %d", len(rs))
+	}
+
+	// Disks that cannot be told apart are an error, rather than one silently
+	// replacing the other.
+	_, err = g.toResourceMap([]*resources.Resource{
+		g.toDiskResource(&compute.Disk{Name: to.Ptr("disk"), ID: diskID}, nil),
+		g.toDiskResource(&compute.Disk{Name: to.Ptr("disk")}, nil),
+	})
+	if err == nil {
+		t.Errorf("expected an error for duplicates without ARM IDs")
+	}
+}
+
+// recordingDisksCloud is a mock cloud whose disks client records the resource
+// groups that disks are deleted from.
+type recordingDisksCloud struct {
+	*azuretasks.MockAzureCloud
+	disks *recordingDisksClient
+}
+
+func (c *recordingDisksCloud) Disk() azure.DisksClient {
+	return c.disks
+}
+
+type recordingDisksClient struct {
+	*azuretasks.MockDisksClient
+	deletedFrom []string
+}
+
+func (c *recordingDisksClient) Delete(ctx context.Context, resourceGroupName, diskName string) error {
+	c.deletedFrom = append(c.deletedFrom, resourceGroupName)
+	return c.MockDisksClient.Delete(ctx, resourceGroupName, diskName)
+}
+
+func TestDeleteVMScaleSetClearsInstanceProtection(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+	}
+	mock.VMScaleSetVMsClient.VMs["nodes/0"] = &compute.VirtualMachineScaleSetVM{
+		InstanceID: to.Ptr("0"),
+		Properties: &compute.VirtualMachineScaleSetVMProperties{
+			StorageProfile: &compute.StorageProfile{},
+			ProtectionPolicy: &compute.VirtualMachineScaleSetVMProtectionPolicy{
+				ProtectFromScaleIn: to.Ptr(true),
+			},
+		},
+	}
+	mock.VMScaleSetVMsClient.VMs["nodes/1"] = &compute.VirtualMachineScaleSetVM{
+		InstanceID: to.Ptr("1"),
+		Properties: &compute.VirtualMachineScaleSetVMProperties{
+			StorageProfile: &compute.StorageProfile{},
+		},
+	}
+	cloud := &recordingVMScaleSetsCloud{MockAzureCloud: mock}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+		AzureForceDelete:       true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	r, ok := rs[toKey(typeVMScaleSet, "nodes")]
+	if !ok {
+		t.Fatalf("expected VMSS to be listed")
+	}
+	if err := r.Deleter(cloud, r); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	expected := []string{
+		"update nodes/0",
+		"delete nodes",
+	}
+	if !reflect.DeepEqual(cloud.calls, expected) {
+		t.Errorf("expected %v, but got %v", expected, cloud.calls)
+	}
+	if isProtectedVM(mock.VMScaleSetVMsClient.VMs["nodes/0"]) {
+		t.Errorf("expected the protection of instance 0 to be cleared")
+	}
+}
+
+// recordingVMScaleSetsCloud is a mock cloud that records the calls updating
+// VM Scale Set VMs and deleting VM Scale Sets, in order.
+type recordingVMScaleSetsCloud struct {
+	*azuretasks.MockAzureCloud
+	calls []string
+}
+
+func (c *recordingVMScaleSetsCloud) VMScaleSet() azure.VMScaleSetsClient {
+	return &recordingVMScaleSetsClient{VMScaleSetsClient: c.MockAzureCloud.VMScaleSet(), cloud: c}
+}
+
+func (c *recordingVMScaleSetsCloud) VMScaleSetVM() azure.VMScaleSetVMsClient {
+	return &recordingVMScaleSetVMsClient{VMScaleSetVMsClient: c.MockAzureCloud.VMScaleSetVM(), cloud: c}
+}
+
+type recordingVMScaleSetsClient struct {
+	azure.VMScaleSetsClient
+	cloud *recordingVMScaleSetsCloud
+}
+
+func (c *recordingVMScaleSetsClient) Delete(ctx context.Context, resourceGroupName, vmssName string) error {
+	c.cloud.calls = append(c.cloud.calls, "delete "+vmssName)
+	return c.VMScaleSetsClient.Delete(ctx, resourceGroupName, vmssName)
+}
+
+type recordingVMScaleSetVMsClient struct {
+	azure.VMScaleSetVMsClient
+	cloud *recordingVMScaleSetsCloud
+}
+
+func (c *recordingVMScaleSetVMsClient) Update(ctx context.Context, resourceGroupName, vmssName, instanceID string, parameters compute.VirtualMachineScaleSetVM) (*compute.VirtualMachineScaleSetVM, error) {
+	c.cloud.calls = append(c.cloud.calls, "update "+vmssName+"/"+instanceID)
+	return c.VMScaleSetVMsClient.Update(ctx, resourceGroupName, vmssName, instanceID, parameters)
+}
+
+func TestListResourcesAzureMultiSub(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	networkCloud := azuretasks.NewMockAzureCloud("eastus")
+	networkCloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	networkCloud.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: clusterTags,
+	}
+	computeCloud := azuretasks.NewMockAzureCloud("eastus")
+	computeCloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	computeCloud.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+
+	rs, err := ListResourcesAzureMultiSub([]azure.AzureCloud{
+		&subscriptionCloud{MockAzureCloud: networkCloud, subscriptionID: "sub-network"},
+		&subscriptionCloud{MockAzureCloud: computeCloud, subscriptionID: "sub-compute"},
+	}, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	var keys []string
+	for k := range rs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	expected := []string{
+		"sub-compute/" + toKey(typeDisk, "disk"),
+		"sub-compute/" + toKey(typeResourceGroup, rgName),
+		"sub-network/" + toKey(typeResourceGroup, rgName),
+		"sub-network/" + toKey(typeRouteTable, "rt"),
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected %v, but got %v", expected, keys)
+	}
+
+	// Dependencies refer to the resources of the same subscription.
+	disk := rs["sub-compute/"+toKey(typeDisk, "disk")]
+	expected = []string{"sub-compute/" + toKey(typeResourceGroup, rgName)}
+	if !reflect.DeepEqual(disk.Blocks, expected) {
+		t.Errorf("expected %v, but got %v", expected, disk.Blocks)
+	}
+
+	_, err = ListResourcesAzureMultiSub([]azure.AzureCloud{
+		&subscriptionCloud{MockAzureCloud: networkCloud, subscriptionID: "sub"},
+		&subscriptionCloud{MockAzureCloud: computeCloud, subscriptionID: "sub"},
+	}, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err == nil {
+		t.Errorf("expected an error for a subscription listed twice")
+	}
+}
+
+// subscriptionCloud is a mock cloud of the given subscription.
+type subscriptionCloud struct {
+	*azuretasks.MockAzureCloud
+	subscriptionID string
+}
+
+func (c *subscriptionCloud) SubscriptionID() string {
+	return c.subscriptionID
+}
+
+func TestListResourcesAzureWithNetworkCloud(t *testing.T) {
+	const (
+		clusterName   = "cluster"
+		rgName        = "rg"
+		networkRGName = "network"
+		networkSubID  = "sub-network"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	grid := []struct {
+		name          string
+		networkShared bool
+	}{
+		{
+			name: "owned network",
+		},
+		{
+			name:          "shared network",
+			networkShared: true,
+		},
+	}
+	for _, g := range grid {
+		t.Run(g.name, func(t *testing.T) {
+			computeMock := azuretasks.NewMockAzureCloud("eastus")
+			computeMock.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+				Name: to.Ptr(rgName),
+				Tags: clusterTags,
+			}
+			computeMock.DisksClient.Disks["disk"] = &compute.Disk{
+				Name: to.Ptr("disk"),
+				Tags: clusterTags,
+			}
+			computeMock.RouteTablesClient.RTs["compute-rt"] = &network.RouteTable{
+				Name: to.Ptr("compute-rt"),
+				Tags: clusterTags,
+			}
+			networkMock := azuretasks.NewMockAzureCloud("eastus")
+			networkMock.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+				Name: to.Ptr("vnet"),
+				Tags: clusterTags,
+			}
+			networkMock.RouteTablesClient.RTs["network-rt"] = &network.RouteTable{
+				Name: to.Ptr("network-rt"),
+				Tags: clusterTags,
+			}
+			// The disks of the network subscription are not listed.
+			networkMock.DisksClient.Disks["network-disk"] = &compute.Disk{
+				Name: to.Ptr("network-disk"),
+				Tags: clusterTags,
+			}
+			computeCloud := &subscriptionCloud{MockAzureCloud: computeMock, subscriptionID: "sub-compute"}
+			networkCloud := &subscriptionCloud{MockAzureCloud: networkMock, subscriptionID: networkSubID}
+			clusterInfo := resources.ClusterInfo{
+				Name:                           clusterName,
+				AzureResourceGroupName:         rgName,
+				AzureNetworkResourceGroupNames: []string{networkRGName},
+				AzureNetworkSubscriptionID:     networkSubID,
+				AzureNetworkShared:             g.networkShared,
+			}
+
+			rs, err := ListResourcesAzureWithNetworkCloud(context.Background(), computeCloud, networkCloud, clusterInfo)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			var keys []string
+			for k := range rs {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			expected := []string{
+				toKey(typeDisk, "disk"),
+				toKey(typeResourceGroup, rgName),
+				toKey(typeRouteTable, "compute-rt"),
+				toKey(typeRouteTable, "network-rt"),
+				toKey(typeVirtualNetwork, "vnet"),
+			}
+			if !reflect.DeepEqual(keys, expected) {
+				t.Fatalf("expected %v, but got %v", expected, keys)
+			}
+			if rs[toKey(typeRouteTable, "compute-rt")].Shared {
+				t.Errorf("expected the route table of the cluster resource group to be deleted")
+			}
+			for _, key := range []string{toKey(typeRouteTable, "network-rt"), toKey(typeVirtualNetwork, "vnet")} {
+				if r := rs[key]; r.Shared != g.networkShared || r.GroupName != networkRGName {
+					t.Errorf("expected %q to be in resource group %q and shared: %v, but got %q and %v", key, networkRGName, g.networkShared, r.GroupName, r.Shared)
+				}
+			}
+			if g.networkShared {
+				return
+			}
+
+			// The network resources are deleted from the network subscription.
+			rt := rs[toKey(typeRouteTable, "network-rt")]
+			if err := rt.Deleter(networkCloud, rt); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if _, ok := networkMock.RouteTablesClient.RTs["network-rt"]; ok {
+				t.Errorf("expected the route table to be deleted from the network subscription")
+			}
+			if _, ok := computeMock.RouteTablesClient.RTs["compute-rt"]; !ok {
+				t.Errorf("expected the route table of the compute subscription to be kept")
+			}
+		})
+	}
+
+	t.Run("no network cloud", func(t *testing.T) {
+		cloud := &subscriptionCloud{MockAzureCloud: azuretasks.NewMockAzureCloud("eastus"), subscriptionID: "sub-compute"}
+		_, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+			Name:                           clusterName,
+			AzureResourceGroupName:         rgName,
+			AzureNetworkResourceGroupNames: []string{networkRGName},
+			AzureNetworkSubscriptionID:     networkSubID,
+		})
+		if err == nil {
+			t.Errorf("expected an error listing a network subscription without its cloud")
+		}
+	})
+	t.Run("no network resource groups", func(t *testing.T) {
+		cloud := &subscriptionCloud{MockAzureCloud: azuretasks.NewMockAzureCloud("eastus"), subscriptionID: "sub-compute"}
+		networkCloud := &subscriptionCloud{MockAzureCloud: azuretasks.NewMockAzureCloud("eastus"), subscriptionID: networkSubID}
+		_, err := ListResourcesAzureWithNetworkCloud(context.Background(), cloud, networkCloud, resources.ClusterInfo{
+			Name:                       clusterName,
+			AzureResourceGroupName:     rgName,
+			AzureNetworkSubscriptionID: networkSubID,
+		})
+		if err == nil {
+			t.Errorf("expected an error for a network subscription without network resource groups")
+		}
+	})
+}
+
+func TestListResourcesAzureAsync(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: clusterTags,
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+		Identity: &compute.VirtualMachineScaleSetIdentity{
+			Type:        to.Ptr(compute.ResourceIdentityTypeSystemAssigned),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	cloud.RoleAssignmentsClient.RAs["ra"] = &authz.RoleAssignment{
+		Name: to.Ptr("ra"),
+		Properties: &authz.RoleAssignmentProperties{
+			Scope:       to.Ptr("scope"),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	expected := map[string]bool{
+		toKey(typeResourceGroup, rgName): true,
+		toKey(typeVMScaleSet, "nodes"):   true,
+		toKey(typeRoleAssignment, "ra"):  false,
+	}
+	for key, async := range expected {
+		r, ok := rs[key]
+		if !ok {
+			t.Errorf("expected %q to be listed", key)
+			continue
+		}
+		if r.Async != async {
+			t.Errorf("expected %q to have Async %t, but got %t", key, async, r.Async)
+		}
+	}
+}
+
+func TestListNodeResourceGroup(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		nodeRGName  = "MC_rg_cluster_eastus"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	newDisk := func(rg, name string) *compute.Disk {
+		return &compute.Disk{
+			ID:   to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Compute/disks/%s", rg, name)),
+			Name: to.Ptr(name),
+			Tags: clusterTags,
+		}
+	}
+
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	for _, name := range []string{rgName, nodeRGName} {
+		mock.ResourceGroupsClient.RGs[name] = &armresources.ResourceGroup{
+			Name: to.Ptr(name),
+			Tags: clusterTags,
+		}
+	}
+	disks := &resourceGroupDisksClient{
+		DisksClient: mock.DisksClient,
+		disks: map[string][]*compute.Disk{
+			rgName:     {newDisk(rgName, "disk")},
+			nodeRGName: {newDisk(nodeRGName, "node-disk")},
+		},
+	}
+	cloud := &resourceGroupDisksCloud{MockAzureCloud: mock, disks: disks}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                       clusterName,
+		AzureResourceGroupName:     rgName,
+		AzureNodeResourceGroupName: nodeRGName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	for _, key := range []string{toKey(typeDisk, "disk"), toKey(typeDisk, "node-disk")} {
+		if _, ok := rs[key]; !ok {
+			t.Errorf("expected %q to be listed", key)
+		}
+	}
+	if rg := rs[toKey(typeResourceGroup, rgName)]; rg == nil || rg.Shared {
+		t.Errorf("expected the cluster resource group to be deleted")
+	}
+	if rg := rs[toKey(typeResourceGroup, nodeRGName)]; rg == nil || !rg.Shared {
+		t.Errorf("expected the node resource group to be listed as shared")
+	}
+
+	// The disk in the node resource group is deleted from it.
+	r := rs[toKey(typeDisk, "node-disk")]
+	if err := r.Deleter(cloud, r); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(disks.disks[nodeRGName]) != 0 {
+		t.Errorf("expected the disk to be deleted from the node resource group")
+	}
+}
+
+// resourceGroupDisksCloud is a mock cloud whose disks are kept per resource group.
+type resourceGroupDisksCloud struct {
+	*azuretasks.MockAzureCloud
+	disks *resourceGroupDisksClient
+}
+
+func (c *resourceGroupDisksCloud) Disk() azure.DisksClient {
+	return c.disks
+}
+
+type resourceGroupDisksClient struct {
+	azure.DisksClient
+	disks map[string][]*compute.Disk
+}
+
+func (c *resourceGroupDisksClient) List(ctx context.Context, resourceGroupName string) ([]*compute.Disk, error) {
+	return c.disks[resourceGroupName], nil
+}
+
+func (c *resourceGroupDisksClient) Delete(ctx context.Context, resourceGroupName, diskName string) error {
+	var kept []*compute.Disk
+	for _, disk := range c.disks[resourceGroupName] {
+		if *disk.Name != diskName {
+			kept = append(kept, disk)
+		}
+	}
+	c.disks[resourceGroupName] = kept
+	return nil
+}
+
+func TestListResourcesAzureOmitRawObjects(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+		Identity: &compute.VirtualMachineScaleSetIdentity{
+			Type:        to.Ptr(compute.ResourceIdentityTypeSystemAssigned),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	cloud.RoleAssignmentsClient.RAs["ra"] = &authz.RoleAssignment{
+		Name: to.Ptr("ra"),
+		Properties: &authz.RoleAssignmentProperties{
+			Scope:       to.Ptr("scope"),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+		AzureOmitRawObjects:    true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for key, r := range rs {
+		if r.Obj != nil {
+			t.Errorf("expected the object of %s to be omitted, but got %T", key, r.Obj)
+		}
+	}
+
+	ra, ok := rs[toKey(typeRoleAssignment, "ra")]
+	if !ok {
+		t.Fatalf("expected role assignment to be listed")
+	}
+	if !slices.Contains(ra.Blocks, toKey(typeVMScaleSet, "nodes")) {
+		t.Errorf("expected role assignment to block the VM Scale Set, but got %v", ra.Blocks)
+	}
+	vmss, ok := rs[toKey(typeVMScaleSet, "nodes")]
+	if !ok {
+		t.Fatalf("expected VMSS to be listed")
+	}
+	for _, r := range []*resources.Resource{ra, vmss} {
+		if err := r.Deleter(cloud, r); err != nil {
+			t.Fatalf("unexpected error deleting %s: %s", toKey(r.Type, r.ID), err)
+		}
+	}
+	if len(cloud.RoleAssignmentsClient.RAs) != 0 {
+		t.Errorf("expected role assignment to be deleted")
+	}
+	if len(cloud.VMScaleSetsClient.VMSSes) != 0 {
+		t.Errorf("expected VMSS to be deleted")
+	}
+}
+
+func TestDeleteCallTimeout(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.DisksClient.Disks["etcd"] = &compute.Disk{
+		Name: to.Ptr("etcd"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+	}
+	cloud := &hungDisksCloud{MockAzureCloud: mock}
+
+	rs, err := ListResourcesAzureWithContext(context.Background(), cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+		AzureCallTimeout:       10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	disk, ok := rs[toKey(typeDisk, "etcd")]
+	if !ok {
+		t.Fatalf("expected disk to be listed")
+	}
+	if err := disk.Deleter(cloud, disk); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deletion to time out, but got %v", err)
+	}
+}
+
+// hungDisksCloud is a mock cloud whose disks client never completes a
+// deletion, like a hung ARM endpoint.
+type hungDisksCloud struct {
+	*azuretasks.MockAzureCloud
+}
+
+func (c *hungDisksCloud) Disk() azure.DisksClient {
+	return &hungDisksClient{c.MockAzureCloud.Disk()}
+}
+
+type hungDisksClient struct {
+	azure.DisksClient
+}
+
+func (c *hungDisksClient) Delete(ctx context.Context, resourceGroupName, diskName string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestListNetworkResourceGroups(t *testing.T) {
+	const (
+		clusterName   = "cluster"
+		rgName        = "rg"
+		networkRGName = "network"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	nsgID := func(rg string) string {
+		return fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/networkSecurityGroups/nsg", rg)
+	}
+
+	grid := []struct {
+		name          string
+		networkShared bool
+	}{
+		{
+			name: "owned network",
+		},
+		{
+			name:          "shared network",
+			networkShared: true,
+		},
+	}
+	for _, g := range grid {
+		t.Run(g.name, func(t *testing.T) {
+			mock := azuretasks.NewMockAzureCloud("eastus")
+			mock.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+				Name: to.Ptr(rgName),
+				Tags: clusterTags,
+			}
+			mock.ResourceGroupsClient.RGs[networkRGName] = &armresources.ResourceGroup{
+				Name: to.Ptr(networkRGName),
+				Tags: map[string]*string{
+					azure.TagNameClusterOwnershipPrefix + clusterName: to.Ptr(clusterTagValueShared),
+				},
+			}
+			cloud := &resourceGroupNetworkCloud{
+				MockAzureCloud: mock,
+				vnets: map[string][]*network.VirtualNetwork{
+					networkRGName: {
+						{
+							ID:   to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/vnet", networkRGName)),
+							Name: to.Ptr("vnet"),
+							Tags: clusterTags,
+						},
+					},
+				},
+				nsgs: map[string][]*network.SecurityGroup{
+					rgName: {
+						{
+							ID:         to.Ptr(nsgID(rgName)),
+							Name:       to.Ptr("nsg"),
+							Tags:       clusterTags,
+							Properties: &network.SecurityGroupPropertiesFormat{},
+						},
+					},
+					networkRGName: {
+						{
+							ID:         to.Ptr(nsgID(networkRGName)),
+							Name:       to.Ptr("nsg"),
+							Tags:       clusterTags,
+							Properties: &network.SecurityGroupPropertiesFormat{},
+						},
+					},
+				},
+			}
+
+			rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+				Name:                           clusterName,
+				AzureResourceGroupName:         rgName,
+				AzureNetworkResourceGroupNames: []string{networkRGName},
+				AzureNetworkShared:             g.networkShared,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+
+			// The security groups of the same name are told apart by resource group.
+			computeNSG, ok := rs[toKey(typeNetworkSecurityGroup, nsgID(rgName))]
+			if !ok {
+				t.Fatalf("expected the security group of the cluster resource group to be listed")
+			}
+			if computeNSG.Shared {
+				t.Errorf("expected the security group of the cluster resource group to be deleted")
+			}
+			networkNSG, ok := rs[toKey(typeNetworkSecurityGroup, nsgID(networkRGName))]
+			if !ok {
+				t.Fatalf("expected the security group of the network resource group to be listed")
+			}
+			vnet, ok := rs[toKey(typeVirtualNetwork, "vnet")]
+			if !ok {
+				t.Fatalf("expected the virtual network to be listed")
+			}
+			for _, r := range []*resources.Resource{networkNSG, vnet} {
+				if r.Shared != g.networkShared {
+					t.Errorf("expected %s %q to be shared: %v", r.Type, r.Name, g.networkShared)
+				}
+			}
+			if rg := rs[toKey(typeResourceGroup, networkRGName)]; rg == nil || !rg.Shared {
+				t.Errorf("expected the network resource group to be listed as shared")
+			}
+
+			// The resources are grouped by the resource group they are in.
+			groups := make(map[string][]string)
+			for _, r := range rs {
+				groups[r.DisplayGroup()] = append(groups[r.DisplayGroup()], r.Name)
+			}
+			for _, names := range groups {
+				sort.Strings(names)
+			}
+			expectedGroups := map[string][]string{
+				rgName + "/" + typeResourceGroup:               {rgName},
+				rgName + "/" + typeNetworkSecurityGroup:        {"nsg"},
+				networkRGName + "/" + typeResourceGroup:        {networkRGName},
+				networkRGName + "/" + typeNetworkSecurityGroup: {"nsg"},
+				networkRGName + "/" + typeVirtualNetwork:       {"vnet"},
+			}
+			if !reflect.DeepEqual(groups, expectedGroups) {
+				t.Errorf("expected the resources to be grouped as %v, but got %v", expectedGroups, groups)
+			}
+			if g.networkShared {
+				return
+			}
+
+			// The security group of the network resource group is deleted from it.
+			if err := networkNSG.Deleter(cloud, networkNSG); err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if len(cloud.nsgs[networkRGName]) != 0 || len(cloud.nsgs[rgName]) != 1 {
+				t.Errorf("expected the security group to be deleted from the network resource group only")
+			}
+		})
+	}
+}
+
+// resourceGroupNetworkCloud is a mock cloud whose virtual networks and network
+// security groups are kept per resource group.
+type resourceGroupNetworkCloud struct {
+	*azuretasks.MockAzureCloud
+	vnets map[string][]*network.VirtualNetwork
+	nsgs  map[string][]*network.SecurityGroup
+}
+
+func (c *resourceGroupNetworkCloud) VirtualNetwork() azure.VirtualNetworksClient {
+	return &resourceGroupVirtualNetworksClient{VirtualNetworksClient: c.MockAzureCloud.VirtualNetwork(), cloud: c}
+}
+
+func (c *resourceGroupNetworkCloud) NetworkSecurityGroup() azure.NetworkSecurityGroupsClient {
+	return &resourceGroupNetworkSecurityGroupsClient{NetworkSecurityGroupsClient: c.MockAzureCloud.NetworkSecurityGroup(), cloud: c}
+}
+
+type resourceGroupVirtualNetworksClient struct {
+	azure.VirtualNetworksClient
+	cloud *resourceGroupNetworkCloud
+}
+
+func (c *resourceGroupVirtualNetworksClient) List(ctx context.Context, resourceGroupName string) ([]*network.VirtualNetwork, error) {
+	return c.cloud.vnets[resourceGroupName], nil
+}
+
+type resourceGroupNetworkSecurityGroupsClient struct {
+	azure.NetworkSecurityGroupsClient
+	cloud *resourceGroupNetworkCloud
+}
+
+func (c *resourceGroupNetworkSecurityGroupsClient) List(ctx context.Context, resourceGroupName string) ([]*network.SecurityGroup, error) {
+	return c.cloud.nsgs[resourceGroupName], nil
+}
+
+func (c *resourceGroupNetworkSecurityGroupsClient) Delete(ctx context.Context, resourceGroupName, nsgName string) error {
+	var kept []*network.SecurityGroup
+	for _, nsg := range c.cloud.nsgs[resourceGroupName] {
+		if *nsg.Name != nsgName {
+			kept = append(kept, nsg)
+		}
+	}
+	c.cloud.nsgs[resourceGroupName] = kept
+	return nil
+}
+
+// shuffledListCloud is a mock cloud that lists subnets and route tables in a
+// random order, as ARM gives no guarantee on the order of the listed resources.
+type shuffledListCloud struct {
+	*azuretasks.MockAzureCloud
+	rand *rand.Rand
+}
+
+func (c *shuffledListCloud) Subnet() azure.SubnetsClient {
+	return &shuffledSubnetsClient{c.MockAzureCloud.Subnet(), c.rand}
+}
+
+func (c *shuffledListCloud) RouteTable() azure.RouteTablesClient {
+	return &shuffledRouteTablesClient{c.MockAzureCloud.RouteTable(), c.rand}
+}
+
+type shuffledSubnetsClient struct {
+	azure.SubnetsClient
+	rand *rand.Rand
+}
+
+func (c *shuffledSubnetsClient) List(ctx context.Context, resourceGroupName, virtualNetworkName string) ([]*network.Subnet, error) {
+	l, err := c.SubnetsClient.List(ctx, resourceGroupName, virtualNetworkName)
+	c.rand.Shuffle(len(l), func(i, j int) { l[i], l[j] = l[j], l[i] })
+	return l, err
+}
+
+type shuffledRouteTablesClient struct {
+	azure.RouteTablesClient
+	rand *rand.Rand
+}
+
+func (c *shuffledRouteTablesClient) List(ctx context.Context, resourceGroupName string) ([]*network.RouteTable, error) {
+	l, err := c.RouteTablesClient.List(ctx, resourceGroupName)
+	c.rand.Shuffle(len(l), func(i, j int) { l[i], l[j] = l[j], l[i] })
+	return l, err
+}
+
+func TestListAllIsSorted(t *testing.T) {
+	const clusterName = "cluster"
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	list := func(seed uint64) []string {
+		cloud := azuretasks.NewMockAzureCloud("eastus")
+		cloud.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+			Name: to.Ptr("vnet"),
+			Tags: clusterTags,
+		}
+		for i := 0; i < 5; i++ {
+			name := fmt.Sprintf("subnet-%d", i)
+			cloud.SubnetsClient.Subnets[name] = &network.Subnet{Name: to.Ptr(name)}
+			name = fmt.Sprintf("rt-%d", i)
+			cloud.RouteTablesClient.RTs[name] = &network.RouteTable{Name: to.Ptr(name), Tags: clusterTags}
+		}
+		g := &resourceGetter{
+			cloud: &shuffledListCloud{MockAzureCloud: cloud, rand: rand.New(rand.NewPCG(seed, seed))},
+			clusterInfo: resources.ClusterInfo{
+				Name:                   clusterName,
+				AzureResourceGroupName: "rg",
+			},
+		}
+		rs, err := g.listAll()
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		var keys []string
+		for _, r := range rs {
+			keys = append(keys, toKey(r.Type, r.ID))
+		}
+		return keys
+	}
+
+	expected := list(0)
+	if !sort.StringsAreSorted(expected) {
+		t.Errorf("expected the resources to be sorted by key, but got %v", expected)
+	}
+	if len(expected) < 10 {
+		t.Fatalf("expected the subnets and route tables to be listed, but got %v", expected)
+	}
+	for seed := uint64(1); seed < 10; seed++ {
+		if actual := list(seed); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected the resources to be listed as %v whatever the list order, but got %v", expected, actual)
+		}
+	}
+}
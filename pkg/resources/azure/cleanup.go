@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// AzureCleanupFunc lists additional resources to be deleted with the cluster.
+type AzureCleanupFunc func(ctx context.Context, cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) ([]*resources.Resource, error)
+
+var (
+	cleanupsMutex sync.Mutex
+	cleanups      = map[string]AzureCleanupFunc{}
+)
+
+// RegisterAzureCleanup registers a function that contributes resources to be
+// deleted by "kops delete cluster", such as resources created by add-ons.
+// It panics if a cleanup function with the same name is already registered.
+func RegisterAzureCleanup(name string, fn AzureCleanupFunc) {
+	cleanupsMutex.Lock()
+	defer cleanupsMutex.Unlock()
+
+	if fn == nil {
+		panic(fmt.Sprintf("azure cleanup %q is nil", name))
+	}
+	if _, found := cleanups[name]; found {
+		panic(fmt.Sprintf("azure cleanup %q is already registered", name))
+	}
+	cleanups[name] = fn
+}
+
+// registeredCleanups returns the registered cleanup functions, ordered by name.
+func (g *resourceGetter) registeredCleanups() []func(ctx context.Context) ([]*resources.Resource, error) {
+	cleanupsMutex.Lock()
+	defer cleanupsMutex.Unlock()
+
+	var names []string
+	for name := range cleanups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fns []func(ctx context.Context) ([]*resources.Resource, error)
+	for _, name := range names {
+		fn := cleanups[name]
+		fns = append(fns, func(ctx context.Context) ([]*resources.Resource, error) {
+			rs, err := fn(ctx, g.cloud, g.clusterInfo)
+			if err != nil {
+				return nil, fmt.Errorf("running azure cleanup %q: %w", name, err)
+			}
+			return rs, nil
+		})
+	}
+	return fns
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestRegisterAzureCleanup(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		cleanupName = "test-addon"
+	)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+	}
+
+	var called bool
+	RegisterAzureCleanup(cleanupName, func(ctx context.Context, c azure.AzureCloud, clusterInfo resources.ClusterInfo) ([]*resources.Resource, error) {
+		called = true
+		if c != cloud {
+			t.Errorf("expected the cleanup to be called with the cluster cloud")
+		}
+		if clusterInfo.Name != clusterName {
+			t.Errorf("expected cluster name %q, but got %q", clusterName, clusterInfo.Name)
+		}
+		return []*resources.Resource{
+			{
+				Type: "AddonResource",
+				ID:   "addon",
+				Name: "addon",
+				Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+					return nil
+				},
+				Blocks: []string{toKey(typeResourceGroup, rgName)},
+			},
+		}, nil
+	})
+	t.Cleanup(func() {
+		cleanupsMutex.Lock()
+		defer cleanupsMutex.Unlock()
+		delete(cleanups, cleanupName)
+	})
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !called {
+		t.Fatalf("expected the registered cleanup to be called")
+	}
+	if _, ok := rs[toKey("AddonResource", "addon")]; !ok {
+		t.Errorf("expected the resource contributed by the cleanup to be listed")
+	}
+	if _, ok := rs[toKey(typeResourceGroup, rgName)]; !ok {
+		t.Errorf("expected the resource group to be listed")
+	}
+}
+
+func TestRegisterAzureCleanupDuplicate(t *testing.T) {
+	const cleanupName = "test-duplicate"
+	fn := func(ctx context.Context, cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) ([]*resources.Resource, error) {
+		return nil, nil
+	}
+	RegisterAzureCleanup(cleanupName, fn)
+	t.Cleanup(func() {
+		cleanupsMutex.Lock()
+		defer cleanupsMutex.Unlock()
+		delete(cleanups, cleanupName)
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering a duplicate cleanup to panic")
+		}
+	}()
+	RegisterAzureCleanup(cleanupName, fn)
+}
+
+func TestListResourcesAzureRejectsCyclicGraph(t *testing.T) {
+	const cleanupName = "cyclic-addon"
+
+	RegisterAzureCleanup(cleanupName, func(ctx context.Context, c azure.AzureCloud, clusterInfo resources.ClusterInfo) ([]*resources.Resource, error) {
+		return []*resources.Resource{
+			{Type: "AddonResource", ID: "a", Name: "a", Blocks: []string{"AddonResource:b"}},
+			{Type: "AddonResource", ID: "b", Name: "b", Blocks: []string{"AddonResource:a"}},
+		}, nil
+	})
+	t.Cleanup(func() {
+		cleanupsMutex.Lock()
+		defer cleanupsMutex.Unlock()
+		delete(cleanups, cleanupName)
+	})
+
+	_, err := ListResourcesAzure(azuretasks.NewMockAzureCloud("eastus"), resources.ClusterInfo{
+		Name:                   "cluster",
+		AzureResourceGroupName: "rg",
+	})
+	if err == nil || !strings.Contains(err.Error(), "AddonResource:a -> AddonResource:b -> AddonResource:a") {
+		t.Errorf("expected an error naming the cycle, but got %v", err)
+	}
+}
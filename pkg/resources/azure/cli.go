@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"strings"
+
+	authz "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	"k8s.io/klog/v2"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// azCLIDeleteCommands are the az CLI commands deleting the resources of each
+// type by resource group and name. The commands asking for confirmation are
+// given --yes. The resources of the other types are deleted by ARM ID.
+var azCLIDeleteCommands = map[string]string{
+	typeVirtualNetwork:           "network vnet delete",
+	typeNetworkSecurityGroup:     "network nsg delete",
+	typeApplicationSecurityGroup: "network asg delete",
+	typeRouteTable:               "network route-table delete",
+	typeVMScaleSet:               "vmss delete",
+	typeVirtualMachine:           "vm delete --yes",
+	typeNetworkInterface:         "network nic delete",
+	typeDisk:                     "disk delete --yes",
+	typeDiskEncryptionSet:        "disk-encryption-set delete",
+	typeLoadBalancer:             "network lb delete",
+	typePublicIPAddress:          "network public-ip delete",
+	typeNatGateway:               "network nat gateway delete",
+	typeSnapshot:                 "snapshot delete",
+	typeStorageAccount:           "storage account delete --yes",
+	typePrivateEndpoint:          "network private-endpoint delete",
+	typeManagedIdentity:          "identity delete",
+	typePrivateDNSZone:           "network private-dns zone delete --yes",
+	typeApplicationGateway:       "network application-gateway delete",
+}
+
+// azCLIDeleteCommand returns the az CLI command equivalent to deleting the
+// resource, or "" if there is none.
+func (g *resourceGetter) azCLIDeleteCommand(r *resources.Resource) string {
+	switch r.Type {
+	case typeResourceGroup:
+		return fmt.Sprintf("az group delete --name %s --yes", r.Name)
+	case typeSubnet:
+		if subnetID, err := azure.ParseSubnetID(g.armID(r)); err == nil {
+			return fmt.Sprintf("az network vnet subnet delete --resource-group %s --vnet-name %s --name %s", subnetID.ResourceGroupName, subnetID.VirtualNetworkName, subnetID.SubnetName)
+		}
+	case typeNetworkSecurityRule:
+		if nsgName, _, ok := strings.Cut(r.ID, "/"); ok {
+			return fmt.Sprintf("az network nsg rule delete --resource-group %s --nsg-name %s --name %s", g.resourceGroupOf(r), nsgName, r.Name)
+		}
+	case typeRoute:
+		if rtName, _, ok := strings.Cut(r.ID, "/"); ok {
+			return fmt.Sprintf("az network route-table route delete --resource-group %s --route-table-name %s --name %s", g.resourceGroupOf(r), rtName, r.Name)
+		}
+	case typePrivateDNSRecordSet:
+		if l := strings.SplitN(r.ID, "/", 3); len(l) == 3 {
+			return fmt.Sprintf("az network private-dns record-set %s delete --resource-group %s --zone-name %s --name %s --yes", strings.ToLower(l[1]), g.resourceGroupOf(r), l[0], l[2])
+		}
+	case typePrivateDNSZoneLink:
+		if zoneName, _, ok := strings.Cut(r.ID, "/"); ok {
+			return fmt.Sprintf("az network private-dns link vnet delete --resource-group %s --zone-name %s --name %s --yes", g.resourceGroupOf(r), zoneName, r.Name)
+		}
+	case typeRoleAssignment:
+		if ra, ok := r.Obj.(*authz.RoleAssignment); ok && ra.ID != nil {
+			return fmt.Sprintf("az role assignment delete --ids %s", *ra.ID)
+		}
+	}
+	if command, ok := azCLIDeleteCommands[r.Type]; ok {
+		return fmt.Sprintf("az %s --resource-group %s --name %s", command, g.resourceGroupOf(r), r.Name)
+	}
+	if id := g.armID(r); id != "" {
+		return fmt.Sprintf("az resource delete --ids %s", id)
+	}
+	return ""
+}
+
+// logEquivalentCLI makes the deleters log the az CLI command equivalent to the
+// deletion they perform, for information.
+func (g *resourceGetter) logEquivalentCLI(resourceMap map[string]*resources.Resource) {
+	for _, r := range resourceMap {
+		if r.Shared || r.Done || r.Deleter == nil {
+			continue
+		}
+		command := g.azCLIDeleteCommand(r)
+		if command == "" {
+			continue
+		}
+		deleter := r.Deleter
+		r.Deleter = func(cloud fi.Cloud, r *resources.Resource) error {
+			klog.Infof("Deleting %s %q, like: %s", r.Type, r.Name, command)
+			return deleter(cloud, r)
+		}
+	}
+}
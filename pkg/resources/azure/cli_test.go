@@ -0,0 +1,78 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"k8s.io/klog/v2"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestLogEquivalentCLI(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.DisksClient.Disks["etcd"] = &compute.Disk{
+		ID:   to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Compute/disks/etcd", rgName)),
+		Name: to.Ptr("etcd"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr(clusterName),
+		},
+	}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+		AzureLogEquivalentCLI:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	disk, ok := rs[toKey(typeDisk, "etcd")]
+	if !ok {
+		t.Fatalf("expected disk to be listed")
+	}
+
+	var logs bytes.Buffer
+	klog.LogToStderr(false)
+	klog.SetOutput(&logs)
+	defer klog.LogToStderr(true)
+
+	if err := disk.Deleter(cloud, disk); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	klog.Flush()
+
+	expected := "az disk delete --yes --resource-group rg --name etcd"
+	if !strings.Contains(logs.String(), expected) {
+		t.Errorf("expected the logs to contain %q, but got %q", expected, logs.String())
+	}
+	if _, ok := cloud.DisksClient.Disks["etcd"]; ok {
+		t.Errorf("expected disk to be deleted")
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+// slowListCallDelay is the latency of each list call of slowListCloud.
+const slowListCallDelay = 50 * time.Millisecond
+
+// listCallTracker records the highest number of list calls in flight at once.
+type listCallTracker struct {
+	mutex       sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *listCallTracker) call() {
+	c.mutex.Lock()
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.mutex.Unlock()
+
+	time.Sleep(slowListCallDelay)
+
+	c.mutex.Lock()
+	c.inFlight--
+	c.mutex.Unlock()
+}
+
+// slowListCloud is a mock cloud where listing some kinds of resources is slow.
+type slowListCloud struct {
+	*azuretasks.MockAzureCloud
+	tracker *listCallTracker
+}
+
+func (c *slowListCloud) RouteTable() azure.RouteTablesClient {
+	return &slowRouteTablesClient{c.MockAzureCloud.RouteTable(), c.tracker}
+}
+
+func (c *slowListCloud) LoadBalancer() azure.LoadBalancersClient {
+	return &slowLoadBalancersClient{c.MockAzureCloud.LoadBalancer(), c.tracker}
+}
+
+func (c *slowListCloud) PublicIPAddress() azure.PublicIPAddressesClient {
+	return &slowPublicIPAddressesClient{c.MockAzureCloud.PublicIPAddress(), c.tracker}
+}
+
+func (c *slowListCloud) NatGateway() azure.NatGatewaysClient {
+	return &slowNatGatewaysClient{c.MockAzureCloud.NatGateway(), c.tracker}
+}
+
+type slowRouteTablesClient struct {
+	azure.RouteTablesClient
+	tracker *listCallTracker
+}
+
+func (c *slowRouteTablesClient) List(ctx context.Context, resourceGroupName string) ([]*network.RouteTable, error) {
+	c.tracker.call()
+	return c.RouteTablesClient.List(ctx, resourceGroupName)
+}
+
+type slowLoadBalancersClient struct {
+	azure.LoadBalancersClient
+	tracker *listCallTracker
+}
+
+func (c *slowLoadBalancersClient) List(ctx context.Context, resourceGroupName string) ([]*network.LoadBalancer, error) {
+	c.tracker.call()
+	return c.LoadBalancersClient.List(ctx, resourceGroupName)
+}
+
+type slowPublicIPAddressesClient struct {
+	azure.PublicIPAddressesClient
+	tracker *listCallTracker
+}
+
+func (c *slowPublicIPAddressesClient) List(ctx context.Context, resourceGroupName string) ([]*network.PublicIPAddress, error) {
+	c.tracker.call()
+	return c.PublicIPAddressesClient.List(ctx, resourceGroupName)
+}
+
+type slowNatGatewaysClient struct {
+	azure.NatGatewaysClient
+	tracker *listCallTracker
+}
+
+func (c *slowNatGatewaysClient) List(ctx context.Context, resourceGroupName string) ([]*network.NatGateway, error) {
+	c.tracker.call()
+	return c.NatGatewaysClient.List(ctx, resourceGroupName)
+}
+
+func TestListResourcesAzureConcurrency(t *testing.T) {
+	const clusterName = "cluster"
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	newCloud := func() *slowListCloud {
+		cloud := azuretasks.NewMockAzureCloud("eastus")
+		for i := 0; i < 3; i++ {
+			name := fmt.Sprintf("rt-%d", i)
+			cloud.RouteTablesClient.RTs[name] = &network.RouteTable{Name: to.Ptr(name), Tags: clusterTags}
+			name = fmt.Sprintf("pip-%d", i)
+			cloud.PublicIPAddressesClient.PubIPs[name] = &network.PublicIPAddress{Name: to.Ptr(name), Tags: clusterTags}
+		}
+		return &slowListCloud{MockAzureCloud: cloud, tracker: &listCallTracker{}}
+	}
+	list := func(concurrency int) ([]string, int, time.Duration) {
+		cloud := newCloud()
+		g := &resourceGetter{
+			cloud: cloud,
+			clusterInfo: resources.ClusterInfo{
+				Name:                   clusterName,
+				AzureResourceGroupName: "rg",
+				AzureListConcurrency:   concurrency,
+			},
+		}
+		start := time.Now()
+		rs, err := g.listAll()
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		elapsed := time.Since(start)
+		var keys []string
+		for _, r := range rs {
+			keys = append(keys, toKey(r.Type, r.ID))
+		}
+		return keys, cloud.tracker.maxInFlight, elapsed
+	}
+
+	sequentialKeys, sequentialMax, sequentialElapsed := list(1)
+	if sequentialMax != 1 {
+		t.Errorf("expected a single list call at a time, but got %d", sequentialMax)
+	}
+	if sequentialElapsed < 4*slowListCallDelay {
+		t.Errorf("expected the slow list calls to be made one after the other, but listing took %s", sequentialElapsed)
+	}
+
+	concurrentKeys, concurrentMax, concurrentElapsed := list(0)
+	if concurrentMax != 4 {
+		t.Errorf("expected the 4 slow list calls to be made at the same time, but got %d", concurrentMax)
+	}
+	if concurrentElapsed >= sequentialElapsed {
+		t.Errorf("expected concurrent listing to be faster than %s, but it took %s", sequentialElapsed, concurrentElapsed)
+	}
+
+	// The same resources are listed either way.
+	sort.Strings(sequentialKeys)
+	sort.Strings(concurrentKeys)
+	if !reflect.DeepEqual(sequentialKeys, concurrentKeys) {
+		t.Errorf("expected %v, but got %v", sequentialKeys, concurrentKeys)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// CountResourcesAzure returns the number of resources of the cluster in its
+// resource group by type, e.g. for metrics of what remains of a cluster. All
+// the types of resources listed for a cluster are counted, even if none are
+// found, so that the keys are stable. Nothing is deleted.
+func CountResourcesAzure(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) (map[string]int, error) {
+	g := resourceGetter{
+		ctx:         context.Background(),
+		cloud:       cloud,
+		clusterInfo: clusterInfo,
+	}
+	if err := g.applyTeardownProfile(); err != nil {
+		return nil, err
+	}
+	if err := g.validateResourceFilter(); err != nil {
+		return nil, err
+	}
+	rs, err := g.listAll()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(resourceTypes))
+	for _, rtype := range resourceTypes {
+		counts[rtype] = 0
+	}
+	for _, r := range rs {
+		counts[r.Type]++
+	}
+	return counts, nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestCountResourcesAzure(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		vnetName    = "vnet"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.VirtualNetworksClient.VNets[vnetName] = &network.VirtualNetwork{
+		Name:       to.Ptr(vnetName),
+		Tags:       clusterTags,
+		Properties: &network.VirtualNetworkPropertiesFormat{},
+	}
+	for _, name := range []string{"master", "nodes"} {
+		cloud.SubnetsClient.Subnets[name] = &network.Subnet{
+			Name:       to.Ptr(name),
+			Properties: &network.SubnetPropertiesFormat{},
+		}
+	}
+	for _, name := range []string{"data-0", "data-1"} {
+		cloud.DisksClient.Disks[name] = &compute.Disk{
+			Name: to.Ptr(name),
+			Tags: clusterTags,
+		}
+	}
+
+	counts, err := CountResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	expected := map[string]int{
+		typeResourceGroup:  1,
+		typeVirtualNetwork: 1,
+		typeSubnet:         2,
+		typeDisk:           2,
+	}
+	for _, rtype := range resourceTypes {
+		count, ok := counts[rtype]
+		if !ok {
+			t.Errorf("expected a count of %s resources", rtype)
+			continue
+		}
+		if count != expected[rtype] {
+			t.Errorf("expected %d %s resources, but got %d", expected[rtype], rtype, count)
+		}
+	}
+	if len(counts) != len(resourceTypes) {
+		t.Errorf("expected counts of %d types, but got %v", len(resourceTypes), counts)
+	}
+
+	// Counting deletes nothing.
+	if len(cloud.DisksClient.Disks) != 2 || len(cloud.SubnetsClient.Subnets) != 2 || len(cloud.VirtualNetworksClient.VNets) != 1 {
+		t.Errorf("expected no resources to be deleted")
+	}
+}
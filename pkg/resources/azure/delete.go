@@ -0,0 +1,126 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/utils/set"
+)
+
+// AzureTeardownFailure is the failure to delete a single resource.
+type AzureTeardownFailure struct {
+	Resource *resources.Resource
+	Err      error
+}
+
+// AzureTeardownError is returned, possibly wrapped, when some Azure resources
+// could not be deleted. Use errors.As to enumerate the failures.
+type AzureTeardownError struct {
+	Failures []AzureTeardownFailure
+}
+
+var _ error = &AzureTeardownError{}
+
+func (e *AzureTeardownError) Error() string {
+	var msgs []string
+	for _, f := range e.Failures {
+		msgs = append(msgs, fmt.Sprintf("deleting %s: %v", toKey(f.Resource.Type, f.Resource.ID), f.Err))
+	}
+	return fmt.Sprintf("failed to delete %d resource(s): %s", len(e.Failures), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failures, so that errors.Is and errors.As
+// can match them.
+func (e *AzureTeardownError) Unwrap() []error {
+	var errs []error
+	for _, f := range e.Failures {
+		errs = append(errs, f.Err)
+	}
+	return errs
+}
+
+// deletionOrder returns the keys of the resources to be deleted, in an order
+// that satisfies their dependencies. Shared resources are omitted. Resources
+// marked as done, because they are deleted together with the resource group,
+// are included.
+func deletionOrder(resourceMap map[string]*resources.Resource) ([]string, error) {
+	layers, err := resources.DeletionLayers(resourceMap, func(k string) bool {
+		return resourceMap[k].Shared
+	})
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	for _, layer := range layers {
+		order = append(order, layer...)
+	}
+	return order, nil
+}
+
+// dependencies returns, for each resource key, the keys of the resources that
+// must be deleted before it.
+func dependencies(resourceMap map[string]*resources.Resource) map[string][]string {
+	deps := map[string][]string{}
+	for k, r := range resourceMap {
+		for _, b := range r.Blocks {
+			deps[b] = append(deps[b], k)
+		}
+		deps[k] = append(deps[k], r.Blocked...)
+	}
+	return deps
+}
+
+// AzureTeardownCriticalPath returns the keys of the longest chain of dependent
+// deletions, in deletion order, and its estimated duration. This is the minimum
+// time needed to delete the resources, however many are deleted in parallel.
+// Durations are estimated from stats; resources marked as done take no time.
+func AzureTeardownCriticalPath(resourceMap map[string]*resources.Resource, stats *resources.DeletionStats) ([]string, time.Duration, error) {
+	order, err := deletionOrder(resourceMap)
+	if err != nil {
+		return nil, 0, err
+	}
+	deps := dependencies(resourceMap)
+
+	finish := make(map[string]time.Duration)
+	prev := make(map[string]string)
+	var last string
+	for _, k := range order {
+		r := resourceMap[k]
+		for _, dep := range set.New(deps[k]...).SortedList() {
+			if d, ok := finish[dep]; ok && (prev[k] == "" || d > finish[prev[k]]) {
+				prev[k] = dep
+			}
+		}
+		finish[k] = finish[prev[k]]
+		if !r.Done && stats != nil {
+			finish[k] += stats.Estimate(map[string]int{r.Type: 1})
+		}
+		if last == "" || finish[k] > finish[last] {
+			last = k
+		}
+	}
+
+	var path []string
+	for k := last; k != ""; k = prev[k] {
+		path = append([]string{k}, path...)
+	}
+	return path, finish[last], nil
+}
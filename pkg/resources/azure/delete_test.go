@@ -0,0 +1,82 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/kops/pkg/resources"
+)
+
+func TestDeletionOrder(t *testing.T) {
+	rgKey := toKey(typeResourceGroup, "rg")
+	resourceMap := map[string]*resources.Resource{
+		rgKey:                             {Type: typeResourceGroup, ID: "rg", Name: "rg"},
+		toKey(typeVirtualNetwork, "vnet"): {Type: typeVirtualNetwork, ID: "vnet", Name: "vnet", Shared: true},
+		toKey(typeDisk, "done"):           {Type: typeDisk, ID: "done", Name: "done", Done: true, Blocks: []string{rgKey}},
+		toKey(typeDisk, "disk"):           {Type: typeDisk, ID: "disk", Name: "disk", Blocks: []string{rgKey, toKey(typeVirtualNetwork, "vnet")}},
+		toKey(typeSnapshot, "snap"):       {Type: typeSnapshot, ID: "snap", Name: "snap", Blocks: []string{toKey(typeDisk, "disk")}},
+	}
+
+	order, err := deletionOrder(resourceMap)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	expected := []string{
+		toKey(typeDisk, "done"),
+		toKey(typeSnapshot, "snap"),
+		toKey(typeDisk, "disk"),
+		rgKey,
+	}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, but got %v", expected, order)
+	}
+}
+
+func TestAzureTeardownCriticalPath(t *testing.T) {
+	rgKey := toKey(typeResourceGroup, "rg")
+	vnetKey := toKey(typeVirtualNetwork, "vnet")
+	subnetKey := toKey(typeSubnet, "subnet")
+	vmssKey := toKey(typeVMScaleSet, "vmss")
+	resourceMap := map[string]*resources.Resource{
+		rgKey:                   {Type: typeResourceGroup, ID: "rg", Name: "rg"},
+		vnetKey:                 {Type: typeVirtualNetwork, ID: "vnet", Name: "vnet", Blocks: []string{rgKey}},
+		subnetKey:               {Type: typeSubnet, ID: "subnet", Name: "subnet", Blocks: []string{vnetKey}},
+		vmssKey:                 {Type: typeVMScaleSet, ID: "vmss", Name: "vmss", Blocks: []string{subnetKey}},
+		toKey(typeDisk, "disk"): {Type: typeDisk, ID: "disk", Name: "disk", Blocks: []string{rgKey}},
+	}
+	stats := &resources.DeletionStats{}
+	stats.Record(typeResourceGroup, 2*time.Minute)
+	stats.Record(typeVirtualNetwork, 20*time.Second)
+	stats.Record(typeSubnet, 10*time.Second)
+	stats.Record(typeVMScaleSet, 5*time.Minute)
+	stats.Record(typeDisk, time.Minute)
+
+	path, d, err := AzureTeardownCriticalPath(resourceMap, stats)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	expected := []string{vmssKey, subnetKey, vnetKey, rgKey}
+	if !reflect.DeepEqual(path, expected) {
+		t.Errorf("expected path %v, but got %v", expected, path)
+	}
+	if d != 7*time.Minute+30*time.Second {
+		t.Errorf("expected duration 7m30s, but got %s", d)
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	azureresources "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/utils/set"
+)
+
+// DescribeAzureResource returns a one-line description of a resource listed by
+// ListResourcesAzure, e.g. "VirtualNetwork vnet (eastus, shared, 2 dependencies)".
+func DescribeAzureResource(r *resources.Resource) string {
+	var details []string
+	if location := resourceLocation(r.Obj); location != "" {
+		details = append(details, location)
+	}
+	if r.Shared {
+		details = append(details, "shared")
+	}
+	deps := len(r.Blocks) + len(r.Blocked)
+	if deps == 1 {
+		details = append(details, "1 dependency")
+	} else {
+		details = append(details, fmt.Sprintf("%d dependencies", deps))
+	}
+	return fmt.Sprintf("%s %s (%s)", r.Type, r.Name, strings.Join(details, ", "))
+}
+
+// DescribeRoleAssignmentScopes summarizes the role assignments in the resource map
+// by the granularity of their scope, e.g. "3 at ResourceGroup scope, 1 at Disk scope".
+func DescribeRoleAssignmentScopes(resourceMap map[string]*resources.Resource) string {
+	counts := make(map[string]int)
+	for _, r := range resourceMap {
+		if r.Type != typeRoleAssignment {
+			continue
+		}
+		level := r.Metadata[metadataScope]
+		if level == "" {
+			level = "unknown"
+		}
+		counts[level]++
+	}
+
+	levels := make([]string, 0, len(counts))
+	for level := range counts {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if counts[levels[i]] != counts[levels[j]] {
+			return counts[levels[i]] > counts[levels[j]]
+		}
+		return levels[i] < levels[j]
+	})
+
+	var parts []string
+	for _, level := range levels {
+		parts = append(parts, fmt.Sprintf("%d at %s scope", counts[level], level))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// resourceLocation returns the location of an Azure resource, or an empty string
+// if it is not known.
+func resourceLocation(obj interface{}) string {
+	var location *string
+	switch obj := obj.(type) {
+	case *azureresources.ResourceGroup:
+		location = obj.Location
+	case *azureresources.GenericResourceExpanded:
+		location = obj.Location
+	case *network.VirtualNetwork:
+		location = obj.Location
+	case *network.SecurityGroup:
+		location = obj.Location
+	case *network.ApplicationSecurityGroup:
+		location = obj.Location
+	case *network.RouteTable:
+		location = obj.Location
+	case *network.LoadBalancer:
+		location = obj.Location
+	case *network.PublicIPAddress:
+		location = obj.Location
+	case *network.NatGateway:
+		location = obj.Location
+	case *network.PrivateEndpoint:
+		location = obj.Location
+	case *network.Interface:
+		location = obj.Location
+	case *network.ApplicationGateway:
+		location = obj.Location
+	case *azure.PrivateDNSZone:
+		location = obj.Location
+	case *compute.VirtualMachineScaleSet:
+		location = obj.Location
+	case *compute.VirtualMachine:
+		location = obj.Location
+	case *compute.Disk:
+		location = obj.Location
+	case *compute.DiskEncryptionSet:
+		location = obj.Location
+	case *compute.Snapshot:
+		location = obj.Location
+	case *armstorage.Account:
+		location = obj.Location
+	}
+	if location == nil {
+		return ""
+	}
+	return *location
+}
+
+// AzureResourceGraph is a preview of the deletion of an Azure cluster: the
+// resources that would be deleted and the order constraints between them.
+type AzureResourceGraph struct {
+	// Resources are the resources listed for the cluster, by key.
+	Resources map[string]*resources.Resource
+	// Edges maps the key of each resource to the sorted keys of the listed
+	// resources that can only be deleted after it.
+	Edges map[string][]string
+}
+
+// DescribeResourcesAzure lists the resources of the cluster like
+// ListResourcesAzure and returns them with their dependency graph. Nothing is
+// deleted.
+func DescribeResourcesAzure(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) (*AzureResourceGraph, error) {
+	resourceMap, err := ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		return nil, err
+	}
+	return newAzureResourceGraph(resourceMap), nil
+}
+
+// newAzureResourceGraph derives the edges of the graph from the Blocks and
+// Blocked of the resources, dropping the ones to resources that are not listed.
+func newAzureResourceGraph(resourceMap map[string]*resources.Resource) *AzureResourceGraph {
+	edges := make(map[string]set.Set[string])
+	addEdge := func(from, to string) {
+		if _, ok := resourceMap[from]; !ok {
+			return
+		}
+		if _, ok := resourceMap[to]; !ok {
+			return
+		}
+		if edges[from] == nil {
+			edges[from] = set.New[string]()
+		}
+		edges[from].Insert(to)
+	}
+	for k, r := range resourceMap {
+		for _, blocked := range r.Blocks {
+			addEdge(k, blocked)
+		}
+		for _, blocker := range r.Blocked {
+			addEdge(blocker, k)
+		}
+	}
+
+	graph := &AzureResourceGraph{
+		Resources: resourceMap,
+		Edges:     make(map[string][]string),
+	}
+	for k, to := range edges {
+		graph.Edges[k] = to.SortedList()
+	}
+	return graph
+}
+
+// ToDOT renders the graph in the DOT language of Graphviz, with an edge from
+// each resource to the resources that can only be deleted after it. Shared
+// resources, which are not deleted, are drawn dashed.
+func (g *AzureResourceGraph) ToDOT() string {
+	keys := make([]string, 0, len(g.Resources))
+	for k := range g.Resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("digraph resources {\n")
+	for _, k := range keys {
+		r := g.Resources[k]
+		style := ""
+		if r.Shared {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %q [label=%q%s];\n", k, r.Type+" "+r.Name, style)
+	}
+	for _, k := range keys {
+		for _, to := range g.Edges[k] {
+			fmt.Fprintf(&b, "  %q -> %q;\n", k, to)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	authz "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestDescribeAzureResource(t *testing.T) {
+	vnet := &resources.Resource{
+		Obj: &network.VirtualNetwork{
+			Name:     to.Ptr("vnet"),
+			Location: to.Ptr("eastus"),
+		},
+		Type:   typeVirtualNetwork,
+		ID:     "vnet",
+		Name:   "vnet",
+		Shared: true,
+		Blocks: []string{toKey(typeResourceGroup, "rg")},
+	}
+
+	description := DescribeAzureResource(vnet)
+	if expected := "VirtualNetwork vnet (eastus, shared, 1 dependency)"; description != expected {
+		t.Errorf("expected %q, but got %q", expected, description)
+	}
+	for _, s := range []string{"shared", "eastus"} {
+		if !strings.Contains(description, s) {
+			t.Errorf("expected %q to contain %q", description, s)
+		}
+	}
+
+	ra := &resources.Resource{
+		Type: typeRoleAssignment,
+		ID:   "ra",
+		Name: "ra",
+	}
+	if expected := "RoleAssignment ra (0 dependencies)"; DescribeAzureResource(ra) != expected {
+		t.Errorf("expected %q, but got %q", expected, DescribeAzureResource(ra))
+	}
+}
+
+func TestDescribeRoleAssignmentScopes(t *testing.T) {
+	g := &resourceGetter{
+		cloud:       azuretasks.NewMockAzureCloud("eastus"),
+		clusterInfo: resources.ClusterInfo{AzureResourceGroupName: "rg"},
+	}
+	scopes := map[string]string{
+		"ra-sub":   "/subscriptions/sid",
+		"ra-rg-1":  "/subscriptions/sid/resourceGroups/rg",
+		"ra-rg-2":  "/subscriptions/sid/resourceGroups/rg/",
+		"ra-rg-3":  "/subscriptions/sid/resourceGroups/other",
+		"ra-disk":  "/subscriptions/sid/resourceGroups/rg/providers/Microsoft.Compute/disks/etcd",
+		"ra-vault": "/subscriptions/sid/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv",
+		"ra-other": "/subscriptions/sid/resourceGroups/rg/providers/Microsoft.ContainerRegistry/registries/acr",
+		"ra-mg":    "/providers/Microsoft.Management/managementGroups/mg",
+	}
+	expectedLevels := map[string]string{
+		"ra-sub":   "Subscription",
+		"ra-rg-1":  "ResourceGroup",
+		"ra-rg-2":  "ResourceGroup",
+		"ra-rg-3":  "ResourceGroup",
+		"ra-disk":  "Disk",
+		"ra-vault": "KeyVault",
+		"ra-other": "Microsoft.ContainerRegistry/registries",
+		"ra-mg":    "Microsoft.Management/managementGroups",
+	}
+
+	resourceMap := make(map[string]*resources.Resource)
+	for name, scope := range scopes {
+		r := g.toRoleAssignmentResource(&authz.RoleAssignment{
+			Name: to.Ptr(name),
+			ID:   to.Ptr(fmt.Sprintf("%s/providers/Microsoft.Authorization/roleAssignments/%s", scope, name)),
+			Properties: &authz.RoleAssignmentProperties{
+				Scope: to.Ptr(scope),
+			},
+		}, nil)
+		if actual := r.Metadata[metadataScope]; actual != expectedLevels[name] {
+			t.Errorf("expected %q to be at %q scope, but got %q", name, expectedLevels[name], actual)
+		}
+		resourceMap[toKey(r.Type, r.ID)] = r
+	}
+
+	expected := "3 at ResourceGroup scope, 1 at Disk scope, 1 at KeyVault scope, 1 at Microsoft.ContainerRegistry/registries scope, " +
+		"1 at Microsoft.Management/managementGroups scope, 1 at Subscription scope"
+	if actual := DescribeRoleAssignmentScopes(resourceMap); actual != expected {
+		t.Errorf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestDescribeResourcesAzure(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	subnetID := azure.SubnetID{
+		SubscriptionID:     "sid",
+		ResourceGroupName:  rgName,
+		VirtualNetworkName: "vnet",
+		SubnetName:         "subnet",
+	}
+
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	mock.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+		Name:       to.Ptr("vnet"),
+		Tags:       clusterTags,
+		Properties: &network.VirtualNetworkPropertiesFormat{},
+	}
+	mock.SubnetsClient.Subnets["subnet"] = &network.Subnet{
+		ID:         to.Ptr(subnetID.String()),
+		Name:       to.Ptr("subnet"),
+		Properties: &network.SubnetPropertiesFormat{},
+	}
+	mock.VMScaleSetsClient.VMSSes["vmss"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("vmss"),
+		Tags: clusterTags,
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
+					NetworkInterfaceConfigurations: []*compute.VirtualMachineScaleSetNetworkConfiguration{
+						{
+							Properties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+								IPConfigurations: []*compute.VirtualMachineScaleSetIPConfiguration{
+									{
+										Properties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+											Subnet: &compute.APIEntityReference{
+												ID: to.Ptr(subnetID.String()),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// Describing makes no write calls, let alone deletes.
+	graph, err := DescribeResourcesAzure(&readOnlyCloud{MockAzureCloud: mock}, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	vmssKey := toKey(typeVMScaleSet, "vmss")
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		toKey(typeSubnet, "subnet"),
+		toKey(typeVirtualNetwork, "vnet"),
+	}
+	if actual := graph.Edges[vmssKey]; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected edges %v from %q, but got %v", expected, vmssKey, actual)
+	}
+
+	dot := graph.ToDOT()
+	for _, edge := range []string{
+		fmt.Sprintf("%q -> %q;", vmssKey, toKey(typeSubnet, "subnet")),
+		fmt.Sprintf("%q -> %q;", vmssKey, toKey(typeResourceGroup, rgName)),
+	} {
+		if !strings.Contains(dot, edge) {
+			t.Errorf("expected DOT output to contain %s, but got:\n%s", edge, dot)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"k8s.io/kops/pkg/resources"
+)
+
+// resourceTypes are the types of the resources listed for a cluster, which
+// the AzureResourceFilter of the cluster info can select.
+var resourceTypes = []string{
+	typeResourceGroup,
+	typeVirtualNetwork,
+	typeNetworkSecurityGroup,
+	typeNetworkSecurityRule,
+	typeApplicationSecurityGroup,
+	typeSubnet,
+	typeRouteTable,
+	typeRoute,
+	typeVMScaleSet,
+	typeVirtualMachine,
+	typeNetworkInterface,
+	typeDisk,
+	typeDiskEncryptionSet,
+	typeRoleAssignment,
+	typeLoadBalancer,
+	typePublicIPAddress,
+	typeNatGateway,
+	typeApplicationGateway,
+	typeSnapshot,
+	typeStorageAccount,
+	typePrivateEndpoint,
+	typeKeyVault,
+	typeRecoveryServicesVault,
+	typeNetworkWatcher,
+	typeManagedIdentity,
+	typePrivateDNSZone,
+	typePrivateDNSRecordSet,
+	typePrivateDNSZoneLink,
+}
+
+// typedLister is a lister along with the types of the resources it lists.
+type typedLister struct {
+	types []string
+	list  func(ctx context.Context) ([]*resources.Resource, error)
+}
+
+// validateResourceFilter checks that the AzureResourceFilter names known types.
+func (g *resourceGetter) validateResourceFilter() error {
+	filter := g.clusterInfo.AzureResourceFilter
+	for _, t := range append(slices.Clone(filter.Include), filter.Exclude...) {
+		if !slices.Contains(resourceTypes, t) {
+			return fmt.Errorf("unknown Azure resource type %q in resource filter", t)
+		}
+	}
+	return nil
+}
+
+// allowedListers returns the listers of which the AzureResourceFilter allows
+// at least one type, so that the others make no call to Azure.
+func (g *resourceGetter) allowedListers(listers []typedLister) []func(ctx context.Context) ([]*resources.Resource, error) {
+	filter := g.clusterInfo.AzureResourceFilter
+	var fns []func(ctx context.Context) ([]*resources.Resource, error)
+	for _, l := range listers {
+		if slices.ContainsFunc(l.types, filter.Allows) {
+			fns = append(fns, l.list)
+		}
+	}
+	return fns
+}
+
+// filterResources drops the resources of the types that the AzureResourceFilter
+// does not allow, which the listers of several types may have listed.
+func (g *resourceGetter) filterResources(rs []*resources.Resource) []*resources.Resource {
+	filter := g.clusterInfo.AzureResourceFilter
+	if filter.IsZero() {
+		return rs
+	}
+	return slices.DeleteFunc(rs, func(r *resources.Resource) bool {
+		return !filter.Allows(r.Type)
+	})
+}
+
+// resourceFilterKeepsResourceGroup returns whether the resource group must be
+// kept because of the AzureResourceFilter: deleting it would also delete the
+// resources of the types filtered out, unless it is explicitly included.
+func (g *resourceGetter) resourceFilterKeepsResourceGroup() bool {
+	filter := g.clusterInfo.AzureResourceFilter
+	if filter.IsZero() {
+		return false
+	}
+	return len(filter.Exclude) > 0 || !slices.Contains(filter.Include, typeResourceGroup)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+// unlistableDisksCloud fails to list disks.
+type unlistableDisksCloud struct {
+	*azuretasks.MockAzureCloud
+}
+
+func (c *unlistableDisksCloud) Disk() azure.DisksClient {
+	return &unlistableDisksClient{c.MockAzureCloud.Disk()}
+}
+
+type unlistableDisksClient struct{ azure.DisksClient }
+
+func (c *unlistableDisksClient) List(context.Context, string) ([]*compute.Disk, error) {
+	return nil, errors.New("disks listed")
+}
+
+func TestListResourcesAzureResourceFilter(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	mock.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+		Name:       to.Ptr("vnet"),
+		Tags:       clusterTags,
+		Properties: &network.VirtualNetworkPropertiesFormat{},
+	}
+	mock.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: clusterTags,
+	}
+	mock.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+	clusterInfo.AzureResourceFilter.Exclude = []string{typeDisk}
+	rs, err := ListResourcesAzure(&unlistableDisksCloud{MockAzureCloud: mock}, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, ok := rs[toKey(typeDisk, "disk")]; ok {
+		t.Errorf("expected disk not to be listed")
+	}
+	for _, key := range []string{
+		toKey(typeResourceGroup, rgName),
+		toKey(typeVirtualNetwork, "vnet"),
+		toKey(typeRouteTable, "rt"),
+	} {
+		if _, ok := rs[key]; !ok {
+			t.Errorf("expected %q to be listed", key)
+		}
+	}
+	if !rs[toKey(typeResourceGroup, rgName)].Shared {
+		t.Errorf("expected the resource group holding the disks to be kept")
+	}
+
+	clusterInfo.AzureResourceFilter = resources.AzureResourceFilter{
+		Include: []string{typeResourceGroup, typeDisk},
+	}
+	rs, err = ListResourcesAzure(mock, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(rs) != 2 {
+		t.Errorf("expected only the resource group and the disk to be listed, but got %v", rs)
+	}
+	if rg := rs[toKey(typeResourceGroup, rgName)]; rg == nil || rg.Shared {
+		t.Errorf("expected the included resource group to be deleted")
+	}
+
+	clusterInfo.AzureResourceFilter = resources.AzureResourceFilter{
+		Exclude: []string{"Disks"},
+	}
+	if _, err := ListResourcesAzure(mock, clusterInfo); err == nil {
+		t.Errorf("expected an error for an unknown resource type")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// expectedClusterResourceTypes are the types of resources that every fully
+// created cluster has.
+var expectedClusterResourceTypes = []string{
+	typeVirtualNetwork,
+	typeSubnet,
+	typeRouteTable,
+	typeVMScaleSet,
+}
+
+// IncompleteCluster is the set of resources left behind by a cluster whose
+// creation did not complete.
+type IncompleteCluster struct {
+	// Resources are the resources of the cluster that exist.
+	Resources map[string]*resources.Resource
+	// Missing are the types of resources that a complete cluster would have,
+	// but that were not found.
+	Missing []string
+}
+
+// ListIncompleteResourcesAzure lists the resources of a cluster whose creation
+// failed or was interrupted, e.g. a virtual network without any VM Scale Set.
+// It returns nil if the cluster has all the expected resource types, so that
+// the resources of a healthy cluster are never mistaken for leftovers.
+func ListIncompleteResourcesAzure(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) (*IncompleteCluster, error) {
+	resourceMap, err := ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		return nil, err
+	}
+	return findIncompleteCluster(resourceMap), nil
+}
+
+// findIncompleteCluster returns the resources and the missing resource types
+// if the resources are an incomplete cluster, or nil otherwise.
+func findIncompleteCluster(resourceMap map[string]*resources.Resource) *IncompleteCluster {
+	if len(resourceMap) == 0 {
+		return nil
+	}
+
+	found := map[string]bool{}
+	for _, r := range resourceMap {
+		found[r.Type] = true
+	}
+	var missing []string
+	for _, rtype := range expectedClusterResourceTypes {
+		if !found[rtype] {
+			missing = append(missing, rtype)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return &IncompleteCluster{
+		Resources: resourceMap,
+		Missing:   missing,
+	}
+}
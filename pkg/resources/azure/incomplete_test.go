@@ -0,0 +1,134 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestListIncompleteResourcesAzure(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		vnetName    = "vnet"
+		subnetName  = "sub"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	// The creation of the cluster failed after the network was created.
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.VirtualNetworksClient.VNets[vnetName] = &network.VirtualNetwork{
+		Name:       to.Ptr(vnetName),
+		Tags:       clusterTags,
+		Properties: &network.VirtualNetworkPropertiesFormat{},
+	}
+	cloud.SubnetsClient.Subnets[subnetName] = &network.Subnet{
+		Name:       to.Ptr(subnetName),
+		Properties: &network.SubnetPropertiesFormat{},
+	}
+
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+	incomplete, err := ListIncompleteResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if incomplete == nil {
+		t.Fatalf("expected the cluster to be incomplete")
+	}
+
+	var actual []string
+	for k := range incomplete.Resources {
+		actual = append(actual, k)
+	}
+	expected := []string{
+		toKey(typeResourceGroup, rgName),
+		toKey(typeSubnet, subnetName),
+		toKey(typeVirtualNetwork, vnetName),
+	}
+	sort.Strings(actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected resources %v, but got %v", expected, actual)
+	}
+	expectedMissing := []string{typeRouteTable, typeVMScaleSet}
+	if !reflect.DeepEqual(incomplete.Missing, expectedMissing) {
+		t.Errorf("expected missing %v, but got %v", expectedMissing, incomplete.Missing)
+	}
+}
+
+func TestFindIncompleteCluster(t *testing.T) {
+	newResources := func(rtypes ...string) map[string]*resources.Resource {
+		m := map[string]*resources.Resource{}
+		for _, rtype := range rtypes {
+			m[toKey(rtype, "name")] = &resources.Resource{Type: rtype, ID: "name", Name: "name"}
+		}
+		return m
+	}
+
+	testCases := []struct {
+		name            string
+		resources       map[string]*resources.Resource
+		expectedMissing []string
+	}{
+		{
+			name: "no resources",
+		},
+		{
+			name:      "complete cluster",
+			resources: newResources(typeResourceGroup, typeVirtualNetwork, typeSubnet, typeRouteTable, typeVMScaleSet),
+		},
+		{
+			name:            "compute only",
+			resources:       newResources(typeVMScaleSet, typeDisk),
+			expectedMissing: []string{typeVirtualNetwork, typeSubnet, typeRouteTable},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			incomplete := findIncompleteCluster(tc.resources)
+			if tc.expectedMissing == nil {
+				if incomplete != nil {
+					t.Errorf("expected no incomplete cluster, but got %+v", incomplete)
+				}
+				return
+			}
+			if incomplete == nil {
+				t.Fatalf("expected an incomplete cluster")
+			}
+			if !reflect.DeepEqual(incomplete.Missing, tc.expectedMissing) {
+				t.Errorf("expected missing %v, but got %v", tc.expectedMissing, incomplete.Missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/utils/set"
+	"sigs.k8s.io/yaml"
+)
+
+// teardownManifestVersion is the version of the teardown manifest format.
+const teardownManifestVersion = 1
+
+// TeardownManifest lists the Azure resources of a cluster to be deleted, in
+// deletion order, so that the teardown can be reviewed before it is applied.
+type TeardownManifest struct {
+	Version       int                        `json:"version"`
+	ClusterName   string                     `json:"clusterName"`
+	ResourceGroup string                     `json:"resourceGroup"`
+	Resources     []TeardownManifestResource `json:"resources"`
+}
+
+// TeardownManifestResource is a resource listed in a TeardownManifest.
+type TeardownManifestResource struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ExportAzureTeardownManifest lists the resources of the cluster and returns
+// a YAML manifest of the resources that would be deleted, in deletion order.
+// Resources deleted together with the resource group are listed too. Entries
+// can be removed from the manifest before it is applied.
+func ExportAzureTeardownManifest(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) ([]byte, error) {
+	resourceMap, err := ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		return nil, err
+	}
+	order, err := deletionOrder(resourceMap)
+	if err != nil {
+		return nil, err
+	}
+
+	m := TeardownManifest{
+		Version:       teardownManifestVersion,
+		ClusterName:   clusterInfo.Name,
+		ResourceGroup: clusterInfo.AzureResourceGroupName,
+	}
+	for _, k := range order {
+		r := resourceMap[k]
+		m.Resources = append(m.Resources, TeardownManifestResource{
+			Type: r.Type,
+			ID:   r.ID,
+			Name: r.Name,
+		})
+	}
+	return yaml.Marshal(m)
+}
+
+// SelectAzureTeardownManifest discovers the resources of the cluster again, so
+// that they are deleted with up-to-date dependencies, and returns them with the
+// resources not listed in the manifest marked as done. It is an error if a
+// listed resource no longer belongs to the cluster, or if the manifest deletes
+// the resource group but keeps some of the resources in it.
+func SelectAzureTeardownManifest(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo, manifest []byte) (map[string]*resources.Resource, error) {
+	var m TeardownManifest
+	if err := yaml.UnmarshalStrict(manifest, &m); err != nil {
+		return nil, fmt.Errorf("parsing teardown manifest: %w", err)
+	}
+	if m.Version != teardownManifestVersion {
+		return nil, fmt.Errorf("unsupported teardown manifest version %d", m.Version)
+	}
+	if m.ClusterName != clusterInfo.Name {
+		return nil, fmt.Errorf("teardown manifest is for cluster %q, not %q", m.ClusterName, clusterInfo.Name)
+	}
+
+	resourceMap, err := ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		return nil, err
+	}
+	selected := set.New[string]()
+	for _, mr := range m.Resources {
+		k := toKey(mr.Type, mr.ID)
+		if _, ok := resourceMap[k]; !ok {
+			return nil, fmt.Errorf("resource %s in the teardown manifest was not found", k)
+		}
+		selected.Insert(k)
+	}
+
+	rgKey := toKey(typeResourceGroup, clusterInfo.AzureResourceGroupName)
+	if selected.Has(rgKey) {
+		for _, k := range set.KeySet(resourceMap).SortedList() {
+			if !selected.Has(k) && !resourceMap[k].Shared {
+				return nil, fmt.Errorf("teardown manifest deletes resource group %q but keeps %s, which would be deleted with it", clusterInfo.AzureResourceGroupName, k)
+			}
+		}
+	}
+
+	selectedMap := make(map[string]*resources.Resource)
+	for k, r := range resourceMap {
+		if r.Shared {
+			continue
+		}
+		if !selected.Has(k) {
+			r.Done = true
+		} else if !selected.Has(rgKey) {
+			// The resource group is kept, so the resource must be deleted on its own.
+			r.Done = false
+		}
+		selectedMap[k] = r
+	}
+	return selectedMap, nil
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+	"sigs.k8s.io/yaml"
+)
+
+func newTeardownManifestTestCloud(clusterName, rgName string) *azuretasks.MockAzureCloud {
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: clusterTags,
+	}
+	cloud.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip"] = &network.PublicIPAddress{
+		Name: to.Ptr("pip"),
+		Tags: clusterTags,
+	}
+	return cloud
+}
+
+func TestExportAzureTeardownManifest(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	cloud := newTeardownManifestTestCloud(clusterName, rgName)
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+
+	b, err := ExportAzureTeardownManifest(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	var m TeardownManifest
+	if err := yaml.UnmarshalStrict(b, &m); err != nil {
+		t.Fatalf("unexpected error parsing manifest %s", err)
+	}
+	expected := TeardownManifest{
+		Version:       teardownManifestVersion,
+		ClusterName:   clusterName,
+		ResourceGroup: rgName,
+		Resources: []TeardownManifestResource{
+			{Type: typeDisk, ID: "disk", Name: "disk"},
+			{Type: typePublicIPAddress, ID: "pip", Name: "pip"},
+			{Type: typeRouteTable, ID: "rt", Name: "rt"},
+			{Type: typeResourceGroup, ID: rgName, Name: rgName},
+		},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, m)
+	}
+
+	// Exporting again yields the same manifest.
+	b2, err := ExportAzureTeardownManifest(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if string(b) != string(b2) {
+		t.Errorf("expected a stable manifest, but got\n%s\nand\n%s", b, b2)
+	}
+}
+
+func TestExportAzureTeardownManifestResourceGroupDelete(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	cloud := newTeardownManifestTestCloud(clusterName, rgName)
+	clusterInfo := resources.ClusterInfo{
+		Name:                         clusterName,
+		AzureResourceGroupName:       rgName,
+		AzureResourceGroupMoveTarget: "parking",
+	}
+
+	b, err := ExportAzureTeardownManifest(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var m TeardownManifest
+	if err := yaml.UnmarshalStrict(b, &m); err != nil {
+		t.Fatalf("unexpected error parsing manifest %s", err)
+	}
+
+	// The resources deleted with the resource group are listed too.
+	var actual []string
+	for _, r := range m.Resources {
+		actual = append(actual, toKey(r.Type, r.ID))
+	}
+	expected := []string{
+		toKey(typeDisk, "disk"),
+		toKey(typePublicIPAddress, "pip"),
+		toKey(typeRouteTable, "rt"),
+		toKey(typeResourceGroup, rgName),
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestSelectAzureTeardownManifest(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	cloud := newTeardownManifestTestCloud(clusterName, rgName)
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+
+	b, err := ExportAzureTeardownManifest(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var m TeardownManifest
+	if err := yaml.UnmarshalStrict(b, &m); err != nil {
+		t.Fatalf("unexpected error parsing manifest %s", err)
+	}
+	trim := func(dropped ...string) []byte {
+		mm := m
+		mm.Resources = nil
+		for _, r := range m.Resources {
+			if !slices.Contains(dropped, r.Type) {
+				mm.Resources = append(mm.Resources, r)
+			}
+		}
+		b, err := yaml.Marshal(mm)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		return b
+	}
+
+	// Keeping the resource group while dropping one of its resources is fine.
+	selected, err := SelectAzureTeardownManifest(cloud, clusterInfo, trim(typeDisk, typeResourceGroup))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for k, r := range selected {
+		expectDone := k == toKey(typeDisk, "disk") || k == toKey(typeResourceGroup, rgName)
+		if r.Done != expectDone {
+			t.Errorf("expected %s to be done=%t", k, expectDone)
+		}
+	}
+
+	// Deleting the resource group while dropping one of its resources is not.
+	if _, err := SelectAzureTeardownManifest(cloud, clusterInfo, trim(typeDisk)); err == nil {
+		t.Errorf("expected an error for a manifest deleting the resource group but keeping the disk")
+	}
+
+	// Manifests of another format are rejected.
+	if _, err := SelectAzureTeardownManifest(cloud, clusterInfo, []byte("version: 2\nclusterName: cluster\n")); err == nil {
+		t.Errorf("expected an error for an unsupported manifest version")
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"slices"
+
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// kopsLoadBalancingRuleNames are the names of the load balancing rules that
+// kops creates on the API load balancer.
+var kopsLoadBalancingRuleNames = []string{
+	azure.LoadBalancingRuleKubeAPIServer,
+	azure.LoadBalancingRuleKopsController,
+}
+
+// foreignLoadBalancingRules returns the names of the load balancing rules of
+// the load balancer that were not created by kops.
+func foreignLoadBalancingRules(lb *network.LoadBalancer) []string {
+	if lb.Properties == nil {
+		return nil
+	}
+	var names []string
+	for _, rule := range lb.Properties.LoadBalancingRules {
+		if rule == nil || rule.Name == nil {
+			continue
+		}
+		if slices.Contains(kopsLoadBalancingRuleNames, *rule.Name) {
+			continue
+		}
+		names = append(names, *rule.Name)
+	}
+	return names
+}
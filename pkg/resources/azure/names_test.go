@@ -0,0 +1,43 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+)
+
+func TestForeignLoadBalancingRules(t *testing.T) {
+	lb := &network.LoadBalancer{
+		Name: to.Ptr("api-cluster"),
+		Properties: &network.LoadBalancerPropertiesFormat{
+			LoadBalancingRules: []*network.LoadBalancingRule{
+				{Name: to.Ptr("TCP-443")},
+				{Name: to.Ptr("TCP-3988")},
+				{Name: to.Ptr("TCP-44")},
+				{Name: to.Ptr("a1b2c3-TCP-80")},
+			},
+		},
+	}
+
+	actual := foreignLoadBalancingRules(lb)
+	if len(actual) != 2 || actual[0] != "TCP-44" || actual[1] != "a1b2c3-TCP-80" {
+		t.Errorf("expected [TCP-44 a1b2c3-TCP-80], but got %v", actual)
+	}
+}
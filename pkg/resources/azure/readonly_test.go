@@ -0,0 +1,469 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	authz "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+var errReadOnly = errors.New("write call made with a read-only role")
+
+// readOnlyCloud is a mock cloud whose clients reject every call that would
+// require write permissions, like a principal assigned only the Reader role.
+type readOnlyCloud struct {
+	*azuretasks.MockAzureCloud
+}
+
+var _ azure.AzureCloud = &readOnlyCloud{}
+
+func (c *readOnlyCloud) ResourceGroup() azure.ResourceGroupsClient {
+	return &readOnlyResourceGroupsClient{c.MockAzureCloud.ResourceGroup()}
+}
+
+func (c *readOnlyCloud) VirtualNetwork() azure.VirtualNetworksClient {
+	return &readOnlyVirtualNetworksClient{c.MockAzureCloud.VirtualNetwork()}
+}
+
+func (c *readOnlyCloud) Subnet() azure.SubnetsClient {
+	return &readOnlySubnetsClient{c.MockAzureCloud.Subnet()}
+}
+
+func (c *readOnlyCloud) RouteTable() azure.RouteTablesClient {
+	return &readOnlyRouteTablesClient{c.MockAzureCloud.RouteTable()}
+}
+
+func (c *readOnlyCloud) NetworkSecurityGroup() azure.NetworkSecurityGroupsClient {
+	return &readOnlyNetworkSecurityGroupsClient{c.MockAzureCloud.NetworkSecurityGroup()}
+}
+
+func (c *readOnlyCloud) ApplicationSecurityGroup() azure.ApplicationSecurityGroupsClient {
+	return &readOnlyApplicationSecurityGroupsClient{c.MockAzureCloud.ApplicationSecurityGroup()}
+}
+
+func (c *readOnlyCloud) VMScaleSet() azure.VMScaleSetsClient {
+	return &readOnlyVMScaleSetsClient{c.MockAzureCloud.VMScaleSet()}
+}
+
+func (c *readOnlyCloud) VMScaleSetVM() azure.VMScaleSetVMsClient {
+	return &readOnlyVMScaleSetVMsClient{c.MockAzureCloud.VMScaleSetVM()}
+}
+
+func (c *readOnlyCloud) VirtualMachine() azure.VirtualMachinesClient {
+	return &readOnlyVirtualMachinesClient{c.MockAzureCloud.VirtualMachine()}
+}
+
+func (c *readOnlyCloud) NetworkInterface() azure.NetworkInterfacesClient {
+	return &readOnlyNetworkInterfacesClient{c.MockAzureCloud.NetworkInterface()}
+}
+
+func (c *readOnlyCloud) Disk() azure.DisksClient {
+	return &readOnlyDisksClient{c.MockAzureCloud.Disk()}
+}
+
+func (c *readOnlyCloud) RoleAssignment() azure.RoleAssignmentsClient {
+	return &readOnlyRoleAssignmentsClient{c.MockAzureCloud.RoleAssignment()}
+}
+
+func (c *readOnlyCloud) LoadBalancer() azure.LoadBalancersClient {
+	return &readOnlyLoadBalancersClient{c.MockAzureCloud.LoadBalancer()}
+}
+
+func (c *readOnlyCloud) PublicIPAddress() azure.PublicIPAddressesClient {
+	return &readOnlyPublicIPAddressesClient{c.MockAzureCloud.PublicIPAddress()}
+}
+
+func (c *readOnlyCloud) NatGateway() azure.NatGatewaysClient {
+	return &readOnlyNatGatewaysClient{c.MockAzureCloud.NatGateway()}
+}
+
+func (c *readOnlyCloud) Snapshot() azure.SnapshotsClient {
+	return &readOnlySnapshotsClient{c.MockAzureCloud.Snapshot()}
+}
+
+func (c *readOnlyCloud) DiskEncryptionSet() azure.DiskEncryptionSetsClient {
+	return &readOnlyDiskEncryptionSetsClient{c.MockAzureCloud.DiskEncryptionSet()}
+}
+
+func (c *readOnlyCloud) KeyVault() azure.KeyVaultsClient {
+	return &readOnlyKeyVaultsClient{c.MockAzureCloud.KeyVault()}
+}
+
+func (c *readOnlyCloud) Resource() azure.ResourcesClient {
+	return &readOnlyResourcesClient{c.MockAzureCloud.Resource()}
+}
+
+func (c *readOnlyCloud) BackupItem() azure.BackupItemsClient {
+	return &readOnlyBackupItemsClient{c.MockAzureCloud.BackupItem()}
+}
+
+func (c *readOnlyCloud) PrivateDNSZone() azure.PrivateDNSZonesClient {
+	return &readOnlyPrivateDNSZonesClient{c.MockAzureCloud.PrivateDNSZone()}
+}
+
+func (c *readOnlyCloud) ApplicationGateway() azure.ApplicationGatewaysClient {
+	return &readOnlyApplicationGatewaysClient{c.MockAzureCloud.ApplicationGateway()}
+}
+
+func (c *readOnlyCloud) ManagedIdentity() azure.ManagedIdentitiesClient {
+	return &readOnlyManagedIdentitiesClient{c.MockAzureCloud.ManagedIdentity()}
+}
+
+func (c *readOnlyCloud) StorageAccount() azure.StorageAccountsClient {
+	return &readOnlyStorageAccountsClient{c.MockAzureCloud.StorageAccount()}
+}
+
+func (c *readOnlyCloud) PrivateEndpoint() azure.PrivateEndpointsClient {
+	return &readOnlyPrivateEndpointsClient{c.MockAzureCloud.PrivateEndpoint()}
+}
+
+type readOnlyResourceGroupsClient struct{ azure.ResourceGroupsClient }
+
+func (c *readOnlyResourceGroupsClient) CreateOrUpdate(context.Context, string, armresources.ResourceGroup) error {
+	return errReadOnly
+}
+
+func (c *readOnlyResourceGroupsClient) Delete(context.Context, string) error {
+	return errReadOnly
+}
+
+type readOnlyVirtualNetworksClient struct{ azure.VirtualNetworksClient }
+
+func (c *readOnlyVirtualNetworksClient) CreateOrUpdate(context.Context, string, string, network.VirtualNetwork) (*network.VirtualNetwork, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyVirtualNetworksClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlySubnetsClient struct{ azure.SubnetsClient }
+
+func (c *readOnlySubnetsClient) CreateOrUpdate(context.Context, string, string, string, network.Subnet) (*network.Subnet, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlySubnetsClient) Delete(context.Context, string, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyRouteTablesClient struct{ azure.RouteTablesClient }
+
+func (c *readOnlyRouteTablesClient) CreateOrUpdate(context.Context, string, string, network.RouteTable) (*network.RouteTable, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyRouteTablesClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyNetworkSecurityGroupsClient struct {
+	azure.NetworkSecurityGroupsClient
+}
+
+func (c *readOnlyNetworkSecurityGroupsClient) CreateOrUpdate(context.Context, string, string, network.SecurityGroup) (*network.SecurityGroup, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyNetworkSecurityGroupsClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyApplicationSecurityGroupsClient struct {
+	azure.ApplicationSecurityGroupsClient
+}
+
+func (c *readOnlyApplicationSecurityGroupsClient) CreateOrUpdate(context.Context, string, string, network.ApplicationSecurityGroup) (*network.ApplicationSecurityGroup, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyApplicationSecurityGroupsClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyVMScaleSetsClient struct{ azure.VMScaleSetsClient }
+
+func (c *readOnlyVMScaleSetsClient) CreateOrUpdate(context.Context, string, string, compute.VirtualMachineScaleSet) (*compute.VirtualMachineScaleSet, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyVMScaleSetsClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyVMScaleSetVMsClient struct{ azure.VMScaleSetVMsClient }
+
+func (c *readOnlyVMScaleSetVMsClient) Update(context.Context, string, string, string, compute.VirtualMachineScaleSetVM) (*compute.VirtualMachineScaleSetVM, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyVMScaleSetVMsClient) Delete(context.Context, string, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyVirtualMachinesClient struct{ azure.VirtualMachinesClient }
+
+func (c *readOnlyVirtualMachinesClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyNetworkInterfacesClient struct{ azure.NetworkInterfacesClient }
+
+func (c *readOnlyNetworkInterfacesClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyDisksClient struct{ azure.DisksClient }
+
+func (c *readOnlyDisksClient) CreateOrUpdate(context.Context, string, string, compute.Disk) (*compute.Disk, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyDisksClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyRoleAssignmentsClient struct{ azure.RoleAssignmentsClient }
+
+func (c *readOnlyRoleAssignmentsClient) Create(context.Context, string, string, authz.RoleAssignmentCreateParameters) (*authz.RoleAssignment, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyRoleAssignmentsClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyLoadBalancersClient struct{ azure.LoadBalancersClient }
+
+func (c *readOnlyLoadBalancersClient) CreateOrUpdate(context.Context, string, string, network.LoadBalancer) (*network.LoadBalancer, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyLoadBalancersClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyPublicIPAddressesClient struct{ azure.PublicIPAddressesClient }
+
+func (c *readOnlyPublicIPAddressesClient) CreateOrUpdate(context.Context, string, string, network.PublicIPAddress) (*network.PublicIPAddress, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyPublicIPAddressesClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyNatGatewaysClient struct{ azure.NatGatewaysClient }
+
+func (c *readOnlyNatGatewaysClient) CreateOrUpdate(context.Context, string, string, network.NatGateway) (*network.NatGateway, error) {
+	return nil, errReadOnly
+}
+
+func (c *readOnlyNatGatewaysClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlySnapshotsClient struct{ azure.SnapshotsClient }
+
+func (c *readOnlySnapshotsClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyDiskEncryptionSetsClient struct {
+	azure.DiskEncryptionSetsClient
+}
+
+func (c *readOnlyDiskEncryptionSetsClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyKeyVaultsClient struct{ azure.KeyVaultsClient }
+
+func (c *readOnlyKeyVaultsClient) PurgeDeleted(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyResourcesClient struct{ azure.ResourcesClient }
+
+func (c *readOnlyResourcesClient) DeleteByID(context.Context, string, string) error {
+	return errReadOnly
+}
+
+func (c *readOnlyResourcesClient) MoveResources(context.Context, string, []string, string) error {
+	return errReadOnly
+}
+
+func (c *readOnlyResourcesClient) UpdateTags(context.Context, string, map[string]*string) error {
+	return errReadOnly
+}
+
+type readOnlyBackupItemsClient struct{ azure.BackupItemsClient }
+
+func (c *readOnlyBackupItemsClient) Delete(context.Context, string) error {
+	return errReadOnly
+}
+
+type readOnlyPrivateDNSZonesClient struct{ azure.PrivateDNSZonesClient }
+
+func (c *readOnlyPrivateDNSZonesClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+func (c *readOnlyPrivateDNSZonesClient) DeleteRecordSet(context.Context, string, string, string, string) error {
+	return errReadOnly
+}
+
+func (c *readOnlyPrivateDNSZonesClient) DeleteVirtualNetworkLink(context.Context, string, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyApplicationGatewaysClient struct {
+	azure.ApplicationGatewaysClient
+}
+
+func (c *readOnlyApplicationGatewaysClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyManagedIdentitiesClient struct{ azure.ManagedIdentitiesClient }
+
+func (c *readOnlyManagedIdentitiesClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyStorageAccountsClient struct{ azure.StorageAccountsClient }
+
+func (c *readOnlyStorageAccountsClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+type readOnlyPrivateEndpointsClient struct{ azure.PrivateEndpointsClient }
+
+func (c *readOnlyPrivateEndpointsClient) Delete(context.Context, string, string) error {
+	return errReadOnly
+}
+
+func TestListResourcesAzureReadOnly(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	keyVaultID := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.KeyVault/vaults/kv", rgName)
+
+	mock := newTeardownManifestTestCloud(clusterName, rgName)
+	mock.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: clusterTags,
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+		Identity: &compute.VirtualMachineScaleSetIdentity{
+			Type:        to.Ptr(compute.ResourceIdentityTypeSystemAssigned),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	mock.RoleAssignmentsClient.RAs["ra"] = &authz.RoleAssignment{
+		Name: to.Ptr("ra"),
+		Properties: &authz.RoleAssignmentProperties{
+			Scope:       to.Ptr("scope"),
+			PrincipalID: to.Ptr("pid"),
+		},
+	}
+	mock.StorageAccountsClient.SAs["sa"] = &armstorage.Account{
+		Name: to.Ptr("sa"),
+		Tags: clusterTags,
+	}
+	mock.ResourcesClient.Resources[keyVaultID] = &armresources.GenericResourceExpanded{
+		ID:   to.Ptr(keyVaultID),
+		Name: to.Ptr("kv"),
+		Type: to.Ptr(keyVaultResourceType),
+		Tags: clusterTags,
+	}
+	mock.PrivateEndpointsClient.PEs["pe"] = &network.PrivateEndpoint{
+		Name: to.Ptr("pe"),
+		Tags: clusterTags,
+		Properties: &network.PrivateEndpointProperties{
+			PrivateLinkServiceConnections: []*network.PrivateLinkServiceConnection{
+				{
+					Properties: &network.PrivateLinkServiceConnectionProperties{
+						PrivateLinkServiceID: to.Ptr(keyVaultID),
+					},
+				},
+			},
+		},
+	}
+	cloud := &readOnlyCloud{MockAzureCloud: mock}
+
+	testCases := []struct {
+		name        string
+		clusterInfo resources.ClusterInfo
+	}{
+		{
+			name: "default",
+			clusterInfo: resources.ClusterInfo{
+				Name:                   clusterName,
+				AzureResourceGroupName: rgName,
+			},
+		},
+		{
+			name: "all opt-ins",
+			clusterInfo: resources.ClusterInfo{
+				Name:                         clusterName,
+				AzureResourceGroupName:       rgName,
+				AzureDeleteSnapshots:         true,
+				AzureDeleteStorage:           true,
+				AzureDeleteBackups:           true,
+				AzureResourceGroupMoveTarget: "/subscriptions/sid/resourceGroups/target",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs, err := ListResourcesAzure(cloud, tc.clusterInfo)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			for _, key := range []string{
+				toKey(typeResourceGroup, rgName),
+				toKey(typeVMScaleSet, "nodes"),
+				toKey(typeRoleAssignment, "ra"),
+				toKey(typeDisk, "disk"),
+			} {
+				if _, ok := rs[key]; !ok {
+					t.Errorf("expected %q to be listed", key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	azureresources "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/klog/v2"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/utils/set"
+)
+
+// maxResourceGroupDeleteExclusions is the maximum number of resources not owned by
+// the cluster that are moved out of the cluster resource group so that it can be
+// deleted as a whole.
+const maxResourceGroupDeleteExclusions = 5
+
+// resourceGroupDeletePlan describes how the cluster resource group is torn down.
+type resourceGroupDeletePlan struct {
+	// wholeGroup is true if the resource group is deleted with a single call,
+	// instead of deleting each of its resources first.
+	wholeGroup bool
+	// owned are the resources deleted together with the resource group.
+	owned []*resources.Resource
+	// exclusions are the IDs of the resources in the resource group that are not
+	// owned by the cluster, and must be moved to another resource group before
+	// the resource group is deleted.
+	exclusions []string
+}
+
+// planResourceGroupDelete decides whether the resource group can be deleted with
+// a single call. This is only the case if the resource group is owned by the cluster
+// and all but a few of the resources in it are owned by the cluster too.
+//
+// The discovered resources only include the ones tagged with the cluster name, so
+// rgResourceIDs must list every resource in the resource group: any of them that
+// is not an owned resource is moved out of the way, whether it is shared with the
+// cluster or not related to it at all.
+func planResourceGroupDelete(rgName string, resourceMap map[string]*resources.Resource, armIDs map[*resources.Resource]string, rgResourceIDs []string) *resourceGroupDeletePlan {
+	plan := &resourceGroupDeletePlan{}
+	rg, ok := resourceMap[toKey(typeResourceGroup, rgName)]
+	if !ok || rg.Shared {
+		return plan
+	}
+
+	ownedIDs := set.New[string]()
+	for _, r := range sortResources(resourceMap) {
+		if r == rg || r.Shared {
+			continue
+		}
+		plan.owned = append(plan.owned, r)
+		if id := armIDs[r]; id != "" {
+			ownedIDs.Insert(strings.ToLower(id))
+		}
+	}
+
+	for _, id := range rgResourceIDs {
+		if !ownedIDs.Has(strings.ToLower(id)) {
+			plan.exclusions = append(plan.exclusions, id)
+		}
+	}
+	sort.Strings(plan.exclusions)
+
+	if len(plan.owned) == 0 || len(plan.exclusions) > maxResourceGroupDeleteExclusions || len(plan.exclusions) >= len(plan.owned) {
+		return plan
+	}
+	plan.wholeGroup = true
+	return plan
+}
+
+// applyResourceGroupDeletePlan switches the teardown to a single resource group
+// deletion when the plan allows it. The owned resources are marked as done, as
+// they go away with the resource group, and the resource group deleter moves
+// the other resources out of the way first.
+//
+// The AzureResourceGroupDeleteMode of the cluster can force either way.
+func (g *resourceGetter) applyResourceGroupDeletePlan(ctx context.Context, resourceMap map[string]*resources.Resource) error {
+	mode := g.clusterInfo.AzureResourceGroupDeleteMode
+	switch mode {
+	case "", resources.AzureResourceGroupDeleteAuto:
+		if g.clusterInfo.AzureResourceGroupMoveTarget == "" {
+			return nil
+		}
+	case resources.AzureResourceGroupDeleteChildren:
+		return nil
+	case resources.AzureResourceGroupDeleteWholeGroup:
+	default:
+		return fmt.Errorf("unknown resource group delete mode %q", mode)
+	}
+	rgKey := toKey(typeResourceGroup, g.resourceGroupName())
+	if rg, ok := resourceMap[rgKey]; !ok || rg.Shared {
+		if mode == resources.AzureResourceGroupDeleteWholeGroup {
+			klog.Warningf("Resource group %q is not owned by the cluster; deleting resources one by one", g.resourceGroupName())
+		}
+		return nil
+	}
+
+	rgResources, err := retryListOnThrottle(ctx, g.callTimeout(), func(ctx context.Context) ([]*azureresources.GenericResourceExpanded, error) {
+		return g.cloud.Resource().ListByResourceGroup(ctx, g.resourceGroupName())
+	})
+	if err != nil {
+		return err
+	}
+	var rgResourceIDs []string
+	for _, r := range rgResources {
+		if r.ID == nil {
+			return fmt.Errorf("found resource without ID in resource group %q", g.resourceGroupName())
+		}
+		rgResourceIDs = append(rgResourceIDs, *r.ID)
+	}
+
+	plan := planResourceGroupDelete(g.resourceGroupName(), resourceMap, g.armIDs, rgResourceIDs)
+	if mode == resources.AzureResourceGroupDeleteWholeGroup {
+		if len(plan.exclusions) > 0 && g.clusterInfo.AzureResourceGroupMoveTarget == "" {
+			return fmt.Errorf("resource group %q holds %d resources not owned by the cluster; a move target is required to delete it as a whole", g.resourceGroupName(), len(plan.exclusions))
+		}
+	} else if !plan.wholeGroup {
+		klog.V(2).Infof("Resource group %q holds %d resources not owned by the cluster; deleting resources one by one", g.resourceGroupName(), len(plan.exclusions))
+		return nil
+	}
+
+	for _, r := range plan.owned {
+		r.Done = true
+	}
+	exclusionIDs := plan.exclusions
+	rg := resourceMap[rgKey]
+	rg.Deleter = func(cloud fi.Cloud, r *resources.Resource) error {
+		if len(exclusionIDs) > 0 {
+			target := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", g.cloud.SubscriptionID(), g.clusterInfo.AzureResourceGroupMoveTarget)
+			if err := retryOnThrottle(g.context(), g.callTimeout(), func(ctx context.Context) error {
+				return g.cloud.Resource().MoveResources(ctx, r.Name, exclusionIDs, target)
+			}); err != nil {
+				return err
+			}
+		}
+		return g.deleteResourceGroup(cloud, r)
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestPlanResourceGroupDelete(t *testing.T) {
+	const rgName = "rg"
+	type rgContents struct {
+		rgShared bool
+		owned    int
+		shared   int
+		// foreign is the number of resources in the resource group that are
+		// not tagged with the cluster name, and so never discovered.
+		foreign int
+	}
+	newResourceMap := func(c rgContents) (map[string]*resources.Resource, map[*resources.Resource]string, []string) {
+		armIDs := map[*resources.Resource]string{}
+		var rgResourceIDs []string
+		rs := map[string]*resources.Resource{
+			toKey(typeResourceGroup, rgName): {
+				Type:   typeResourceGroup,
+				ID:     rgName,
+				Name:   rgName,
+				Shared: c.rgShared,
+			},
+		}
+		add := func(name string, shared bool) {
+			r := &resources.Resource{Type: typeDisk, ID: name, Name: name, Shared: shared}
+			rs[toKey(typeDisk, name)] = r
+			armIDs[r] = "/" + name
+			rgResourceIDs = append(rgResourceIDs, "/"+name)
+		}
+		for i := 0; i < c.owned; i++ {
+			add(fmt.Sprintf("owned-%d", i), false)
+		}
+		for i := 0; i < c.shared; i++ {
+			add(fmt.Sprintf("shared-%d", i), true)
+		}
+		for i := 0; i < c.foreign; i++ {
+			rgResourceIDs = append(rgResourceIDs, fmt.Sprintf("/foreign-%d", i))
+		}
+		return rs, armIDs, rgResourceIDs
+	}
+
+	testCases := []struct {
+		name               string
+		contents           rgContents
+		expectedWholeGroup bool
+		expectedExclusions []string
+	}{
+		{
+			name:               "mostly owned",
+			contents:           rgContents{owned: 8, shared: 2},
+			expectedWholeGroup: true,
+			expectedExclusions: []string{"/shared-0", "/shared-1"},
+		},
+		{
+			name:               "mostly owned with untagged resources",
+			contents:           rgContents{owned: 8, shared: 1, foreign: 2},
+			expectedWholeGroup: true,
+			expectedExclusions: []string{"/foreign-0", "/foreign-1", "/shared-0"},
+		},
+		{
+			name:               "fully owned",
+			contents:           rgContents{owned: 3},
+			expectedWholeGroup: true,
+		},
+		{
+			name:               "shared resource group",
+			contents:           rgContents{rgShared: true, owned: 8, shared: 2},
+			expectedWholeGroup: false,
+		},
+		{
+			name:               "mostly shared",
+			contents:           rgContents{owned: 2, shared: 3},
+			expectedWholeGroup: false,
+			expectedExclusions: []string{"/shared-0", "/shared-1", "/shared-2"},
+		},
+		{
+			name:               "mostly untagged",
+			contents:           rgContents{owned: 2, foreign: 2},
+			expectedWholeGroup: false,
+			expectedExclusions: []string{"/foreign-0", "/foreign-1"},
+		},
+		{
+			name:               "too many shared",
+			contents:           rgContents{owned: 20, shared: 3, foreign: maxResourceGroupDeleteExclusions - 2},
+			expectedWholeGroup: false,
+			expectedExclusions: []string{"/foreign-0", "/foreign-1", "/foreign-2", "/shared-0", "/shared-1", "/shared-2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resourceMap, armIDs, rgResourceIDs := newResourceMap(tc.contents)
+			plan := planResourceGroupDelete(rgName, resourceMap, armIDs, rgResourceIDs)
+			if plan.wholeGroup != tc.expectedWholeGroup {
+				t.Errorf("expected wholeGroup %t, but got %t", tc.expectedWholeGroup, plan.wholeGroup)
+			}
+			if !reflect.DeepEqual(plan.exclusions, tc.expectedExclusions) {
+				t.Errorf("expected exclusions %v, but got %v", tc.expectedExclusions, plan.exclusions)
+			}
+		})
+	}
+}
+
+func TestApplyResourceGroupDeletePlan(t *testing.T) {
+	const (
+		rgName     = "rg"
+		targetName = "shared-rg"
+	)
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	g := &resourceGetter{
+		cloud: cloud,
+		clusterInfo: resources.ClusterInfo{
+			AzureResourceGroupName:       rgName,
+			AzureResourceGroupMoveTarget: targetName,
+		},
+	}
+	rg := g.toResourceGroupResource(&armresources.ResourceGroup{Name: to.Ptr(rgName), ID: to.Ptr("/rg")})
+	owned := g.toDiskResource(&compute.Disk{Name: to.Ptr("owned"), ID: to.Ptr("/owned")}, nil)
+	owned2 := g.toDiskResource(&compute.Disk{Name: to.Ptr("owned2"), ID: to.Ptr("/owned2")}, nil)
+	owned3 := g.toDiskResource(&compute.Disk{Name: to.Ptr("owned3"), ID: to.Ptr("/owned3")}, nil)
+	shared := g.toDiskResource(&compute.Disk{Name: to.Ptr("shared"), ID: to.Ptr("/shared")}, nil)
+	shared.Shared = true
+	resourceMap := map[string]*resources.Resource{
+		toKey(rg.Type, rg.ID):         rg,
+		toKey(owned.Type, owned.ID):   owned,
+		toKey(owned2.Type, owned2.ID): owned2,
+		toKey(owned3.Type, owned3.ID): owned3,
+		toKey(shared.Type, shared.ID): shared,
+	}
+	cloud.ResourceGroupsClient.RGs[rgName] = rg.Obj.(*armresources.ResourceGroup)
+	// The untagged resource is never discovered by the listers, but must survive the deletion.
+	for _, id := range []string{"/owned", "/owned2", "/owned3", "/shared", "/untagged"} {
+		cloud.ResourcesClient.Resources[id] = &armresources.GenericResourceExpanded{ID: to.Ptr(id)}
+	}
+
+	if err := g.applyResourceGroupDeletePlan(context.Background(), resourceMap); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !owned.Done || !owned2.Done || !owned3.Done {
+		t.Errorf("expected the owned disks to be marked as done")
+	}
+	if shared.Done {
+		t.Errorf("expected the shared disk not to be marked as done")
+	}
+
+	if err := rg.Deleter(cloud, rg); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	target := "/subscriptions//resourceGroups/" + targetName
+	expectedMoved := map[string]string{"/shared": target, "/untagged": target}
+	if !reflect.DeepEqual(cloud.ResourcesClient.Moved, expectedMoved) {
+		t.Errorf("expected moved resources %v, but got %v", expectedMoved, cloud.ResourcesClient.Moved)
+	}
+	if _, ok := cloud.ResourceGroupsClient.RGs[rgName]; ok {
+		t.Errorf("expected the resource group to be deleted")
+	}
+}
+
+// recordingDeleteCloud records the deletions of disks and resource groups, and
+// the tag updates, in order.
+type recordingDeleteCloud struct {
+	*azuretasks.MockAzureCloud
+	calls []string
+}
+
+func (c *recordingDeleteCloud) Resource() azure.ResourcesClient {
+	return &recordingDeleteResourcesClient{ResourcesClient: c.MockAzureCloud.Resource(), cloud: c}
+}
+
+func (c *recordingDeleteCloud) Disk() azure.DisksClient {
+	return &recordingDeleteDisksClient{DisksClient: c.MockAzureCloud.Disk(), cloud: c}
+}
+
+func (c *recordingDeleteCloud) ResourceGroup() azure.ResourceGroupsClient {
+	return &recordingDeleteResourceGroupsClient{ResourceGroupsClient: c.MockAzureCloud.ResourceGroup(), cloud: c}
+}
+
+type recordingDeleteDisksClient struct {
+	azure.DisksClient
+	cloud *recordingDeleteCloud
+}
+
+func (c *recordingDeleteDisksClient) Delete(ctx context.Context, resourceGroupName, diskName string) error {
+	c.cloud.calls = append(c.cloud.calls, toKey(typeDisk, diskName))
+	return c.DisksClient.Delete(ctx, resourceGroupName, diskName)
+}
+
+type recordingDeleteResourceGroupsClient struct {
+	azure.ResourceGroupsClient
+	cloud *recordingDeleteCloud
+}
+
+func (c *recordingDeleteResourceGroupsClient) Delete(ctx context.Context, name string) error {
+	c.cloud.calls = append(c.cloud.calls, toKey(typeResourceGroup, name))
+	return c.ResourceGroupsClient.Delete(ctx, name)
+}
+
+type recordingDeleteResourcesClient struct {
+	azure.ResourcesClient
+	cloud *recordingDeleteCloud
+}
+
+func (c *recordingDeleteResourcesClient) UpdateTags(ctx context.Context, resourceID string, tags map[string]*string) error {
+	c.cloud.calls = append(c.cloud.calls, "tags:"+resourceID)
+	return c.ResourcesClient.UpdateTags(ctx, resourceID, tags)
+}
+
+func TestResourceGroupDeleteMode(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+		targetName  = "shared-rg"
+	)
+	testCases := []struct {
+		name       string
+		mode       resources.AzureResourceGroupDeleteMode
+		moveTarget string
+		// foreign adds a resource not tagged with the cluster to the resource group.
+		foreign       bool
+		expected      []string
+		expectedError string
+	}{
+		{
+			name:     "auto without move target",
+			expected: []string{"Disk:disk-1", "Disk:disk-2", "ResourceGroup:rg"},
+		},
+		{
+			name:       "auto with move target",
+			mode:       resources.AzureResourceGroupDeleteAuto,
+			moveTarget: targetName,
+			foreign:    true,
+			expected:   []string{"ResourceGroup:rg"},
+		},
+		{
+			name:       "children",
+			mode:       resources.AzureResourceGroupDeleteChildren,
+			moveTarget: targetName,
+			foreign:    true,
+			expected:   []string{"Disk:disk-1", "Disk:disk-2", "ResourceGroup:rg"},
+		},
+		{
+			name:     "whole group",
+			mode:     resources.AzureResourceGroupDeleteWholeGroup,
+			expected: []string{"ResourceGroup:rg"},
+		},
+		{
+			name:          "whole group with foreign resources and no move target",
+			mode:          resources.AzureResourceGroupDeleteWholeGroup,
+			foreign:       true,
+			expectedError: `resource group "rg" holds 1 resources not owned by the cluster; a move target is required to delete it as a whole`,
+		},
+		{
+			name:          "unknown",
+			mode:          "everything",
+			expectedError: `unknown resource group delete mode "everything"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clusterTags := map[string]*string{
+				azure.TagClusterName: to.Ptr(clusterName),
+			}
+			mock := azuretasks.NewMockAzureCloud("eastus")
+			mock.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+				Name: to.Ptr(rgName),
+				ID:   to.Ptr("/subscriptions/sid/resourceGroups/" + rgName),
+				Tags: clusterTags,
+			}
+			for _, name := range []string{"disk-1", "disk-2"} {
+				id := fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Compute/disks/%s", rgName, name)
+				mock.DisksClient.Disks[name] = &compute.Disk{
+					Name: to.Ptr(name),
+					ID:   to.Ptr(id),
+					Tags: clusterTags,
+				}
+				mock.ResourcesClient.Resources[id] = &armresources.GenericResourceExpanded{ID: to.Ptr(id)}
+			}
+			if tc.foreign {
+				mock.ResourcesClient.Resources["/foreign"] = &armresources.GenericResourceExpanded{ID: to.Ptr("/foreign")}
+			}
+			cloud := &recordingDeleteCloud{MockAzureCloud: mock}
+
+			rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+				Name:                         clusterName,
+				AzureResourceGroupName:       rgName,
+				AzureResourceGroupMoveTarget: tc.moveTarget,
+				AzureResourceGroupDeleteMode: tc.mode,
+			})
+			if tc.expectedError != "" {
+				if err == nil || err.Error() != tc.expectedError {
+					t.Fatalf("expected error %q, but got %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+
+			order, err := deletionOrder(rs)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			for _, k := range order {
+				r := rs[k]
+				if r.Done {
+					continue
+				}
+				if err := r.Deleter(cloud, r); err != nil {
+					t.Fatalf("unexpected error deleting %q: %s", k, err)
+				}
+			}
+			if !reflect.DeepEqual(cloud.calls, tc.expected) {
+				t.Errorf("expected deletions %v, but got %v", tc.expected, cloud.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// SelectAzureResourcesOfType lists the resources of the cluster and returns
+// them with all the resources not of the given type marked as done, so that
+// only the resources of that type are deleted. It is an error if a resource of
+// that type must be deleted after a resource of another type, which is kept.
+func SelectAzureResourcesOfType(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo, rtype string) (map[string]*resources.Resource, error) {
+	resourceMap, err := ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := dependencies(resourceMap)
+	var blocked []string
+	for k, r := range resourceMap {
+		if r.Type != rtype || r.Shared {
+			continue
+		}
+		for _, dep := range deps[k] {
+			if d, ok := resourceMap[dep]; ok && d.Type != rtype && !d.Shared {
+				blocked = append(blocked, fmt.Sprintf("%s (depends on %s)", k, dep))
+			}
+		}
+	}
+	if len(blocked) > 0 {
+		sort.Strings(blocked)
+		return nil, fmt.Errorf("cannot delete only the resources of type %s, as resources of other types depend on them: %v", rtype, blocked)
+	}
+
+	selectedMap := make(map[string]*resources.Resource)
+	for k, r := range resourceMap {
+		if r.Shared {
+			continue
+		}
+		// The other resources, including the resource group, are kept, so the
+		// selected resources must be deleted on their own.
+		r.Done = r.Type != rtype
+		selectedMap[k] = r
+	}
+	return selectedMap, nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"sort"
+
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	azureresources "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// MissingTags is a resource of the cluster that is not tagged with it.
+type MissingTags struct {
+	Resource *resources.Resource
+	// Tags are the tags to apply to the resource.
+	Tags map[string]string
+}
+
+// FindAzureResourcesMissingTags lists the resources of the cluster like ListResourcesAzure,
+// and returns the ones that were only found to belong to the cluster by their relation to
+// other resources, e.g. the disks attached to its VM Scale Sets, together with the tags to
+// apply to them so that they are found by their tags in the future. Resources that cannot
+// be tagged, like subnets and role assignments, and shared resources are skipped. Nothing
+// is modified.
+func FindAzureResourcesMissingTags(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo) ([]MissingTags, error) {
+	// The tags are read from the Azure objects.
+	clusterInfo.AzureOmitRawObjects = false
+	g := resourceGetter{
+		cloud:       cloud,
+		clusterInfo: clusterInfo,
+	}
+	resourceMap, err := g.listResourcesAzure()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []MissingTags
+	for _, r := range resourceMap {
+		if r.Shared {
+			continue
+		}
+		tags, ok := resourceTags(r.Obj)
+		if !ok || g.isOwnedByCluster(tags) {
+			continue
+		}
+		missing = append(missing, MissingTags{
+			Resource: r,
+			Tags: map[string]string{
+				azure.TagClusterName: clusterInfo.Name,
+			},
+		})
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		a, b := missing[i].Resource, missing[j].Resource
+		return toKey(a.Type, a.ID) < toKey(b.Type, b.ID)
+	})
+	return missing, nil
+}
+
+// flattenTags returns the tags of an Azure resource without pointers, or nil if
+// it has none.
+func flattenTags(tags map[string]*string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	flattened := make(map[string]string, len(tags))
+	for k, v := range tags {
+		flattened[k] = fi.ValueOf(v)
+	}
+	return flattened
+}
+
+// resourceTags returns the tags of an Azure resource, and false if it cannot be tagged.
+func resourceTags(obj interface{}) (map[string]*string, bool) {
+	switch obj := obj.(type) {
+	case *azureresources.ResourceGroup:
+		return obj.Tags, true
+	case *azureresources.GenericResourceExpanded:
+		return obj.Tags, true
+	case *network.VirtualNetwork:
+		return obj.Tags, true
+	case *network.SecurityGroup:
+		return obj.Tags, true
+	case *network.ApplicationSecurityGroup:
+		return obj.Tags, true
+	case *network.RouteTable:
+		return obj.Tags, true
+	case *network.LoadBalancer:
+		return obj.Tags, true
+	case *network.PublicIPAddress:
+		return obj.Tags, true
+	case *network.NatGateway:
+		return obj.Tags, true
+	case *network.PrivateEndpoint:
+		return obj.Tags, true
+	case *network.Interface:
+		return obj.Tags, true
+	case *network.ApplicationGateway:
+		return obj.Tags, true
+	case *azure.PrivateDNSZone:
+		return obj.Tags, true
+	case *compute.VirtualMachineScaleSet:
+		return obj.Tags, true
+	case *compute.VirtualMachine:
+		return obj.Tags, true
+	case *compute.Disk:
+		return obj.Tags, true
+	case *compute.DiskEncryptionSet:
+		return obj.Tags, true
+	case *compute.Snapshot:
+		return obj.Tags, true
+	case *armstorage.Account:
+		return obj.Tags, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestFindAzureResourcesMissingTags(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: clusterTags,
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+	}
+	cloud.DisksClient.Disks["tagged"] = &compute.Disk{
+		Name: to.Ptr("tagged"),
+		Tags: clusterTags,
+	}
+	// Attached to a cluster VM Scale Set, but not tagged.
+	cloud.DisksClient.Disks["untagged"] = &compute.Disk{
+		Name:      to.Ptr("untagged"),
+		ManagedBy: to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Compute/virtualMachineScaleSets/nodes/virtualMachines/0", rgName)),
+	}
+
+	missing, err := FindAzureResourcesMissingTags(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(missing) != 1 {
+		t.Fatalf("expected 1 resource missing tags, but got %d", len(missing))
+	}
+	if key := toKey(missing[0].Resource.Type, missing[0].Resource.ID); key != toKey(typeDisk, "untagged") {
+		t.Errorf("expected the untagged disk, but got %s", key)
+	}
+	expected := map[string]string{
+		azure.TagClusterName: clusterName,
+	}
+	if !reflect.DeepEqual(missing[0].Tags, expected) {
+		t.Errorf("expected %v, but got %v", expected, missing[0].Tags)
+	}
+}
+
+func TestResourceGroupResourceTags(t *testing.T) {
+	g := &resourceGetter{
+		clusterInfo: resources.ClusterInfo{
+			Name:                   "cluster",
+			AzureResourceGroupName: "rg",
+		},
+	}
+	r := g.toResourceGroupResource(&armresources.ResourceGroup{
+		Name: to.Ptr("rg"),
+		Tags: map[string]*string{
+			azure.TagClusterName: to.Ptr("cluster"),
+			"empty":              nil,
+		},
+	})
+	expected := map[string]string{
+		azure.TagClusterName: "cluster",
+		"empty":              "",
+	}
+	if !reflect.DeepEqual(r.Tags, expected) {
+		t.Errorf("expected %v, but got %v", expected, r.Tags)
+	}
+
+	if r := g.toResourceGroupResource(&armresources.ResourceGroup{Name: to.Ptr("untagged")}); r.Tags != nil {
+		t.Errorf("expected no tags, but got %v", r.Tags)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"k8s.io/klog/v2"
+)
+
+// The retries of the calls throttled by Azure Resource Manager. They are
+// variables so that tests can override them.
+var (
+	// throttleRetries is the number of times a throttled call is retried.
+	throttleRetries = 5
+	// throttleBaseDelay is the delay before the first retry, doubled for each
+	// following retry, unless Azure tells how long to wait with Retry-After.
+	throttleBaseDelay = 2 * time.Second
+	// throttleMaxDelay caps the delay between two retries.
+	throttleMaxDelay = time.Minute
+	// throttleSleep waits before retrying, or until the context is done.
+	throttleSleep = func(ctx context.Context, d time.Duration) error {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		}
+	}
+)
+
+// retryOnThrottle calls call, retrying it with a capped exponential backoff
+// while Azure Resource Manager throttles it with 429 Too Many Requests. The
+// Retry-After header of the response is honored when present. Each attempt is
+// given a context that times out after timeout, unless it is zero.
+func retryOnThrottle(ctx context.Context, timeout time.Duration, call func(ctx context.Context) error) error {
+	delay := throttleBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := callWithTimeout(ctx, timeout, call)
+		retryAfter, throttled := throttledRetryAfter(err)
+		if !throttled || attempt >= throttleRetries {
+			return err
+		}
+
+		wait := min(delay, throttleMaxDelay)
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		klog.V(2).Infof("Azure call throttled, retrying in %v: %v", wait, err)
+		if err := throttleSleep(ctx, wait); err != nil {
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// callWithTimeout calls call with a context that times out after timeout,
+// unless it is zero.
+func callWithTimeout(ctx context.Context, timeout time.Duration, call func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return call(ctx)
+}
+
+// retryListOnThrottle is retryOnThrottle for the calls listing Azure objects.
+func retryListOnThrottle[T any](ctx context.Context, timeout time.Duration, list func(ctx context.Context) ([]T, error)) ([]T, error) {
+	var objs []T
+	err := retryOnThrottle(ctx, timeout, func(ctx context.Context) error {
+		var err error
+		objs, err = list(ctx)
+		return err
+	})
+	return objs, err
+}
+
+// throttledRetryAfter returns whether err is a 429 Too Many Requests response,
+// and the delay asked for by its Retry-After header, or zero if it has none.
+func throttledRetryAfter(err error) (time.Duration, bool) {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if respErr.RawResponse == nil {
+		return 0, true
+	}
+	retryAfter := respErr.RawResponse.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0, true
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(retryAfter); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d, true
+		}
+	}
+	return 0, true
+}
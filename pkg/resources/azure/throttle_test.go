@@ -0,0 +1,170 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+// throttlingCloud is a mock cloud whose virtual networks client is throttled:
+// it fails with 429 Too Many Requests for the given number of calls first.
+type throttlingCloud struct {
+	*azuretasks.MockAzureCloud
+	throttled  int
+	retryAfter string
+	calls      int
+}
+
+func (c *throttlingCloud) VirtualNetwork() azure.VirtualNetworksClient {
+	return &throttlingVirtualNetworksClient{VirtualNetworksClient: c.MockAzureCloud.VirtualNetwork(), cloud: c}
+}
+
+type throttlingVirtualNetworksClient struct {
+	azure.VirtualNetworksClient
+	cloud *throttlingCloud
+}
+
+func (c *throttlingVirtualNetworksClient) List(ctx context.Context, resourceGroupName string) ([]*network.VirtualNetwork, error) {
+	c.cloud.calls++
+	if c.cloud.calls <= c.cloud.throttled {
+		header := http.Header{}
+		if c.cloud.retryAfter != "" {
+			header.Set("Retry-After", c.cloud.retryAfter)
+		}
+		return nil, &azcore.ResponseError{
+			StatusCode:  http.StatusTooManyRequests,
+			RawResponse: &http.Response{StatusCode: http.StatusTooManyRequests, Header: header},
+		}
+	}
+	return c.VirtualNetworksClient.List(ctx, resourceGroupName)
+}
+
+// recordThrottleDelays makes the retries of throttled calls record their
+// delays instead of sleeping, until the test ends.
+func recordThrottleDelays(t *testing.T) *[]time.Duration {
+	retries, baseDelay, maxDelay, sleep := throttleRetries, throttleBaseDelay, throttleMaxDelay, throttleSleep
+	t.Cleanup(func() {
+		throttleRetries, throttleBaseDelay, throttleMaxDelay, throttleSleep = retries, baseDelay, maxDelay, sleep
+	})
+
+	var delays []time.Duration
+	throttleRetries = 3
+	throttleBaseDelay = time.Second
+	throttleMaxDelay = 10 * time.Second
+	throttleSleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return &delays
+}
+
+func TestListResourcesAzureRetriesOnThrottle(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+
+	grid := []struct {
+		name       string
+		retryAfter string
+		expected   []time.Duration
+	}{
+		{
+			name:     "exponential backoff",
+			expected: []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:       "retry after",
+			retryAfter: "30",
+			expected:   []time.Duration{30 * time.Second, 30 * time.Second},
+		},
+	}
+	for _, g := range grid {
+		t.Run(g.name, func(t *testing.T) {
+			delays := recordThrottleDelays(t)
+
+			mock := azuretasks.NewMockAzureCloud("eastus")
+			mock.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+				Name: to.Ptr("vnet"),
+				Tags: map[string]*string{
+					azure.TagClusterName: to.Ptr(clusterName),
+				},
+			}
+			cloud := &throttlingCloud{MockAzureCloud: mock, throttled: 2, retryAfter: g.retryAfter}
+
+			rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+				Name:                   clusterName,
+				AzureResourceGroupName: rgName,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if _, ok := rs[toKey(typeVirtualNetwork, "vnet")]; !ok {
+				t.Errorf("expected the virtual network to be listed")
+			}
+			if cloud.calls != 3 {
+				t.Errorf("expected 3 calls, but got %d", cloud.calls)
+			}
+			if !reflect.DeepEqual(*delays, g.expected) {
+				t.Errorf("expected delays %v, but got %v", g.expected, *delays)
+			}
+		})
+	}
+}
+
+func TestRetryOnThrottleGivesUp(t *testing.T) {
+	delays := recordThrottleDelays(t)
+
+	throttled := &azcore.ResponseError{StatusCode: http.StatusTooManyRequests}
+	calls := 0
+	err := retryOnThrottle(context.Background(), 0, func(context.Context) error {
+		calls++
+		return throttled
+	})
+	if !errors.Is(err, throttled) {
+		t.Errorf("expected the throttling error, but got %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("expected 4 calls, but got %d", calls)
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	if !reflect.DeepEqual(*delays, expected) {
+		t.Errorf("expected delays %v, but got %v", expected, *delays)
+	}
+
+	// Other errors are not retried.
+	calls = 0
+	notFound := &azcore.ResponseError{StatusCode: http.StatusNotFound}
+	if err := retryOnThrottle(context.Background(), 0, func(context.Context) error {
+		calls++
+		return notFound
+	}); !errors.Is(err, notFound) || calls != 1 {
+		t.Errorf("expected a single failed call, but got %d calls and %v", calls, err)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"sort"
+
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// ValidateAzureTopology checks that the resources listed by ListResourcesAzure form
+// the network topology of a cluster: every subnet is in a listed virtual network,
+// every VM Scale Set is in listed subnets and every load balancer uses listed public
+// IP addresses. It returns the inconsistencies found, sorted, which typically point
+// at a cluster that was partially deleted or modified outside of kops.
+func ValidateAzureTopology(resourceMap map[string]*resources.Resource) []string {
+	var inconsistencies []string
+	missing := func(r *resources.Resource, rtype, name string) {
+		if _, ok := resourceMap[toKey(rtype, name)]; !ok {
+			inconsistencies = append(inconsistencies, fmt.Sprintf("%s references %s %q, which was not found", toKey(r.Type, r.ID), rtype, name))
+		}
+	}
+	invalid := func(r *resources.Resource, err error) {
+		inconsistencies = append(inconsistencies, fmt.Sprintf("%s: %v", toKey(r.Type, r.ID), err))
+	}
+
+	for _, r := range resourceMap {
+		switch obj := r.Obj.(type) {
+		case *network.Subnet:
+			if obj.ID == nil {
+				continue
+			}
+			subnetID, err := azure.ParseSubnetID(*obj.ID)
+			if err != nil {
+				invalid(r, err)
+				continue
+			}
+			missing(r, typeVirtualNetwork, subnetID.VirtualNetworkName)
+		case *compute.VirtualMachineScaleSet:
+			if obj.Properties == nil || obj.Properties.VirtualMachineProfile == nil || obj.Properties.VirtualMachineProfile.NetworkProfile == nil {
+				continue
+			}
+			for _, iface := range obj.Properties.VirtualMachineProfile.NetworkProfile.NetworkInterfaceConfigurations {
+				if iface.Properties == nil {
+					continue
+				}
+				for _, ip := range iface.Properties.IPConfigurations {
+					if ip.Properties == nil || ip.Properties.Subnet == nil || ip.Properties.Subnet.ID == nil {
+						continue
+					}
+					subnetID, err := azure.ParseSubnetID(*ip.Properties.Subnet.ID)
+					if err != nil {
+						invalid(r, err)
+						continue
+					}
+					missing(r, typeSubnet, subnetID.SubnetName)
+				}
+			}
+		case *network.LoadBalancer:
+			if obj.Properties == nil {
+				continue
+			}
+			for _, fip := range obj.Properties.FrontendIPConfigurations {
+				if fip.Properties == nil || fip.Properties.PublicIPAddress == nil || fip.Properties.PublicIPAddress.ID == nil {
+					continue
+				}
+				pipID, err := azure.ParsePublicIPAddressID(*fip.Properties.PublicIPAddress.ID)
+				if err != nil {
+					invalid(r, err)
+					continue
+				}
+				missing(r, typePublicIPAddress, pipID.PublicIPAddressName)
+			}
+		}
+	}
+
+	sort.Strings(inconsistencies)
+	return inconsistencies
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestValidateAzureTopology(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	subnetID := func(name string) string {
+		id := azure.SubnetID{
+			SubscriptionID:     "sid",
+			ResourceGroupName:  rgName,
+			VirtualNetworkName: "vnet",
+			SubnetName:         name,
+		}
+		return id.String()
+	}
+	newVMSS := func(name, subnetName string) *compute.VirtualMachineScaleSet {
+		return &compute.VirtualMachineScaleSet{
+			Name: to.Ptr(name),
+			Tags: clusterTags,
+			Properties: &compute.VirtualMachineScaleSetProperties{
+				VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+					NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
+						NetworkInterfaceConfigurations: []*compute.VirtualMachineScaleSetNetworkConfiguration{
+							{
+								Properties: &compute.VirtualMachineScaleSetNetworkConfigurationProperties{
+									IPConfigurations: []*compute.VirtualMachineScaleSetIPConfiguration{
+										{
+											Properties: &compute.VirtualMachineScaleSetIPConfigurationProperties{
+												Subnet: &compute.APIEntityReference{
+													ID: to.Ptr(subnetID(subnetName)),
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+		Name: to.Ptr("vnet"),
+		Tags: clusterTags,
+	}
+	cloud.SubnetsClient.Subnets["sub"] = &network.Subnet{
+		ID:   to.Ptr(subnetID("sub")),
+		Name: to.Ptr("sub"),
+	}
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = newVMSS("nodes", "sub")
+
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+	rs, err := ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if inconsistencies := ValidateAzureTopology(rs); len(inconsistencies) != 0 {
+		t.Errorf("expected no inconsistencies, but got %v", inconsistencies)
+	}
+
+	// A VM Scale Set in a subnet that was deleted outside of kops.
+	cloud.VMScaleSetsClient.VMSSes["orphan"] = newVMSS("orphan", "deleted")
+	rs, err = ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	expected := []string{
+		`VMScaleSet:orphan references Subnet "deleted", which was not found`,
+	}
+	if inconsistencies := ValidateAzureTopology(rs); !reflect.DeepEqual(inconsistencies, expected) {
+		t.Errorf("expected %v, but got %v", expected, inconsistencies)
+	}
+}
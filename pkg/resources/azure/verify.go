@@ -0,0 +1,165 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// AzureDeleteVerifier checks that a deleted resource is gone. It returns an
+// error while the resource is still present.
+type AzureDeleteVerifier func(ctx context.Context, cloud azure.AzureCloud, resourceGroupName, name string) error
+
+var (
+	deleteVerifiersMutex sync.Mutex
+	deleteVerifiers      = map[string]AzureDeleteVerifier{
+		typeDisk: verifyDiskDeleted,
+	}
+)
+
+// RegisterAzureDeleteVerifier registers a function checking that the resources
+// of the given type are gone after being deleted, when AzureStrictVerify is set.
+// It panics if a verifier for the type is already registered.
+func RegisterAzureDeleteVerifier(resourceType string, fn AzureDeleteVerifier) {
+	deleteVerifiersMutex.Lock()
+	defer deleteVerifiersMutex.Unlock()
+
+	if fn == nil {
+		panic(fmt.Sprintf("azure delete verifier for %q is nil", resourceType))
+	}
+	if _, found := deleteVerifiers[resourceType]; found {
+		panic(fmt.Sprintf("azure delete verifier for %q is already registered", resourceType))
+	}
+	deleteVerifiers[resourceType] = fn
+}
+
+func verifyDiskDeleted(ctx context.Context, cloud azure.AzureCloud, resourceGroupName, name string) error {
+	disk, err := cloud.Disk().Get(ctx, resourceGroupName, name)
+	if err != nil {
+		return err
+	}
+	if disk != nil {
+		return fmt.Errorf("disk %q still exists", name)
+	}
+	return nil
+}
+
+// verifyAfterDelete makes the deleters of the resources with a registered
+// verifier check that the resources are gone. Once a delete succeeded, later
+// attempts only check again, as the deletion may take a while to be effective.
+func (g *resourceGetter) verifyAfterDelete(resourceMap map[string]*resources.Resource) {
+	deleteVerifiersMutex.Lock()
+	defer deleteVerifiersMutex.Unlock()
+
+	for _, r := range resourceMap {
+		verify, ok := deleteVerifiers[r.Type]
+		if !ok || r.Shared || r.Done || r.Deleter == nil {
+			continue
+		}
+		deleter := r.Deleter
+		deleted := false
+		r.Deleter = func(cloud fi.Cloud, r *resources.Resource) error {
+			if !deleted {
+				if err := deleter(cloud, r); err != nil {
+					return err
+				}
+				deleted = true
+			}
+			if err := callWithTimeout(g.context(), g.callTimeout(), func(ctx context.Context) error {
+				return verify(ctx, g.cloud, g.resourceGroupOf(r), r.Name)
+			}); err != nil {
+				return fmt.Errorf("verifying deletion of %s %q: %w", r.Type, r.Name, err)
+			}
+			return nil
+		}
+	}
+}
+
+// armResourceTypes maps the types of the resources that live directly in a
+// resource group to their ARM type.
+var armResourceTypes = map[string]string{
+	typeVirtualNetwork:           "Microsoft.Network/virtualNetworks",
+	typeNetworkSecurityGroup:     "Microsoft.Network/networkSecurityGroups",
+	typeApplicationSecurityGroup: "Microsoft.Network/applicationSecurityGroups",
+	typeRouteTable:               "Microsoft.Network/routeTables",
+	typeVMScaleSet:               "Microsoft.Compute/virtualMachineScaleSets",
+	typeVirtualMachine:           "Microsoft.Compute/virtualMachines",
+	typeNetworkInterface:         "Microsoft.Network/networkInterfaces",
+	typeDisk:                     "Microsoft.Compute/disks",
+	typeDiskEncryptionSet:        "Microsoft.Compute/diskEncryptionSets",
+	typeLoadBalancer:             "Microsoft.Network/loadBalancers",
+	typePublicIPAddress:          "Microsoft.Network/publicIPAddresses",
+	typeNatGateway:               "Microsoft.Network/natGateways",
+	typeSnapshot:                 "Microsoft.Compute/snapshots",
+	typeStorageAccount:           "Microsoft.Storage/storageAccounts",
+	typePrivateEndpoint:          "Microsoft.Network/privateEndpoints",
+	typeKeyVault:                 keyVaultResourceType,
+	typeRecoveryServicesVault:    recoveryServicesVaultResourceType,
+	typeNetworkWatcher:           networkWatcherResourceType,
+	typeManagedIdentity:          "Microsoft.ManagedIdentity/userAssignedIdentities",
+	typePrivateDNSZone:           "Microsoft.Network/privateDnsZones",
+	typeApplicationGateway:       "Microsoft.Network/applicationGateways",
+}
+
+// RemainingAzureResources returns the deleted resources that are still listed
+// in the cluster resource group, or in its node resource group. It catches the
+// asynchronous deletions that Azure accepted but failed to carry out. Resources
+// that do not live directly in a resource group, like subnets and role
+// assignments, are not checked.
+func RemainingAzureResources(cloud azure.AzureCloud, clusterInfo resources.ClusterInfo, deleted []*resources.Resource) ([]*resources.Resource, error) {
+	ctx := context.TODO()
+	rgNames := []string{clusterInfo.AzureResourceGroupName}
+	if clusterInfo.AzureNodeResourceGroupName != "" {
+		rgNames = append(rgNames, clusterInfo.AzureNodeResourceGroupName)
+	}
+
+	present := make(map[string]bool)
+	for _, rgName := range rgNames {
+		rgResources, err := cloud.Resource().ListByResourceGroup(ctx, rgName)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rgResources {
+			if r.Type == nil || r.Name == nil {
+				continue
+			}
+			present[strings.ToLower(*r.Type+"/"+*r.Name)] = true
+		}
+	}
+
+	var remaining []*resources.Resource
+	for _, r := range deleted {
+		if r.Shared {
+			continue
+		}
+		armType, ok := armResourceTypes[r.Type]
+		if !ok {
+			continue
+		}
+		if present[strings.ToLower(armType+"/"+r.Name)] {
+			remaining = append(remaining, r)
+		}
+	}
+	return remaining, nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestRemainingAzureResources(t *testing.T) {
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourcesClient.Resources["/disk-stuck"] = &armresources.GenericResourceExpanded{
+		ID:   to.Ptr("/disk-stuck"),
+		Name: to.Ptr("disk-stuck"),
+		Type: to.Ptr("Microsoft.Compute/disks"),
+	}
+	// A resource of another type, with the name of a deleted disk.
+	cloud.ResourcesClient.Resources["/snapshot"] = &armresources.GenericResourceExpanded{
+		ID:   to.Ptr("/snapshot"),
+		Name: to.Ptr("disk-deleted"),
+		Type: to.Ptr("Microsoft.Compute/snapshots"),
+	}
+
+	stuck := &resources.Resource{Type: typeDisk, ID: "disk-stuck", Name: "disk-stuck"}
+	deleted := []*resources.Resource{
+		stuck,
+		{Type: typeDisk, ID: "disk-deleted", Name: "disk-deleted"},
+		{Type: typeSubnet, ID: "subnet", Name: "subnet"},
+	}
+	remaining, err := RemainingAzureResources(cloud, resources.ClusterInfo{AzureResourceGroupName: "rg"}, deleted)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if expected := []*resources.Resource{stuck}; !reflect.DeepEqual(remaining, expected) {
+		t.Errorf("expected %v, but got %v", expected, remaining)
+	}
+}
+
+// lingeringDisksCloud reports deleted disks as still present for a while, like
+// Azure does for deletions that take time to be effective.
+type lingeringDisksCloud struct {
+	*azuretasks.MockAzureCloud
+	disks *lingeringDisksClient
+}
+
+func (c *lingeringDisksCloud) Disk() azure.DisksClient {
+	return c.disks
+}
+
+type lingeringDisksClient struct {
+	*azuretasks.MockDisksClient
+	// lingering is the number of Get calls for which a deleted disk is still present.
+	lingering int
+	deletes   int
+	gets      int
+}
+
+func (c *lingeringDisksClient) Delete(ctx context.Context, resourceGroupName, diskName string) error {
+	c.deletes++
+	return c.MockDisksClient.Delete(ctx, resourceGroupName, diskName)
+}
+
+func (c *lingeringDisksClient) Get(ctx context.Context, resourceGroupName, diskName string) (*compute.Disk, error) {
+	c.gets++
+	if c.gets <= c.lingering {
+		return &compute.Disk{Name: to.Ptr(diskName)}, nil
+	}
+	return c.MockDisksClient.Get(ctx, resourceGroupName, diskName)
+}
+
+func TestStrictVerify(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	mock.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+	disks := &lingeringDisksClient{MockDisksClient: mock.DisksClient, lingering: 1}
+	cloud := &lingeringDisksCloud{MockAzureCloud: mock, disks: disks}
+
+	rs, err := ListResourcesAzure(cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+		AzureStrictVerify:      true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	disk := rs[toKey(typeDisk, "disk")]
+
+	// The disk is still present right after being deleted.
+	if err := disk.Deleter(cloud, disk); err == nil {
+		t.Fatalf("expected the verification to fail while the disk is present")
+	}
+	// It is gone when the deletion is retried, so it is not deleted again.
+	if err := disk.Deleter(cloud, disk); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if disks.deletes != 1 {
+		t.Errorf("expected the disk to be deleted once, but got %d deletes", disks.deletes)
+	}
+	if disks.gets != 2 {
+		t.Errorf("expected the disk to be checked twice, but got %d checks", disks.gets)
+	}
+
+	// The resource group has no verifier, so it is deleted without checks.
+	rg := rs[toKey(typeResourceGroup, rgName)]
+	if err := rg.Deleter(cloud, rg); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if disks.gets != 2 {
+		t.Errorf("expected no more checks, but got %d", disks.gets)
+	}
+}
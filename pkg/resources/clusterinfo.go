@@ -16,6 +16,49 @@ limitations under the License.
 
 package resources
 
+import (
+	"slices"
+	"time"
+)
+
+// AzureResourceGroupDeleteMode controls how the Azure resource group of a cluster is deleted.
+type AzureResourceGroupDeleteMode string
+
+const (
+	// AzureResourceGroupDeleteAuto deletes the resource group with a single call
+	// when a move target is set and few resources in it are not owned by the
+	// cluster, and deletes its resources one by one otherwise.
+	AzureResourceGroupDeleteAuto AzureResourceGroupDeleteMode = "auto"
+	// AzureResourceGroupDeleteChildren deletes the resources in the resource
+	// group one by one, then the empty resource group.
+	AzureResourceGroupDeleteChildren AzureResourceGroupDeleteMode = "children"
+	// AzureResourceGroupDeleteWholeGroup deletes the resource group with a single
+	// call, after moving the resources not owned by the cluster to the move target.
+	AzureResourceGroupDeleteWholeGroup AzureResourceGroupDeleteMode = "wholeGroup"
+)
+
+// AzureResourceFilter selects the types of Azure resources listed for a
+// cluster, by the names of their types, like Disk or VirtualNetwork.
+type AzureResourceFilter struct {
+	// Include, if not empty, holds the only types listed.
+	Include []string
+	// Exclude holds the types not listed.
+	Exclude []string
+}
+
+// IsZero returns whether the filter selects all the types.
+func (f AzureResourceFilter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Allows returns whether the filter selects the type.
+func (f AzureResourceFilter) Allows(resourceType string) bool {
+	if len(f.Include) > 0 && !slices.Contains(f.Include, resourceType) {
+		return false
+	}
+	return !slices.Contains(f.Exclude, resourceType)
+}
+
 type ClusterInfo struct {
 	Name        string
 	UsesNoneDNS bool
@@ -24,4 +67,92 @@ type ClusterInfo struct {
 	AzureResourceGroupShared bool
 	AzureNetworkShared       bool
 	AzureRouteTableShared    bool
+	// AzureDeleteSnapshots opts in to deleting disk snapshots, including the ones
+	// created by the Azure Disk CSI driver from disks owned by the cluster.
+	AzureDeleteSnapshots bool
+	// AzureResourceGroupMoveTarget enables deleting the cluster resource group with a
+	// single call when all but a few of its resources are owned by the cluster. The
+	// other resources, tagged or not, are moved to the named resource group before
+	// the deletion.
+	AzureResourceGroupMoveTarget string
+	// AzureResourceGroupDeleteMode controls how the cluster resource group is
+	// deleted. Empty means AzureResourceGroupDeleteAuto.
+	AzureResourceGroupDeleteMode AzureResourceGroupDeleteMode
+	// AzureDiscoveryProgress, if set, is called each time the discovery of a
+	// kind of Azure resource completes, so that progress can be shown to the user.
+	AzureDiscoveryProgress func(completed, total int)
+	// AzurePreserveResourceGroup keeps the cluster resource group, regardless of
+	// its tags, while all the cluster resources in it are deleted.
+	AzurePreserveResourceGroup bool
+	// AzureDeleteStorage opts in to deleting the storage accounts and key vaults
+	// tagged with the cluster, which may hold data that should outlive it.
+	AzureDeleteStorage bool
+	// AzurePurgeKeyVault purges the key vaults after deleting them. Azure keeps
+	// deleted key vaults for their retention period otherwise, and their names
+	// cannot be used again until then.
+	AzurePurgeKeyVault bool
+	// AzureDeleteBackups opts in to deleting the Recovery Services vaults tagged
+	// with the cluster, along with the backups they hold.
+	AzureDeleteBackups bool
+	// AzureStateStoreAccount is the name of the storage account holding the kops
+	// state store. It is never deleted.
+	AzureStateStoreAccount string
+	// AzureForceDelete clears the protection of VM Scale Set instances against
+	// scale-in and scale set actions, which would make deleting the VM Scale Set fail.
+	AzureForceDelete bool
+	// AzureNodeResourceGroupName is the name of an AKS-style node resource group,
+	// e.g. MC_<group>_<cluster>_<location>, whose resources tagged with the cluster
+	// are listed too. The node resource group itself is never deleted.
+	AzureNodeResourceGroupName string
+	// AzureNetworkResourceGroupNames are the names of the resource groups, other
+	// than the cluster resource group, holding the virtual networks, subnets,
+	// security groups and route tables of the cluster. Their resources are listed
+	// as shared if AzureNetworkShared is set.
+	AzureNetworkResourceGroupNames []string
+	// AzureNetworkSubscriptionID is the ID of the subscription holding the
+	// AzureNetworkResourceGroupNames, if it is not the subscription of the cluster.
+	AzureNetworkSubscriptionID string
+	// AzureTagBeforeDelete tags each resource with the time of its deletion right
+	// before deleting it, so that audit systems can observe the intent.
+	AzureTagBeforeDelete bool
+	// AzureStrictVerify checks that the resources of the types with a registered
+	// verifier are gone after deleting them, and fails their deletion otherwise.
+	AzureStrictVerify bool
+	// AzureLegacyTagKeys are the tag keys used by older kops versions in place of
+	// the cluster tag. Resources tagged with them are recognized as belonging to
+	// the cluster too.
+	AzureLegacyTagKeys []string
+	// AzureIncludeSystemResources includes the resources that Azure creates on its
+	// own in the cluster resource group, like network watchers, when the resource
+	// group is deleted with the cluster.
+	AzureIncludeSystemResources bool
+	// AzureListConcurrency is the maximum number of kinds of Azure resources listed
+	// at the same time. Zero means 8.
+	AzureListConcurrency int
+	// AzureOmitRawObjects drops the Azure objects from the listed resources once
+	// their dependencies and deleters are derived, to save memory on large
+	// clusters. Describing the resources then shows less detail.
+	AzureOmitRawObjects bool
+	// AzureLogEquivalentCLI logs the az CLI command equivalent to each deletion,
+	// for information, e.g. to finish a deletion by hand.
+	AzureLogEquivalentCLI bool
+	// AzureCallTimeout is the time after which each call to Azure, while listing
+	// or deleting the resources, is given up. Zero means 5 minutes.
+	AzureCallTimeout time.Duration
+	// AzureTeardownProfile provides the tuning parameters, like
+	// AzureListConcurrency and AzureCallTimeout, that are left at zero.
+	AzureTeardownProfile AzureTeardownProfile
+	// AzureResourceFilter selects the types of resources listed. The listing of
+	// the types filtered out is skipped, and the resource group is kept when
+	// deleting it would delete them too.
+	AzureResourceFilter AzureResourceFilter
+	// AzureContinueOnError gives up on the resources that keep failing to be
+	// deleted, and on the resources that can only be deleted after them, but
+	// keeps deleting the others. The failures are returned together at the end.
+	AzureContinueOnError bool
+	// AzureShuffleWithinPhase deletes the resources that are ready to be deleted
+	// one at a time, in a random order seeded with AzureShuffleSeed, to surface
+	// the dependencies missing from Blocks and Blocked.
+	AzureShuffleWithinPhase bool
+	AzureShuffleSeed        int64
 }
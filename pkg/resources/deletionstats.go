@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxDeletionSamples is the number of deletion durations kept per resource type.
+const maxDeletionSamples = 20
+
+// DeletionStats records how long the deletions of each resource type took, so
+// that the time needed to delete the remaining resources can be estimated. It
+// can be saved and loaded again, to estimate later deletions from earlier ones.
+type DeletionStats struct {
+	// Durations holds the most recent deletion durations of each resource type.
+	Durations map[string][]time.Duration `json:"durations"`
+}
+
+// LoadDeletionStats loads the deletion stats saved at path. A missing file
+// yields empty stats.
+func LoadDeletionStats(path string) (*DeletionStats, error) {
+	stats := &DeletionStats{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stats, nil
+		}
+		return nil, fmt.Errorf("reading deletion stats: %w", err)
+	}
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, fmt.Errorf("parsing deletion stats %q: %w", path, err)
+	}
+	return stats, nil
+}
+
+// Save writes the deletion stats to path.
+func (s *DeletionStats) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encoding deletion stats: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating directory for deletion stats: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing deletion stats: %w", err)
+	}
+	return nil
+}
+
+// Record records the duration of the deletion of a resource of the given type.
+func (s *DeletionStats) Record(rtype string, d time.Duration) {
+	if s.Durations == nil {
+		s.Durations = map[string][]time.Duration{}
+	}
+	ds := append(s.Durations[rtype], d)
+	if len(ds) > maxDeletionSamples {
+		ds = ds[len(ds)-maxDeletionSamples:]
+	}
+	s.Durations[rtype] = ds
+}
+
+// Average returns the average deletion duration of the given resource type,
+// or false if no resource of that type has been deleted yet.
+func (s *DeletionStats) Average(rtype string) (time.Duration, bool) {
+	ds := s.Durations[rtype]
+	if len(ds) == 0 {
+		return 0, false
+	}
+	return sumDurations(ds) / time.Duration(len(ds)), true
+}
+
+// Estimate returns the estimated time needed to delete the given number of
+// resources per type. Types that have not been observed yet are estimated
+// with the average duration across all types.
+func (s *DeletionStats) Estimate(remaining map[string]int) time.Duration {
+	var all []time.Duration
+	for _, ds := range s.Durations {
+		all = append(all, ds...)
+	}
+	if len(all) == 0 {
+		return 0
+	}
+	overall := sumDurations(all) / time.Duration(len(all))
+
+	var estimate time.Duration
+	for rtype, n := range remaining {
+		avg, ok := s.Average(rtype)
+		if !ok {
+			avg = overall
+		}
+		estimate += avg * time.Duration(n)
+	}
+	return estimate
+}
+
+func sumDurations(ds []time.Duration) time.Duration {
+	var total time.Duration
+	for _, d := range ds {
+		total += d
+	}
+	return total
+}
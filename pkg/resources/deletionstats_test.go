@@ -0,0 +1,55 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeletionStatsSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kops", "deletion-stats.json")
+
+	stats, err := LoadDeletionStats(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading missing stats: %v", err)
+	}
+	if len(stats.Durations) != 0 {
+		t.Errorf("expected empty stats, but got %v", stats.Durations)
+	}
+
+	for i := 1; i <= maxDeletionSamples+5; i++ {
+		stats.Record("Disk", time.Duration(i)*time.Second)
+	}
+	if err := stats.Save(path); err != nil {
+		t.Fatalf("unexpected error saving stats: %v", err)
+	}
+
+	loaded, err := LoadDeletionStats(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading stats: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, stats) {
+		t.Errorf("expected %v, but got %v", stats, loaded)
+	}
+	ds := loaded.Durations["Disk"]
+	if len(ds) != maxDeletionSamples || ds[0] != 6*time.Second {
+		t.Errorf("expected the %d most recent samples to be kept, but got %v", maxDeletionSamples, ds)
+	}
+}
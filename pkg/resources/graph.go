@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DeletionLayers sorts the resources topologically by the dependencies given by
+// their Blocks and Blocked, with Kahn's algorithm. The first layer holds the
+// resources that depend on no other, and each following layer the resources
+// whose dependencies are all in the previous layers, so the resources of a layer
+// can be deleted in parallel. The keys of each layer are sorted. The resources
+// for which done returns true are left out, and no longer hold back the others,
+// like the resources not in the map. It fails if the dependencies form a cycle,
+// as the resources in it could never be deleted.
+func DeletionLayers(resources map[string]*Resource, done func(key string) bool) ([][]string, error) {
+	pending := make(map[string]*Resource)
+	for k, r := range resources {
+		if done == nil || !done(k) {
+			pending[k] = r
+		}
+	}
+	deps := dependencyGraph(pending)
+
+	remaining := make(map[string]int)
+	dependents := make(map[string][]string)
+	var layer []string
+	for k := range pending {
+		remaining[k] = len(deps[k])
+		for dep := range deps[k] {
+			dependents[dep] = append(dependents[dep], k)
+		}
+		if remaining[k] == 0 {
+			layer = append(layer, k)
+		}
+	}
+
+	var layers [][]string
+	sorted := 0
+	for len(layer) > 0 {
+		sort.Strings(layer)
+		layers = append(layers, layer)
+		sorted += len(layer)
+
+		var next []string
+		for _, k := range layer {
+			for _, dependent := range dependents[k] {
+				remaining[dependent]--
+				if remaining[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		layer = next
+	}
+
+	if sorted != len(pending) {
+		return nil, ValidateGraph(pending)
+	}
+	return layers, nil
+}
+
+// ValidateGraph checks that the dependencies given by the Blocks and Blocked of
+// the resources do not form a cycle, which would keep the resources in it from
+// ever being deleted. The error names the resources in the cycle.
+func ValidateGraph(resources map[string]*Resource) error {
+	if cycle := findCycle(resources, dependencyGraph(resources)); cycle != nil {
+		return fmt.Errorf("resources have cyclic dependencies and cannot be deleted: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// dependencyGraph returns, for each resource, the resources to be deleted
+// before it. Dependencies on resources not in the map are left out.
+func dependencyGraph(resources map[string]*Resource) map[string]map[string]bool {
+	deps := make(map[string]map[string]bool)
+	addDep := func(k, dep string) {
+		if _, ok := resources[k]; !ok {
+			return
+		}
+		if _, ok := resources[dep]; !ok {
+			return
+		}
+		if deps[k] == nil {
+			deps[k] = make(map[string]bool)
+		}
+		deps[k][dep] = true
+	}
+	for k, r := range resources {
+		for _, blocked := range r.Blocks {
+			addDep(blocked, k)
+		}
+		for _, dep := range r.Blocked {
+			addDep(k, dep)
+		}
+	}
+	return deps
+}
+
+// findCycle returns the keys of the resources forming a cycle in deps, each to
+// be deleted after the next one, starting and ending with the same key, or nil
+// if there is none.
+func findCycle(resources map[string]*Resource, deps map[string]map[string]bool) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+
+	sortedKeys := func(m map[string]bool) []string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	var visit func(k string) []string
+	visit = func(k string) []string {
+		state[k] = visiting
+		path = append(path, k)
+		for _, dep := range sortedKeys(deps[k]) {
+			switch state[dep] {
+			case visiting:
+				for i, p := range path {
+					if p == dep {
+						return append(append([]string{}, path[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[k] = visited
+		return nil
+	}
+
+	keys := make([]string, 0, len(resources))
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if state[k] == unvisited {
+			if cycle := visit(k); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
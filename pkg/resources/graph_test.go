@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateGraph(t *testing.T) {
+	grid := []struct {
+		name      string
+		resources map[string]*Resource
+		expected  string
+	}{
+		{
+			name: "acyclic",
+			resources: map[string]*Resource{
+				"t:a": {Type: "t", ID: "a", Blocks: []string{"t:b", "t:missing"}},
+				"t:b": {Type: "t", ID: "b", Blocked: []string{"t:c"}},
+				"t:c": {Type: "t", ID: "c"},
+			},
+		},
+		{
+			name: "self-loop",
+			resources: map[string]*Resource{
+				"t:a": {Type: "t", ID: "a", Blocks: []string{"t:a"}},
+				"t:b": {Type: "t", ID: "b"},
+			},
+			expected: "resources have cyclic dependencies and cannot be deleted: t:a -> t:a",
+		},
+		{
+			name: "three-node cycle",
+			resources: map[string]*Resource{
+				"t:a": {Type: "t", ID: "a", Blocks: []string{"t:b"}},
+				"t:b": {Type: "t", ID: "b", Blocks: []string{"t:c"}},
+				"t:c": {Type: "t", ID: "c", Blocked: []string{"t:b"}, Blocks: []string{"t:a"}},
+			},
+			expected: "resources have cyclic dependencies and cannot be deleted: t:a -> t:c -> t:b -> t:a",
+		},
+	}
+	for _, g := range grid {
+		t.Run(g.name, func(t *testing.T) {
+			err := ValidateGraph(g.resources)
+			if g.expected == "" {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != g.expected {
+				t.Errorf("expected error %q, but got %v", g.expected, err)
+			}
+		})
+	}
+}
+
+func TestDeletionLayers(t *testing.T) {
+	resourceMap := map[string]*Resource{
+		"VMScaleSet:nodes": {
+			Blocks: []string{"Subnet:subnet", "LoadBalancer:api", "ResourceGroup:rg"},
+		},
+		"RoleAssignment:ra": {
+			Blocks: []string{"VMScaleSet:nodes", "ResourceGroup:rg"},
+		},
+		"LoadBalancer:api": {
+			Blocks: []string{"PublicIPAddress:api", "ResourceGroup:rg"},
+		},
+		"PublicIPAddress:api": {
+			Blocks: []string{"ResourceGroup:rg"},
+		},
+		"Subnet:subnet": {
+			Blocks: []string{"VirtualNetwork:vnet", "RouteTable:rt", "ResourceGroup:rg"},
+		},
+		"VirtualNetwork:vnet": {
+			Blocks: []string{"ResourceGroup:rg"},
+		},
+		"RouteTable:rt": {
+			Blocks: []string{"ResourceGroup:rg"},
+		},
+		"Disk:etcd": {
+			Blocked: []string{"VMScaleSet:nodes"},
+			Blocks:  []string{"ResourceGroup:rg"},
+		},
+		"ResourceGroup:rg": {},
+	}
+
+	layers, err := DeletionLayers(resourceMap, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	expected := [][]string{
+		{"RoleAssignment:ra"},
+		{"VMScaleSet:nodes"},
+		{"Disk:etcd", "LoadBalancer:api", "Subnet:subnet"},
+		{"PublicIPAddress:api", "RouteTable:rt", "VirtualNetwork:vnet"},
+		{"ResourceGroup:rg"},
+	}
+	if !reflect.DeepEqual(layers, expected) {
+		t.Fatalf("expected layers %v, but got %v", expected, layers)
+	}
+
+	// Every resource is in the layer following the last of its dependencies.
+	layerOf := make(map[string]int)
+	for i, layer := range layers {
+		for _, k := range layer {
+			layerOf[k] = i
+		}
+	}
+	deps := dependencyGraph(resourceMap)
+	for k := range resourceMap {
+		want := 0
+		for dep := range deps[k] {
+			want = max(want, layerOf[dep]+1)
+		}
+		if layerOf[k] != want {
+			t.Errorf("expected %s in layer %d, but got %d", k, want, layerOf[k])
+		}
+	}
+
+	// Deleted resources are left out, and no longer hold back the others.
+	done := func(k string) bool {
+		return k == "RoleAssignment:ra" || k == "VMScaleSet:nodes"
+	}
+	layers, err = DeletionLayers(resourceMap, done)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(layers, expected[2:]) {
+		t.Errorf("expected layers %v, but got %v", expected[2:], layers)
+	}
+
+	// A cycle is an error, unless a resource in it is already deleted.
+	resourceMap["ResourceGroup:rg"].Blocks = []string{"RoleAssignment:ra"}
+	if _, err := DeletionLayers(resourceMap, nil); err == nil {
+		t.Errorf("expected an error for cyclic dependencies")
+	}
+	if _, err := DeletionLayers(resourceMap, done); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
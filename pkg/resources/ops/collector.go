@@ -17,7 +17,10 @@ limitations under the License.
 package ops
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"k8s.io/kops/pkg/apis/kops"
 	"k8s.io/kops/pkg/resources"
@@ -38,8 +41,82 @@ import (
 	cloudscaleway "k8s.io/kops/upup/pkg/fi/cloudup/scaleway"
 )
 
+// ListOptions holds the cloud-specific options of ListResources.
+type ListOptions struct {
+	// AzureDeleteSnapshots opts in to deleting the disk snapshots of the cluster,
+	// including the ones created by the Azure Disk CSI driver for VolumeSnapshots.
+	AzureDeleteSnapshots bool
+	// AzureResourceGroupMoveTarget enables deleting the Azure resource group of the
+	// cluster with a single call, after moving the few resources in it that are not
+	// owned by the cluster to the named resource group.
+	AzureResourceGroupMoveTarget string
+	// AzureResourceGroupDeleteMode controls whether the Azure resource group of the
+	// cluster is deleted after its resources, or as a whole.
+	AzureResourceGroupDeleteMode resources.AzureResourceGroupDeleteMode
+	// AzureDeleteStorage opts in to deleting the Azure storage accounts and key vaults
+	// of the cluster. The storage account of the state store is never deleted.
+	AzureDeleteStorage bool
+	// AzurePurgeKeyVault purges the deleted Azure key vaults of the cluster.
+	AzurePurgeKeyVault bool
+	// AzureDeleteBackups opts in to deleting the Azure Recovery Services vaults of
+	// the cluster, and the backups in them.
+	AzureDeleteBackups bool
+	// AzurePreserveResourceGroup keeps the Azure resource group of the cluster,
+	// while all the cluster resources in it are deleted.
+	AzurePreserveResourceGroup bool
+	// AzureForceDelete clears the protection of the Azure VM Scale Set instances
+	// of the cluster before deleting the VM Scale Sets.
+	AzureForceDelete bool
+	// AzureNodeResourceGroupName is an AKS-style node resource group holding
+	// resources of the cluster too.
+	AzureNodeResourceGroupName string
+	// AzureNetworkResourceGroupNames are other resource groups holding the network of the cluster.
+	AzureNetworkResourceGroupNames []string
+	// AzureNetworkSubscriptionID is the subscription of the AzureNetworkResourceGroupNames,
+	// if it is not the subscription of the cluster.
+	AzureNetworkSubscriptionID string
+	// AzureTagBeforeDelete tags the Azure resources of the cluster with the time of
+	// their deletion right before deleting them.
+	AzureTagBeforeDelete bool
+	// AzureStrictVerify checks that the Azure resources of the types with a
+	// registered verifier, such as disks, are gone after deleting them.
+	AzureStrictVerify bool
+	// AzureLegacyTagKeys are the tag keys that older kops versions used in place of
+	// the cluster tag of the Azure resources of the cluster.
+	AzureLegacyTagKeys []string
+	// AzureIncludeSystemResources deletes the resources that Azure creates on its
+	// own in the Azure resource group of the cluster, like network watchers.
+	AzureIncludeSystemResources bool
+	// AzureListConcurrency is the maximum number of kinds of Azure resources of the
+	// cluster listed at the same time. Zero means the default of 8.
+	AzureListConcurrency int
+	// AzureOmitRawObjects drops the Azure objects from the listed resources to save memory.
+	AzureOmitRawObjects bool
+	// AzureLogEquivalentCLI logs the az CLI command equivalent to each deletion.
+	AzureLogEquivalentCLI bool
+	// AzureCallTimeout is the time after which each call to Azure is given up. Zero means 5 minutes.
+	AzureCallTimeout time.Duration
+	// AzureTeardownProfile provides the Azure tuning parameters left at zero.
+	AzureTeardownProfile resources.AzureTeardownProfile
+	// AzureResourceFilter selects the types of Azure resources deleted.
+	AzureResourceFilter resources.AzureResourceFilter
+	// AzureContinueOnError keeps deleting the Azure resources independent of the
+	// ones that fail to be deleted.
+	AzureContinueOnError bool
+	// AzureShuffleWithinPhase deletes the Azure resources ready to be deleted in a
+	// random order seeded with AzureShuffleSeed.
+	AzureShuffleWithinPhase bool
+	AzureShuffleSeed        int64
+}
+
 // ListResources collects the resources from the specified cloud
-func ListResources(cloud fi.Cloud, cluster *kops.Cluster) (map[string]*resources.Resource, error) {
+func ListResources(cloud fi.Cloud, cluster *kops.Cluster, options ListOptions) (map[string]*resources.Resource, error) {
+	return ListResourcesWithContext(context.Background(), cloud, cluster, options)
+}
+
+// ListResourcesWithContext collects the resources from the specified cloud like
+// ListResources. On Azure, the context is used for listing and deleting them.
+func ListResourcesWithContext(ctx context.Context, cloud fi.Cloud, cluster *kops.Cluster, options ListOptions) (map[string]*resources.Resource, error) {
 	clusterInfo := resources.ClusterInfo{
 		Name:        cluster.Name,
 		UsesNoneDNS: cluster.UsesNoneDNS(),
@@ -57,11 +134,47 @@ func ListResources(cloud fi.Cloud, cluster *kops.Cluster) (map[string]*resources
 	case kops.CloudProviderOpenstack:
 		return openstack.ListResources(cloud.(cloudopenstack.OpenstackCloud), clusterInfo)
 	case kops.CloudProviderAzure:
+		if options.AzurePreserveResourceGroup && options.AzureResourceGroupMoveTarget != "" {
+			return nil, fmt.Errorf("the Azure resource group cannot be both preserved and deleted after moving resources out of it")
+		}
 		clusterInfo.AzureResourceGroupName = cluster.AzureResourceGroupName()
 		clusterInfo.AzureResourceGroupShared = cluster.IsSharedAzureResourceGroup()
 		clusterInfo.AzureNetworkShared = cluster.SharedVPC()
 		clusterInfo.AzureRouteTableShared = cluster.IsSharedAzureRouteTable()
-		return azure.ListResourcesAzure(cloud.(cloudazure.AzureCloud), clusterInfo)
+		clusterInfo.AzureDeleteSnapshots = options.AzureDeleteSnapshots
+		clusterInfo.AzureResourceGroupMoveTarget = options.AzureResourceGroupMoveTarget
+		clusterInfo.AzureResourceGroupDeleteMode = options.AzureResourceGroupDeleteMode
+		clusterInfo.AzureDeleteStorage = options.AzureDeleteStorage
+		clusterInfo.AzurePurgeKeyVault = options.AzurePurgeKeyVault
+		clusterInfo.AzureDeleteBackups = options.AzureDeleteBackups
+		clusterInfo.AzurePreserveResourceGroup = options.AzurePreserveResourceGroup
+		clusterInfo.AzureForceDelete = options.AzureForceDelete
+		clusterInfo.AzureNodeResourceGroupName = options.AzureNodeResourceGroupName
+		clusterInfo.AzureNetworkResourceGroupNames = options.AzureNetworkResourceGroupNames
+		clusterInfo.AzureNetworkSubscriptionID = options.AzureNetworkSubscriptionID
+		clusterInfo.AzureTagBeforeDelete = options.AzureTagBeforeDelete
+		clusterInfo.AzureStrictVerify = options.AzureStrictVerify
+		clusterInfo.AzureLegacyTagKeys = options.AzureLegacyTagKeys
+		clusterInfo.AzureIncludeSystemResources = options.AzureIncludeSystemResources
+		clusterInfo.AzureListConcurrency = options.AzureListConcurrency
+		clusterInfo.AzureOmitRawObjects = options.AzureOmitRawObjects
+		clusterInfo.AzureLogEquivalentCLI = options.AzureLogEquivalentCLI
+		clusterInfo.AzureCallTimeout = options.AzureCallTimeout
+		clusterInfo.AzureTeardownProfile = options.AzureTeardownProfile
+		clusterInfo.AzureResourceFilter = options.AzureResourceFilter
+		clusterInfo.AzureContinueOnError = options.AzureContinueOnError
+		clusterInfo.AzureShuffleWithinPhase = options.AzureShuffleWithinPhase
+		clusterInfo.AzureShuffleSeed = options.AzureShuffleSeed
+		clusterInfo.AzureStateStoreAccount = os.Getenv("AZURE_STORAGE_ACCOUNT")
+		azureCloud := cloud.(cloudazure.AzureCloud)
+		if id := clusterInfo.AzureNetworkSubscriptionID; id != "" && id != azureCloud.SubscriptionID() {
+			networkCloud, err := cloudazure.NewAzureCloud(id, clusterInfo.AzureResourceGroupName, azureCloud.Region(), nil)
+			if err != nil {
+				return nil, fmt.Errorf("creating the Azure cloud of network subscription %q: %w", id, err)
+			}
+			return azure.ListResourcesAzureWithNetworkCloud(ctx, azureCloud, networkCloud, clusterInfo)
+		}
+		return azure.ListResourcesAzureWithContext(ctx, azureCloud, clusterInfo)
 	case kops.CloudProviderScaleway:
 		return scaleway.ListResources(cloud.(cloudscaleway.ScwCloud), clusterInfo)
 	default:
@@ -17,31 +17,155 @@ limitations under the License.
 package ops
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
 	"k8s.io/klog/v2"
+	"k8s.io/kops/pkg/apis/kops"
 	"k8s.io/kops/pkg/resources"
 	awsresources "k8s.io/kops/pkg/resources/aws"
+	azureresources "k8s.io/kops/pkg/resources/azure"
 	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/utils/clock"
 )
 
+// defaultContinueOnErrorAttempts is the number of failed attempts after which
+// ContinueOnError gives up on a resource, when Count is zero.
+const defaultContinueOnErrorAttempts = 3
+
+// DeleteOptions configures DeleteResourcesWithOptions.
+type DeleteOptions struct {
+	// Count is the number of passes without progress after which deletion gives up. Zero retries forever.
+	Count int
+	// Interval is the time to wait between passes.
+	Interval time.Duration
+	// Wait is the overall time after which deletion gives up. Zero waits forever.
+	Wait time.Duration
+	// DeleteInterval is the minimum time between issuing two deletes, to avoid
+	// being throttled by the cloud provider. Zero issues them without pausing.
+	DeleteInterval time.Duration
+	// ContinueOnError gives up on a resource once deleting it has failed Count times
+	// in a row, or 3 times if Count is zero, skips the resources that can only be
+	// deleted after it, and carries on with the others. The failures are returned
+	// together at the end.
+	ContinueOnError bool
+	// MaxConsecutiveFailures halts the deletion once that many deletes in a row
+	// have failed, e.g. because the cloud provider is degraded, returning what
+	// could not be deleted. Zero never halts.
+	MaxConsecutiveFailures int
+
+	// Stats, if set, records how long each successful deletion took.
+	Stats *resources.DeletionStats
+	// EstimateRemaining, if set, is called after each successful deletion with the
+	// time needed to delete the remaining resources, as estimated from Stats.
+	EstimateRemaining func(remaining time.Duration)
+	// Clock is used to time the deletions and to pause between them. Defaults to
+	// the real clock.
+	Clock clock.Clock
+	// Report, if set, receives a line for each attempt to delete a resource, with
+	// its outcome and how long it took.
+	Report io.Writer
+	// ShuffleWithinPhase deletes the resources that are ready to be deleted one at a
+	// time, in a random order seeded with ShuffleSeed, instead of all at once. It is
+	// meant for tests, to surface dependencies missing from Blocks and Blocked.
+	ShuffleWithinPhase bool
+	ShuffleSeed        int64
+	// VerifyDeleted, if set, is called once every resource has been deleted, with
+	// the deleted resources. It returns the ones still present, e.g. because an
+	// asynchronous deletion failed after being accepted.
+	VerifyDeleted func(deleted []*resources.Resource) ([]*resources.Resource, error)
+	// RequeueRemaining deletes the resources returned by VerifyDeleted again,
+	// instead of giving up on them.
+	RequeueRemaining bool
+	// Context, if set, interrupts the deletion once it is done. The deletes in
+	// progress are completed first.
+	Context context.Context
+}
+
 // DeleteResources deletes the resources, as previously collected by ListResources
 func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource, count int, interval, wait time.Duration) error {
-	depMap := make(map[string][]string)
+	return DeleteResourcesWithOptions(cloud, resourceMap, DeleteOptions{
+		Count:    count,
+		Interval: interval,
+		Wait:     wait,
+	})
+}
+
+// WithAzureTeardownProfile returns the options with the deletion parameters
+// left at zero set to the values of the Azure teardown profile.
+func (o DeleteOptions) WithAzureTeardownProfile(profile resources.AzureTeardownProfile) (DeleteOptions, error) {
+	settings, err := profile.Settings()
+	if err != nil {
+		return o, err
+	}
+	if o.DeleteInterval == 0 {
+		o.DeleteInterval = settings.DeleteInterval
+	}
+	if o.Count == 0 {
+		o.Count = settings.Count
+	}
+	return o, nil
+}
+
+// DeleteResourcesWithOptions deletes the resources, as previously collected by ListResources
+func DeleteResourcesWithOptions(cloud fi.Cloud, resourceMap map[string]*resources.Resource, options DeleteOptions) error {
+	count, interval, wait := options.Count, options.Interval, options.Wait
+	clk := options.Clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	stats := options.Stats
+	if stats == nil && options.EstimateRemaining != nil {
+		stats = &resources.DeletionStats{}
+	}
+
+	giveUpAfter := count
+	if giveUpAfter == 0 {
+		giveUpAfter = defaultContinueOnErrorAttempts
+	}
+
+	var rng *rand.Rand
+	if options.ShuffleWithinPhase {
+		rng = rand.New(rand.NewSource(options.ShuffleSeed))
+	}
 
 	done := make(map[string]*resources.Resource)
+	// lastErrors holds the error of the last failed attempt to delete each resource.
+	lastErrors := make(map[string]error)
+	// attempts counts the consecutive failed attempts to delete each resource.
+	attempts := make(map[string]int)
+	// abandoned holds the resources given up on with ContinueOnError, and the
+	// resources depending on them.
+	abandoned := make(map[string]*resources.Resource)
+
+	// lastIssued is when the last delete was issued, for DeleteInterval.
+	var lastIssued time.Time
+	// consecutiveFailures counts the deletes that failed since the last success,
+	// for MaxConsecutiveFailures.
+	consecutiveFailures := 0
 
 	var mutex sync.Mutex
 
-	for k, t := range resourceMap {
-		for _, block := range t.Blocks {
-			depMap[block] = append(depMap[block], k)
-		}
+	// Shared resources are never deleted, so they are left out, like the
+	// resources already deleted outside of kops.
+	resourceMap = withoutShared(resourceMap)
+	pruneMissingDependencies(resourceMap)
 
-		depMap[k] = append(depMap[k], t.Blocked...)
+	// The resources are tracked by their key in the map, which is not always
+	// their type and ID, e.g. when qualified with a subscription.
+	keys := make(map[*resources.Resource]string, len(resourceMap))
+	for k, r := range resourceMap {
+		keys[r] = k
+	}
 
+	depMap := deletionDependencies(resourceMap)
+	for k, t := range resourceMap {
 		if t.Done {
 			done[k] = t
 		}
@@ -55,16 +179,28 @@ func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource,
 	timeout := time.Now().Add(wait)
 	iterationsWithNoProgress := 0
 	for {
+		if err := interrupted(options.Context); err != nil {
+			return err
+		}
 		if wait > 0 && time.Now().After(timeout) {
-			return fmt.Errorf("wait time exceeded during resources deletion")
+			return giveUpError(cloud, "wait time exceeded during resources deletion", resourceMap, done, lastErrors)
 		}
 
 		failed := make(map[string]*resources.Resource)
 
-		for {
+		layers, err := resources.DeletionLayers(resourceMap, func(k string) bool {
+			_, d := done[k]
+			return d
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, layer := range layers {
 			phase := make(map[string]*resources.Resource)
 
-			for k, r := range resourceMap {
+			for _, k := range layer {
+				r := resourceMap[k]
 				if _, d := done[k]; d {
 					continue
 				}
@@ -74,8 +210,19 @@ func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource,
 					continue
 				}
 
+				if _, a := abandoned[k]; a {
+					continue
+				}
+
 				ready := true
 				for _, dep := range depMap[k] {
+					if _, a := abandoned[dep]; a {
+						fmt.Printf("%s\tskipped, depends on %s\n", k, dep)
+						abandoned[k] = r
+						lastErrors[k] = fmt.Errorf("not deleted because %s could not be deleted", dep)
+						ready = false
+						break
+					}
 					if _, d := done[dep]; !d {
 						klog.V(4).Infof("dependency %q of %q not deleted; skipping", dep, k)
 						ready = false
@@ -89,7 +236,7 @@ func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource,
 			}
 
 			if len(phase) == 0 {
-				break
+				continue
 			}
 
 			groups := make(map[string][]*resources.Resource)
@@ -101,22 +248,48 @@ func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource,
 				groups[groupKey] = append(groups[groupKey], t)
 			}
 
+			groupKeys := make([]string, 0, len(groups))
+			for groupKey := range groups {
+				groupKeys = append(groupKeys, groupKey)
+			}
+			sort.Strings(groupKeys)
+			if rng != nil {
+				rng.Shuffle(len(groupKeys), func(i, j int) {
+					groupKeys[i], groupKeys[j] = groupKeys[j], groupKeys[i]
+				})
+			}
+
 			var wg sync.WaitGroup
-			for _, trackers := range groups {
+			for _, groupKey := range groupKeys {
+				trackers := groups[groupKey]
+				if options.DeleteInterval > 0 {
+					if !lastIssued.IsZero() {
+						if pause := options.DeleteInterval - clk.Since(lastIssued); pause > 0 {
+							clk.Sleep(pause)
+						}
+					}
+					lastIssued = clk.Now()
+				}
 				wg.Add(1)
 
 				go func(trackers []*resources.Resource) {
 					mutex.Lock()
 					for _, t := range trackers {
-						k := t.Type + ":" + t.ID
-						failed[k] = t
+						failed[keys[t]] = t
 					}
 					mutex.Unlock()
 
 					defer wg.Done()
 
-					human := trackers[0].Type + ":" + trackers[0].ID
+					human := keys[trackers[0]]
+
+					if trackers[0].Async {
+						mutex.Lock()
+						fmt.Printf("%s\tdeleting, waiting for completion\n", human)
+						mutex.Unlock()
+					}
 
+					start := clk.Now()
 					var err error
 					if trackers[0].GroupDeleter != nil {
 						err = trackers[0].GroupDeleter(cloud, trackers)
@@ -126,6 +299,18 @@ func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource,
 						}
 						err = trackers[0].Deleter(cloud, trackers[0])
 					}
+					elapsed := clk.Since(start)
+					if options.Report != nil {
+						mutex.Lock()
+						for _, t := range trackers {
+							outcome := "deleted"
+							if err != nil {
+								outcome = fmt.Sprintf("failed: %v", err)
+							}
+							fmt.Fprintf(options.Report, "%s:%s\t%s\t%s\n", t.Type, t.ID, outcome, elapsed.Round(time.Millisecond))
+						}
+						mutex.Unlock()
+					}
 					if err != nil {
 						mutex.Lock()
 						if awsresources.IsDependencyViolation(err) {
@@ -134,9 +319,16 @@ func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource,
 						} else {
 							fmt.Printf("%s\terror deleting resources, will retry: %v\n", human, err)
 						}
+						consecutiveFailures++
 						for _, t := range trackers {
-							k := t.Type + ":" + t.ID
+							k := keys[t]
 							failed[k] = t
+							lastErrors[k] = err
+							attempts[k]++
+							if options.ContinueOnError && attempts[k] >= giveUpAfter {
+								fmt.Printf("%s\tfailed %d times, giving up\n", k, attempts[k])
+								abandoned[k] = t
+							}
 						}
 						mutex.Unlock()
 					} else {
@@ -144,20 +336,57 @@ func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource,
 						fmt.Printf("%s\tok\n", human)
 
 						iterationsWithNoProgress = 0
+						consecutiveFailures = 0
 						for _, t := range trackers {
-							k := t.Type + ":" + t.ID
+							k := keys[t]
 							delete(failed, k)
+							delete(lastErrors, k)
+							delete(attempts, k)
 							done[k] = t
+							if stats != nil {
+								stats.Record(t.Type, elapsed/time.Duration(len(trackers)))
+							}
+						}
+						if options.EstimateRemaining != nil {
+							options.EstimateRemaining(stats.Estimate(remainingByType(resourceMap, done)))
 						}
 						mutex.Unlock()
 					}
 				}(trackers)
+				if rng != nil {
+					wg.Wait()
+				}
 			}
 			wg.Wait()
+
+			if err := interrupted(options.Context); err != nil {
+				return err
+			}
+			if options.MaxConsecutiveFailures > 0 && consecutiveFailures >= options.MaxConsecutiveFailures {
+				return giveUpError(cloud, fmt.Sprintf("%d deletes failed in a row; halting", consecutiveFailures), resourceMap, done, lastErrors)
+			}
 		}
 
 		if len(resourceMap) == len(done) {
-			return nil
+			if options.VerifyDeleted == nil {
+				return nil
+			}
+			remaining, err := verifyDeleted(options.VerifyDeleted, keys, done, lastErrors)
+			if err != nil {
+				return err
+			}
+			if len(remaining) == 0 {
+				return nil
+			}
+			if !options.RequeueRemaining {
+				return giveUpError(cloud, "some resources are still present after being deleted", resourceMap, done, lastErrors)
+			}
+			for _, k := range remaining {
+				delete(done, k)
+			}
+		}
+		if len(resourceMap) == len(done)+len(abandoned) {
+			return giveUpError(cloud, "some resources could not be deleted", resourceMap, done, lastErrors)
 		}
 
 		fmt.Printf("Not all resources deleted; waiting before reattempting deletion\n")
@@ -165,15 +394,148 @@ func DeleteResources(cloud fi.Cloud, resourceMap map[string]*resources.Resource,
 			if _, d := done[k]; d {
 				continue
 			}
+			if _, a := abandoned[k]; a {
+				continue
+			}
 
 			fmt.Printf("\t%s\n", k)
 		}
 
 		iterationsWithNoProgress++
 		if iterationsWithNoProgress > count && count != 0 {
-			return fmt.Errorf("not making progress deleting resources; giving up")
+			return giveUpError(cloud, "not making progress deleting resources; giving up", resourceMap, done, lastErrors)
+		}
+
+		if options.Context == nil {
+			time.Sleep(interval)
+			continue
+		}
+		select {
+		case <-options.Context.Done():
+		case <-time.After(interval):
+		}
+	}
+}
+
+// interrupted returns an error if the context is set and done.
+func interrupted(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("resources deletion interrupted: %w", err)
+	}
+	return nil
+}
+
+// verifyDeleted calls verify with the deleted resources, and returns the keys of
+// the ones still present. They are recorded in lastErrors.
+func verifyDeleted(verify func([]*resources.Resource) ([]*resources.Resource, error), keys map[*resources.Resource]string, done map[string]*resources.Resource, lastErrors map[string]error) ([]string, error) {
+	var deleted []*resources.Resource
+	for _, r := range done {
+		deleted = append(deleted, r)
+	}
+	present, err := verify(deleted)
+	if err != nil {
+		return nil, fmt.Errorf("verifying resources deletion: %w", err)
+	}
+	var remaining []string
+	for _, r := range present {
+		k, ok := keys[r]
+		if !ok {
+			k = r.Type + ":" + r.ID
+		}
+		fmt.Printf("%s	still present after deletion\n", k)
+		lastErrors[k] = errors.New("still present after deletion")
+		remaining = append(remaining, k)
+	}
+	sort.Strings(remaining)
+	return remaining, nil
+}
+
+// giveUpError returns the error reported when deletion gives up. On Azure, it
+// wraps an AzureTeardownError listing the resources that failed to be deleted.
+func giveUpError(cloud fi.Cloud, msg string, resourceMap map[string]*resources.Resource, done map[string]*resources.Resource, lastErrors map[string]error) error {
+	if cloud == nil || cloud.ProviderID() != kops.CloudProviderAzure {
+		return errors.New(msg)
+	}
+
+	var keys []string
+	for k := range resourceMap {
+		if _, d := done[k]; d {
+			continue
 		}
+		if _, ok := lastErrors[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New(msg)
+	}
+	sort.Strings(keys)
+
+	teardownErr := &azureresources.AzureTeardownError{}
+	for _, k := range keys {
+		teardownErr.Failures = append(teardownErr.Failures, azureresources.AzureTeardownFailure{
+			Resource: resourceMap[k],
+			Err:      lastErrors[k],
+		})
+	}
+	return fmt.Errorf("%s: %w", msg, teardownErr)
+}
+
+// withoutShared returns the resources that are not shared.
+func withoutShared(resourceMap map[string]*resources.Resource) map[string]*resources.Resource {
+	owned := make(map[string]*resources.Resource, len(resourceMap))
+	for k, r := range resourceMap {
+		if !r.Shared {
+			owned[k] = r
+		}
+	}
+	return owned
+}
+
+// pruneMissingDependencies removes the dependencies on resources that are not in
+// the map, e.g. because they were deleted outside of kops since they were listed.
+// Otherwise a resource blocked by such a dependency would wait for it forever.
+func pruneMissingDependencies(resourceMap map[string]*resources.Resource) {
+	prune := func(k string, deps []string) []string {
+		var kept []string
+		for _, dep := range deps {
+			if _, ok := resourceMap[dep]; !ok {
+				klog.V(2).Infof("dependency %q of %q no longer exists; ignoring", dep, k)
+				continue
+			}
+			kept = append(kept, dep)
+		}
+		return kept
+	}
+	for k, r := range resourceMap {
+		r.Blocks = prune(k, r.Blocks)
+		r.Blocked = prune(k, r.Blocked)
+	}
+}
 
-		time.Sleep(interval)
+// deletionDependencies returns, for each resource key, the keys of the resources
+// that must be deleted before it.
+func deletionDependencies(resourceMap map[string]*resources.Resource) map[string][]string {
+	depMap := make(map[string][]string)
+	for k, t := range resourceMap {
+		for _, block := range t.Blocks {
+			depMap[block] = append(depMap[block], k)
+		}
+		depMap[k] = append(depMap[k], t.Blocked...)
+	}
+	return depMap
+}
+
+// remainingByType counts the resources not deleted yet, per type.
+func remainingByType(resourceMap map[string]*resources.Resource, done map[string]*resources.Resource) map[string]int {
+	remaining := make(map[string]int)
+	for k, r := range resourceMap {
+		if _, d := done[k]; !d {
+			remaining[r.Type]++
+		}
 	}
+	return remaining
 }
@@ -0,0 +1,618 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"k8s.io/kops/pkg/resources"
+	azureresources "k8s.io/kops/pkg/resources/azure"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestDeleteResourcesEstimateRemaining(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Unix(0, 0))
+	var deleted []string
+	newResource := func(rtype, id string, d time.Duration, blocks ...string) *resources.Resource {
+		return &resources.Resource{
+			Type: rtype,
+			ID:   id,
+			Name: id,
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				clk.Step(d)
+				deleted = append(deleted, r.Type+":"+r.ID)
+				return nil
+			},
+			Blocks: blocks,
+		}
+	}
+	// The resources block each other in a chain, so they are deleted one at a time.
+	resourceMap := map[string]*resources.Resource{
+		"Snapshot:snap":    newResource("Snapshot", "snap", 20*time.Second, "Disk:disk-a"),
+		"Disk:disk-a":      newResource("Disk", "disk-a", 10*time.Second, "Disk:disk-b"),
+		"Disk:disk-b":      newResource("Disk", "disk-b", 10*time.Second, "Disk:disk-c"),
+		"Disk:disk-c":      newResource("Disk", "disk-c", 10*time.Second, "ResourceGroup:rg"),
+		"ResourceGroup:rg": newResource("ResourceGroup", "rg", time.Minute),
+	}
+
+	stats := &resources.DeletionStats{}
+	var estimates []time.Duration
+	err := DeleteResourcesWithOptions(nil, resourceMap, DeleteOptions{
+		Stats: stats,
+		EstimateRemaining: func(remaining time.Duration) {
+			estimates = append(estimates, remaining)
+		},
+		Clock: clk,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	expectedOrder := []string{"Snapshot:snap", "Disk:disk-a", "Disk:disk-b", "Disk:disk-c", "ResourceGroup:rg"}
+	if !reflect.DeepEqual(deleted, expectedOrder) {
+		t.Errorf("expected deletion order %v, but got %v", expectedOrder, deleted)
+	}
+
+	expectedEstimates := []time.Duration{
+		// 3 disks and 1 resource group at the 20s overall average.
+		80 * time.Second,
+		// 2 disks at 10s, 1 resource group at the overall average of 30s/2.
+		20*time.Second + 15*time.Second,
+		// 1 disk at 10s, 1 resource group at the overall average of 40s/3.
+		10*time.Second + 40*time.Second/3,
+		// 1 resource group at the overall average of 50s/4.
+		50 * time.Second / 4,
+		0,
+	}
+	if !reflect.DeepEqual(estimates, expectedEstimates) {
+		t.Errorf("expected estimates %v, but got %v", expectedEstimates, estimates)
+	}
+	for i := 1; i < len(estimates); i++ {
+		if estimates[i] >= estimates[i-1] {
+			t.Errorf("expected estimates to decrease, but got %v", estimates)
+		}
+	}
+
+	if avg, ok := stats.Average("ResourceGroup"); !ok || avg != time.Minute {
+		t.Errorf("expected average resource group deletion duration of 1m, but got %v", avg)
+	}
+}
+
+func TestDeleteResourcesRecordsOnlySuccesses(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Unix(0, 0))
+	attempts := 0
+	resourceMap := map[string]*resources.Resource{
+		"Disk:disk": {
+			Type: "Disk",
+			ID:   "disk",
+			Name: "disk",
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				attempts++
+				if attempts == 1 {
+					clk.Step(5 * time.Minute)
+					return errors.New("still attached")
+				}
+				clk.Step(10 * time.Second)
+				return nil
+			},
+		},
+	}
+
+	stats := &resources.DeletionStats{}
+	if err := DeleteResourcesWithOptions(nil, resourceMap, DeleteOptions{Stats: stats, Clock: clk}); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, but got %d", attempts)
+	}
+	if ds := stats.Durations["Disk"]; !reflect.DeepEqual(ds, []time.Duration{10 * time.Second}) {
+		t.Errorf("expected only the successful deletion to be recorded, but got %v", ds)
+	}
+}
+
+func TestDeleteResourcesAzureTeardownError(t *testing.T) {
+	errBoom := errors.New("boom")
+	var deleted []string
+	resourceMap := map[string]*resources.Resource{
+		"Disk:disk-a": {
+			Type: "Disk",
+			ID:   "disk-a",
+			Name: "disk-a",
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				return errBoom
+			},
+		},
+		"Disk:disk-b": {
+			Type: "Disk",
+			ID:   "disk-b",
+			Name: "disk-b",
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				deleted = append(deleted, r.Name)
+				return nil
+			},
+		},
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	err := DeleteResources(cloud, resourceMap, 1, 0, 0)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	var teardownErr *azureresources.AzureTeardownError
+	if !errors.As(err, &teardownErr) {
+		t.Fatalf("expected an AzureTeardownError, but got %T", err)
+	}
+	if len(teardownErr.Failures) != 1 {
+		t.Fatalf("expected 1 failure, but got %d", len(teardownErr.Failures))
+	}
+	failure := teardownErr.Failures[0]
+	if failure.Resource.Name != "disk-a" || failure.Err != errBoom {
+		t.Errorf("expected disk-a to fail with %v, but got %s with %v", errBoom, failure.Resource.Name, failure.Err)
+	}
+	if !errors.Is(err, errBoom) {
+		t.Errorf("expected the error to wrap %v", errBoom)
+	}
+
+	// The failure does not prevent deleting the other resources.
+	if !reflect.DeepEqual(deleted, []string{"disk-b"}) {
+		t.Errorf("expected [disk-b] to be deleted, but got %v", deleted)
+	}
+}
+
+func TestDeleteResourcesContinueOnError(t *testing.T) {
+	errBoom := errors.New("boom")
+	attempts := 0
+	var deleted []string
+	deleter := func(_ fi.Cloud, r *resources.Resource) error {
+		deleted = append(deleted, r.Name)
+		return nil
+	}
+	resourceMap := map[string]*resources.Resource{
+		"RoleAssignment:ra": {
+			Type: "RoleAssignment",
+			ID:   "ra",
+			Name: "ra",
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				attempts++
+				return errBoom
+			},
+			Blocks: []string{"VMScaleSet:nodes"},
+		},
+		"VMScaleSet:nodes": {
+			Type:    "VMScaleSet",
+			ID:      "nodes",
+			Name:    "nodes",
+			Deleter: deleter,
+		},
+		"Disk:disk": {
+			Type:    "Disk",
+			ID:      "disk",
+			Name:    "disk",
+			Deleter: deleter,
+		},
+	}
+
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	err := DeleteResourcesWithOptions(cloud, resourceMap, DeleteOptions{
+		Count:           2,
+		ContinueOnError: true,
+	})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts to delete ra, but got %d", attempts)
+	}
+
+	// The unrelated disk is deleted, while the VMSS that can only be deleted
+	// after the role assignment is skipped.
+	if !reflect.DeepEqual(deleted, []string{"disk"}) {
+		t.Errorf("expected [disk] to be deleted, but got %v", deleted)
+	}
+
+	var teardownErr *azureresources.AzureTeardownError
+	if !errors.As(err, &teardownErr) {
+		t.Fatalf("expected an AzureTeardownError, but got %T", err)
+	}
+	var failed []string
+	for _, f := range teardownErr.Failures {
+		failed = append(failed, f.Resource.Name)
+	}
+	if !reflect.DeepEqual(failed, []string{"ra", "nodes"}) {
+		t.Errorf("expected [ra nodes] to fail, but got %v", failed)
+	}
+	if !errors.Is(err, errBoom) {
+		t.Errorf("expected the error to wrap %v", errBoom)
+	}
+}
+
+func TestDeleteResourcesContinueOnErrorDefaultCount(t *testing.T) {
+	attempts := 0
+	var deleted []string
+	resourceMap := map[string]*resources.Resource{
+		"RoleAssignment:ra": {
+			Type: "RoleAssignment",
+			ID:   "ra",
+			Name: "ra",
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				attempts++
+				return errors.New("boom")
+			},
+		},
+		"Disk:disk": {
+			Type: "Disk",
+			ID:   "disk",
+			Name: "disk",
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				deleted = append(deleted, r.Name)
+				return nil
+			},
+		},
+	}
+
+	// Without a Count, the resource is given up on after the default number of
+	// attempts instead of being retried forever.
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	err := DeleteResourcesWithOptions(cloud, resourceMap, DeleteOptions{
+		ContinueOnError: true,
+	})
+	var teardownErr *azureresources.AzureTeardownError
+	if !errors.As(err, &teardownErr) {
+		t.Fatalf("expected an AzureTeardownError, but got %v", err)
+	}
+	if attempts != defaultContinueOnErrorAttempts {
+		t.Errorf("expected %d attempts to delete ra, but got %d", defaultContinueOnErrorAttempts, attempts)
+	}
+	if !reflect.DeepEqual(deleted, []string{"disk"}) {
+		t.Errorf("expected [disk] to be deleted, but got %v", deleted)
+	}
+}
+
+func TestDeleteResourcesPrunesMissingDependencies(t *testing.T) {
+	var deleted []string
+	deleter := func(_ fi.Cloud, r *resources.Resource) error {
+		deleted = append(deleted, r.Name)
+		return nil
+	}
+	resourceMap := map[string]*resources.Resource{
+		"Subnet:subnet": {
+			Type:    "Subnet",
+			ID:      "subnet",
+			Name:    "subnet",
+			Deleter: deleter,
+			// The VMSS was deleted outside of kops after the resources were listed.
+			Blocked: []string{"VMScaleSet:nodes"},
+			Blocks:  []string{"VirtualNetwork:vnet"},
+		},
+		"VirtualNetwork:vnet": {
+			Type:    "VirtualNetwork",
+			ID:      "vnet",
+			Name:    "vnet",
+			Deleter: deleter,
+		},
+	}
+
+	if err := DeleteResources(nil, resourceMap, 1, 0, 0); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"subnet", "vnet"}) {
+		t.Errorf("expected [subnet vnet] to be deleted, but got %v", deleted)
+	}
+	if subnet := resourceMap["Subnet:subnet"]; subnet.Blocked != nil {
+		t.Errorf("expected the missing dependency to be pruned, but got %v", subnet.Blocked)
+	}
+}
+
+func TestDeleteResourcesSkipsShared(t *testing.T) {
+	var deleted []string
+	deleter := func(_ fi.Cloud, r *resources.Resource) error {
+		deleted = append(deleted, r.Name)
+		return nil
+	}
+	resourceMap := map[string]*resources.Resource{
+		"Subnet:subnet": {
+			Type:    "Subnet",
+			ID:      "subnet",
+			Name:    "subnet",
+			Deleter: deleter,
+			Blocks:  []string{"VirtualNetwork:vnet"},
+		},
+		"VirtualNetwork:vnet": {
+			Type:    "VirtualNetwork",
+			ID:      "vnet",
+			Name:    "vnet",
+			Shared:  true,
+			Deleter: deleter,
+		},
+	}
+
+	if err := DeleteResources(nil, resourceMap, 1, 0, 0); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"subnet"}) {
+		t.Errorf("expected only [subnet] to be deleted, but got %v", deleted)
+	}
+}
+
+func TestDeleteResourcesShuffleWithinPhase(t *testing.T) {
+	deleteAll := func(options DeleteOptions) []string {
+		var deleted []string
+		resourceMap := make(map[string]*resources.Resource)
+		for i := 0; i < 8; i++ {
+			name := fmt.Sprintf("disk-%d", i)
+			resourceMap["Disk:"+name] = &resources.Resource{
+				Type: "Disk",
+				ID:   name,
+				Name: name,
+				Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+					deleted = append(deleted, r.Name)
+					return nil
+				},
+				Blocks: []string{"ResourceGroup:rg"},
+			}
+		}
+		resourceMap["ResourceGroup:rg"] = &resources.Resource{
+			Type: "ResourceGroup",
+			ID:   "rg",
+			Name: "rg",
+			Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+				deleted = append(deleted, r.Name)
+				return nil
+			},
+		}
+		if err := DeleteResourcesWithOptions(nil, resourceMap, options); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		return deleted
+	}
+
+	options := DeleteOptions{
+		ShuffleWithinPhase: true,
+		ShuffleSeed:        42,
+	}
+	first := deleteAll(options)
+	if second := deleteAll(options); !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same order with the same seed, but got %v and %v", first, second)
+	}
+	if first[len(first)-1] != "rg" {
+		t.Errorf("expected the resource group to be deleted last, but got %v", first)
+	}
+	disks := first[:len(first)-1]
+	sorted := append([]string(nil), disks...)
+	sort.Strings(sorted)
+	if reflect.DeepEqual(disks, sorted) {
+		t.Errorf("expected a shuffled order, but got the sorted order %v", disks)
+	}
+}
+
+func TestDeleteResourcesDeleteInterval(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Unix(0, 0))
+	var starts []time.Duration
+	deleter := func(d time.Duration) func(fi.Cloud, *resources.Resource) error {
+		return func(_ fi.Cloud, r *resources.Resource) error {
+			starts = append(starts, clk.Since(time.Unix(0, 0)))
+			clk.Step(d)
+			return nil
+		}
+	}
+	// The resources are deleted one after the other: vmss, then nic, then vnet.
+	resourceMap := map[string]*resources.Resource{
+		"VirtualMachineScaleSet:vmss": {
+			Type:    "VirtualMachineScaleSet",
+			ID:      "vmss",
+			Deleter: deleter(time.Second),
+			Blocks:  []string{"NetworkInterface:nic"},
+		},
+		"NetworkInterface:nic": {
+			Type:    "NetworkInterface",
+			ID:      "nic",
+			Deleter: deleter(15 * time.Second),
+			Blocks:  []string{"VirtualNetwork:vnet"},
+		},
+		"VirtualNetwork:vnet": {
+			Type:    "VirtualNetwork",
+			ID:      "vnet",
+			Deleter: deleter(0),
+		},
+	}
+
+	if err := DeleteResourcesWithOptions(nil, resourceMap, DeleteOptions{DeleteInterval: 10 * time.Second, Clock: clk}); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	// The nic waits for the rest of the interval after the vmss; the vnet doesn't
+	// wait, as deleting the nic took longer than the interval.
+	expected := []time.Duration{0, 10 * time.Second, 25 * time.Second}
+	if !reflect.DeepEqual(starts, expected) {
+		t.Errorf("expected deletes to start at %v, but got %v", expected, starts)
+	}
+}
+
+func TestDeleteResourcesVerifyDeleted(t *testing.T) {
+	for _, requeue := range []bool{true, false} {
+		t.Run(fmt.Sprintf("requeue=%v", requeue), func(t *testing.T) {
+			attempts := make(map[string]int)
+			deleter := func(_ fi.Cloud, r *resources.Resource) error {
+				attempts[r.ID]++
+				return nil
+			}
+			resourceMap := map[string]*resources.Resource{
+				"Disk:disk": {
+					Type:    "Disk",
+					ID:      "disk",
+					Deleter: deleter,
+					Blocks:  []string{"ResourceGroup:rg"},
+				},
+				"ResourceGroup:rg": {
+					Type:    "ResourceGroup",
+					ID:      "rg",
+					Deleter: deleter,
+				},
+			}
+
+			// The disk deletion is accepted, but the disk is still there after the first pass.
+			verifications := 0
+			verify := func(deleted []*resources.Resource) ([]*resources.Resource, error) {
+				verifications++
+				if len(deleted) != len(resourceMap) {
+					t.Errorf("expected %d deleted resources, but got %d", len(resourceMap), len(deleted))
+				}
+				if verifications == 1 {
+					return []*resources.Resource{resourceMap["Disk:disk"]}, nil
+				}
+				return nil, nil
+			}
+
+			err := DeleteResourcesWithOptions(nil, resourceMap, DeleteOptions{
+				Count:            3,
+				VerifyDeleted:    verify,
+				RequeueRemaining: requeue,
+			})
+			if !requeue {
+				if err == nil || err.Error() != "some resources are still present after being deleted" {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if attempts["disk"] != 1 {
+					t.Errorf("expected the disk to be deleted once, but got %d attempts", attempts["disk"])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if attempts["disk"] != 2 {
+				t.Errorf("expected the disk to be deleted again, but got %d attempts", attempts["disk"])
+			}
+			if attempts["rg"] != 1 {
+				t.Errorf("expected the resource group to be deleted once, but got %d attempts", attempts["rg"])
+			}
+			if verifications != 2 {
+				t.Errorf("expected 2 verifications, but got %d", verifications)
+			}
+		})
+	}
+}
+
+func TestDeleteResourcesMaxConsecutiveFailures(t *testing.T) {
+	errBoom := errors.New("boom")
+	for _, failures := range []int{2, 3, 5} {
+		t.Run(fmt.Sprintf("%d failures", failures), func(t *testing.T) {
+			attempts := 0
+			resourceMap := map[string]*resources.Resource{
+				"Disk:disk": {
+					Type: "Disk",
+					ID:   "disk",
+					Name: "disk",
+					Deleter: func(_ fi.Cloud, r *resources.Resource) error {
+						attempts++
+						if attempts <= failures {
+							return errBoom
+						}
+						return nil
+					},
+				},
+			}
+
+			cloud := azuretasks.NewMockAzureCloud("eastus")
+			err := DeleteResourcesWithOptions(cloud, resourceMap, DeleteOptions{
+				MaxConsecutiveFailures: 3,
+			})
+			if failures < 3 {
+				if err != nil {
+					t.Fatalf("unexpected error %s", err)
+				}
+				if attempts != failures+1 {
+					t.Errorf("expected %d attempts, but got %d", failures+1, attempts)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if attempts != 3 {
+				t.Errorf("expected the deletion to halt after 3 attempts, but got %d", attempts)
+			}
+			var teardownErr *azureresources.AzureTeardownError
+			if !errors.As(err, &teardownErr) || len(teardownErr.Failures) != 1 {
+				t.Errorf("expected an AzureTeardownError with one failure, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeleteResourcesCyclicDependencies(t *testing.T) {
+	deleter := func(_ fi.Cloud, r *resources.Resource) error {
+		t.Errorf("unexpected deletion of %s", r.Name)
+		return nil
+	}
+	resourceMap := map[string]*resources.Resource{
+		"Subnet:subnet": {
+			Type:    "Subnet",
+			ID:      "subnet",
+			Name:    "subnet",
+			Deleter: deleter,
+			Blocks:  []string{"NetworkSecurityGroup:nsg"},
+		},
+		"NetworkSecurityGroup:nsg": {
+			Type:    "NetworkSecurityGroup",
+			ID:      "nsg",
+			Name:    "nsg",
+			Deleter: deleter,
+			Blocks:  []string{"Subnet:subnet", "VirtualNetwork:vnet"},
+		},
+		"VirtualNetwork:vnet": {
+			Type:    "VirtualNetwork",
+			ID:      "vnet",
+			Name:    "vnet",
+			Deleter: deleter,
+		},
+	}
+
+	err := DeleteResources(nil, resourceMap, 0, 0, 0)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	expected := "resources have cyclic dependencies and cannot be deleted: NetworkSecurityGroup:nsg -> Subnet:subnet -> NetworkSecurityGroup:nsg"
+	if err.Error() != expected {
+		t.Errorf("expected error %q, but got %q", expected, err)
+	}
+}
+
+func TestDeleteOptionsWithAzureTeardownProfile(t *testing.T) {
+	// The parameters that are set are kept, the others come from the profile.
+	options, err := DeleteOptions{Count: 1}.WithAzureTeardownProfile(resources.AzureTeardownProfileSafe)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if options.Count != 1 {
+		t.Errorf("expected count 1, but got %d", options.Count)
+	}
+	if options.DeleteInterval != time.Second {
+		t.Errorf("expected delete interval 1s, but got %s", options.DeleteInterval)
+	}
+
+	if _, err := (DeleteOptions{}).WithAzureTeardownProfile("reckless"); err == nil {
+		t.Errorf("expected an error for an unknown profile")
+	}
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/kops/pkg/resources"
+	azureresources "k8s.io/kops/pkg/resources/azure"
+	cloudazure "k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// DeleteAzureResourcesOfType lists the resources of an Azure cluster and deletes
+// only the ones of the given type, e.g. to clean up orphaned disks. It fails
+// without deleting anything if resources of other types depend on them.
+func DeleteAzureResourcesOfType(ctx context.Context, cloud cloudazure.AzureCloud, clusterInfo resources.ClusterInfo, rtype string) error {
+	resourceMap, err := azureresources.SelectAzureResourcesOfType(cloud, clusterInfo, rtype)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return DeleteResourcesWithOptions(cloud, resourceMap, DeleteOptions{
+		Interval: 10 * time.Second,
+		Wait:     10 * time.Minute,
+	})
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestDeleteAzureResourcesOfType(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.VMScaleSetsClient.VMSSes["nodes"] = &compute.VirtualMachineScaleSet{
+		Name: to.Ptr("nodes"),
+		Tags: clusterTags,
+		Properties: &compute.VirtualMachineScaleSetProperties{
+			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+				NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{},
+			},
+		},
+	}
+	cloud.DisksClient.Disks["orphaned"] = &compute.Disk{
+		Name: to.Ptr("orphaned"),
+		Tags: clusterTags,
+	}
+	cloud.DisksClient.Disks["attached"] = &compute.Disk{
+		Name:      to.Ptr("attached"),
+		Tags:      clusterTags,
+		ManagedBy: to.Ptr(fmt.Sprintf("/subscriptions/sid/resourceGroups/%s/providers/Microsoft.Compute/virtualMachineScaleSets/nodes/virtualMachines/0", rgName)),
+	}
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+	ctx := context.Background()
+
+	// A disk attached to the VM Scale Set can only be deleted after it.
+	err := DeleteAzureResourcesOfType(ctx, cloud, clusterInfo, "Disk")
+	if err == nil || !strings.Contains(err.Error(), "VMScaleSet:nodes") {
+		t.Fatalf("expected an error deleting a disk attached to a VM Scale Set that is kept, but got %v", err)
+	}
+	if len(cloud.DisksClient.Disks) != 2 {
+		t.Errorf("expected no disk to be deleted, but got %d disks", len(cloud.DisksClient.Disks))
+	}
+
+	delete(cloud.DisksClient.Disks, "attached")
+	if err := DeleteAzureResourcesOfType(ctx, cloud, clusterInfo, "Disk"); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, ok := cloud.DisksClient.Disks["orphaned"]; ok {
+		t.Errorf("expected disk to be deleted")
+	}
+	if _, ok := cloud.VMScaleSetsClient.VMSSes["nodes"]; !ok {
+		t.Errorf("expected VM Scale Set to be kept")
+	}
+	if _, ok := cloud.ResourceGroupsClient.RGs[rgName]; !ok {
+		t.Errorf("expected resource group to be kept")
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"k8s.io/kops/pkg/resources"
+	azureresources "k8s.io/kops/pkg/resources/azure"
+	cloudazure "k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// ApplyAzureTeardownManifest deletes exactly the resources listed in a manifest
+// exported by ExportAzureTeardownManifest, possibly trimmed after review.
+func ApplyAzureTeardownManifest(cloud cloudazure.AzureCloud, clusterInfo resources.ClusterInfo, manifest []byte, options DeleteOptions) error {
+	resourceMap, err := azureresources.SelectAzureTeardownManifest(cloud, clusterInfo, manifest)
+	if err != nil {
+		return err
+	}
+	return DeleteResourcesWithOptions(cloud, resourceMap, options)
+}
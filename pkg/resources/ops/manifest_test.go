@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	azureresources "k8s.io/kops/pkg/resources/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+	"sigs.k8s.io/yaml"
+)
+
+func TestApplyAzureTeardownManifest(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: clusterTags,
+	}
+	cloud.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+	cloud.PublicIPAddressesClient.PubIPs["pip"] = &network.PublicIPAddress{
+		Name: to.Ptr("pip"),
+		Tags: clusterTags,
+	}
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+
+	b, err := azureresources.ExportAzureTeardownManifest(cloud, clusterInfo)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var m azureresources.TeardownManifest
+	if err := yaml.UnmarshalStrict(b, &m); err != nil {
+		t.Fatalf("unexpected error parsing manifest %s", err)
+	}
+	trim := func(dropped ...string) []byte {
+		mm := m
+		mm.Resources = nil
+		for _, r := range m.Resources {
+			keep := true
+			for _, name := range dropped {
+				if r.Name == name {
+					keep = false
+				}
+			}
+			if keep {
+				mm.Resources = append(mm.Resources, r)
+			}
+		}
+		b, err := yaml.Marshal(mm)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		return b
+	}
+	options := DeleteOptions{Count: 1}
+
+	// A manifest deleting the resource group while keeping the disk is rejected,
+	// as the disk would be deleted with the resource group.
+	if err := ApplyAzureTeardownManifest(cloud, clusterInfo, trim("disk"), options); err == nil {
+		t.Fatalf("expected an error applying a manifest that keeps a resource of a deleted resource group")
+	}
+	if len(cloud.PublicIPAddressesClient.PubIPs) != 1 || len(cloud.RouteTablesClient.RTs) != 1 {
+		t.Errorf("expected nothing to be deleted for a rejected manifest")
+	}
+
+	// Trim the manifest to keep the disk and the resource group.
+	trimmed := trim("disk", rgName)
+	if err := ApplyAzureTeardownManifest(cloud, clusterInfo, trimmed, options); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, ok := cloud.PublicIPAddressesClient.PubIPs["pip"]; ok {
+		t.Errorf("expected public IP address to be deleted")
+	}
+	if _, ok := cloud.RouteTablesClient.RTs["rt"]; ok {
+		t.Errorf("expected route table to be deleted")
+	}
+	if _, ok := cloud.DisksClient.Disks["disk"]; !ok {
+		t.Errorf("expected disk to be kept")
+	}
+	if _, ok := cloud.ResourceGroupsClient.RGs[rgName]; !ok {
+		t.Errorf("expected resource group to be kept")
+	}
+
+	// Applying the manifest again fails, as the resources are gone.
+	if err := ApplyAzureTeardownManifest(cloud, clusterInfo, trimmed, options); err == nil {
+		t.Errorf("expected an error applying a manifest with deleted resources")
+	}
+}
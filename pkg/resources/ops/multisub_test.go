@@ -0,0 +1,88 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	azureresources "k8s.io/kops/pkg/resources/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+// subscriptionCloud is a mock cloud of the given subscription.
+type subscriptionCloud struct {
+	*azuretasks.MockAzureCloud
+	subscriptionID string
+}
+
+func (c *subscriptionCloud) SubscriptionID() string {
+	return c.subscriptionID
+}
+
+func TestDeleteResourcesAzureMultiSub(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+
+	// The resource group and the disk have the same names in both subscriptions.
+	var clouds []*azuretasks.MockAzureCloud
+	var subscriptionClouds []azure.AzureCloud
+	for _, subscriptionID := range []string{"sub-a", "sub-b"} {
+		cloud := azuretasks.NewMockAzureCloud("eastus")
+		cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+			Name: to.Ptr(rgName),
+			Tags: clusterTags,
+		}
+		cloud.DisksClient.Disks["disk"] = &compute.Disk{
+			Name: to.Ptr("disk"),
+			Tags: clusterTags,
+		}
+		clouds = append(clouds, cloud)
+		subscriptionClouds = append(subscriptionClouds, &subscriptionCloud{MockAzureCloud: cloud, subscriptionID: subscriptionID})
+	}
+
+	resourceMap, err := azureresources.ListResourcesAzureMultiSub(subscriptionClouds, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(resourceMap) != 4 {
+		t.Fatalf("expected 4 resources, but got %v", resourceMap)
+	}
+
+	// Deleting a resource that is already deleted fails, so each must be
+	// deleted exactly once.
+	if err := DeleteResourcesWithOptions(clouds[0], resourceMap, DeleteOptions{Count: 1}); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	for i, cloud := range clouds {
+		if len(cloud.ResourceGroupsClient.RGs) != 0 || len(cloud.DisksClient.Disks) != 0 {
+			t.Errorf("expected the resources of subscription %d to be deleted, but got %v and %v", i, cloud.ResourceGroupsClient.RGs, cloud.DisksClient.Disks)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"k8s.io/kops/pkg/resources"
+	azureresources "k8s.io/kops/pkg/resources/azure"
+	cloudazure "k8s.io/kops/upup/pkg/fi/cloudup/azure"
+)
+
+// DeleteAllAzureWithReport lists and deletes all the resources of an Azure cluster,
+// writing a line to w for each deletion attempt as it completes, with the resource,
+// the outcome and the time taken. Once everything is deleted, the resources still
+// listed in the resource group are deleted again. It returns the failures as an
+// AzureTeardownError.
+func DeleteAllAzureWithReport(ctx context.Context, cloud cloudazure.AzureCloud, clusterInfo resources.ClusterInfo, w io.Writer) error {
+	resourceMap, err := azureresources.ListResourcesAzure(cloud, clusterInfo)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return DeleteResourcesWithOptions(cloud, resourceMap, DeleteOptions{
+		Interval: 10 * time.Second,
+		Wait:     10 * time.Minute,
+		Report:   w,
+		VerifyDeleted: func(deleted []*resources.Resource) ([]*resources.Resource, error) {
+			return azureresources.RemainingAzureResources(cloud, clusterInfo, deleted)
+		},
+		RequeueRemaining: true,
+	})
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestDeleteAllAzureWithReport(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	cloud := azuretasks.NewMockAzureCloud("eastus")
+	cloud.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	cloud.RouteTablesClient.RTs["rt"] = &network.RouteTable{
+		Name: to.Ptr("rt"),
+		Tags: clusterTags,
+	}
+	cloud.DisksClient.Disks["disk"] = &compute.Disk{
+		Name: to.Ptr("disk"),
+		Tags: clusterTags,
+	}
+
+	var report bytes.Buffer
+	err := DeleteAllAzureWithReport(context.Background(), cloud, resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}, &report)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, but got %q", report.String())
+	}
+	// The resource group is deleted after the resources in it.
+	if !strings.HasPrefix(lines[2], "ResourceGroup:rg\tdeleted\t") {
+		t.Errorf("expected the resource group to be reported last, but got %q", lines[2])
+	}
+	var reported []string
+	for _, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			t.Fatalf("expected resource, outcome and duration, but got %q", line)
+		}
+		if fields[1] != "deleted" {
+			t.Errorf("expected %s to be deleted, but got %q", fields[0], fields[1])
+		}
+		reported = append(reported, fields[0])
+	}
+	sort.Strings(reported)
+	expected := []string{"Disk:disk", "ResourceGroup:rg", "RouteTable:rt"}
+	if !reflect.DeepEqual(reported, expected) {
+		t.Errorf("expected %v to be reported, but got %v", expected, reported)
+	}
+}
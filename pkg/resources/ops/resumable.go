@@ -0,0 +1,129 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/kops/pkg/resources"
+	azureresources "k8s.io/kops/pkg/resources/azure"
+	"k8s.io/kops/upup/pkg/fi"
+	cloudazure "k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/utils/set"
+)
+
+// azureDeleteState is the progress of a resumable teardown of an Azure cluster,
+// as persisted to its state file.
+type azureDeleteState struct {
+	// Deleted are the keys of the resources deleted so far.
+	Deleted []string `json:"deleted"`
+}
+
+// DeleteAllAzureResumable lists the resources of an Azure cluster and deletes
+// them, recording each completed deletion in stateFile. When run again after an
+// interruption, the resources recorded there are skipped, even if Azure still
+// lists them because their deletion has not completed yet. The state file is
+// removed once every resource is deleted.
+func DeleteAllAzureResumable(ctx context.Context, cloud cloudazure.AzureCloud, clusterInfo resources.ClusterInfo, stateFile string) error {
+	state, err := readAzureDeleteState(stateFile)
+	if err != nil {
+		return err
+	}
+	deleted := set.New(state.Deleted...)
+
+	resourceMap, err := azureresources.ListResourcesAzureWithContext(ctx, cloud, clusterInfo)
+	if err != nil {
+		return err
+	}
+
+	var mutex sync.Mutex
+	for k, r := range resourceMap {
+		if deleted.Has(k) {
+			klog.V(2).Infof("%s was deleted by a previous run; skipping", k)
+			r.Done = true
+			continue
+		}
+		if r.Done || r.Shared || r.Deleter == nil {
+			continue
+		}
+		deleter := r.Deleter
+		r.Deleter = func(cloud fi.Cloud, r *resources.Resource) error {
+			if err := deleter(cloud, r); err != nil {
+				return err
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			deleted.Insert(k)
+			return writeAzureDeleteState(stateFile, deleted)
+		}
+	}
+
+	if err := DeleteResourcesWithOptions(cloud, resourceMap, DeleteOptions{
+		Interval: 10 * time.Second,
+		Wait:     10 * time.Minute,
+		Context:  ctx,
+	}); err != nil {
+		return err
+	}
+	if err := os.Remove(stateFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing teardown state file: %w", err)
+	}
+	return nil
+}
+
+// readAzureDeleteState reads the state file of a resumable teardown. A missing
+// file is an empty state.
+func readAzureDeleteState(stateFile string) (*azureDeleteState, error) {
+	state := &azureDeleteState{}
+	b, err := os.ReadFile(stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading teardown state file: %w", err)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("parsing teardown state file %q: %w", stateFile, err)
+	}
+	return state, nil
+}
+
+// writeAzureDeleteState writes the state file of a resumable teardown. It is
+// replaced atomically, so that an interruption never leaves it truncated.
+func writeAzureDeleteState(stateFile string, deleted set.Set[string]) error {
+	state := azureDeleteState{Deleted: deleted.SortedList()}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := stateFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("writing teardown state file: %w", err)
+	}
+	if err := os.Rename(tmp, stateFile); err != nil {
+		return fmt.Errorf("writing teardown state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ops
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"k8s.io/kops/pkg/resources"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azure"
+	"k8s.io/kops/upup/pkg/fi/cloudup/azuretasks"
+)
+
+func TestDeleteAllAzureResumable(t *testing.T) {
+	const (
+		clusterName = "cluster"
+		rgName      = "rg"
+	)
+	clusterTags := map[string]*string{
+		azure.TagClusterName: to.Ptr(clusterName),
+	}
+	mock := azuretasks.NewMockAzureCloud("eastus")
+	mock.ResourceGroupsClient.RGs[rgName] = &armresources.ResourceGroup{
+		Name: to.Ptr(rgName),
+		Tags: clusterTags,
+	}
+	mock.DisksClient.Disks["etcd"] = &compute.Disk{
+		Name: to.Ptr("etcd"),
+		Tags: clusterTags,
+	}
+	mock.VirtualNetworksClient.VNets["vnet"] = &network.VirtualNetwork{
+		Name: to.Ptr("vnet"),
+		Tags: clusterTags,
+	}
+	cloud := &asyncDisksCloud{MockAzureCloud: mock}
+	clusterInfo := resources.ClusterInfo{
+		Name:                   clusterName,
+		AzureResourceGroupName: rgName,
+	}
+	stateFile := filepath.Join(t.TempDir(), "teardown.json")
+
+	// The teardown is interrupted while deleting the disk and the virtual
+	// network, before deleting the resource group.
+	ctx, cancel := context.WithCancel(context.Background())
+	cloud.onDelete = cancel
+	if err := DeleteAllAzureResumable(ctx, cloud, clusterInfo, stateFile); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the teardown to be interrupted, but got %v", err)
+	}
+	if _, ok := mock.ResourceGroupsClient.RGs[rgName]; !ok {
+		t.Fatalf("expected the resource group not to be deleted yet")
+	}
+	state, err := readAzureDeleteState(stateFile)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	expected := []string{"Disk:etcd", "VirtualNetwork:vnet"}
+	if !reflect.DeepEqual(state.Deleted, expected) {
+		t.Errorf("expected %v to be recorded, but got %v", expected, state.Deleted)
+	}
+
+	// The disk is still listed, as its deletion has not completed, but it is
+	// not deleted again when the teardown is resumed.
+	cloud.onDelete = nil
+	if err := DeleteAllAzureResumable(context.Background(), cloud, clusterInfo, stateFile); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if cloud.diskDeletes != 1 {
+		t.Errorf("expected the disk to be deleted once, but got %d deletions", cloud.diskDeletes)
+	}
+	if _, ok := mock.ResourceGroupsClient.RGs[rgName]; ok {
+		t.Errorf("expected the resource group to be deleted")
+	}
+	if _, err := os.Stat(stateFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the state file to be removed, but got %v", err)
+	}
+}
+
+// asyncDisksCloud is a mock cloud whose disk deletions are accepted but not
+// completed, so that the disks are still listed afterwards.
+type asyncDisksCloud struct {
+	*azuretasks.MockAzureCloud
+	// onDelete, if set, is called when a disk is deleted.
+	onDelete    func()
+	diskDeletes int
+}
+
+func (c *asyncDisksCloud) Disk() azure.DisksClient {
+	return &asyncDisksClient{DisksClient: c.MockAzureCloud.Disk(), cloud: c}
+}
+
+type asyncDisksClient struct {
+	azure.DisksClient
+	cloud *asyncDisksCloud
+}
+
+func (c *asyncDisksClient) Delete(ctx context.Context, resourceGroupName, diskName string) error {
+	c.cloud.diskDeletes++
+	if c.cloud.onDelete != nil {
+		c.cloud.onDelete()
+	}
+	return nil
+}
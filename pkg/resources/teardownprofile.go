@@ -0,0 +1,85 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"time"
+)
+
+// AzureTeardownProfile is a named set of tuning parameters for deleting an
+// Azure cluster, so that they do not have to be set one by one.
+type AzureTeardownProfile string
+
+const (
+	// AzureTeardownProfileFast lists and deletes as much as possible at once, and
+	// gives up early on resources that cannot be deleted.
+	AzureTeardownProfileFast AzureTeardownProfile = "fast"
+	// AzureTeardownProfileSafe paces the deletions and retries for longer.
+	AzureTeardownProfileSafe AzureTeardownProfile = "safe"
+	// AzureTeardownProfileThrottleAverse keeps the request rate low, for
+	// subscriptions close to their Azure Resource Manager limits.
+	AzureTeardownProfileThrottleAverse AzureTeardownProfile = "throttle-averse"
+)
+
+// AzureTeardownSettings are the tuning parameters of an Azure teardown. Zero
+// values leave the default in place.
+type AzureTeardownSettings struct {
+	// ListConcurrency is the maximum number of kinds of resources listed at the
+	// same time, see ClusterInfo.AzureListConcurrency.
+	ListConcurrency int
+	// CallTimeout is the time after which each call to Azure is given up, see
+	// ClusterInfo.AzureCallTimeout.
+	CallTimeout time.Duration
+	// DeleteInterval is the minimum time between issuing two deletes.
+	DeleteInterval time.Duration
+	// Count is the number of passes without progress after which deletion gives up.
+	Count int
+}
+
+var azureTeardownProfiles = map[AzureTeardownProfile]AzureTeardownSettings{
+	AzureTeardownProfileFast: {
+		ListConcurrency: 16,
+		CallTimeout:     2 * time.Minute,
+		Count:           3,
+	},
+	AzureTeardownProfileSafe: {
+		ListConcurrency: 4,
+		CallTimeout:     5 * time.Minute,
+		DeleteInterval:  time.Second,
+		Count:           10,
+	},
+	AzureTeardownProfileThrottleAverse: {
+		ListConcurrency: 2,
+		CallTimeout:     10 * time.Minute,
+		DeleteInterval:  5 * time.Second,
+		Count:           20,
+	},
+}
+
+// Settings returns the tuning parameters of the profile. The empty profile has
+// none set.
+func (p AzureTeardownProfile) Settings() (AzureTeardownSettings, error) {
+	if p == "" {
+		return AzureTeardownSettings{}, nil
+	}
+	settings, ok := azureTeardownProfiles[p]
+	if !ok {
+		return AzureTeardownSettings{}, fmt.Errorf("unknown Azure teardown profile %q", p)
+	}
+	return settings, nil
+}
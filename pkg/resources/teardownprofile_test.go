@@ -0,0 +1,74 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAzureTeardownProfileSettings(t *testing.T) {
+	testCases := []struct {
+		profile  AzureTeardownProfile
+		expected AzureTeardownSettings
+	}{
+		{
+			profile: "",
+		},
+		{
+			profile: AzureTeardownProfileFast,
+			expected: AzureTeardownSettings{
+				ListConcurrency: 16,
+				CallTimeout:     2 * time.Minute,
+				Count:           3,
+			},
+		},
+		{
+			profile: AzureTeardownProfileSafe,
+			expected: AzureTeardownSettings{
+				ListConcurrency: 4,
+				CallTimeout:     5 * time.Minute,
+				DeleteInterval:  time.Second,
+				Count:           10,
+			},
+		},
+		{
+			profile: AzureTeardownProfileThrottleAverse,
+			expected: AzureTeardownSettings{
+				ListConcurrency: 2,
+				CallTimeout:     10 * time.Minute,
+				DeleteInterval:  5 * time.Second,
+				Count:           20,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.profile), func(t *testing.T) {
+			actual, err := tc.profile.Settings()
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %+v, but got %+v", tc.expected, actual)
+			}
+		})
+	}
+
+	if _, err := AzureTeardownProfile("reckless").Settings(); err == nil {
+		t.Errorf("expected an error for an unknown profile")
+	}
+}
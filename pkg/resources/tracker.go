@@ -32,6 +32,10 @@ type Resource struct {
 	Blocked []string
 	Done    bool
 
+	// Async is a hint that deleting this resource is a long-running operation,
+	// rather than one that completes as soon as the deletion is requested.
+	Async bool
+
 	Deleter      func(cloud fi.Cloud, tracker *Resource) error
 	GroupKey     string
 	GroupDeleter func(cloud fi.Cloud, trackers []*Resource) error
@@ -39,5 +43,25 @@ type Resource struct {
 	// Dumper populates the dump with any information from the resource
 	Dumper func(op *DumpOperation, r *Resource) error
 
+	// Metadata holds cloud-specific details about the resource, for display only.
+	Metadata map[string]string
+
+	// Tags holds the tags of the resource in the cloud, if the provider sets them.
+	Tags map[string]string
+
+	// GroupName optionally names the group the resource is in, like its Azure
+	// resource group, to group it with the others of its type in output.
+	GroupName string
+
 	Obj interface{}
 }
+
+// DisplayGroup returns the key the resource is grouped by in output: its type,
+// within its GroupName if it has one. It is not the GroupKey used to delete
+// resources together.
+func (r *Resource) DisplayGroup() string {
+	if r.GroupName == "" {
+		return r.Type
+	}
+	return r.GroupName + "/" + r.Type
+}
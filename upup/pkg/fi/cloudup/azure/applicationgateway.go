@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+)
+
+// ApplicationGatewaysClient is a client for managing Application Gateways.
+type ApplicationGatewaysClient interface {
+	List(ctx context.Context, resourceGroupName string) ([]*network.ApplicationGateway, error)
+	Delete(ctx context.Context, resourceGroupName, applicationGatewayName string) error
+}
+
+type applicationGatewaysClientImpl struct {
+	c *network.ApplicationGatewaysClient
+}
+
+var _ ApplicationGatewaysClient = &applicationGatewaysClientImpl{}
+
+func (c *applicationGatewaysClientImpl) List(ctx context.Context, resourceGroupName string) ([]*network.ApplicationGateway, error) {
+	if resourceGroupName == "" {
+		return nil, nil
+	}
+
+	var l []*network.ApplicationGateway
+	pager := c.c.NewListPager(resourceGroupName, nil)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			var respErr *azcore.ResponseError
+			if errors.As(err, &respErr) && respErr.ErrorCode == "ResourceGroupNotFound" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("listing application gateways: %w", err)
+		}
+		l = append(l, resp.Value...)
+	}
+	return l, nil
+}
+
+func (c *applicationGatewaysClientImpl) Delete(ctx context.Context, resourceGroupName, applicationGatewayName string) error {
+	future, err := c.c.BeginDelete(ctx, resourceGroupName, applicationGatewayName, nil)
+	if err != nil {
+		return fmt.Errorf("deleting application gateway: %w", err)
+	}
+	if _, err := future.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for application gateway deletion completion: %w", err)
+	}
+	return nil
+}
+
+func newApplicationGatewaysClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*applicationGatewaysClientImpl, error) {
+	c, err := network.NewApplicationGatewaysClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating application gateways client: %w", err)
+	}
+	return &applicationGatewaysClientImpl{
+		c: c,
+	}, nil
+}
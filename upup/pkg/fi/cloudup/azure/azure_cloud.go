@@ -39,6 +39,9 @@ const (
 	TagRoleControlPlane      = "control_plane"
 	TagRoleMaster            = "master"
 	TagNameEtcdClusterPrefix = "k8s.io_etcd_"
+	// TagNameClusterOwnershipPrefix is followed by the cluster name, and set to
+	// "owned" or "shared", like the kubernetes.io/cluster/<name> tag on other clouds.
+	TagNameClusterOwnershipPrefix = "kubernetes.io_cluster_"
 )
 
 // AzureCloud provides clients to make API calls to Azure.
@@ -56,12 +59,23 @@ type AzureCloud interface {
 	ApplicationSecurityGroup() ApplicationSecurityGroupsClient
 	VMScaleSet() VMScaleSetsClient
 	VMScaleSetVM() VMScaleSetVMsClient
+	VirtualMachine() VirtualMachinesClient
 	Disk() DisksClient
 	RoleAssignment() RoleAssignmentsClient
 	NetworkInterface() NetworkInterfacesClient
 	LoadBalancer() LoadBalancersClient
 	PublicIPAddress() PublicIPAddressesClient
 	NatGateway() NatGatewaysClient
+	Snapshot() SnapshotsClient
+	DiskEncryptionSet() DiskEncryptionSetsClient
+	Resource() ResourcesClient
+	StorageAccount() StorageAccountsClient
+	PrivateEndpoint() PrivateEndpointsClient
+	BackupItem() BackupItemsClient
+	ManagedIdentity() ManagedIdentitiesClient
+	KeyVault() KeyVaultsClient
+	PrivateDNSZone() PrivateDNSZonesClient
+	ApplicationGateway() ApplicationGatewaysClient
 }
 
 type azureCloudImplementation struct {
@@ -77,6 +91,7 @@ type azureCloudImplementation struct {
 	routeTablesClient               RouteTablesClient
 	vmscaleSetsClient               VMScaleSetsClient
 	vmscaleSetVMsClient             VMScaleSetVMsClient
+	virtualMachinesClient           VirtualMachinesClient
 	disksClient                     DisksClient
 	roleAssignmentsClient           RoleAssignmentsClient
 	networkInterfacesClient         NetworkInterfacesClient
@@ -84,6 +99,15 @@ type azureCloudImplementation struct {
 	publicIPAddressesClient         PublicIPAddressesClient
 	natGatewaysClient               NatGatewaysClient
 	storageAccountsClient           StorageAccountsClient
+	snapshotsClient                 SnapshotsClient
+	diskEncryptionSetsClient        DiskEncryptionSetsClient
+	resourcesClient                 ResourcesClient
+	privateEndpointsClient          PrivateEndpointsClient
+	backupItemsClient               BackupItemsClient
+	managedIdentitiesClient         ManagedIdentitiesClient
+	keyVaultsClient                 KeyVaultsClient
+	privateDNSZonesClient           PrivateDNSZonesClient
+	applicationGatewaysClient       ApplicationGatewaysClient
 }
 
 var _ fi.Cloud = &azureCloudImplementation{}
@@ -126,6 +150,9 @@ func NewAzureCloud(subscriptionID, resourceGroupName, location string, tags map[
 	if azureCloudImpl.vmscaleSetVMsClient, err = newVMScaleSetVMsClientImpl(subscriptionID, cred); err != nil {
 		return nil, err
 	}
+	if azureCloudImpl.virtualMachinesClient, err = newVirtualMachinesClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
 	if azureCloudImpl.disksClient, err = newDisksClientImpl(subscriptionID, cred); err != nil {
 		return nil, err
 	}
@@ -147,6 +174,33 @@ func NewAzureCloud(subscriptionID, resourceGroupName, location string, tags map[
 	if azureCloudImpl.storageAccountsClient, err = newStorageAccountsClientImpl(subscriptionID, cred); err != nil {
 		return nil, err
 	}
+	if azureCloudImpl.snapshotsClient, err = newSnapshotsClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
+	if azureCloudImpl.diskEncryptionSetsClient, err = newDiskEncryptionSetsClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
+	if azureCloudImpl.resourcesClient, err = newResourcesClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
+	if azureCloudImpl.privateEndpointsClient, err = newPrivateEndpointsClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
+	if azureCloudImpl.backupItemsClient, err = newBackupItemsClientImpl(cred); err != nil {
+		return nil, err
+	}
+	if azureCloudImpl.managedIdentitiesClient, err = newManagedIdentitiesClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
+	if azureCloudImpl.keyVaultsClient, err = newKeyVaultsClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
+	if azureCloudImpl.privateDNSZonesClient, err = newPrivateDNSZonesClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
+	if azureCloudImpl.applicationGatewaysClient, err = newApplicationGatewaysClientImpl(subscriptionID, cred); err != nil {
+		return nil, err
+	}
 
 	return azureCloudImpl, nil
 }
@@ -375,6 +429,10 @@ func (c *azureCloudImplementation) VMScaleSetVM() VMScaleSetVMsClient {
 	return c.vmscaleSetVMsClient
 }
 
+func (c *azureCloudImplementation) VirtualMachine() VirtualMachinesClient {
+	return c.virtualMachinesClient
+}
+
 func (c *azureCloudImplementation) Disk() DisksClient {
 	return c.disksClient
 }
@@ -398,3 +456,43 @@ func (c *azureCloudImplementation) PublicIPAddress() PublicIPAddressesClient {
 func (c *azureCloudImplementation) NatGateway() NatGatewaysClient {
 	return c.natGatewaysClient
 }
+
+func (c *azureCloudImplementation) Snapshot() SnapshotsClient {
+	return c.snapshotsClient
+}
+
+func (c *azureCloudImplementation) DiskEncryptionSet() DiskEncryptionSetsClient {
+	return c.diskEncryptionSetsClient
+}
+
+func (c *azureCloudImplementation) Resource() ResourcesClient {
+	return c.resourcesClient
+}
+
+func (c *azureCloudImplementation) StorageAccount() StorageAccountsClient {
+	return c.storageAccountsClient
+}
+
+func (c *azureCloudImplementation) PrivateEndpoint() PrivateEndpointsClient {
+	return c.privateEndpointsClient
+}
+
+func (c *azureCloudImplementation) BackupItem() BackupItemsClient {
+	return c.backupItemsClient
+}
+
+func (c *azureCloudImplementation) ManagedIdentity() ManagedIdentitiesClient {
+	return c.managedIdentitiesClient
+}
+
+func (c *azureCloudImplementation) KeyVault() KeyVaultsClient {
+	return c.keyVaultsClient
+}
+
+func (c *azureCloudImplementation) PrivateDNSZone() PrivateDNSZonesClient {
+	return c.privateDNSZonesClient
+}
+
+func (c *azureCloudImplementation) ApplicationGateway() ApplicationGatewaysClient {
+	return c.applicationGatewaysClient
+}
@@ -184,3 +184,115 @@ func ParsePublicIPAddressID(s string) (*PublicIPAddressID, error) {
 		PublicIPAddressName: l[8],
 	}, nil
 }
+
+// DiskID contains the resource ID/names required to construct a disk ID.
+type DiskID struct {
+	SubscriptionID    string
+	ResourceGroupName string
+	DiskName          string
+}
+
+// String returns the disk ID in the path format.
+func (s *DiskID) String() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/disks/%s",
+		s.SubscriptionID,
+		s.ResourceGroupName,
+		s.DiskName)
+}
+
+// ParseDiskID parses a given disk ID string and returns a DiskID.
+func ParseDiskID(s string) (*DiskID, error) {
+	l := strings.Split(s, "/")
+	if len(l) != 9 {
+		return nil, fmt.Errorf("malformed format of disk ID: %s, %d", s, len(l))
+	}
+	return &DiskID{
+		SubscriptionID:    l[2],
+		ResourceGroupName: l[4],
+		DiskName:          l[8],
+	}, nil
+}
+
+// ManagedIdentityID contains the resource ID/names required to construct a user-assigned managed identity ID.
+type ManagedIdentityID struct {
+	SubscriptionID      string
+	ResourceGroupName   string
+	ManagedIdentityName string
+}
+
+// String returns the managed identity ID in the path format.
+func (s *ManagedIdentityID) String() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ManagedIdentity/userAssignedIdentities/%s",
+		s.SubscriptionID,
+		s.ResourceGroupName,
+		s.ManagedIdentityName)
+}
+
+// ParseManagedIdentityID parses a given user-assigned managed identity ID string and returns a ManagedIdentityID.
+func ParseManagedIdentityID(s string) (*ManagedIdentityID, error) {
+	l := strings.Split(s, "/")
+	if len(l) != 9 {
+		return nil, fmt.Errorf("malformed format of managed identity ID: %s, %d", s, len(l))
+	}
+	return &ManagedIdentityID{
+		SubscriptionID:      l[2],
+		ResourceGroupName:   l[4],
+		ManagedIdentityName: l[8],
+	}, nil
+}
+
+// RouteTableID contains the resource ID/names required to construct a route table ID.
+type RouteTableID struct {
+	SubscriptionID    string
+	ResourceGroupName string
+	RouteTableName    string
+}
+
+// String returns the route table ID in the path format.
+func (s *RouteTableID) String() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/routeTables/%s",
+		s.SubscriptionID,
+		s.ResourceGroupName,
+		s.RouteTableName)
+}
+
+// ParseRouteTableID parses a given route table ID string and returns a RouteTableID.
+func ParseRouteTableID(s string) (*RouteTableID, error) {
+	l := strings.Split(s, "/")
+	if len(l) != 9 {
+		return nil, fmt.Errorf("malformed format of route table ID: %s, %d", s, len(l))
+	}
+	return &RouteTableID{
+		SubscriptionID:    l[2],
+		ResourceGroupName: l[4],
+		RouteTableName:    l[8],
+	}, nil
+}
+
+// ApplicationGatewayID contains the resource ID/names required to construct an application gateway ID.
+type ApplicationGatewayID struct {
+	SubscriptionID         string
+	ResourceGroupName      string
+	ApplicationGatewayName string
+}
+
+// String returns the application gateway ID in the path format.
+func (s *ApplicationGatewayID) String() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/applicationGateways/%s",
+		s.SubscriptionID,
+		s.ResourceGroupName,
+		s.ApplicationGatewayName)
+}
+
+// ParseApplicationGatewayID parses a given application gateway ID string and returns an ApplicationGatewayID.
+func ParseApplicationGatewayID(s string) (*ApplicationGatewayID, error) {
+	l := strings.Split(s, "/")
+	if len(l) != 9 {
+		return nil, fmt.Errorf("malformed format of application gateway ID: %s, %d", s, len(l))
+	}
+	return &ApplicationGatewayID{
+		SubscriptionID:         l[2],
+		ResourceGroupName:      l[4],
+		ApplicationGatewayName: l[8],
+	}, nil
+}
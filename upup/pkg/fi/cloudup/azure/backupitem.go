@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// backupAPIVersion is the API version of the Recovery Services backup operations.
+const backupAPIVersion = "2023-04-01"
+
+// BackupItem is an item protected by Azure Backup in a Recovery Services vault.
+type BackupItem struct {
+	ID   *string `json:"id,omitempty"`
+	Name *string `json:"name,omitempty"`
+}
+
+// BackupItemsClient is a client for managing the items protected in Recovery Services vaults.
+type BackupItemsClient interface {
+	List(ctx context.Context, vaultID string) ([]*BackupItem, error)
+	Delete(ctx context.Context, itemID string) error
+}
+
+// backupItemsClientImpl calls the Recovery Services backup API directly, as the
+// Azure SDK for it is not a dependency of kops.
+type backupItemsClientImpl struct {
+	c *arm.Client
+}
+
+var _ BackupItemsClient = &backupItemsClientImpl{}
+
+func (c *backupItemsClientImpl) List(ctx context.Context, vaultID string) ([]*BackupItem, error) {
+	var l []*BackupItem
+	next := runtime.JoinPaths(c.c.Endpoint(), vaultID, "backupProtectedItems") + "?api-version=" + backupAPIVersion
+	for next != "" {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, next)
+		if err != nil {
+			return nil, fmt.Errorf("listing backup items: %w", err)
+		}
+		req.Raw().Header["Accept"] = []string{"application/json"}
+		resp, err := c.c.Pipeline().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("listing backup items: %w", err)
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK) {
+			return nil, fmt.Errorf("listing backup items: %w", runtime.NewResponseError(resp))
+		}
+		var page struct {
+			Value    []*BackupItem `json:"value"`
+			NextLink *string       `json:"nextLink"`
+		}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return nil, fmt.Errorf("listing backup items: %w", err)
+		}
+		l = append(l, page.Value...)
+		next = ""
+		if page.NextLink != nil {
+			next = *page.NextLink
+		}
+	}
+	return l, nil
+}
+
+func (c *backupItemsClientImpl) Delete(ctx context.Context, itemID string) error {
+	req, err := runtime.NewRequest(ctx, http.MethodDelete, runtime.JoinPaths(c.c.Endpoint(), itemID)+"?api-version="+backupAPIVersion)
+	if err != nil {
+		return fmt.Errorf("deleting backup item: %w", err)
+	}
+	req.Raw().Header["Accept"] = []string{"application/json"}
+	resp, err := c.c.Pipeline().Do(req)
+	if err != nil {
+		return fmt.Errorf("deleting backup item: %w", err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusAccepted, http.StatusNoContent) {
+		return fmt.Errorf("deleting backup item: %w", runtime.NewResponseError(resp))
+	}
+	poller, err := runtime.NewPoller[struct{}](resp, c.c.Pipeline(), nil)
+	if err != nil {
+		return fmt.Errorf("deleting backup item: %w", err)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for backup item deletion completion: %w", err)
+	}
+	return nil
+}
+
+func newBackupItemsClientImpl(cred *azidentity.DefaultAzureCredential) (*backupItemsClientImpl, error) {
+	c, err := arm.NewClient("k8s.io/kops", "", cred, &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Telemetry: policy.TelemetryOptions{Disabled: true},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating backup items client: %w", err)
+	}
+	return &backupItemsClientImpl{
+		c: c,
+	}, nil
+}
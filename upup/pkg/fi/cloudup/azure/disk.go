@@ -18,8 +18,11 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 )
@@ -28,6 +31,8 @@ import (
 type DisksClient interface {
 	CreateOrUpdate(ctx context.Context, resourceGroupName, diskName string, parameters compute.Disk) (*compute.Disk, error)
 	List(ctx context.Context, resourceGroupName string) ([]*compute.Disk, error)
+	// Get returns the disk, or nil if it does not exist.
+	Get(ctx context.Context, resourceGroupName, diskName string) (*compute.Disk, error)
 	Delete(ctx context.Context, resourceGroupName, diskname string) error
 }
 
@@ -62,6 +67,18 @@ func (c *disksClientImpl) List(ctx context.Context, resourceGroupName string) ([
 	return l, nil
 }
 
+func (c *disksClientImpl) Get(ctx context.Context, resourceGroupName, diskName string) (*compute.Disk, error) {
+	resp, err := c.c.Get(ctx, resourceGroupName, diskName, nil)
+	if err != nil {
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting disk: %w", err)
+	}
+	return &resp.Disk, nil
+}
+
 func (c *disksClientImpl) Delete(ctx context.Context, resourceGroupName, diskName string) error {
 	future, err := c.c.BeginDelete(ctx, resourceGroupName, diskName, nil)
 	if err != nil {
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+)
+
+// DiskEncryptionSetsClient is a client for managing disk encryption sets.
+type DiskEncryptionSetsClient interface {
+	List(ctx context.Context, resourceGroupName string) ([]*compute.DiskEncryptionSet, error)
+	Delete(ctx context.Context, resourceGroupName, diskEncryptionSetName string) error
+}
+
+type diskEncryptionSetsClientImpl struct {
+	c *compute.DiskEncryptionSetsClient
+}
+
+var _ DiskEncryptionSetsClient = &diskEncryptionSetsClientImpl{}
+
+func (c *diskEncryptionSetsClientImpl) List(ctx context.Context, resourceGroupName string) ([]*compute.DiskEncryptionSet, error) {
+	if resourceGroupName == "" {
+		return nil, nil
+	}
+
+	var l []*compute.DiskEncryptionSet
+	pager := c.c.NewListByResourceGroupPager(resourceGroupName, nil)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			var respErr *azcore.ResponseError
+			if errors.As(err, &respErr) && respErr.ErrorCode == "ResourceGroupNotFound" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("listing disk encryption sets: %w", err)
+		}
+		l = append(l, resp.Value...)
+	}
+	return l, nil
+}
+
+func (c *diskEncryptionSetsClientImpl) Delete(ctx context.Context, resourceGroupName, diskEncryptionSetName string) error {
+	future, err := c.c.BeginDelete(ctx, resourceGroupName, diskEncryptionSetName, nil)
+	if err != nil {
+		return fmt.Errorf("deleting disk encryption set: %w", err)
+	}
+	if _, err := future.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for disk encryption set deletion completion: %w", err)
+	}
+	return nil
+}
+
+func newDiskEncryptionSetsClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*diskEncryptionSetsClientImpl, error) {
+	c, err := compute.NewDiskEncryptionSetsClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating disk encryption sets client: %w", err)
+	}
+	return &diskEncryptionSetsClientImpl{
+		c: c,
+	}, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// keyVaultAPIVersion is the API version of the key vault operations.
+const keyVaultAPIVersion = "2022-07-01"
+
+// KeyVaultsClient is a client for managing key vaults. Key vaults are listed
+// and deleted with the ResourcesClient.
+type KeyVaultsClient interface {
+	// PurgeDeleted permanently deletes a soft-deleted key vault, so that its
+	// name can be used again.
+	PurgeDeleted(ctx context.Context, location, vaultName string) error
+}
+
+// keyVaultsClientImpl calls the key vault API directly, as the Azure SDK for
+// it is not a dependency of kops.
+type keyVaultsClientImpl struct {
+	subscriptionID string
+	c              *arm.Client
+}
+
+var _ KeyVaultsClient = &keyVaultsClientImpl{}
+
+func (c *keyVaultsClientImpl) PurgeDeleted(ctx context.Context, location, vaultName string) error {
+	path := runtime.JoinPaths(c.c.Endpoint(), "subscriptions", c.subscriptionID, "providers/Microsoft.KeyVault/locations", location, "deletedVaults", vaultName, "purge")
+	req, err := runtime.NewRequest(ctx, http.MethodPost, path+"?api-version="+keyVaultAPIVersion)
+	if err != nil {
+		return fmt.Errorf("purging deleted key vault: %w", err)
+	}
+	req.Raw().Header["Accept"] = []string{"application/json"}
+	resp, err := c.c.Pipeline().Do(req)
+	if err != nil {
+		return fmt.Errorf("purging deleted key vault: %w", err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusAccepted) {
+		return fmt.Errorf("purging deleted key vault: %w", runtime.NewResponseError(resp))
+	}
+	poller, err := runtime.NewPoller[struct{}](resp, c.c.Pipeline(), nil)
+	if err != nil {
+		return fmt.Errorf("purging deleted key vault: %w", err)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for deleted key vault purge completion: %w", err)
+	}
+	return nil
+}
+
+func newKeyVaultsClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*keyVaultsClientImpl, error) {
+	c, err := arm.NewClient("k8s.io/kops", "", cred, &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Telemetry: policy.TelemetryOptions{Disabled: true},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating key vaults client: %w", err)
+	}
+	return &keyVaultsClientImpl{
+		subscriptionID: subscriptionID,
+		c:              c,
+	}, nil
+}
@@ -27,6 +27,15 @@ import (
 	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 )
 
+const (
+	// LoadBalancingRuleKubeAPIServer is the name of the load balancing rule kops
+	// creates for the Kubernetes API server.
+	LoadBalancingRuleKubeAPIServer = "TCP-443"
+	// LoadBalancingRuleKopsController is the name of the load balancing rule kops
+	// creates for kops-controller.
+	LoadBalancingRuleKopsController = "TCP-3988"
+)
+
 // LoadBalancersClient is a client for managing load balancers.
 type LoadBalancersClient interface {
 	CreateOrUpdate(ctx context.Context, resourceGroupName, loadBalancerName string, parameters network.LoadBalancer) (*network.LoadBalancer, error)
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// managedIdentityAPIVersion is the API version of the managed identity operations.
+const managedIdentityAPIVersion = "2023-01-31"
+
+// ManagedIdentity is a user-assigned managed identity.
+type ManagedIdentity struct {
+	ID   *string            `json:"id,omitempty"`
+	Name *string            `json:"name,omitempty"`
+	Tags map[string]*string `json:"tags,omitempty"`
+}
+
+// ManagedIdentitiesClient is a client for managing user-assigned managed identities.
+type ManagedIdentitiesClient interface {
+	List(ctx context.Context, resourceGroupName string) ([]*ManagedIdentity, error)
+	Delete(ctx context.Context, resourceGroupName, identityName string) error
+}
+
+// managedIdentitiesClientImpl calls the managed identity API directly, as the
+// Azure SDK for it is not a dependency of kops.
+type managedIdentitiesClientImpl struct {
+	subscriptionID string
+	c              *arm.Client
+}
+
+var _ ManagedIdentitiesClient = &managedIdentitiesClientImpl{}
+
+func (c *managedIdentitiesClientImpl) resourceGroupPath(resourceGroupName string) string {
+	return runtime.JoinPaths(c.c.Endpoint(), "subscriptions", c.subscriptionID, "resourceGroups", resourceGroupName, "providers/Microsoft.ManagedIdentity/userAssignedIdentities")
+}
+
+func (c *managedIdentitiesClientImpl) List(ctx context.Context, resourceGroupName string) ([]*ManagedIdentity, error) {
+	var l []*ManagedIdentity
+	next := c.resourceGroupPath(resourceGroupName) + "?api-version=" + managedIdentityAPIVersion
+	for next != "" {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, next)
+		if err != nil {
+			return nil, fmt.Errorf("listing managed identities: %w", err)
+		}
+		req.Raw().Header["Accept"] = []string{"application/json"}
+		resp, err := c.c.Pipeline().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("listing managed identities: %w", err)
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK) {
+			return nil, fmt.Errorf("listing managed identities: %w", runtime.NewResponseError(resp))
+		}
+		var page struct {
+			Value    []*ManagedIdentity `json:"value"`
+			NextLink *string            `json:"nextLink"`
+		}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return nil, fmt.Errorf("listing managed identities: %w", err)
+		}
+		l = append(l, page.Value...)
+		next = ""
+		if page.NextLink != nil {
+			next = *page.NextLink
+		}
+	}
+	return l, nil
+}
+
+func (c *managedIdentitiesClientImpl) Delete(ctx context.Context, resourceGroupName, identityName string) error {
+	req, err := runtime.NewRequest(ctx, http.MethodDelete, runtime.JoinPaths(c.resourceGroupPath(resourceGroupName), identityName)+"?api-version="+managedIdentityAPIVersion)
+	if err != nil {
+		return fmt.Errorf("deleting managed identity: %w", err)
+	}
+	req.Raw().Header["Accept"] = []string{"application/json"}
+	resp, err := c.c.Pipeline().Do(req)
+	if err != nil {
+		return fmt.Errorf("deleting managed identity: %w", err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusNoContent) {
+		return fmt.Errorf("deleting managed identity: %w", runtime.NewResponseError(resp))
+	}
+	return nil
+}
+
+func newManagedIdentitiesClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*managedIdentitiesClientImpl, error) {
+	c, err := arm.NewClient("k8s.io/kops", "", cred, &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Telemetry: policy.TelemetryOptions{Disabled: true},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating managed identities client: %w", err)
+	}
+	return &managedIdentitiesClientImpl{
+		subscriptionID: subscriptionID,
+		c:              c,
+	}, nil
+}
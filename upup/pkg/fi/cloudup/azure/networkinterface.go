@@ -27,6 +27,10 @@ import (
 // NetworkInterfacesClient is a client for managing Network Interfaces.
 type NetworkInterfacesClient interface {
 	ListScaleSetsNetworkInterfaces(ctx context.Context, resourceGroupName, vmssName string) ([]*network.Interface, error)
+	// List returns the standalone network interfaces of the resource group, which
+	// excludes those of the instances of VM Scale Sets in Uniform orchestration mode.
+	List(ctx context.Context, resourceGroupName string) ([]*network.Interface, error)
+	Delete(ctx context.Context, resourceGroupName, networkInterfaceName string) error
 }
 
 type networkInterfacesClientImpl struct {
@@ -48,6 +52,30 @@ func (c *networkInterfacesClientImpl) ListScaleSetsNetworkInterfaces(ctx context
 	return l, nil
 }
 
+func (c *networkInterfacesClientImpl) List(ctx context.Context, resourceGroupName string) ([]*network.Interface, error) {
+	var l []*network.Interface
+	pager := c.c.NewListPager(resourceGroupName, nil)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing network interfaces: %w", err)
+		}
+		l = append(l, resp.Value...)
+	}
+	return l, nil
+}
+
+func (c *networkInterfacesClientImpl) Delete(ctx context.Context, resourceGroupName, networkInterfaceName string) error {
+	future, err := c.c.BeginDelete(ctx, resourceGroupName, networkInterfaceName, nil)
+	if err != nil {
+		return fmt.Errorf("deleting network interface: %w", err)
+	}
+	if _, err := future.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for network interface deletion completion: %w", err)
+	}
+	return nil
+}
+
 func newNetworkInterfacesClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*networkInterfacesClientImpl, error) {
 	c, err := network.NewInterfacesClient(subscriptionID, cred, nil)
 	if err != nil {
@@ -0,0 +1,188 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// privateDNSAPIVersion is the API version of the private DNS operations.
+const privateDNSAPIVersion = "2020-06-01"
+
+// PrivateDNSZone is a private DNS zone.
+type PrivateDNSZone struct {
+	ID       *string            `json:"id,omitempty"`
+	Name     *string            `json:"name,omitempty"`
+	Location *string            `json:"location,omitempty"`
+	Tags     map[string]*string `json:"tags,omitempty"`
+}
+
+// PrivateDNSRecordSet is a record set of a private DNS zone.
+type PrivateDNSRecordSet struct {
+	ID   *string `json:"id,omitempty"`
+	Name *string `json:"name,omitempty"`
+	// Type is the resource type of the record set, like
+	// Microsoft.Network/privateDnsZones/A.
+	Type *string `json:"type,omitempty"`
+}
+
+// PrivateDNSVirtualNetworkLink links a private DNS zone to a virtual network.
+type PrivateDNSVirtualNetworkLink struct {
+	ID         *string                                 `json:"id,omitempty"`
+	Name       *string                                 `json:"name,omitempty"`
+	Tags       map[string]*string                      `json:"tags,omitempty"`
+	Properties *PrivateDNSVirtualNetworkLinkProperties `json:"properties,omitempty"`
+}
+
+// PrivateDNSVirtualNetworkLinkProperties are the properties of a virtual network link.
+type PrivateDNSVirtualNetworkLinkProperties struct {
+	VirtualNetwork *PrivateDNSSubResource `json:"virtualNetwork,omitempty"`
+}
+
+// PrivateDNSSubResource is a reference to another resource.
+type PrivateDNSSubResource struct {
+	ID *string `json:"id,omitempty"`
+}
+
+// PrivateDNSZonesClient is a client for managing private DNS zones, with their
+// record sets and virtual network links.
+type PrivateDNSZonesClient interface {
+	List(ctx context.Context, resourceGroupName string) ([]*PrivateDNSZone, error)
+	Delete(ctx context.Context, resourceGroupName, zoneName string) error
+	ListRecordSets(ctx context.Context, resourceGroupName, zoneName string) ([]*PrivateDNSRecordSet, error)
+	DeleteRecordSet(ctx context.Context, resourceGroupName, zoneName, recordType, recordSetName string) error
+	ListVirtualNetworkLinks(ctx context.Context, resourceGroupName, zoneName string) ([]*PrivateDNSVirtualNetworkLink, error)
+	DeleteVirtualNetworkLink(ctx context.Context, resourceGroupName, zoneName, linkName string) error
+}
+
+// privateDNSZonesClientImpl calls the private DNS API directly, as the Azure
+// SDK for it is not a dependency of kops.
+type privateDNSZonesClientImpl struct {
+	subscriptionID string
+	c              *arm.Client
+}
+
+var _ PrivateDNSZonesClient = &privateDNSZonesClientImpl{}
+
+func (c *privateDNSZonesClientImpl) resourceGroupPath(resourceGroupName string) string {
+	return runtime.JoinPaths(c.c.Endpoint(), "subscriptions", c.subscriptionID, "resourceGroups", resourceGroupName, "providers/Microsoft.Network/privateDnsZones")
+}
+
+func (c *privateDNSZonesClientImpl) List(ctx context.Context, resourceGroupName string) ([]*PrivateDNSZone, error) {
+	return listPrivateDNS[PrivateDNSZone](ctx, c.c, c.resourceGroupPath(resourceGroupName), "private DNS zones")
+}
+
+func (c *privateDNSZonesClientImpl) Delete(ctx context.Context, resourceGroupName, zoneName string) error {
+	return deletePrivateDNS(ctx, c.c, runtime.JoinPaths(c.resourceGroupPath(resourceGroupName), zoneName), "private DNS zone")
+}
+
+func (c *privateDNSZonesClientImpl) ListRecordSets(ctx context.Context, resourceGroupName, zoneName string) ([]*PrivateDNSRecordSet, error) {
+	return listPrivateDNS[PrivateDNSRecordSet](ctx, c.c, runtime.JoinPaths(c.resourceGroupPath(resourceGroupName), zoneName, "ALL"), "private DNS record sets")
+}
+
+func (c *privateDNSZonesClientImpl) DeleteRecordSet(ctx context.Context, resourceGroupName, zoneName, recordType, recordSetName string) error {
+	return deletePrivateDNS(ctx, c.c, runtime.JoinPaths(c.resourceGroupPath(resourceGroupName), zoneName, recordType, recordSetName), "private DNS record set")
+}
+
+func (c *privateDNSZonesClientImpl) ListVirtualNetworkLinks(ctx context.Context, resourceGroupName, zoneName string) ([]*PrivateDNSVirtualNetworkLink, error) {
+	return listPrivateDNS[PrivateDNSVirtualNetworkLink](ctx, c.c, runtime.JoinPaths(c.resourceGroupPath(resourceGroupName), zoneName, "virtualNetworkLinks"), "private DNS virtual network links")
+}
+
+func (c *privateDNSZonesClientImpl) DeleteVirtualNetworkLink(ctx context.Context, resourceGroupName, zoneName, linkName string) error {
+	return deletePrivateDNS(ctx, c.c, runtime.JoinPaths(c.resourceGroupPath(resourceGroupName), zoneName, "virtualNetworkLinks", linkName), "private DNS virtual network link")
+}
+
+// listPrivateDNS lists the private DNS resources at the path, following the
+// next links.
+func listPrivateDNS[T any](ctx context.Context, c *arm.Client, path, what string) ([]*T, error) {
+	var l []*T
+	next := path + "?api-version=" + privateDNSAPIVersion
+	for next != "" {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, next)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", what, err)
+		}
+		req.Raw().Header["Accept"] = []string{"application/json"}
+		resp, err := c.Pipeline().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", what, err)
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK) {
+			return nil, fmt.Errorf("listing %s: %w", what, runtime.NewResponseError(resp))
+		}
+		var page struct {
+			Value    []*T    `json:"value"`
+			NextLink *string `json:"nextLink"`
+		}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return nil, fmt.Errorf("listing %s: %w", what, err)
+		}
+		l = append(l, page.Value...)
+		next = ""
+		if page.NextLink != nil {
+			next = *page.NextLink
+		}
+	}
+	return l, nil
+}
+
+// deletePrivateDNS deletes the private DNS resource at the path, waiting for the
+// deletion to complete.
+func deletePrivateDNS(ctx context.Context, c *arm.Client, path, what string) error {
+	req, err := runtime.NewRequest(ctx, http.MethodDelete, path+"?api-version="+privateDNSAPIVersion)
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", what, err)
+	}
+	req.Raw().Header["Accept"] = []string{"application/json"}
+	resp, err := c.Pipeline().Do(req)
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", what, err)
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK, http.StatusAccepted, http.StatusNoContent) {
+		return fmt.Errorf("deleting %s: %w", what, runtime.NewResponseError(resp))
+	}
+	poller, err := runtime.NewPoller[struct{}](resp, c.Pipeline(), nil)
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", what, err)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for %s deletion completion: %w", what, err)
+	}
+	return nil
+}
+
+func newPrivateDNSZonesClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*privateDNSZonesClientImpl, error) {
+	c, err := arm.NewClient("k8s.io/kops", "", cred, &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Telemetry: policy.TelemetryOptions{Disabled: true},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating private DNS zones client: %w", err)
+	}
+	return &privateDNSZonesClientImpl{
+		subscriptionID: subscriptionID,
+		c:              c,
+	}, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	network "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+)
+
+// PrivateEndpointsClient is a client for managing Private Endpoints.
+type PrivateEndpointsClient interface {
+	List(ctx context.Context, resourceGroupName string) ([]*network.PrivateEndpoint, error)
+	Delete(ctx context.Context, resourceGroupName, privateEndpointName string) error
+}
+
+type privateEndpointsClientImpl struct {
+	c *network.PrivateEndpointsClient
+}
+
+var _ PrivateEndpointsClient = &privateEndpointsClientImpl{}
+
+func (c *privateEndpointsClientImpl) List(ctx context.Context, resourceGroupName string) ([]*network.PrivateEndpoint, error) {
+	if resourceGroupName == "" {
+		return nil, nil
+	}
+
+	var l []*network.PrivateEndpoint
+	pager := c.c.NewListPager(resourceGroupName, nil)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			var respErr *azcore.ResponseError
+			if errors.As(err, &respErr) && respErr.ErrorCode == "ResourceGroupNotFound" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("listing private endpoints: %w", err)
+		}
+		l = append(l, resp.Value...)
+	}
+	return l, nil
+}
+
+func (c *privateEndpointsClientImpl) Delete(ctx context.Context, resourceGroupName, privateEndpointName string) error {
+	future, err := c.c.BeginDelete(ctx, resourceGroupName, privateEndpointName, nil)
+	if err != nil {
+		return fmt.Errorf("deleting private endpoint: %w", err)
+	}
+	if _, err := future.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for private endpoint deletion completion: %w", err)
+	}
+	return nil
+}
+
+func newPrivateEndpointsClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*privateEndpointsClientImpl, error) {
+	c, err := network.NewPrivateEndpointsClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating private endpoints client: %w", err)
+	}
+	return &privateEndpointsClientImpl{
+		c: c,
+	}, nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	resources "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+// ResourcesClient is a client for operations that apply to any kind of resource.
+type ResourcesClient interface {
+	ListByResourceGroup(ctx context.Context, resourceGroupName string) ([]*resources.GenericResourceExpanded, error)
+	DeleteByID(ctx context.Context, resourceID string, apiVersion string) error
+	MoveResources(ctx context.Context, sourceResourceGroupName string, resourceIDs []string, targetResourceGroupID string) error
+	// UpdateTags merges the tags into the existing tags of the resource.
+	UpdateTags(ctx context.Context, resourceID string, tags map[string]*string) error
+}
+
+type resourcesClientImpl struct {
+	c    *resources.Client
+	tags *resources.TagsClient
+}
+
+var _ ResourcesClient = &resourcesClientImpl{}
+
+func (c *resourcesClientImpl) ListByResourceGroup(ctx context.Context, resourceGroupName string) ([]*resources.GenericResourceExpanded, error) {
+	var l []*resources.GenericResourceExpanded
+	pager := c.c.NewListByResourceGroupPager(resourceGroupName, nil)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			var respErr *azcore.ResponseError
+			if errors.As(err, &respErr) && respErr.ErrorCode == "ResourceGroupNotFound" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("listing resources: %w", err)
+		}
+		l = append(l, resp.Value...)
+	}
+	return l, nil
+}
+
+func (c *resourcesClientImpl) DeleteByID(ctx context.Context, resourceID string, apiVersion string) error {
+	future, err := c.c.BeginDeleteByID(ctx, resourceID, apiVersion, nil)
+	if err != nil {
+		return fmt.Errorf("deleting resource: %w", err)
+	}
+	if _, err := future.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for resource deletion completion: %w", err)
+	}
+	return nil
+}
+
+func (c *resourcesClientImpl) MoveResources(ctx context.Context, sourceResourceGroupName string, resourceIDs []string, targetResourceGroupID string) error {
+	parameters := resources.MoveInfo{
+		TargetResourceGroup: &targetResourceGroupID,
+	}
+	for i := range resourceIDs {
+		parameters.Resources = append(parameters.Resources, &resourceIDs[i])
+	}
+	future, err := c.c.BeginMoveResources(ctx, sourceResourceGroupName, parameters, nil)
+	if err != nil {
+		return fmt.Errorf("moving resources: %w", err)
+	}
+	if _, err := future.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for resources move completion: %w", err)
+	}
+	return nil
+}
+
+func (c *resourcesClientImpl) UpdateTags(ctx context.Context, resourceID string, tags map[string]*string) error {
+	parameters := resources.TagsPatchResource{
+		Operation: to.Ptr(resources.TagsPatchOperationMerge),
+		Properties: &resources.Tags{
+			Tags: tags,
+		},
+	}
+	if _, err := c.tags.UpdateAtScope(ctx, resourceID, parameters, nil); err != nil {
+		return fmt.Errorf("updating resource tags: %w", err)
+	}
+	return nil
+}
+
+func newResourcesClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*resourcesClientImpl, error) {
+	c, err := resources.NewClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating resources client: %w", err)
+	}
+	tags, err := resources.NewTagsClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating tags client: %w", err)
+	}
+	return &resourcesClientImpl{
+		c:    c,
+		tags: tags,
+	}, nil
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	authz "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
 )
@@ -28,6 +29,8 @@ import (
 type RoleAssignmentsClient interface {
 	Create(ctx context.Context, scope, roleAssignmentName string, parameters authz.RoleAssignmentCreateParameters) (*authz.RoleAssignment, error)
 	List(ctx context.Context, scope string) ([]*authz.RoleAssignment, error)
+	// ListAssignedTo lists the role assignments of a principal at, above and below a scope.
+	ListAssignedTo(ctx context.Context, scope, principalID string) ([]*authz.RoleAssignment, error)
 	Delete(ctx context.Context, scope, raName string) error
 }
 
@@ -60,6 +63,22 @@ func (c *roleAssignmentsClientImpl) List(ctx context.Context, scope string) ([]*
 	return l, nil
 }
 
+func (c *roleAssignmentsClientImpl) ListAssignedTo(ctx context.Context, scope, principalID string) ([]*authz.RoleAssignment, error) {
+	var l []*authz.RoleAssignment
+	opts := &authz.RoleAssignmentsClientListForScopeOptions{
+		Filter: to.Ptr(fmt.Sprintf("assignedTo('%s')", principalID)),
+	}
+	pager := c.c.NewListForScopePager(scope, opts)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing role assignments of principal %s: %w", principalID, err)
+		}
+		l = append(l, resp.Value...)
+	}
+	return l, nil
+}
+
 func (c *roleAssignmentsClientImpl) Delete(ctx context.Context, scope, raName string) error {
 	_, err := c.c.Delete(ctx, scope, raName, nil)
 	if err != nil {
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	compute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
+)
+
+// SnapshotsClient is a client for managing disk snapshots.
+type SnapshotsClient interface {
+	List(ctx context.Context, resourceGroupName string) ([]*compute.Snapshot, error)
+	Delete(ctx context.Context, resourceGroupName, snapshotName string) error
+}
+
+type snapshotsClientImpl struct {
+	c *compute.SnapshotsClient
+}
+
+var _ SnapshotsClient = &snapshotsClientImpl{}
+
+func (c *snapshotsClientImpl) List(ctx context.Context, resourceGroupName string) ([]*compute.Snapshot, error) {
+	if resourceGroupName == "" {
+		return nil, nil
+	}
+
+	var l []*compute.Snapshot
+	pager := c.c.NewListByResourceGroupPager(resourceGroupName, nil)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			var respErr *azcore.ResponseError
+			if errors.As(err, &respErr) && respErr.ErrorCode == "ResourceGroupNotFound" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("listing snapshots: %w", err)
+		}
+		l = append(l, resp.Value...)
+	}
+	return l, nil
+}
+
+func (c *snapshotsClientImpl) Delete(ctx context.Context, resourceGroupName, snapshotName string) error {
+	future, err := c.c.BeginDelete(ctx, resourceGroupName, snapshotName, nil)
+	if err != nil {
+		return fmt.Errorf("deleting snapshot: %w", err)
+	}
+	if _, err := future.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("waiting for snapshot deletion completion: %w", err)
+	}
+	return nil
+}
+
+func newSnapshotsClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*snapshotsClientImpl, error) {
+	c, err := compute.NewSnapshotsClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating snapshots client: %w", err)
+	}
+	return &snapshotsClientImpl{
+		c: c,
+	}, nil
+}
@@ -67,6 +67,10 @@ func (c *mockVMScaleSetVMsClient) List(ctx context.Context, resourceGroupName, v
 	return c.vms, nil
 }
 
+func (c *mockVMScaleSetVMsClient) Update(ctx context.Context, resourceGroupName, vmssName, instanceID string, parameters compute.VirtualMachineScaleSetVM) (*compute.VirtualMachineScaleSetVM, error) {
+	return nil, fmt.Errorf("unimplemented")
+}
+
 func (c *mockVMScaleSetVMsClient) Delete(ctx context.Context, resourceGroupName, vmssName, instanceID string) error {
 	var l []*compute.VirtualMachineScaleSetVM
 	for _, vm := range c.vms {
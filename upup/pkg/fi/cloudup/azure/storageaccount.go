@@ -18,8 +18,10 @@ package azure
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 )
@@ -27,6 +29,8 @@ import (
 // StorageAccountsClient is a client for managing Network Interfaces.
 type StorageAccountsClient interface {
 	List(ctx context.Context) ([]*armstorage.Account, error)
+	ListByResourceGroup(ctx context.Context, resourceGroupName string) ([]*armstorage.Account, error)
+	Delete(ctx context.Context, resourceGroupName, accountName string) error
 }
 
 type storageAccountsClientImpl struct {
@@ -48,6 +52,34 @@ func (c *storageAccountsClientImpl) List(ctx context.Context) ([]*armstorage.Acc
 	return l, nil
 }
 
+func (c *storageAccountsClientImpl) ListByResourceGroup(ctx context.Context, resourceGroupName string) ([]*armstorage.Account, error) {
+	if resourceGroupName == "" {
+		return nil, nil
+	}
+
+	var l []*armstorage.Account
+	pager := c.c.NewListByResourceGroupPager(resourceGroupName, nil)
+	for pager.More() {
+		resp, err := pager.NextPage(ctx)
+		if err != nil {
+			var respErr *azcore.ResponseError
+			if errors.As(err, &respErr) && respErr.ErrorCode == "ResourceGroupNotFound" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("listing storage accounts: %w", err)
+		}
+		l = append(l, resp.Value...)
+	}
+	return l, nil
+}
+
+func (c *storageAccountsClientImpl) Delete(ctx context.Context, resourceGroupName, accountName string) error {
+	if _, err := c.c.Delete(ctx, resourceGroupName, accountName, nil); err != nil {
+		return fmt.Errorf("deleting storage account: %w", err)
+	}
+	return nil
+}
+
 func newStorageAccountsClientImpl(subscriptionID string, cred *azidentity.DefaultAzureCredential) (*storageAccountsClientImpl, error) {
 	c, err := armstorage.NewAccountsClient(subscriptionID, cred, nil)
 	if err != nil {
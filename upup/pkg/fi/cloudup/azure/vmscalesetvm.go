@@ -27,6 +27,7 @@ import (
 // VMScaleSetVMsClient is a client for managing VMs in VM Scale Sets.
 type VMScaleSetVMsClient interface {
 	List(ctx context.Context, resourceGroupName, vmssName string) ([]*compute.VirtualMachineScaleSetVM, error)
+	Update(ctx context.Context, resourceGroupName, vmssName, instanceId string, parameters compute.VirtualMachineScaleSetVM) (*compute.VirtualMachineScaleSetVM, error)
 	Delete(ctx context.Context, resourceGroupName, vmssName, instanceId string) error
 }
 
@@ -49,6 +50,18 @@ func (c *vmScaleSetVMsClientImpl) List(ctx context.Context, resourceGroupName, v
 	return l, nil
 }
 
+func (c *vmScaleSetVMsClientImpl) Update(ctx context.Context, resourceGroupName, vmssName, instanceId string, parameters compute.VirtualMachineScaleSetVM) (*compute.VirtualMachineScaleSetVM, error) {
+	future, err := c.c.BeginUpdate(ctx, resourceGroupName, vmssName, instanceId, parameters, nil)
+	if err != nil {
+		return nil, fmt.Errorf("updating VMSS VM: %w", err)
+	}
+	resp, err := future.PollUntilDone(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for VMSS VM update completion: %w", err)
+	}
+	return &resp.VirtualMachineScaleSetVM, nil
+}
+
 func (c *vmScaleSetVMsClientImpl) Delete(ctx context.Context, resourceGroupName, vmssName, instanceId string) error {
 	future, err := c.c.BeginDelete(ctx, resourceGroupName, vmssName, instanceId, nil)
 	if err != nil {
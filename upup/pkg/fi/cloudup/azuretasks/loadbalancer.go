@@ -219,7 +219,7 @@ func (*LoadBalancer) RenderAzure(t *azure.AzureAPITarget, a, e, changes *LoadBal
 			},
 		})
 		lb.Properties.LoadBalancingRules = append(lb.Properties.LoadBalancingRules, &network.LoadBalancingRule{
-			Name: to.Ptr("TCP-443"),
+			Name: to.Ptr(azure.LoadBalancingRuleKubeAPIServer),
 			Properties: &network.LoadBalancingRulePropertiesFormat{
 				Protocol:             to.Ptr(network.TransportProtocolTCP),
 				FrontendPort:         to.Ptr[int32](wellknownports.KubeAPIServer),
@@ -251,7 +251,7 @@ func (*LoadBalancer) RenderAzure(t *azure.AzureAPITarget, a, e, changes *LoadBal
 			},
 		})
 		lb.Properties.LoadBalancingRules = append(lb.Properties.LoadBalancingRules, &network.LoadBalancingRule{
-			Name: to.Ptr("TCP-3988"),
+			Name: to.Ptr(azure.LoadBalancingRuleKopsController),
 			Properties: &network.LoadBalancingRulePropertiesFormat{
 				Protocol:             to.Ptr(network.TransportProtocolTCP),
 				FrontendPort:         to.Ptr[int32](wellknownports.KopsControllerPort),
@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	authz "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v3"
@@ -51,6 +52,7 @@ type MockAzureCloud struct {
 	ApplicationSecurityGroupsClient *MockApplicationSecurityGroupsClient
 	VMScaleSetsClient               *MockVMScaleSetsClient
 	VMScaleSetVMsClient             *MockVMScaleSetVMsClient
+	VirtualMachinesClient           *MockVirtualMachinesClient
 	DisksClient                     *MockDisksClient
 	RoleAssignmentsClient           *MockRoleAssignmentsClient
 	NetworkInterfacesClient         *MockNetworkInterfacesClient
@@ -58,6 +60,15 @@ type MockAzureCloud struct {
 	PublicIPAddressesClient         *MockPublicIPAddressesClient
 	NatGatewaysClient               *MockNatGatewaysClient
 	StorageAccountsClient           *MockStorageAccountsClient
+	SnapshotsClient                 *MockSnapshotsClient
+	DiskEncryptionSetsClient        *MockDiskEncryptionSetsClient
+	ResourcesClient                 *MockResourcesClient
+	PrivateEndpointsClient          *MockPrivateEndpointsClient
+	BackupItemsClient               *MockBackupItemsClient
+	ManagedIdentitiesClient         *MockManagedIdentitiesClient
+	KeyVaultsClient                 *MockKeyVaultsClient
+	PrivateDNSZonesClient           *MockPrivateDNSZonesClient
+	ApplicationGatewaysClient       *MockApplicationGatewaysClient
 }
 
 var _ azure.AzureCloud = &MockAzureCloud{}
@@ -90,6 +101,9 @@ func NewMockAzureCloud(location string) *MockAzureCloud {
 		VMScaleSetVMsClient: &MockVMScaleSetVMsClient{
 			VMs: map[string]*compute.VirtualMachineScaleSetVM{},
 		},
+		VirtualMachinesClient: &MockVirtualMachinesClient{
+			VMs: map[string]*compute.VirtualMachine{},
+		},
 		DisksClient: &MockDisksClient{
 			Disks: map[string]*compute.Disk{},
 		},
@@ -111,6 +125,37 @@ func NewMockAzureCloud(location string) *MockAzureCloud {
 		StorageAccountsClient: &MockStorageAccountsClient{
 			SAs: map[string]*armstorage.Account{},
 		},
+		SnapshotsClient: &MockSnapshotsClient{
+			Snapshots: map[string]*compute.Snapshot{},
+		},
+		DiskEncryptionSetsClient: &MockDiskEncryptionSetsClient{
+			DESs: map[string]*compute.DiskEncryptionSet{},
+		},
+		ResourcesClient: &MockResourcesClient{
+			Resources:   map[string]*resources.GenericResourceExpanded{},
+			Moved:       map[string]string{},
+			UpdatedTags: map[string]map[string]*string{},
+		},
+		PrivateEndpointsClient: &MockPrivateEndpointsClient{
+			PEs: map[string]*network.PrivateEndpoint{},
+		},
+		BackupItemsClient: &MockBackupItemsClient{
+			Items: map[string][]*azure.BackupItem{},
+		},
+		ManagedIdentitiesClient: &MockManagedIdentitiesClient{
+			Identities: map[string]*azure.ManagedIdentity{},
+		},
+		KeyVaultsClient: &MockKeyVaultsClient{
+			Purged: map[string]string{},
+		},
+		PrivateDNSZonesClient: &MockPrivateDNSZonesClient{
+			Zones:      map[string]*azure.PrivateDNSZone{},
+			RecordSets: map[string][]*azure.PrivateDNSRecordSet{},
+			Links:      map[string][]*azure.PrivateDNSVirtualNetworkLink{},
+		},
+		ApplicationGatewaysClient: &MockApplicationGatewaysClient{
+			AGWs: map[string]*network.ApplicationGateway{},
+		},
 	}
 }
 
@@ -233,6 +278,11 @@ func (c *MockAzureCloud) VMScaleSetVM() azure.VMScaleSetVMsClient {
 	return c.VMScaleSetVMsClient
 }
 
+// VirtualMachine returns the virtual machine client.
+func (c *MockAzureCloud) VirtualMachine() azure.VirtualMachinesClient {
+	return c.VirtualMachinesClient
+}
+
 // Disk returns the disk client.
 func (c *MockAzureCloud) Disk() azure.DisksClient {
 	return c.DisksClient
@@ -263,6 +313,56 @@ func (c *MockAzureCloud) NatGateway() azure.NatGatewaysClient {
 	return c.NatGatewaysClient
 }
 
+// StorageAccount returns the storage account client.
+func (c *MockAzureCloud) StorageAccount() azure.StorageAccountsClient {
+	return c.StorageAccountsClient
+}
+
+// PrivateEndpoint returns the private endpoint client.
+func (c *MockAzureCloud) PrivateEndpoint() azure.PrivateEndpointsClient {
+	return c.PrivateEndpointsClient
+}
+
+// BackupItem returns the backup items client.
+func (c *MockAzureCloud) BackupItem() azure.BackupItemsClient {
+	return c.BackupItemsClient
+}
+
+// ManagedIdentity returns the managed identities client.
+func (c *MockAzureCloud) ManagedIdentity() azure.ManagedIdentitiesClient {
+	return c.ManagedIdentitiesClient
+}
+
+// KeyVault returns the key vaults client.
+func (c *MockAzureCloud) KeyVault() azure.KeyVaultsClient {
+	return c.KeyVaultsClient
+}
+
+// PrivateDNSZone returns the private DNS zones client.
+func (c *MockAzureCloud) PrivateDNSZone() azure.PrivateDNSZonesClient {
+	return c.PrivateDNSZonesClient
+}
+
+// ApplicationGateway returns the application gateways client.
+func (c *MockAzureCloud) ApplicationGateway() azure.ApplicationGatewaysClient {
+	return c.ApplicationGatewaysClient
+}
+
+// Snapshot returns the snapshot client.
+func (c *MockAzureCloud) Snapshot() azure.SnapshotsClient {
+	return c.SnapshotsClient
+}
+
+// DiskEncryptionSet returns the disk encryption set client.
+func (c *MockAzureCloud) DiskEncryptionSet() azure.DiskEncryptionSetsClient {
+	return c.DiskEncryptionSetsClient
+}
+
+// Resource returns the generic resources client.
+func (c *MockAzureCloud) Resource() azure.ResourcesClient {
+	return c.ResourcesClient
+}
+
 // MockResourceGroupsClient is a mock implementation of resource group client.
 type MockResourceGroupsClient struct {
 	RGs map[string]*resources.ResourceGroup
@@ -459,6 +559,7 @@ func (c *MockVMScaleSetsClient) Delete(ctx context.Context, resourceGroupName, v
 
 // MockVMScaleSetVMsClient is a mock implementation of VM Scale Set VM client.
 type MockVMScaleSetVMsClient struct {
+	// VMs is keyed by "<VM Scale Set name>/<instance ID>".
 	VMs map[string]*compute.VirtualMachineScaleSetVM
 }
 
@@ -466,14 +567,28 @@ var _ azure.VMScaleSetVMsClient = &MockVMScaleSetVMsClient{}
 
 // List returns a slice of VM Scale Set VMs.
 func (c *MockVMScaleSetVMsClient) List(ctx context.Context, resourceGroupName, vmssName string) ([]*compute.VirtualMachineScaleSetVM, error) {
-	// Ignore resourceGroupName and vmssName for simplicity.
+	// Ignore resourceGroupName for simplicity.
 	var l []*compute.VirtualMachineScaleSetVM
-	for _, vm := range c.VMs {
+	for k, vm := range c.VMs {
+		if !strings.HasPrefix(k, vmssName+"/") {
+			continue
+		}
 		l = append(l, vm)
 	}
 	return l, nil
 }
 
+// Update updates a VM Scale Set VM.
+func (c *MockVMScaleSetVMsClient) Update(ctx context.Context, resourceGroupName, vmssName, instanceID string, parameters compute.VirtualMachineScaleSetVM) (*compute.VirtualMachineScaleSetVM, error) {
+	// Ignore resourceGroupName for simplicity.
+	key := vmssName + "/" + instanceID
+	if _, ok := c.VMs[key]; !ok {
+		return nil, fmt.Errorf("VM Scale Set VM %s not found", key)
+	}
+	c.VMs[key] = &parameters
+	return &parameters, nil
+}
+
 // Delete deletes a VM Scale Set VMs.
 func (c *MockVMScaleSetVMsClient) Delete(ctx context.Context, resourceGroupName, vmssName, instanceID string) error {
 	// Ignore resourceGroupName and vmssName for simplicity.
@@ -481,6 +596,33 @@ func (c *MockVMScaleSetVMsClient) Delete(ctx context.Context, resourceGroupName,
 	return nil
 }
 
+// MockVirtualMachinesClient is a mock implementation of virtual machine client.
+type MockVirtualMachinesClient struct {
+	VMs map[string]*compute.VirtualMachine
+}
+
+var _ azure.VirtualMachinesClient = &MockVirtualMachinesClient{}
+
+// List returns a slice of virtual machines.
+func (c *MockVirtualMachinesClient) List(ctx context.Context, resourceGroupName string) ([]*compute.VirtualMachine, error) {
+	// Ignore resourceGroupName for simplicity.
+	var l []*compute.VirtualMachine
+	for _, vm := range c.VMs {
+		l = append(l, vm)
+	}
+	return l, nil
+}
+
+// Delete deletes a specified virtual machine.
+func (c *MockVirtualMachinesClient) Delete(ctx context.Context, resourceGroupName, vmName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.VMs[vmName]; !ok {
+		return fmt.Errorf("%s does not exist", vmName)
+	}
+	delete(c.VMs, vmName)
+	return nil
+}
+
 // MockDisksClient is a mock implementation of disk client.
 type MockDisksClient struct {
 	Disks map[string]*compute.Disk
@@ -509,6 +651,12 @@ func (c *MockDisksClient) List(ctx context.Context, resourceGroupName string) ([
 	return l, nil
 }
 
+// Get returns a specified disk, or nil if it does not exist.
+func (c *MockDisksClient) Get(ctx context.Context, resourceGroupName, diskName string) (*compute.Disk, error) {
+	// Ignore resourceGroupName for simplicity.
+	return c.Disks[diskName], nil
+}
+
 // Delete deletes a specified disk.
 func (c *MockDisksClient) Delete(ctx context.Context, resourceGroupName, diskName string) error {
 	// Ignore resourceGroupName for simplicity.
@@ -558,6 +706,18 @@ func (c *MockRoleAssignmentsClient) List(ctx context.Context, scope string) ([]*
 	return l, nil
 }
 
+// ListAssignedTo returns a slice of role assignments of the principal.
+func (c *MockRoleAssignmentsClient) ListAssignedTo(ctx context.Context, scope, principalID string) ([]*authz.RoleAssignment, error) {
+	// Ignore scope for simplicity.
+	var l []*authz.RoleAssignment
+	for _, ra := range c.RAs {
+		if ra.Properties != nil && fi.ValueOf(ra.Properties.PrincipalID) == principalID {
+			l = append(l, ra)
+		}
+	}
+	return l, nil
+}
+
 // Delete deletes a specified role assignment.
 func (c *MockRoleAssignmentsClient) Delete(ctx context.Context, scope, raName string) error {
 	// Ignore scope for simplicity.
@@ -585,6 +745,26 @@ func (c *MockNetworkInterfacesClient) ListScaleSetsNetworkInterfaces(ctx context
 	return l, nil
 }
 
+// List returns a slice of Network Interfaces.
+func (c *MockNetworkInterfacesClient) List(ctx context.Context, resourceGroupName string) ([]*network.Interface, error) {
+	// Ignore resourceGroupName for simplicity.
+	var l []*network.Interface
+	for _, ni := range c.NIs {
+		l = append(l, ni)
+	}
+	return l, nil
+}
+
+// Delete deletes a specified Network Interface.
+func (c *MockNetworkInterfacesClient) Delete(ctx context.Context, resourceGroupName, networkInterfaceName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.NIs[networkInterfaceName]; !ok {
+		return fmt.Errorf("%s does not exist", networkInterfaceName)
+	}
+	delete(c.NIs, networkInterfaceName)
+	return nil
+}
+
 // MockLoadBalancersClient is a mock implementation of role assignment client.
 type MockLoadBalancersClient struct {
 	LBs map[string]*network.LoadBalancer
@@ -810,7 +990,7 @@ func (c *MockNatGatewaysClient) Delete(ctx context.Context, resourceGroupName, n
 	return nil
 }
 
-// MockStorageAccountsClient is a mock implementation of Nat Gateway client.
+// MockStorageAccountsClient is a mock implementation of Storage Account client.
 type MockStorageAccountsClient struct {
 	SAs map[string]*armstorage.Account
 }
@@ -825,3 +1005,309 @@ func (c *MockStorageAccountsClient) List(ctx context.Context) ([]*armstorage.Acc
 	}
 	return l, nil
 }
+
+// ListByResourceGroup returns a slice of Storage Accounts.
+func (c *MockStorageAccountsClient) ListByResourceGroup(ctx context.Context, resourceGroupName string) ([]*armstorage.Account, error) {
+	// Ignore resourceGroupName for simplicity.
+	return c.List(ctx)
+}
+
+// Delete deletes a specified Storage Account.
+func (c *MockStorageAccountsClient) Delete(ctx context.Context, resourceGroupName, accountName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.SAs[accountName]; !ok {
+		return fmt.Errorf("%s does not exist", accountName)
+	}
+	delete(c.SAs, accountName)
+	return nil
+}
+
+// MockSnapshotsClient is a mock implementation of snapshot client.
+type MockSnapshotsClient struct {
+	Snapshots map[string]*compute.Snapshot
+}
+
+var _ azure.SnapshotsClient = &MockSnapshotsClient{}
+
+// List returns a slice of snapshots.
+func (c *MockSnapshotsClient) List(ctx context.Context, resourceGroupName string) ([]*compute.Snapshot, error) {
+	var l []*compute.Snapshot
+	for _, snapshot := range c.Snapshots {
+		l = append(l, snapshot)
+	}
+	return l, nil
+}
+
+// Delete deletes a specified snapshot.
+func (c *MockSnapshotsClient) Delete(ctx context.Context, resourceGroupName, snapshotName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.Snapshots[snapshotName]; !ok {
+		return fmt.Errorf("%s does not exist", snapshotName)
+	}
+	delete(c.Snapshots, snapshotName)
+	return nil
+}
+
+// MockDiskEncryptionSetsClient is a mock implementation of disk encryption set client.
+type MockDiskEncryptionSetsClient struct {
+	DESs map[string]*compute.DiskEncryptionSet
+}
+
+var _ azure.DiskEncryptionSetsClient = &MockDiskEncryptionSetsClient{}
+
+// List returns a slice of disk encryption sets.
+func (c *MockDiskEncryptionSetsClient) List(ctx context.Context, resourceGroupName string) ([]*compute.DiskEncryptionSet, error) {
+	var l []*compute.DiskEncryptionSet
+	for _, des := range c.DESs {
+		l = append(l, des)
+	}
+	return l, nil
+}
+
+// Delete deletes a specified disk encryption set.
+func (c *MockDiskEncryptionSetsClient) Delete(ctx context.Context, resourceGroupName, diskEncryptionSetName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.DESs[diskEncryptionSetName]; !ok {
+		return fmt.Errorf("%s does not exist", diskEncryptionSetName)
+	}
+	delete(c.DESs, diskEncryptionSetName)
+	return nil
+}
+
+// MockResourcesClient is a mock implementation of the generic resources client.
+type MockResourcesClient struct {
+	// Resources are the resources in the resource group, keyed by ID.
+	Resources map[string]*resources.GenericResourceExpanded
+	// Moved maps the IDs of moved resources to the ID of their target resource group.
+	Moved map[string]string
+	// UpdatedTags maps the IDs of resources to the tags merged into their tags.
+	UpdatedTags map[string]map[string]*string
+}
+
+var _ azure.ResourcesClient = &MockResourcesClient{}
+
+// ListByResourceGroup returns a slice of resources.
+func (c *MockResourcesClient) ListByResourceGroup(ctx context.Context, resourceGroupName string) ([]*resources.GenericResourceExpanded, error) {
+	var l []*resources.GenericResourceExpanded
+	for _, r := range c.Resources {
+		l = append(l, r)
+	}
+	return l, nil
+}
+
+// DeleteByID deletes the resource with the given ID.
+func (c *MockResourcesClient) DeleteByID(ctx context.Context, resourceID string, apiVersion string) error {
+	if _, ok := c.Resources[resourceID]; !ok {
+		return fmt.Errorf("%s does not exist", resourceID)
+	}
+	delete(c.Resources, resourceID)
+	return nil
+}
+
+// MoveResources moves resources to another resource group.
+func (c *MockResourcesClient) MoveResources(ctx context.Context, sourceResourceGroupName string, resourceIDs []string, targetResourceGroupID string) error {
+	for _, id := range resourceIDs {
+		c.Moved[id] = targetResourceGroupID
+	}
+	return nil
+}
+
+// UpdateTags records the tags merged into the tags of a resource.
+func (c *MockResourcesClient) UpdateTags(ctx context.Context, resourceID string, tags map[string]*string) error {
+	if c.UpdatedTags[resourceID] == nil {
+		c.UpdatedTags[resourceID] = make(map[string]*string)
+	}
+	for k, v := range tags {
+		c.UpdatedTags[resourceID][k] = v
+	}
+	return nil
+}
+
+// MockPrivateEndpointsClient is a mock implementation of Private Endpoint client.
+type MockPrivateEndpointsClient struct {
+	PEs map[string]*network.PrivateEndpoint
+}
+
+var _ azure.PrivateEndpointsClient = &MockPrivateEndpointsClient{}
+
+// List returns a slice of Private Endpoints.
+func (c *MockPrivateEndpointsClient) List(ctx context.Context, resourceGroupName string) ([]*network.PrivateEndpoint, error) {
+	var l []*network.PrivateEndpoint
+	for _, pe := range c.PEs {
+		l = append(l, pe)
+	}
+	return l, nil
+}
+
+// Delete deletes a specified Private Endpoint.
+func (c *MockPrivateEndpointsClient) Delete(ctx context.Context, resourceGroupName, peName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.PEs[peName]; !ok {
+		return fmt.Errorf("%s does not exist", peName)
+	}
+	delete(c.PEs, peName)
+	return nil
+}
+
+// MockBackupItemsClient is a mock implementation of backup items client.
+type MockBackupItemsClient struct {
+	// Items are the backup items, keyed by the ID of their vault.
+	Items map[string][]*azure.BackupItem
+}
+
+var _ azure.BackupItemsClient = &MockBackupItemsClient{}
+
+// List returns a slice of the backup items of a vault.
+func (c *MockBackupItemsClient) List(ctx context.Context, vaultID string) ([]*azure.BackupItem, error) {
+	return c.Items[vaultID], nil
+}
+
+// Delete deletes a specified backup item.
+func (c *MockBackupItemsClient) Delete(ctx context.Context, itemID string) error {
+	for vaultID, items := range c.Items {
+		for i, item := range items {
+			if *item.ID == itemID {
+				c.Items[vaultID] = append(items[:i:i], items[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s does not exist", itemID)
+}
+
+// MockManagedIdentitiesClient is a mock implementation of managed identities client.
+type MockManagedIdentitiesClient struct {
+	Identities map[string]*azure.ManagedIdentity
+}
+
+var _ azure.ManagedIdentitiesClient = &MockManagedIdentitiesClient{}
+
+// List returns a slice of managed identities.
+func (c *MockManagedIdentitiesClient) List(ctx context.Context, resourceGroupName string) ([]*azure.ManagedIdentity, error) {
+	var l []*azure.ManagedIdentity
+	for _, identity := range c.Identities {
+		l = append(l, identity)
+	}
+	return l, nil
+}
+
+// Delete deletes a specified managed identity.
+func (c *MockManagedIdentitiesClient) Delete(ctx context.Context, resourceGroupName, identityName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.Identities[identityName]; !ok {
+		return fmt.Errorf("%s does not exist", identityName)
+	}
+	delete(c.Identities, identityName)
+	return nil
+}
+
+// MockKeyVaultsClient is a mock implementation of key vaults client.
+type MockKeyVaultsClient struct {
+	// Purged holds the locations of the purged key vaults, by name.
+	Purged map[string]string
+}
+
+var _ azure.KeyVaultsClient = &MockKeyVaultsClient{}
+
+// PurgeDeleted purges a specified deleted key vault.
+func (c *MockKeyVaultsClient) PurgeDeleted(ctx context.Context, location, vaultName string) error {
+	if _, ok := c.Purged[vaultName]; ok {
+		return fmt.Errorf("%s does not exist", vaultName)
+	}
+	c.Purged[vaultName] = location
+	return nil
+}
+
+// MockPrivateDNSZonesClient is a mock implementation of private DNS zones client.
+type MockPrivateDNSZonesClient struct {
+	Zones map[string]*azure.PrivateDNSZone
+	// RecordSets are the record sets of each zone.
+	RecordSets map[string][]*azure.PrivateDNSRecordSet
+	// Links are the virtual network links of each zone.
+	Links map[string][]*azure.PrivateDNSVirtualNetworkLink
+}
+
+var _ azure.PrivateDNSZonesClient = &MockPrivateDNSZonesClient{}
+
+// List returns a slice of private DNS zones.
+func (c *MockPrivateDNSZonesClient) List(ctx context.Context, resourceGroupName string) ([]*azure.PrivateDNSZone, error) {
+	var l []*azure.PrivateDNSZone
+	for _, zone := range c.Zones {
+		l = append(l, zone)
+	}
+	return l, nil
+}
+
+// Delete deletes a specified private DNS zone, with its record sets.
+func (c *MockPrivateDNSZonesClient) Delete(ctx context.Context, resourceGroupName, zoneName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.Zones[zoneName]; !ok {
+		return fmt.Errorf("%s does not exist", zoneName)
+	}
+	if len(c.Links[zoneName]) > 0 {
+		return fmt.Errorf("%s has virtual network links", zoneName)
+	}
+	delete(c.Zones, zoneName)
+	delete(c.RecordSets, zoneName)
+	return nil
+}
+
+// ListRecordSets returns the record sets of a private DNS zone.
+func (c *MockPrivateDNSZonesClient) ListRecordSets(ctx context.Context, resourceGroupName, zoneName string) ([]*azure.PrivateDNSRecordSet, error) {
+	return c.RecordSets[zoneName], nil
+}
+
+// DeleteRecordSet deletes a specified record set of a private DNS zone.
+func (c *MockPrivateDNSZonesClient) DeleteRecordSet(ctx context.Context, resourceGroupName, zoneName, recordType, recordSetName string) error {
+	suffix := "/" + recordType
+	for i, rs := range c.RecordSets[zoneName] {
+		if *rs.Name == recordSetName && strings.HasSuffix(*rs.Type, suffix) {
+			c.RecordSets[zoneName] = append(c.RecordSets[zoneName][:i], c.RecordSets[zoneName][i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s record set %s does not exist in %s", recordType, recordSetName, zoneName)
+}
+
+// ListVirtualNetworkLinks returns the virtual network links of a private DNS zone.
+func (c *MockPrivateDNSZonesClient) ListVirtualNetworkLinks(ctx context.Context, resourceGroupName, zoneName string) ([]*azure.PrivateDNSVirtualNetworkLink, error) {
+	return c.Links[zoneName], nil
+}
+
+// DeleteVirtualNetworkLink deletes a specified virtual network link of a private DNS zone.
+func (c *MockPrivateDNSZonesClient) DeleteVirtualNetworkLink(ctx context.Context, resourceGroupName, zoneName, linkName string) error {
+	for i, link := range c.Links[zoneName] {
+		if *link.Name == linkName {
+			c.Links[zoneName] = append(c.Links[zoneName][:i], c.Links[zoneName][i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("virtual network link %s does not exist in %s", linkName, zoneName)
+}
+
+// MockApplicationGatewaysClient is a mock implementation of application gateways client.
+type MockApplicationGatewaysClient struct {
+	AGWs map[string]*network.ApplicationGateway
+}
+
+var _ azure.ApplicationGatewaysClient = &MockApplicationGatewaysClient{}
+
+// List returns a slice of application gateways.
+func (c *MockApplicationGatewaysClient) List(ctx context.Context, resourceGroupName string) ([]*network.ApplicationGateway, error) {
+	// Ignore resourceGroupName for simplicity.
+	var l []*network.ApplicationGateway
+	for _, agw := range c.AGWs {
+		l = append(l, agw)
+	}
+	return l, nil
+}
+
+// Delete deletes a specified application gateway.
+func (c *MockApplicationGatewaysClient) Delete(ctx context.Context, resourceGroupName, applicationGatewayName string) error {
+	// Ignore resourceGroupName for simplicity.
+	if _, ok := c.AGWs[applicationGatewayName]; !ok {
+		return fmt.Errorf("%s does not exist", applicationGatewayName)
+	}
+	delete(c.AGWs, applicationGatewayName)
+	return nil
+}
@@ -61,6 +61,21 @@ func TestLoadBalancerIDParse(t *testing.T) {
 	}
 }
 
+func TestApplicationGatewayIDParse(t *testing.T) {
+	applicationGatewayID := &azure.ApplicationGatewayID{
+		SubscriptionID:         "sid",
+		ResourceGroupName:      "rg",
+		ApplicationGatewayName: "agw",
+	}
+	actual, err := azure.ParseApplicationGatewayID(applicationGatewayID.String())
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(actual, applicationGatewayID) {
+		t.Errorf("expected %+v, but got %+v", applicationGatewayID, actual)
+	}
+}
+
 func newTestVMScaleSet() *VMScaleSet {
 	return &VMScaleSet{
 		Name:      to.Ptr("vmss"),
@@ -17,7 +17,10 @@ limitations under the License.
 package nodetasks
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"k8s.io/kops/upup/pkg/fi"
 )
@@ -96,3 +99,47 @@ func TestFileDependencies(t *testing.T) {
 		})
 	}
 }
+
+func TestFileUnchangedContentsNotRewritten(t *testing.T) {
+	const contents = "-----BEGIN CERTIFICATE-----\nunchanged\n-----END CERTIFICATE-----\n"
+
+	p := filepath.Join(t.TempDir(), "kops-controller.crt")
+	if err := os.WriteFile(p, []byte(contents), 0o644); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatalf("error setting file times: %v", err)
+	}
+
+	e := &File{
+		Path:     p,
+		Contents: fi.NewStringResource(contents),
+		Type:     FileType_File,
+		Mode:     fi.PtrTo("0644"),
+	}
+	a, err := e.Find(nil)
+	if err != nil {
+		t.Fatalf("error finding file: %v", err)
+	}
+	if a == nil {
+		t.Fatalf("expected file %q to be found", p)
+	}
+
+	changes := &File{}
+	fi.BuildChanges(a, e, changes)
+	if changes.Contents != nil {
+		t.Errorf("expected no content changes for unchanged file")
+	}
+
+	if err := e.RenderLocal(nil, a, e, changes); err != nil {
+		t.Fatalf("error rendering file: %v", err)
+	}
+	stat, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	if !stat.ModTime().Equal(mtime) {
+		t.Errorf("expected unchanged file not to be written, but modification time changed from %v to %v", mtime, stat.ModTime())
+	}
+}
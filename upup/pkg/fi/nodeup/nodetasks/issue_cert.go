@@ -19,34 +19,51 @@ package nodetasks
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/x509/pkix"
 	"fmt"
 	"hash/fnv"
 	"io"
 	"net"
+	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 	"k8s.io/kops/pkg/pki"
 	"k8s.io/kops/upup/pkg/fi"
+	"sigs.k8s.io/yaml"
 )
 
 // PKIXName is a simplified form of pkix.Name, for better golden test output
 type PKIXName struct {
 	fi.NodeupNotADependency
-	CommonName   string
-	Organization []string `json:",omitempty"`
+	CommonName         string
+	Organization       []string `json:",omitempty"`
+	OrganizationalUnit []string `json:",omitempty"`
 }
 
 func (n *PKIXName) toPKIXName() pkix.Name {
 	return pkix.Name{
-		CommonName:   n.CommonName,
-		Organization: n.Organization,
+		CommonName:         n.CommonName,
+		Organization:       n.Organization,
+		OrganizationalUnit: n.OrganizationalUnit,
 	}
 }
 
+// Algorithms of the keys issued with certificates.
+const (
+	KeyAlgorithmRSA   = "RSA"
+	KeyAlgorithmECDSA = "ECDSA"
+)
+
 type IssueCert struct {
 	Name string
 
@@ -56,12 +73,32 @@ type IssueCert struct {
 	Subject        PKIXName `json:"subject"`
 	AlternateNames []string `json:"alternateNames,omitempty"`
 
+	// Algorithm is the algorithm of the issued key: KeyAlgorithmRSA, the default,
+	// or KeyAlgorithmECDSA for a P-256 key.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Validity is the lifetime of the issued certificate. If unset, it is about 15 months,
+	// skewed by up to 30 days per node.
+	Validity time.Duration `json:"validity,omitempty"`
+
 	// IncludeRootCertificate will force the certificate data to include the full chain, not just the leaf
 	IncludeRootCertificate bool `json:"includeRootCertificate,omitempty"`
 
+	// ReusePath is the path, without the .crt and .key extensions, of a certificate and key issued
+	// by an earlier run. They are reused while still valid for the same signer, subject and alternate
+	// names, so that the files are not rewritten and their watchers are not restarted.
+	ReusePath string `json:"reusePath,omitempty"`
+
 	cert *fi.NodeupTaskDependentResource
 	key  *fi.NodeupTaskDependentResource
 	ca   *fi.NodeupTaskDependentResource
+
+	pkcs12         *fi.NodeupTaskDependentResource
+	pkcs12Password string
+
+	secretManifest          *fi.NodeupTaskDependentResource
+	secretManifestNamespace string
+	secretManifestName      string
 }
 
 var (
@@ -87,6 +124,27 @@ func (i *IssueCert) GetResources() (certResource, keyResource, caResource *fi.No
 	return i.cert, i.key, i.ca
 }
 
+// GetPKCS12Resource returns a resource holding the issued certificate and key
+// as a PKCS#12 bundle, with the key protected by password.
+func (i *IssueCert) GetPKCS12Resource(password string) *fi.NodeupTaskDependentResource {
+	if i.pkcs12 == nil {
+		i.pkcs12 = &fi.NodeupTaskDependentResource{Task: i}
+	}
+	i.pkcs12Password = password
+	return i.pkcs12
+}
+
+// GetSecretManifestResource returns a resource holding the manifest of a
+// Kubernetes TLS Secret with the issued certificate and key.
+func (i *IssueCert) GetSecretManifestResource(namespace, name string) *fi.NodeupTaskDependentResource {
+	if i.secretManifest == nil {
+		i.secretManifest = &fi.NodeupTaskDependentResource{Task: i}
+	}
+	i.secretManifestNamespace = namespace
+	i.secretManifestName = name
+	return i.secretManifest
+}
+
 func (i *IssueCert) AddFileTasks(c *fi.NodeupModelBuilderContext, dir string, name string, caName string, owner *string) error {
 	certResource, keyResource, caResource := i.GetResources()
 	c.EnsureTask(&File{
@@ -151,18 +209,46 @@ func (e *IssueCert) Run(c *fi.NodeupContext) error {
 		AlternateNames: e.AlternateNames,
 		Validity:       time.Hour * time.Duration(validHours),
 	}
+	if e.Validity != 0 {
+		req.Validity = e.Validity
+	}
 
 	keystore, err := newStaticKeystore(ctx, e.Signer, e.KeypairID, c.T.Keystore)
 	if err != nil {
 		return err
 	}
 
+	if e.ReusePath != "" && keystore != nil {
+		caCertificate, _, err := keystore.FindPrimaryKeypair(ctx, e.Signer)
+		if err != nil {
+			return err
+		}
+		if certificate, privateKey := e.findReusableCert(req, caCertificate); certificate != nil {
+			klog.Infof("reusing certificate for %q", e.Name)
+			return e.setResources(certificate, privateKey, caCertificate)
+		}
+	}
+
+	switch e.algorithm() {
+	case KeyAlgorithmRSA:
+	case KeyAlgorithmECDSA:
+		if req.PrivateKey, err = pki.GenerateECDSAPrivateKey(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown key algorithm %q for %q", e.Algorithm, e.Name)
+	}
+
 	klog.Infof("signing certificate for %q", e.Name)
 	certificate, privateKey, caCertificate, err := pki.IssueCert(ctx, req, keystore)
 	if err != nil {
 		return err
 	}
 
+	return e.setResources(certificate, privateKey, caCertificate)
+}
+
+func (e *IssueCert) setResources(certificate *pki.Certificate, privateKey *pki.PrivateKey, caCertificate *pki.Certificate) error {
 	certResource, keyResource, caResource := e.GetResources()
 	certResource.Resource = &asBytesResource{certificate}
 	keyResource.Resource = &asBytesResource{privateKey}
@@ -180,9 +266,167 @@ func (e *IssueCert) Run(c *fi.NodeupContext) error {
 		certResource.Resource = fi.NewBytesResource(b.Bytes())
 	}
 
+	if e.pkcs12 != nil {
+		data, err := pki.EncodePKCS12(certificate, privateKey, e.pkcs12Password)
+		if err != nil {
+			return fmt.Errorf("error encoding PKCS#12 bundle for %q: %v", e.Name, err)
+		}
+		e.pkcs12.Resource = fi.NewBytesResource(data)
+	}
+
+	if e.secretManifest != nil {
+		certData, err := fi.ResourceAsBytes(certResource.Resource)
+		if err != nil {
+			return err
+		}
+		keyData, err := fi.ResourceAsBytes(keyResource.Resource)
+		if err != nil {
+			return err
+		}
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Secret",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: e.secretManifestNamespace,
+				Name:      e.secretManifestName,
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       certData,
+				corev1.TLSPrivateKeyKey: keyData,
+			},
+		}
+		data, err := yaml.Marshal(secret)
+		if err != nil {
+			return fmt.Errorf("error encoding secret manifest for %q: %v", e.Name, err)
+		}
+		e.secretManifest.Resource = fi.NewBytesResource(data)
+	}
+
 	return nil
 }
 
+// reuseCertMinValidity is how long a certificate must remain valid to be reused,
+// or half its validity if that is shorter.
+const reuseCertMinValidity = 60 * 24 * time.Hour
+
+// findReusableCert returns the certificate and key at ReusePath if they are still
+// valid for req and signed by caCertificate, or nil if a new one should be issued.
+func (e *IssueCert) findReusableCert(req *pki.IssueCertRequest, caCertificate *pki.Certificate) (*pki.Certificate, *pki.PrivateKey) {
+	certData, err := os.ReadFile(e.ReusePath + ".crt")
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.Warningf("error reading existing certificate for %q: %v", e.Name, err)
+		}
+		return nil, nil
+	}
+	keyData, err := os.ReadFile(e.ReusePath + ".key")
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.Warningf("error reading existing key for %q: %v", e.Name, err)
+		}
+		return nil, nil
+	}
+	certificate, err := pki.ParsePEMCertificate(certData)
+	if err != nil {
+		klog.Warningf("error parsing existing certificate for %q: %v", e.Name, err)
+		return nil, nil
+	}
+	privateKey, err := pki.ParsePEMPrivateKey(keyData)
+	if err != nil || privateKey == nil {
+		klog.Warningf("error parsing existing key for %q: %v", e.Name, err)
+		return nil, nil
+	}
+
+	reason := certMismatch(certificate, privateKey, req, caCertificate)
+	if reason == "" && keyAlgorithm(privateKey) != e.algorithm() {
+		reason = "the key algorithm changed"
+	}
+	if reason == "" && e.Validity != 0 && time.Until(certificate.Certificate.NotAfter) > e.Validity {
+		reason = "the validity was shortened"
+	}
+	if reason != "" {
+		klog.Infof("not reusing certificate for %q: %s", e.Name, reason)
+		return nil, nil
+	}
+	return certificate, privateKey
+}
+
+// algorithm returns the algorithm of the key to issue.
+func (e *IssueCert) algorithm() string {
+	if e.Algorithm == "" {
+		return KeyAlgorithmRSA
+	}
+	return e.Algorithm
+}
+
+// keyAlgorithm returns the algorithm of the key, as the Algorithm of an
+// IssueCert would name it.
+func keyAlgorithm(privateKey *pki.PrivateKey) string {
+	switch privateKey.Key.(type) {
+	case *rsa.PrivateKey:
+		return KeyAlgorithmRSA
+	case *ecdsa.PrivateKey:
+		return KeyAlgorithmECDSA
+	default:
+		return fmt.Sprintf("%T", privateKey.Key)
+	}
+}
+
+// certMismatch returns why certificate cannot be reused for req, or "" if it can.
+func certMismatch(certificate *pki.Certificate, privateKey *pki.PrivateKey, req *pki.IssueCertRequest, caCertificate *pki.Certificate) string {
+	cert := certificate.Certificate
+	if time.Until(cert.NotAfter) < min(reuseCertMinValidity, req.Validity/2) {
+		return "it expires soon"
+	}
+	if caCertificate == nil || cert.CheckSignatureFrom(caCertificate.Certificate) != nil {
+		return "it was not signed by the current signer"
+	}
+	if publicKey, ok := privateKey.Key.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !publicKey.Equal(cert.PublicKey) {
+		return "the key does not match"
+	}
+	if cert.Subject.CommonName != req.Subject.CommonName || !slices.Equal(cert.Subject.Organization, req.Subject.Organization) || !slices.Equal(cert.Subject.OrganizationalUnit, req.Subject.OrganizationalUnit) {
+		return "the subject changed"
+	}
+
+	var dnsNames, ipAddresses, uris []string
+	for _, san := range req.AlternateNames {
+		san = strings.TrimSpace(san)
+		if san == "" {
+			continue
+		}
+		if ip := net.ParseIP(san); ip != nil {
+			ipAddresses = append(ipAddresses, ip.String())
+		} else if pki.IsURIAlternateName(san) {
+			uris = append(uris, san)
+		} else {
+			dnsNames = append(dnsNames, san)
+		}
+	}
+	var certIPAddresses []string
+	for _, ip := range cert.IPAddresses {
+		certIPAddresses = append(certIPAddresses, ip.String())
+	}
+	var certURIs []string
+	for _, uri := range cert.URIs {
+		certURIs = append(certURIs, uri.String())
+	}
+	if !sameElements(cert.DNSNames, dnsNames) || !sameElements(certIPAddresses, ipAddresses) || !sameElements(certURIs, uris) {
+		return "the alternate names changed"
+	}
+	return ""
+}
+
+func sameElements(a, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	sort.Strings(a)
+	sort.Strings(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
 type hasAsBytes interface {
 	AsBytes() ([]byte, error)
 }
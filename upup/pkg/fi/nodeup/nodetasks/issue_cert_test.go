@@ -17,9 +17,15 @@ limitations under the License.
 package nodetasks
 
 import (
+	"context"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/kops/pkg/pki"
 	"k8s.io/kops/upup/pkg/fi"
 )
 
@@ -52,3 +58,116 @@ func TestIssueCertFileDependencies(t *testing.T) {
 		assert.ElementsMatch(t, []string{"testCert", "/tmp"}, taskNames)
 	}
 }
+
+// fakeKeystoreReader is a fi.KeystoreReader holding a single keyset.
+type fakeKeystoreReader struct {
+	name   string
+	keyset *fi.Keyset
+}
+
+func (k *fakeKeystoreReader) FindKeyset(ctx context.Context, name string) (*fi.Keyset, error) {
+	if name != k.name {
+		return nil, nil
+	}
+	return k.keyset, nil
+}
+
+func newFakeCA(t *testing.T, name string) *fi.Keyset {
+	certificate, privateKey, _, err := pki.IssueCert(context.Background(), &pki.IssueCertRequest{
+		Type:    "ca",
+		Subject: pkix.Name{CommonName: name},
+	}, nil)
+	if err != nil {
+		t.Fatalf("error issuing CA: %v", err)
+	}
+	item := &fi.KeysetItem{Id: "1", Certificate: certificate, PrivateKey: privateKey}
+	return &fi.Keyset{
+		Items:   map[string]*fi.KeysetItem{"1": item},
+		Primary: item,
+	}
+}
+
+func TestIssueCertReuse(t *testing.T) {
+	keystore := &fakeKeystoreReader{name: "ca", keyset: newFakeCA(t, "ca")}
+	dir := t.TempDir()
+	reusePath := filepath.Join(dir, "server")
+
+	// run issues the certificate and writes it to disk, as the File tasks would.
+	run := func(issue *IssueCert) (cert, key []byte) {
+		c, err := fi.NewNodeupContext(context.Background(), nil, keystore, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("error building context: %v", err)
+		}
+		if err := issue.Run(c); err != nil {
+			t.Fatalf("error issuing certificate: %v", err)
+		}
+		certResource, keyResource, _ := issue.GetResources()
+		if cert, err = fi.ResourceAsBytes(certResource); err != nil {
+			t.Fatalf("error reading certificate: %v", err)
+		}
+		if key, err = fi.ResourceAsBytes(keyResource); err != nil {
+			t.Fatalf("error reading key: %v", err)
+		}
+		if err := os.WriteFile(reusePath+".crt", cert, 0o644); err != nil {
+			t.Fatalf("error writing certificate: %v", err)
+		}
+		if err := os.WriteFile(reusePath+".key", key, 0o600); err != nil {
+			t.Fatalf("error writing key: %v", err)
+		}
+		return cert, key
+	}
+	newIssueCert := func(alternateNames ...string) *IssueCert {
+		return &IssueCert{
+			Name:           "server",
+			Signer:         "ca",
+			KeypairID:      "1",
+			Type:           "server",
+			Subject:        PKIXName{CommonName: "server"},
+			AlternateNames: alternateNames,
+			ReusePath:      reusePath,
+		}
+	}
+
+	cert, key := run(newIssueCert("server.internal", "10.0.0.1"))
+
+	// The same request reuses the certificate and key.
+	reusedCert, reusedKey := run(newIssueCert("10.0.0.1", "server.internal"))
+	assert.Equal(t, string(cert), string(reusedCert), "certificate was not reused")
+	assert.Equal(t, string(key), string(reusedKey), "key was not reused")
+
+	// Changing the alternate names issues a new certificate.
+	newCert, _ := run(newIssueCert("server.internal", "10.0.0.2"))
+	assert.NotEqual(t, string(cert), string(newCert), "certificate was reused for different alternate names")
+
+	// Rotating the signer issues a new certificate.
+	keystore.keyset = newFakeCA(t, "ca")
+	rotatedCert, _ := run(newIssueCert("server.internal", "10.0.0.2"))
+	assert.NotEqual(t, string(newCert), string(rotatedCert), "certificate was reused after the signer changed")
+
+	// Changing the organizational unit issues a new certificate and key.
+	issue := newIssueCert("server.internal", "10.0.0.2")
+	issue.Subject.OrganizationalUnit = []string{"generation-1"}
+	regeneratedCert, regeneratedKey := run(issue)
+	assert.NotEqual(t, string(rotatedCert), string(regeneratedCert), "certificate was reused for a different subject")
+	assert.NotEqual(t, string(key), string(regeneratedKey), "key was reused for a different subject")
+
+	// Changing the key algorithm issues a new key.
+	issue.Algorithm = KeyAlgorithmECDSA
+	_, ecdsaKey := run(issue)
+	assert.NotEqual(t, string(regeneratedKey), string(ecdsaKey), "key was reused for a different algorithm")
+	assert.Contains(t, string(ecdsaKey), "EC PRIVATE KEY")
+	_, reusedECDSAKey := run(issue)
+	assert.Equal(t, string(ecdsaKey), string(reusedECDSAKey), "ECDSA key was not reused")
+
+	// Shortening the validity issues a new certificate, which is then reused.
+	issue.Validity = 24 * time.Hour
+	shortCert, _ := run(issue)
+	assert.NotEqual(t, string(rotatedCert), string(shortCert), "certificate was reused after the validity was shortened")
+	parsed, err := pki.ParsePEMCertificate(shortCert)
+	if err != nil {
+		t.Fatalf("error parsing certificate: %v", err)
+	}
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), parsed.Certificate.NotAfter, time.Minute)
+	reusedShortCert, _ := run(issue)
+	assert.Equal(t, string(shortCert), string(reusedShortCert), "short-lived certificate was not reused")
+}